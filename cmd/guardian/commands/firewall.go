@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sr-tamim/guardian/internal/daemon"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/platform"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// NewFirewallCmd creates the firewall command
+func NewFirewallCmd(configLoader func() (*models.Config, error), devMode *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firewall",
+		Short: "Inspect and repair Guardian firewall rules",
+		Long:  `Inspect and repair the firewall rules Guardian manages.`,
+	}
+
+	var dryRun bool
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare stored blocks with the actual firewall rules",
+		Long: `Compare stored block records, provider state and the Guardian rules actually present
+in the firewall. Missing rules are recreated, orphaned and expired rules are removed.
+Use --dry-run to only report the drift. Repairs are refused while the daemon runs,
+since it reconciles on its own schedule and would overwrite changes made from here.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := configLoader()
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			// With memory storage this process knows no blocks, so every rule would look orphaned
+			storageType := strings.ToLower(config.Storage.Type)
+			if !dryRun && (storageType == "" || storageType == "memory") {
+				return fmt.Errorf("reconcile needs persistent storage (storage.type json); use --dry-run to only report")
+			}
+
+			// The daemon holds the same records and batch rules in memory; its next flush or rule
+			// rewrite would undo repairs made from here, so only a report is allowed while it runs
+			if pid, running := daemon.NewPIDManager().GetRunningPID(); running && !dryRun {
+				return fmt.Errorf("the Guardian daemon is running (PID %d) and reconciles every blocking.reconcile_interval; "+
+					"stop it with 'guardian stop' before repairing, or use --dry-run to only report", pid)
+			}
+
+			factory := platform.NewFactory()
+			provider, err := factory.CreateProvider(*devMode, config)
+			if err != nil {
+				return fmt.Errorf("failed to create platform provider: %w", err)
+			}
+			defer daemon.CloseStorage(provider)
+
+			reconcilable, ok := provider.(firewall.Reconcilable)
+			if !ok {
+				return fmt.Errorf("%s does not support firewall reconciliation", provider.Name())
+			}
+
+			report, err := reconcilable.Reconcile(dryRun)
+			if err != nil {
+				return fmt.Errorf("reconciliation failed: %w", err)
+			}
+
+			printReconcileReport(report)
+			return nil
+		},
+	}
+	reconcileCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report drift without changing rules or records")
+
+	cmd.AddCommand(reconcileCmd)
+	return cmd
+}
+
+// printReconcileReport prints a reconciliation report
+func printReconcileReport(report *firewall.ReconcileReport) {
+	fmt.Println("🔥 Firewall Reconciliation")
+	fmt.Println("════════════════════════════════")
+	fmt.Printf("📦 Stored blocks: %d\n", report.StoredBlocks)
+	fmt.Printf("🚫 Active blocks: %d\n", report.ActiveBlocks)
+	fmt.Printf("📜 Rule entries: %d\n", report.RuleEntries)
+
+	if len(report.Drifts) == 0 {
+		fmt.Println("✅ No drift found")
+		return
+	}
+
+	fmt.Printf("⚠️  Drift: %d\n", len(report.Drifts))
+	for _, drift := range report.Drifts {
+		status := "🔎"
+		switch {
+		case drift.Error != "":
+			status = "❌"
+		case drift.Fixed:
+			status = "✅"
+		}
+		fmt.Printf("  %s %-15s %-40s %s", status, drift.Kind, drift.Target, drift.Detail)
+		if drift.RuleName != "" {
+			fmt.Printf(" [rule: %s]", drift.RuleName)
+		}
+		if drift.Error != "" {
+			fmt.Printf(" (%s)", drift.Error)
+		}
+		fmt.Println()
+	}
+
+	if report.DryRun {
+		fmt.Println("💡 Dry run: nothing was changed. Run without --dry-run to repair.")
+	} else {
+		fmt.Printf("🔧 Repaired %d of %d\n", report.Fixed(), len(report.Drifts))
+	}
+}
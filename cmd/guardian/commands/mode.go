@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sr-tamim/guardian/internal/daemon"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// NewModeCmd creates the mode command
+func NewModeCmd(configLoader func() (*models.Config, error)) *cobra.Command {
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:       "mode [enforce|observe|paused]",
+		Short:     "Show or switch the protection mode",
+		Long:      "Show the protection mode, or switch every service to enforce, observe or paused until changed (or for --for). The running daemon picks up the change within a few seconds.",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{models.ModeEnforce, models.ModeObserve, models.ModePaused},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				config, err := configLoader()
+				if err != nil {
+					return fmt.Errorf("failed to load configuration: %w", err)
+				}
+				return printMode(config)
+			}
+			mode := strings.ToLower(args[0])
+			if !models.ValidMode(mode) {
+				return fmt.Errorf("unknown mode %q: use enforce, observe or paused", args[0])
+			}
+			return setMode(mode, duration)
+		},
+	}
+
+	cmd.Flags().DurationVar(&duration, "for", 0, "Return to the configured modes after this long (e.g. 30m)")
+	return cmd
+}
+
+// NewPauseCmd creates the pause command
+func NewPauseCmd() *cobra.Command {
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Stop blocking new addresses",
+		Long:  "Pause protection: attacks are still detected and logged, but no new addresses are blocked. Existing blocks stay in place and still expire.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setMode(models.ModePaused, duration)
+		},
+	}
+
+	cmd.Flags().DurationVar(&duration, "for", 0, "Resume automatically after this long (e.g. 30m)")
+	return cmd
+}
+
+// NewResumeCmd creates the resume command
+func NewResumeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume",
+		Short: "Return to the configured protection modes",
+		Long:  "Clear a mode set with 'guardian mode' or 'guardian pause', so the modes in the configuration file apply again.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := firewall.SaveModeOverride(firewall.ModePath(), nil); err != nil {
+				return fmt.Errorf("failed to clear protection mode: %w", err)
+			}
+			fmt.Println("▶️  Configured protection modes apply again")
+			warnIfDaemonStopped()
+			return nil
+		},
+	}
+}
+
+// setMode writes the runtime mode file the daemon watches
+func setMode(mode string, duration time.Duration) error {
+	if duration < 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+	override := &firewall.ModeOverride{Mode: mode, SetBy: "cli", SetAt: time.Now()}
+	if duration > 0 {
+		override.Until = override.SetAt.Add(duration)
+	}
+	if err := firewall.SaveModeOverride(firewall.ModePath(), override); err != nil {
+		return fmt.Errorf("failed to set protection mode: %w", err)
+	}
+	fmt.Printf("🛡️  Protection mode: %s\n", override)
+	warnIfDaemonStopped()
+	return nil
+}
+
+// printMode shows the global mode, any runtime override and per-service modes
+func printMode(config *models.Config) error {
+	override, err := firewall.LoadModeOverride(firewall.ModePath())
+	if err != nil {
+		return fmt.Errorf("failed to read protection mode: %w", err)
+	}
+	if override.Active(time.Now()) {
+		fmt.Printf("🛡️  Protection mode: %s\n", override)
+		fmt.Printf("   Configured mode: %s (applies after 'guardian resume')\n", config.ModeFor(""))
+		return nil
+	}
+	fmt.Printf("🛡️  Protection mode: %s (configured)\n", config.ModeFor(""))
+	for _, service := range config.Services {
+		if service.Mode != "" {
+			fmt.Printf("   %s: %s\n", service.Name, config.ModeFor(service.Name))
+		}
+	}
+	return nil
+}
+
+// warnIfDaemonStopped notes that a mode change waits for the daemon to start
+func warnIfDaemonStopped() {
+	if _, running := daemon.NewPIDManager().GetRunningPID(); !running {
+		fmt.Println("ℹ️  The daemon is not running; the mode applies when it starts")
+	}
+}
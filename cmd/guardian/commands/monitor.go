@@ -9,6 +9,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/sr-tamim/guardian/internal/daemon"
+	"github.com/sr-tamim/guardian/internal/monitor"
 	"github.com/sr-tamim/guardian/internal/platform"
 	"github.com/sr-tamim/guardian/pkg/logger"
 	"github.com/sr-tamim/guardian/pkg/models"
@@ -123,9 +124,23 @@ func NewMonitorCmd(configLoader func() (*models.Config, error), devMode *bool, c
 			sigChan := make(chan os.Signal, 1)
 			signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+			go daemon.PublishQueueStats(ctx)
+
+			// Runs after the pipeline has stopped and saved its checkpoints
+			defer daemon.CloseStorage(provider)
+
+			// Files, journal, containers and syslog are read by the log monitor; event logs by the provider
+			pipeline := daemon.StartLogPipeline(ctx, config, provider, *devMode)
+			if pipeline != nil {
+				defer pipeline.Stop()
+			}
+
 			// Start monitoring for enabled services
 			for _, service := range config.Services {
 				if service.Enabled {
+					if pipeline != nil && monitor.HandlesService(service) {
+						continue
+					}
 					logPaths, err := provider.GetLogPaths(service.Name)
 					if err != nil {
 						fmt.Printf("❌ Failed to get log paths for %s: %v\n", service.Name, err)
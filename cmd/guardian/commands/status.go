@@ -2,16 +2,24 @@ package commands
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sr-tamim/guardian/internal/autostart"
 	"github.com/sr-tamim/guardian/internal/daemon"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/internal/storage"
+	"github.com/sr-tamim/guardian/pkg/models"
 	"github.com/sr-tamim/guardian/pkg/version"
 )
 
 // NewStatusCmd creates the status command
-func NewStatusCmd(devMode *bool) *cobra.Command {
-	return &cobra.Command{
+func NewStatusCmd(configLoader func() (*models.Config, error), devMode *bool) *cobra.Command {
+	var verbose bool
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show Guardian status and statistics",
 		Long:  "Display current Guardian status, active blocks, and monitoring statistics.",
@@ -27,7 +35,8 @@ func NewStatusCmd(devMode *bool) *cobra.Command {
 
 			// Check daemon status
 			pidManager := daemon.NewPIDManager()
-			if pid, running := pidManager.GetRunningPID(); running {
+			pid, running := pidManager.GetRunningPID()
+			if running {
 				fmt.Printf("📊 Status: ✅ Running (PID: %d)\n", pid)
 				fmt.Println("� Monitoring: ✅ Active")
 			} else {
@@ -48,8 +57,92 @@ func NewStatusCmd(devMode *bool) *cobra.Command {
 				fmt.Println("🚀 Auto-startup: ❓ Unknown")
 			}
 
+			if override, err := firewall.LoadModeOverride(firewall.ModePath()); err == nil && override.Active(time.Now()) {
+				fmt.Printf("🛡️  Protection Mode: %s\n", override)
+			} else if config, err := configLoader(); err == nil {
+				fmt.Printf("🛡️  Protection Mode: %s\n", config.ModeFor(""))
+			}
 			fmt.Println("🚫 Active Blocks: 0")
+			if running {
+				printQueues()
+			}
+
+			if verbose {
+				config, err := configLoader()
+				if err != nil {
+					return fmt.Errorf("failed to load configuration: %w", err)
+				}
+				printCheckpoints(config)
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Also show log file read positions")
+	return cmd
+}
+
+// printQueues shows the stage queues the daemon last published, with what each had to drop
+func printQueues() {
+	stats, updated, err := queue.LoadSnapshot(queue.StatusPath())
+	if err != nil || len(stats) == 0 {
+		return
+	}
+
+	fmt.Printf("\n📦 Queues (as of %s)\n", updated.Local().Format(time.DateTime))
+	fmt.Println("════════════════════════════════")
+	for _, stat := range stats {
+		marker := "✅"
+		if stat.Dropped > 0 {
+			marker = "⚠️ "
+		}
+		fmt.Printf("   %s %s (%s): %d/%d queued, %d enqueued, %d dropped",
+			marker, stat.Name, stat.Policy, stat.Queued, stat.Capacity, stat.Enqueued, stat.Dropped)
+		if stat.Policy == queue.PolicySpill {
+			fmt.Printf(", %d on disk (%d spilled in total)", stat.Spilled, stat.SpilledTotal)
+		}
+		if !stat.LastDrop.IsZero() {
+			fmt.Printf(", last drop %s", stat.LastDrop.Local().Format(time.DateTime))
+		}
+		fmt.Println()
+	}
+}
+
+// printCheckpoints lists the log file positions saved by the daemon
+func printCheckpoints(config *models.Config) {
+	fmt.Println("\n📍 Log File Checkpoints")
+	fmt.Println("════════════════════════════════")
+
+	storageType := strings.ToLower(config.Storage.Type)
+	if storageType == "" || storageType == "memory" {
+		fmt.Println("   Checkpoints are kept in daemon memory; set storage.type to json to persist them")
+		return
+	}
+
+	store, err := storage.OpenReadOnly(storage.ResolvePath(config.Storage))
+	if err != nil {
+		fmt.Printf("   ❌ Cannot read storage: %v\n", err)
+		return
+	}
+	defer store.Close()
+
+	checkpoints, err := store.GetCheckpoints()
+	if err != nil {
+		fmt.Printf("   ❌ Cannot read checkpoints: %v\n", err)
+		return
+	}
+	if len(checkpoints) == 0 {
+		fmt.Println("   No log files have been read yet")
+		return
+	}
+	for _, checkpoint := range checkpoints {
+		fingerprint := checkpoint.Fingerprint
+		if len(fingerprint) > 12 {
+			fingerprint = fingerprint[:12]
+		}
+		fmt.Printf("   📝 %s\n", checkpoint.Path)
+		fmt.Printf("      offset %d, device %d, inode %d, fingerprint %s (%d bytes), saved %s\n",
+			checkpoint.Offset, checkpoint.Device, checkpoint.Inode, fingerprint, checkpoint.FingerprintSize,
+			checkpoint.UpdatedAt.Local().Format(time.DateTime))
+	}
 }
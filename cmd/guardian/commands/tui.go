@@ -36,7 +36,7 @@ func NewTUICmd(configLoader func() (*models.Config, error), devMode *bool) *cobr
 			fmt.Println("📊 Starting daemon status viewer...")
 
 			// Launch TUI dashboard directly (no system tray)
-			return tui.StartDashboard(provider, *devMode)
+			return tui.StartDashboard(provider, config, *devMode)
 		},
 	}
 }
@@ -61,11 +61,15 @@ func newRootCommand() *cobra.Command {
 	// Add subcommands
 	rootCmd.AddCommand(commands.NewMonitorCmd(getConfig, &devMode, &configFile))
 	rootCmd.AddCommand(commands.NewStopCmd(getConfig, &devMode))
-	rootCmd.AddCommand(commands.NewStatusCmd(&devMode))
+	rootCmd.AddCommand(commands.NewStatusCmd(getConfig, &devMode))
 	rootCmd.AddCommand(commands.NewVersionCmd())
 	rootCmd.AddCommand(commands.NewTUICmd(getConfig, &devMode))
 	rootCmd.AddCommand(commands.NewAutostartCmd(getConfig, &devMode))
 	rootCmd.AddCommand(commands.NewServiceCmd(getConfig, &devMode, &configFile))
+	rootCmd.AddCommand(commands.NewFirewallCmd(getConfig, &devMode))
+	rootCmd.AddCommand(commands.NewModeCmd(getConfig))
+	rootCmd.AddCommand(commands.NewPauseCmd())
+	rootCmd.AddCommand(commands.NewResumeCmd())
 
 	return rootCmd
 }
@@ -36,6 +36,8 @@ const (
 	ErrIPAlreadyBlocked  ErrorCode = "IP_ALREADY_BLOCKED"
 	ErrIPNotBlocked      ErrorCode = "IP_NOT_BLOCKED"
 	ErrInvalidIP         ErrorCode = "INVALID_IP"
+	ErrBlockLimitReached ErrorCode = "BLOCK_LIMIT_REACHED"
+	ErrProtectionPaused  ErrorCode = "PROTECTION_PAUSED"
 
 	// Storage errors
 	ErrStorageConnection ErrorCode = "STORAGE_CONNECTION"
@@ -57,6 +59,9 @@ const (
 	ErrServicePermission ErrorCode = "SERVICE_PERMISSION"
 	ErrServiceInstall    ErrorCode = "SERVICE_INSTALL"
 	ErrServiceUninstall  ErrorCode = "SERVICE_UNINSTALL"
+
+	// Notification errors
+	ErrNotificationFailed ErrorCode = "NOTIFICATION_FAILED"
 )
 
 // NewError creates a new GuardianError
@@ -68,8 +68,17 @@ type Storage interface {
 	SaveBlock(block *models.BlockRecord) error
 	GetBlock(ip string) (*models.BlockRecord, error)
 	GetActiveBlocks() ([]*models.BlockRecord, error)
+	GetBlocks(since time.Time) ([]*models.BlockRecord, error) // placed or lifted since
 	UpdateBlock(block *models.BlockRecord) error
 
+	// Detection alerts (password spraying, credential stuffing)
+	SaveAlert(alert *models.Alert) error
+	GetAlerts(limit int) ([]*models.Alert, error)
+
+	// Log file positions, replaced as a whole on each save
+	SaveCheckpoints(checkpoints []*models.TailCheckpoint) error
+	GetCheckpoints() ([]*models.TailCheckpoint, error)
+
 	// Statistics
 	GetStatistics() (*models.Statistics, error)
 
@@ -83,6 +92,7 @@ type LogEvent struct {
 	Source    string // log file path
 	Line      string
 	Service   string
+	Backfill  bool // read from history (rotated files) rather than as it was written
 }
 
 // ThreatAssessment represents the result of threat analysis
@@ -11,10 +11,15 @@ import (
 	"time"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/monitor"
+	"github.com/sr-tamim/guardian/internal/queue"
 	"github.com/sr-tamim/guardian/pkg/logger"
 	"github.com/sr-tamim/guardian/pkg/models"
 )
 
+// How often queue counters are published for `guardian status`
+const queueStatsInterval = 10 * time.Second
+
 // Manager handles daemon mode operations
 type Manager struct {
 	pidManager *PIDManager
@@ -279,14 +284,29 @@ func (dm *Manager) RunMonitorWithOptions(ctx context.Context, withTray bool) err
 					"uptime", time.Since(startTime).Truncate(time.Second),
 					"platform", dm.provider.Name(),
 					"services", dm.enabledServiceCount(),
+					"dropped", queue.Summary(),
 				)
 			}
 		}
 	}()
 
+	go PublishQueueStats(monitorCtx)
+
+	// Runs after the pipeline has stopped and saved its checkpoints
+	defer CloseStorage(dm.provider)
+
+	// Files, journal, containers and syslog are read by the log monitor; event logs by the provider
+	pipeline := StartLogPipeline(monitorCtx, dm.config, dm.provider, dm.devMode)
+	if pipeline != nil {
+		defer pipeline.Stop()
+	}
+
 	// Start monitoring for enabled services
 	for _, service := range dm.config.Services {
 		if service.Enabled {
+			if pipeline != nil && monitor.HandlesService(service) {
+				continue
+			}
 			logPaths, err := dm.provider.GetLogPaths(service.Name)
 			if err != nil {
 				fmt.Printf("❌ Failed to get log paths for %s: %v\n", service.Name, err)
@@ -330,6 +350,55 @@ func (dm *Manager) RunMonitorWithOptions(ctx context.Context, withTray bool) err
 	return nil
 }
 
+// StartLogPipeline starts the log monitor for the services it reads
+// Development mode keeps the provider's simulated events instead; nil means nothing was started
+func StartLogPipeline(ctx context.Context, config *models.Config, provider core.PlatformProvider, devMode bool) *monitor.Pipeline {
+	if devMode {
+		return nil
+	}
+	pipeline, err := monitor.NewPipeline(config, provider)
+	if err == nil {
+		err = pipeline.Start(ctx)
+		if err != nil {
+			pipeline.Stop()
+		}
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to start log monitor: %v\n", err)
+		logger.Error("Failed to start log monitor", "error", err)
+		return nil
+	}
+	return pipeline
+}
+
+// CloseStorage writes the provider's pending records before the process exits
+func CloseStorage(provider core.PlatformProvider) {
+	stored, ok := provider.(interface{ Storage() core.Storage })
+	if !ok {
+		return
+	}
+	if err := stored.Storage().Close(); err != nil {
+		logger.Warn("Failed to flush storage", "error", err)
+	}
+}
+
+// PublishQueueStats writes the queue counters for `guardian status` until ctx is done
+func PublishQueueStats(ctx context.Context) {
+	path := queue.StatusPath()
+	ticker := time.NewTicker(queueStatsInterval)
+	defer ticker.Stop()
+	for {
+		if err := queue.SaveSnapshot(path); err != nil {
+			logger.Debug("Failed to write queue status", "path", path, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (dm *Manager) enabledServiceCount() int {
 	count := 0
 	for _, service := range dm.config.Services {
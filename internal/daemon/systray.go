@@ -6,34 +6,108 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"fyne.io/systray"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/notify"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
 	"github.com/sr-tamim/guardian/pkg/version"
 )
 
+// Tray refresh and notification settings
+const (
+	trayRefreshInterval = 5 * time.Second
+	trayRecentBlocks    = 10               // unblock entries in the Recent Blocks menu
+	trayErrorHold       = 10 * time.Minute // the icon stays red this long after a firewall error
+	trayToastAddresses  = 5                // addresses named in a combined block notification
+	trayPauseDuration   = 30 * time.Minute // for "Pause for 30 Minutes"
+	toastTimeout        = 10 * time.Second
+)
+
+// trayState selects the tray icon colour
+type trayState int
+
+const (
+	trayProtecting trayState = iota
+	trayObserving
+	trayPaused
+	trayError
+)
+
+// trayProvider is what the tray reads from the daemon's provider
+type trayProvider interface {
+	FirewallManager() *firewall.Manager
+	Storage() core.Storage
+	Notifier() *notify.Dispatcher
+}
+
 // TrayManager handles system tray functionality for the daemon
+// It runs in the daemon process, so its counters and menus use the daemon's block manager and storage
 type TrayManager struct {
 	provider   core.PlatformProvider
 	devMode    bool
 	ctx        context.Context
 	cancel     context.CancelFunc
 	onShutdown func() // Callback for daemon shutdown
+
+	firewall *firewall.Manager // nil when the provider has no block manager
+	store    core.Storage
+	notifier *notify.Dispatcher
+
+	mu          sync.Mutex
+	blocked     []string  // addresses blocked since the last notification, up to trayToastAddresses
+	blockedMore int       // blocked beyond those
+	blockReason string    // reason of the first, shown when only one was blocked
+	lastToast   time.Time // of the last block notification
+	lastError   time.Time
+	recent      []string // addresses behind the unblock entries
+	state       trayState
+	mode        string // global mode at the last refresh
+	refreshNow  chan struct{}
+
+	mSummary  *systray.MenuItem
+	mMode     *systray.MenuItem
+	modeItems map[string]*systray.MenuItem
+	mRecent   *systray.MenuItem
+	slots     []*systray.MenuItem
+}
+
+// trayStatus is one reading of the daemon's live counters
+type trayStatus struct {
+	state        trayState
+	mode         string
+	override     *firewall.ModeOverride // runtime mode, nil when the configured modes apply
+	activeBlocks int
+	attacksToday int
+	recent       []*models.BlockRecord // newest first
 }
 
 // NewTrayManager creates a new system tray manager for the daemon
 func NewTrayManager(provider core.PlatformProvider, devMode bool, onShutdown func()) *TrayManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &TrayManager{
+	tm := &TrayManager{
 		provider:   provider,
 		devMode:    devMode,
 		ctx:        ctx,
 		cancel:     cancel,
 		onShutdown: onShutdown,
+		refreshNow: make(chan struct{}, 1),
+	}
+	if daemonProvider, ok := provider.(trayProvider); ok {
+		tm.firewall = daemonProvider.FirewallManager()
+		tm.store = daemonProvider.Storage()
+		tm.notifier = daemonProvider.Notifier()
 	}
+	return tm
 }
 
 // StartTray initializes and runs the system tray
@@ -47,10 +121,36 @@ func (tm *TrayManager) onTrayReady() {
 	tm.initializeTrayDisplay()
 
 	// Create menu items
+	tm.mSummary = systray.AddMenuItem("Guardian", "Live protection status")
+	tm.mSummary.Disable()
+	systray.AddSeparator()
+
 	mStatus := systray.AddMenuItem("Show Status", "View Guardian daemon status")
 	mShowDashboard := systray.AddMenuItem("Open Dashboard", "Launch Guardian TUI Dashboard")
 	systray.AddSeparator()
 
+	tm.mRecent = systray.AddMenuItem("Recent Blocks", "Unblock a recently blocked address")
+	for i := 0; i < trayRecentBlocks; i++ {
+		slot := tm.mRecent.AddSubMenuItem("", "Remove this block")
+		slot.Hide()
+		tm.slots = append(tm.slots, slot)
+		go tm.handleUnblock(i, slot)
+	}
+	tm.mMode = systray.AddMenuItem("Protection Mode", "Switch between enforcing, observing and paused")
+	tm.modeItems = make(map[string]*systray.MenuItem)
+	tm.modeItems[models.ModeEnforce] = tm.mMode.AddSubMenuItemCheckbox("Enforce", "Block attacking addresses", false)
+	tm.modeItems[models.ModeObserve] = tm.mMode.AddSubMenuItemCheckbox("Observe Only", "Record what would be blocked without changing the firewall", false)
+	tm.modeItems[models.ModePaused] = tm.mMode.AddSubMenuItemCheckbox("Paused", "Stop blocking new addresses; existing blocks stay", false)
+	mPauseFor := tm.mMode.AddSubMenuItem("Pause for 30 Minutes", "Pause, then return to the configured modes")
+	mConfigured := tm.mMode.AddSubMenuItem("Use Configured Modes", "Clear the mode set at runtime")
+	if tm.firewall == nil {
+		tm.mRecent.Disable()
+		tm.mMode.Disable()
+	} else {
+		go tm.handleModeActions(mPauseFor, mConfigured)
+	}
+	systray.AddSeparator()
+
 	mLogs := systray.AddMenuItem("View Logs", "Open daemon log file")
 	systray.AddSeparator()
 
@@ -58,6 +158,12 @@ func (tm *TrayManager) onTrayReady() {
 	systray.AddSeparator()
 	mExit := systray.AddMenuItem("Exit", "Stop daemon and exit")
 
+	// Block and error events arrive from the daemon's notification dispatcher
+	if tm.notifier != nil {
+		tm.notifier.Add(tm)
+	}
+	go tm.refreshLoop()
+
 	// Handle menu actions in separate goroutines
 	go tm.handleMenuActions(mStatus, mShowDashboard, mLogs, mStop, mExit)
 }
@@ -88,20 +194,310 @@ func (tm *TrayManager) handleMenuActions(mStatus, mShowDashboard, mLogs, mStop,
 	}
 }
 
-// showStatus displays daemon status in a notification or dialog
-func (tm *TrayManager) showStatus() {
-	pidManager := NewPIDManager()
+// Name returns the notifier name
+func (tm *TrayManager) Name() string {
+	return "tray"
+}
 
-	var message string
-	if pid, running := pidManager.GetRunningPID(); running {
-		message = fmt.Sprintf("Guardian Daemon Status:\n✅ Running (PID: %d)\n🛡️ Monitoring active", pid)
-	} else {
-		message = "Guardian Daemon Status:\n❌ Not running"
+// Notify collects new blocks for the next desktop notification and raises firewall errors at once
+func (tm *TrayManager) Notify(alert *models.Alert) error {
+	switch alert.Kind {
+	case models.EventIPBlocked:
+		tm.mu.Lock()
+		if len(tm.blocked) < trayToastAddresses {
+			tm.blocked = append(tm.blocked, alert.IP)
+		} else {
+			tm.blockedMore++
+		}
+		if tm.blockReason == "" {
+			tm.blockReason = alert.Message
+		}
+		tm.mu.Unlock()
+		tm.requestRefresh()
+	case models.EventFirewallError:
+		tm.mu.Lock()
+		tm.lastError = time.Now()
+		tm.mu.Unlock()
+		tm.toast("Guardian firewall error", alert.Message)
+		tm.requestRefresh()
 	}
+	return nil
+}
 
-	// For now, we'll use a simple approach - in a full implementation,
-	// you'd show a native notification or dialog
-	fmt.Printf("📊 %s\n", message)
+// requestRefresh updates the tray without waiting for the next tick
+func (tm *TrayManager) requestRefresh() {
+	select {
+	case tm.refreshNow <- struct{}{}:
+	default:
+	}
+}
+
+// refreshLoop keeps the tooltip, menus and icon current until the tray exits
+func (tm *TrayManager) refreshLoop() {
+	ticker := time.NewTicker(trayRefreshInterval)
+	defer ticker.Stop()
+	for {
+		tm.refresh()
+		select {
+		case <-tm.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-tm.refreshNow:
+		}
+	}
+}
+
+// refresh applies the current counters to the tray and shows pending block notifications
+func (tm *TrayManager) refresh() {
+	status := tm.readStatus()
+
+	label := stateLabel(status.state)
+	if status.override != nil && !status.override.Until.IsZero() {
+		label += " until " + status.override.Until.Format("15:04")
+	}
+	summary := fmt.Sprintf("%s: %d active blocks, %d attacks today", label, status.activeBlocks, status.attacksToday)
+	tm.mSummary.SetTitle(summary)
+	systray.SetTooltip("Guardian - " + summary)
+
+	tm.mu.Lock()
+	if status.state != tm.state {
+		tm.state = status.state
+		systray.SetIcon(trayIcon(status.state))
+	}
+	modeChanged := tm.mode != "" && status.mode != tm.mode
+	tm.mode = status.mode
+	tm.recent = tm.recent[:0]
+	for i, slot := range tm.slots {
+		if i >= len(status.recent) {
+			slot.Hide()
+			continue
+		}
+		block := status.recent[i]
+		tm.recent = append(tm.recent, block.IP)
+		slot.SetTitle(fmt.Sprintf("Unblock %s (%s, %s)", block.IP, block.Service, block.BlockedAt.Format("Jan 2 15:04")))
+		slot.Show()
+	}
+	// Blocks in quick succession are combined into one notification
+	var blocked []string
+	var more int
+	var reason string
+	if time.Since(tm.lastToast) >= trayRefreshInterval && len(tm.blocked) > 0 {
+		blocked, more, reason = tm.blocked, tm.blockedMore, tm.blockReason
+		tm.blocked, tm.blockedMore, tm.blockReason = nil, 0, ""
+		tm.lastToast = time.Now()
+	}
+	tm.mu.Unlock()
+
+	for mode, item := range tm.modeItems {
+		if mode == status.mode {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+	if modeChanged {
+		tm.toast("Guardian protection: "+status.mode, modeDescription(status))
+	}
+
+	switch {
+	case len(blocked) == 1:
+		tm.toast("Guardian blocked "+blocked[0], reason)
+	case len(blocked) > 1:
+		message := strings.Join(blocked, ", ")
+		if more > 0 {
+			message += fmt.Sprintf(" and %d more", more)
+		}
+		tm.toast(fmt.Sprintf("Guardian blocked %d addresses", len(blocked)+more), message)
+	}
+}
+
+// readStatus reads the live counters from the block manager and storage
+func (tm *TrayManager) readStatus() trayStatus {
+	var status trayStatus
+	if tm.firewall != nil {
+		if blocks, err := tm.firewall.ListBlocked(); err == nil {
+			status.activeBlocks = len(blocks)
+			sort.Slice(blocks, func(i, j int) bool {
+				return blocks[i].BlockedAt.After(blocks[j].BlockedAt)
+			})
+			if len(blocks) > trayRecentBlocks {
+				blocks = blocks[:trayRecentBlocks]
+			}
+			status.recent = blocks
+		}
+		status.mode = tm.firewall.Mode("")
+		status.override = tm.firewall.Override()
+		switch status.mode {
+		case models.ModeObserve:
+			status.state = trayObserving
+		case models.ModePaused:
+			status.state = trayPaused
+		}
+	}
+	if tm.store != nil {
+		now := time.Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		status.attacksToday = countAttacksSince(tm.store, midnight)
+	}
+
+	tm.mu.Lock()
+	if !tm.lastError.IsZero() && time.Since(tm.lastError) < trayErrorHold {
+		status.state = trayError
+	}
+	tm.mu.Unlock()
+	return status
+}
+
+// countAttacksSince counts stored attempts logged at or after since
+// GetAttacks returns the most recently stored first, but backfilled attempts carry older
+// timestamps, so older ones are skipped and reading stops only after a full page of them
+func countAttacksSince(store core.Storage, since time.Time) int {
+	const page = 200
+	count := 0
+	for offset := 0; ; offset += page {
+		attempts, err := store.GetAttacks(page, offset)
+		if err != nil {
+			return count
+		}
+		older := 0
+		for _, attempt := range attempts {
+			if attempt.Timestamp.Before(since) {
+				older++
+				continue
+			}
+			count++
+		}
+		if len(attempts) < page || older == page {
+			return count
+		}
+	}
+}
+
+// stateLabel describes a tray state in the menu and tooltip
+func stateLabel(state trayState) string {
+	switch state {
+	case trayObserving:
+		return "Observing"
+	case trayPaused:
+		return "Paused"
+	case trayError:
+		return "Firewall error"
+	default:
+		return "Protecting"
+	}
+}
+
+// handleUnblock removes the block shown in one Recent Blocks entry when it is clicked
+func (tm *TrayManager) handleUnblock(index int, slot *systray.MenuItem) {
+	for {
+		select {
+		case <-slot.ClickedCh:
+		case <-tm.ctx.Done():
+			return
+		}
+
+		tm.mu.Lock()
+		ip := ""
+		if index < len(tm.recent) {
+			ip = tm.recent[index]
+		}
+		tm.mu.Unlock()
+		if ip == "" {
+			continue
+		}
+
+		if err := tm.firewall.Unblock(ip); err != nil {
+			logger.Warn("Failed to unblock from the system tray", "ip", ip, "error", err)
+			tm.toast("Guardian could not unblock "+ip, err.Error())
+		} else {
+			logger.Info("Address unblocked from the system tray", "ip", ip)
+			tm.toast("Guardian unblocked "+ip, "The firewall rule was removed.")
+		}
+		tm.requestRefresh()
+	}
+}
+
+// handleModeActions switches the protection mode from the Protection Mode menu
+func (tm *TrayManager) handleModeActions(pauseFor, configured *systray.MenuItem) {
+	enforce := tm.modeItems[models.ModeEnforce]
+	observe := tm.modeItems[models.ModeObserve]
+	paused := tm.modeItems[models.ModePaused]
+	for {
+		select {
+		case <-enforce.ClickedCh:
+			tm.switchMode(models.ModeEnforce, 0)
+		case <-observe.ClickedCh:
+			tm.switchMode(models.ModeObserve, 0)
+		case <-paused.ClickedCh:
+			tm.switchMode(models.ModePaused, 0)
+		case <-pauseFor.ClickedCh:
+			tm.switchMode(models.ModePaused, trayPauseDuration)
+		case <-configured.ClickedCh:
+			tm.applyOverride(nil)
+		case <-tm.ctx.Done():
+			return
+		}
+	}
+}
+
+// switchMode sets a runtime mode for every service, for a while or until changed
+func (tm *TrayManager) switchMode(mode string, duration time.Duration) {
+	override := &firewall.ModeOverride{Mode: mode, SetBy: "tray", SetAt: time.Now()}
+	if duration > 0 {
+		override.Until = override.SetAt.Add(duration)
+	}
+	tm.applyOverride(override)
+}
+
+// applyOverride writes the mode file the daemon watches, then applies it at once
+func (tm *TrayManager) applyOverride(override *firewall.ModeOverride) {
+	if err := firewall.SaveModeOverride(firewall.ModePath(), override); err != nil {
+		logger.Warn("Failed to switch protection mode from the system tray", "error", err)
+		tm.toast("Guardian could not switch mode", err.Error())
+		return
+	}
+	tm.firewall.SetOverride(override)
+	tm.requestRefresh()
+}
+
+// modeDescription explains a mode in its change notification
+func modeDescription(status trayStatus) string {
+	var text string
+	switch status.mode {
+	case models.ModeObserve:
+		text = "Attacks are recorded as would-block alerts; the firewall is not changed."
+	case models.ModePaused:
+		text = "New attacks are detected but not blocked. Existing blocks stay in place."
+	default:
+		text = "Attacking addresses are blocked."
+	}
+	if status.override != nil && !status.override.Until.IsZero() {
+		text += " Until " + status.override.Until.Format("15:04") + "."
+	}
+	return text
+}
+
+// toast shows a native desktop notification without holding up the caller
+func (tm *TrayManager) toast(title, message string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(tm.ctx, toastTimeout)
+		defer cancel()
+		if output, err := toastCommand(ctx, title, message).CombinedOutput(); err != nil {
+			logger.Debug("Desktop notification failed", "title", title, "error", err, "output", strings.TrimSpace(string(output)))
+		}
+	}()
+}
+
+// showStatus shows the daemon's live status as a desktop notification
+func (tm *TrayManager) showStatus() {
+	status := tm.readStatus()
+	message := fmt.Sprintf("%s (PID %d)\n%d active blocks\n%d attacks today",
+		stateLabel(status.state), os.Getpid(), status.activeBlocks, status.attacksToday)
+	if len(status.recent) > 0 {
+		latest := status.recent[0]
+		message += fmt.Sprintf("\nLast block: %s at %s", latest.IP, latest.BlockedAt.Format("15:04"))
+	}
+	tm.toast("Guardian status", message)
 }
 
 // launchDashboard opens the Guardian TUI dashboard
@@ -189,23 +585,11 @@ func (tm *TrayManager) initializeTrayDisplay() {
 		title += " (Dev)"
 	}
 
-	systray.SetTemplateIcon(tm.getIconData(), tm.getIconData())
+	systray.SetIcon(trayIcon(trayProtecting))
 	systray.SetTitle(title)
 	systray.SetTooltip("Guardian Intrusion Prevention System")
 }
 
-// getIconData returns the system tray icon data
-func (tm *TrayManager) getIconData() []byte {
-	// Simple Guardian shield icon as bytes (placeholder)
-	// In a full implementation, you'd embed actual icon files
-	return []byte{
-		0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D,
-		0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x10,
-		0x08, 0x02, 0x00, 0x00, 0x00, 0x90, 0x91, 0x68, 0x36, 0x00, 0x00, 0x00,
-		// Minimal PNG data for a simple icon
-	}
-}
-
 // getLogPath returns the path to the daemon log file
 func (tm *TrayManager) getLogPath() string {
 	switch runtime.GOOS {
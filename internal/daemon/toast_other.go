@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+// toastCommand builds the command raising a native notification
+// The text is passed as arguments, never as script
+func toastCommand(ctx context.Context, title, message string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, message)
+	}
+	// libnotify, present on most Linux desktops
+	return exec.CommandContext(ctx, "notify-send", "--app-name=Guardian", "--icon=security-high", title, message)
+}
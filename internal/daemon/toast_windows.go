@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// toastScript shows a Windows toast; the text comes from the environment so it is never parsed as script
+// Toasts need a registered AppUserModelID, so they are raised under PowerShell's own
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:GUARDIAN_TOAST_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:GUARDIAN_TOAST_MESSAGE)) > $null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+$appID = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($appID).Show($toast)
+`
+
+// toastCommand builds the command raising a native notification
+func toastCommand(ctx context.Context, title, message string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", toastScript)
+	cmd.Env = append(os.Environ(), "GUARDIAN_TOAST_TITLE="+title, "GUARDIAN_TOAST_MESSAGE="+message)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+	return cmd
+}
@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"runtime"
+)
+
+// trayIconSize is the edge of the generated icon in pixels
+const trayIconSize = 32
+
+// Icon colours: green while protecting, blue while observing, amber while paused, red after a firewall error
+var trayColors = map[trayState]color.NRGBA{
+	trayProtecting: {0x2E, 0x9E, 0x4F, 0xFF},
+	trayObserving:  {0x3B, 0x82, 0xF6, 0xFF},
+	trayPaused:     {0xF0, 0xAD, 0x4E, 0xFF},
+	trayError:      {0xD9, 0x34, 0x3A, 0xFF},
+}
+
+// trayIcon draws the shield in the colour of a state, as ICO on Windows and PNG elsewhere
+func trayIcon(state trayState) []byte {
+	fill := trayColors[state]
+	edge := color.NRGBA{fill.R / 2, fill.G / 2, fill.B / 2, 0xFF}
+
+	img := image.NewNRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+	for y := 0; y < trayIconSize; y++ {
+		for x := 0; x < trayIconSize; x++ {
+			if !inShield(x, y) {
+				continue
+			}
+			if inShield(x-1, y) && inShield(x+1, y) && inShield(x, y-1) && inShield(x, y+1) {
+				img.SetNRGBA(x, y, fill)
+			} else {
+				img.SetNRGBA(x, y, edge)
+			}
+		}
+	}
+
+	var data bytes.Buffer
+	png.Encode(&data, img)
+	if runtime.GOOS != "windows" {
+		return data.Bytes()
+	}
+	return pngToICO(data.Bytes())
+}
+
+// inShield reports whether a pixel lies inside the shield: straight sides down to the
+// shoulder, then tapering to a point
+func inShield(x, y int) bool {
+	const top, shoulder, bottom, halfWidth = 2.0, 16.0, 30.0, 12.0
+	fx, fy := float64(x)+0.5, float64(y)+0.5
+	if fy < top || fy > bottom {
+		return false
+	}
+	half := halfWidth
+	if fy > shoulder {
+		half = halfWidth * (bottom - fy) / (bottom - shoulder)
+	}
+	return math.Abs(fx-trayIconSize/2) <= half
+}
+
+// pngToICO wraps a PNG in a single-image ICO container, which the Windows tray requires
+func pngToICO(data []byte) []byte {
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, struct {
+		Reserved, Type, Count uint16
+	}{0, 1, 1})
+	binary.Write(&ico, binary.LittleEndian, struct {
+		Width, Height, Colors, Reserved uint8
+		Planes, BitCount                uint16
+		Size, Offset                    uint32
+	}{trayIconSize, trayIconSize, 0, 0, 1, 32, uint32(len(data)), 22})
+	ico.Write(data)
+	return ico.Bytes()
+}
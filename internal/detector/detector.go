@@ -0,0 +1,317 @@
+package detector
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// failureClass groups failure codes by what they say about the attacker
+type failureClass int
+
+const (
+	failureUnknown     failureClass = iota // no code logged
+	failureUnknownUser                     // account does not exist: enumeration or spraying
+	failureBadPassword                     // real account, wrong password: targeted guessing
+	failureLockedOut                       // still trying after the lockout policy kicked in
+	failureRestricted                      // disabled, expired or restricted account
+)
+
+// failureClasses maps NTSTATUS (4625 Sub Status, 4776 Error Code) and Kerberos (4771) codes
+var failureClasses = map[string]failureClass{
+	"0xc0000064": failureUnknownUser,
+	"0xc000006a": failureBadPassword,
+	"0xc000006d": failureBadPassword,
+	"0xc0000234": failureLockedOut,
+	"0xc000006f": failureRestricted,
+	"0xc0000070": failureRestricted,
+	"0xc0000071": failureRestricted,
+	"0xc0000072": failureRestricted,
+	"0xc0000193": failureRestricted,
+	"0xc0000224": failureRestricted,
+	"0x6":        failureUnknownUser,
+	"0x18":       failureBadPassword,
+	"0x12":       failureLockedOut,
+	"0x17":       failureRestricted,
+}
+
+// Base confidence per failure class
+var failureConfidence = map[failureClass]float64{
+	failureUnknown:     0.40,
+	failureUnknownUser: 0.45,
+	failureBadPassword: 0.55,
+	failureLockedOut:   0.70,
+	failureRestricted:  0.45,
+}
+
+var failureDescriptions = map[failureClass]string{
+	failureUnknownUser: "unknown user",
+	failureBadPassword: "bad password on an existing account",
+	failureLockedOut:   "attempt on a locked-out account",
+	failureRestricted:  "disabled or restricted account",
+}
+
+// logonTypeAdjustments raise remote logons and lower local ones, which are usually typos or stale credentials
+var logonTypeAdjustments = map[int]float64{
+	2:  -0.20, // Interactive (console)
+	3:  0.05,  // Network (SMB, NLA before RDP)
+	4:  -0.30, // Batch (scheduled task with an old password)
+	5:  -0.30, // Service (service with an old password)
+	7:  -0.20, // Unlock
+	8:  0.05,  // NetworkCleartext (IIS basic auth)
+	10: 0.10,  // RemoteInteractive (RDP)
+}
+
+var logonTypeNames = map[int]string{
+	2: "interactive", 3: "network", 4: "batch", 5: "service",
+	7: "unlock", 8: "network cleartext", 10: "remote interactive",
+}
+
+// Username classes
+var (
+	privilegedUsernames = []string{"administrator", "admin", "root", "sa", "sysadmin", "domainadmin"}
+	dictionaryUsernames = []string{"user", "test", "guest", "demo", "support", "scanner", "backup", "ftp", "oracle", "postgres", "mysql", "user1", "test1"}
+)
+
+// Confidence boundaries for the severity levels
+const (
+	criticalConfidence = 0.85
+	highConfidence     = 0.65
+	mediumConfidence   = 0.40
+
+	// Assessments at or above this confidence recommend blocking without waiting for the threshold
+	immediateBlockConfidence = 0.90
+
+	// Bound on tracked addresses; the longest-idle ones are dropped when exceeded
+	maxTrackedIPs = 50000
+)
+
+// Detector implements core.ThreatDetector
+// Each attempt is scored from its failure code (NTSTATUS substatus), logon type,
+// username class and the number of distinct usernames its address tried recently
+type Detector struct {
+	mu        sync.Mutex
+	config    *models.Config
+	window    time.Duration
+	usernames *distinctTracker // address -> usernames, for the confidence score
+	now       func() time.Time
+
+	// Cross-username rules (see patterns.go)
+	spraying *distinctTracker // service+address -> usernames
+	stuffing *distinctTracker // service+username -> addresses
+	alerted  map[string]time.Time
+}
+
+// New creates a detector using the monitoring lookback as the diversity window
+func New(config *models.Config) *Detector {
+	window := config.Monitoring.LookbackDuration
+	if window <= 0 {
+		window = time.Hour
+	}
+	return &Detector{
+		config:    config,
+		window:    window,
+		usernames: newDistinctTracker(maxTrackedIPs),
+		now:       time.Now,
+		spraying:  newDistinctTracker(maxTrackedIPs),
+		stuffing:  newDistinctTracker(maxTrackedIPs),
+		alerted:   make(map[string]time.Time),
+	}
+}
+
+// AnalyzeAttack scores an attempt and records its username for the diversity signal
+func (d *Detector) AnalyzeAttack(attempt *models.AttackAttempt) core.ThreatAssessment {
+	class := classifyFailure(attempt.FailureCode)
+	confidence := failureConfidence[class]
+	var reasons []string
+	if description, exists := failureDescriptions[class]; exists {
+		reasons = append(reasons, description)
+	}
+
+	if adjustment, exists := logonTypeAdjustments[attempt.LogonType]; exists {
+		confidence += adjustment
+		reasons = append(reasons, logonTypeNames[attempt.LogonType]+" logon")
+	}
+
+	switch usernameClass(attempt.Username) {
+	case "privileged":
+		confidence += 0.15
+		reasons = append(reasons, "privileged account")
+	case "dictionary":
+		confidence += 0.10
+		reasons = append(reasons, "common dictionary username")
+	case "machine":
+		confidence -= 0.20
+		reasons = append(reasons, "machine account")
+	}
+
+	distinct := d.recordUsername(attempt.IP, attempt.Username, attempt.Timestamp)
+	switch {
+	case distinct >= 5:
+		confidence += 0.25
+		reasons = append(reasons, fmt.Sprintf("%d usernames tried from this address", distinct))
+	case distinct >= 3:
+		confidence += 0.15
+		reasons = append(reasons, fmt.Sprintf("%d usernames tried from this address", distinct))
+	}
+
+	// Rounded so boundaries such as 0.65 are not missed by float error
+	confidence = math.Round(min(max(confidence, 0.05), 0.99)*100) / 100
+
+	severity := severityFor(confidence)
+	if attempt.Severity > severity {
+		// Parser-level signals (scanner probes, lockouts) are never downgraded
+		severity = attempt.Severity
+	}
+
+	assessment := core.ThreatAssessment{
+		Severity:          severity,
+		Confidence:        confidence,
+		ShouldBlock:       confidence >= immediateBlockConfidence && !d.IsWhitelisted(attempt.IP),
+		Reason:            strings.Join(reasons, ", "),
+		RecommendedAction: "monitor",
+	}
+	if assessment.Reason == "" {
+		assessment.Reason = "failed authentication"
+	}
+	switch {
+	case d.IsWhitelisted(attempt.IP):
+		assessment.RecommendedAction = "ignore (whitelisted)"
+	case assessment.ShouldBlock:
+		assessment.RecommendedAction = "block immediately"
+	case confidence >= highConfidence:
+		assessment.RecommendedAction = "block at threshold"
+	}
+	return assessment
+}
+
+// ShouldBlock reports whether the attempts from an address reach the blocking threshold
+// A single attempt assessed above the immediate-block confidence is enough
+func (d *Detector) ShouldBlock(ip string, attempts []*models.AttackAttempt) bool {
+	if d.IsWhitelisted(ip) || len(attempts) == 0 {
+		return false
+	}
+
+	if len(attempts) >= d.config.ThresholdFor(attempts[0].Service) {
+		return true
+	}
+
+	for _, attempt := range attempts {
+		if d.AnalyzeAttack(attempt).ShouldBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWhitelisted checks an address against blocking.whitelisted_ips (addresses and CIDRs)
+func (d *Detector) IsWhitelisted(ip string) bool {
+	parsed := utils.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, entry := range d.config.Blocking.WhitelistedIPs {
+		candidate := strings.TrimSpace(entry)
+		if candidate == "" {
+			continue
+		}
+		if strings.Contains(candidate, "/") {
+			_, network, err := net.ParseCIDR(candidate)
+			if err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if whitelisted := utils.ParseIP(candidate); whitelisted != nil && whitelisted.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// DistinctUsernames returns how many usernames an address tried within the window
+func (d *Detector) DistinctUsernames(ip string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.usernames.count(ip, d.now(), d.window)
+}
+
+// recordUsername adds a username to an address's history and returns the distinct count
+func (d *Detector) recordUsername(ip, username string, seen time.Time) int {
+	if ip == "" {
+		return 0
+	}
+	now := d.now()
+	if seen.IsZero() || seen.After(now) {
+		seen = now
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.usernames.add(ip, normalizeUsername(username), seen, now, d.window)
+}
+
+// normalizeUsername lower-cases a username; placeholders count as no username
+func normalizeUsername(username string) string {
+	name := strings.ToLower(strings.TrimSpace(username))
+	if name == "unknown" || name == "-" {
+		return ""
+	}
+	return name
+}
+
+// classifyFailure maps a failure code to its class
+func classifyFailure(code string) failureClass {
+	return failureClasses[strings.ToLower(strings.TrimSpace(code))]
+}
+
+// usernameClass returns "privileged", "dictionary", "machine" or "" for ordinary accounts
+func usernameClass(username string) string {
+	name := strings.ToLower(username)
+	if index := strings.LastIndex(name, `\`); index >= 0 {
+		name = name[index+1:]
+	}
+	if index := strings.Index(name, "@"); index >= 0 {
+		name = name[:index]
+	}
+
+	switch {
+	case name == "" || name == "unknown":
+		return ""
+	case strings.HasSuffix(name, "$"):
+		return "machine"
+	}
+	for _, privileged := range privilegedUsernames {
+		if name == privileged {
+			return "privileged"
+		}
+	}
+	for _, dictionary := range dictionaryUsernames {
+		if name == dictionary {
+			return "dictionary"
+		}
+	}
+	return ""
+}
+
+// severityFor maps a confidence to a severity level
+func severityFor(confidence float64) models.Severity {
+	switch {
+	case confidence >= criticalConfidence:
+		return models.SeverityCritical
+	case confidence >= highConfidence:
+		return models.SeverityHigh
+	case confidence >= mediumConfidence:
+		return models.SeverityMedium
+	default:
+		return models.SeverityLow
+	}
+}
@@ -0,0 +1,225 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// testDetector returns a detector on a fixed clock that tests can move
+func testDetector(config *models.Config) (*Detector, *time.Time) {
+	d := New(config)
+	clock := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return clock }
+	return d, &clock
+}
+
+func sprayConfig() *models.Config {
+	return &models.Config{Detection: models.DetectionConfig{
+		SprayUsernames:  3,
+		StuffingSources: 3,
+		Window:          10 * time.Minute,
+	}}
+}
+
+// attempt is a failed SSH login at the detector's current time
+func attempt(d *Detector, ip, username string) *models.AttackAttempt {
+	return &models.AttackAttempt{Timestamp: d.now(), IP: ip, Service: "SSH", Username: username}
+}
+
+func TestSprayingCountsAggregatedIPv6Network(t *testing.T) {
+	d, _ := testDetector(sprayConfig())
+
+	var alerts []*models.Alert
+	for i, username := range []string{"alice", "bob", "carol"} {
+		// Each attempt comes from another address in the same /64
+		ip := fmt.Sprintf("2001:db8:1:2::%x", i+1)
+		alerts = append(alerts, d.Observe(attempt(d, ip, username))...)
+	}
+
+	if len(alerts) != 1 || alerts[0].Kind != models.AlertPasswordSpraying {
+		t.Fatalf("alerts = %+v, want one password_spraying alert", alerts)
+	}
+	if alerts[0].IP != "2001:db8:1:2::3" || alerts[0].Count != 3 {
+		t.Errorf("alert IP/count = %s/%d, want the last address and 3 usernames", alerts[0].IP, alerts[0].Count)
+	}
+	if want := "Password spraying: 2001:db8:1:2::/64 tried 3 usernames"; !strings.HasPrefix(alerts[0].Message, want) {
+		t.Errorf("message = %q", alerts[0].Message)
+	}
+}
+
+func TestAnalyzeAttackScoring(t *testing.T) {
+	cases := []struct {
+		name       string
+		code       string
+		logonType  int
+		username   string
+		confidence float64
+		severity   models.Severity
+		reason     string
+	}{
+		{name: "no code", username: "bob", confidence: 0.40, severity: models.SeverityMedium, reason: "failed authentication"},
+		{name: "unknown user", code: "0xC0000064", username: "bob", confidence: 0.45, severity: models.SeverityMedium, reason: "unknown user"},
+		{name: "bad password", code: "0xc000006a", username: "bob", confidence: 0.55, severity: models.SeverityMedium, reason: "bad password on an existing account"},
+		{name: "Kerberos bad password", code: "0x18", username: "bob", confidence: 0.55, severity: models.SeverityMedium, reason: "bad password"},
+		{name: "locked out", code: "0xC0000234", username: "bob", confidence: 0.70, severity: models.SeverityHigh, reason: "locked-out account"},
+		{name: "disabled", code: "0xC0000072", username: "bob", confidence: 0.45, severity: models.SeverityMedium, reason: "disabled or restricted account"},
+
+		{name: "RDP logon", code: "0xC000006A", logonType: 10, username: "bob", confidence: 0.65, severity: models.SeverityHigh, reason: "remote interactive logon"},
+		{name: "network logon", logonType: 3, username: "bob", confidence: 0.45, severity: models.SeverityMedium, reason: "network logon"},
+		{name: "service logon", code: "0xC000006A", logonType: 5, username: "bob", confidence: 0.25, severity: models.SeverityLow, reason: "service logon"},
+		{name: "console logon", logonType: 2, username: "bob", confidence: 0.20, severity: models.SeverityLow, reason: "interactive logon"},
+
+		{name: "privileged", username: "Administrator", confidence: 0.55, severity: models.SeverityMedium, reason: "privileged account"},
+		{name: "privileged with domain", username: `CONTOSO\admin`, confidence: 0.55, severity: models.SeverityMedium, reason: "privileged account"},
+		{name: "privileged UPN", username: "root@example.com", confidence: 0.55, severity: models.SeverityMedium, reason: "privileged account"},
+		{name: "dictionary", username: "guest", confidence: 0.50, severity: models.SeverityMedium, reason: "common dictionary username"},
+		{name: "machine account", username: "WS01$", confidence: 0.20, severity: models.SeverityLow, reason: "machine account"},
+
+		{name: "floor", code: "0xC000006A", logonType: 4, username: "WS01$", confidence: 0.05, severity: models.SeverityLow},
+	}
+
+	d, _ := testDetector(&models.Config{})
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// A fresh address each time keeps the diversity signal out
+			attempt := attempt(d, fmt.Sprintf("203.0.113.%d", i+1), tc.username)
+			attempt.FailureCode = tc.code
+			attempt.LogonType = tc.logonType
+
+			assessment := d.AnalyzeAttack(attempt)
+			if assessment.Confidence != tc.confidence || assessment.Severity != tc.severity {
+				t.Errorf("confidence/severity = %.2f/%v, want %.2f/%v (%s)",
+					assessment.Confidence, assessment.Severity, tc.confidence, tc.severity, assessment.Reason)
+			}
+			if !strings.Contains(assessment.Reason, tc.reason) {
+				t.Errorf("reason %q does not mention %q", assessment.Reason, tc.reason)
+			}
+			if assessment.ShouldBlock {
+				t.Error("a single attempt below the immediate-block confidence should not block")
+			}
+		})
+	}
+}
+
+func TestAnalyzeAttackUsernameDiversity(t *testing.T) {
+	d, _ := testDetector(&models.Config{})
+
+	want := []float64{0.40, 0.40, 0.55, 0.55, 0.65}
+	for i, username := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		assessment := d.AnalyzeAttack(attempt(d, "203.0.113.5", username))
+		if assessment.Confidence != want[i] {
+			t.Errorf("username %d: confidence = %.2f, want %.2f (%s)", i+1, assessment.Confidence, want[i], assessment.Reason)
+		}
+	}
+	if got := d.DistinctUsernames("203.0.113.5"); got != 5 {
+		t.Errorf("DistinctUsernames = %d, want 5", got)
+	}
+
+	// Repeating a username does not add to the count
+	if assessment := d.AnalyzeAttack(attempt(d, "203.0.113.5", "ALICE")); !strings.Contains(assessment.Reason, "5 usernames") {
+		t.Errorf("reason = %q, want 5 usernames", assessment.Reason)
+	}
+}
+
+func TestAnalyzeAttackImmediateBlock(t *testing.T) {
+	config := &models.Config{Blocking: models.BlockingConfig{WhitelistedIPs: []string{"198.51.100.0/24"}}}
+	d, _ := testDetector(config)
+
+	// Locked-out administrator over RDP: 0.70 + 0.10 + 0.15
+	locked := attempt(d, "203.0.113.5", "administrator")
+	locked.FailureCode, locked.LogonType = "0xC0000234", 10
+	assessment := d.AnalyzeAttack(locked)
+	if !assessment.ShouldBlock || assessment.Severity != models.SeverityCritical || assessment.RecommendedAction != "block immediately" {
+		t.Errorf("assessment = %+v, want an immediate critical block", assessment)
+	}
+	if !d.ShouldBlock(locked.IP, []*models.AttackAttempt{locked}) {
+		t.Error("ShouldBlock ignored a single immediate-block attempt")
+	}
+
+	whitelisted := *locked
+	whitelisted.IP = "198.51.100.7"
+	assessment = d.AnalyzeAttack(&whitelisted)
+	if assessment.ShouldBlock || assessment.RecommendedAction != "ignore (whitelisted)" {
+		t.Errorf("whitelisted assessment = %+v", assessment)
+	}
+
+	// Parser-level severity is kept even when the score is lower
+	probe := attempt(d, "203.0.113.6", "")
+	probe.Severity = models.SeverityHigh
+	if assessment := d.AnalyzeAttack(probe); assessment.Severity != models.SeverityHigh {
+		t.Errorf("severity = %v, want the parser's high", assessment.Severity)
+	}
+}
+
+func TestCredentialStuffing(t *testing.T) {
+	d, _ := testDetector(sprayConfig())
+
+	var alerts []*models.Alert
+	for _, ip := range []string{"203.0.113.5", "198.51.100.7", "2001:db8::9"} {
+		alerts = append(alerts, d.Observe(attempt(d, ip, "Bob"))...)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != models.AlertCredentialStuffing {
+		t.Fatalf("alerts = %+v, want one credential_stuffing alert", alerts)
+	}
+	alert := alerts[0]
+	if alert.Username != "Bob" || alert.IP != "" || alert.Count != 3 || alert.Severity != models.SeverityCritical {
+		t.Errorf("alert = %+v", alert)
+	}
+	if len(alert.Samples) != 3 {
+		t.Errorf("samples = %v, want the 3 addresses", alert.Samples)
+	}
+
+	// Placeholder usernames never count as one account
+	for _, ip := range []string{"203.0.113.20", "203.0.113.21", "203.0.113.22"} {
+		if alerts := d.Observe(attempt(d, ip, "-")); len(alerts) != 0 {
+			t.Errorf("placeholder username raised %+v", alerts)
+		}
+	}
+}
+
+func TestObserveIgnoresWhitelistedAddresses(t *testing.T) {
+	config := sprayConfig()
+	config.Blocking.WhitelistedIPs = []string{"203.0.113.5"}
+	d, _ := testDetector(config)
+
+	for _, username := range []string{"alice", "bob", "carol", "dave"} {
+		if alerts := d.Observe(attempt(d, "203.0.113.5", username)); len(alerts) != 0 {
+			t.Fatalf("whitelisted address raised %+v", alerts)
+		}
+	}
+}
+
+// TestSprayingAlertRepeatsOncePerWindow checks shouldAlert: while the pattern goes on, the alert
+// repeats once the window has passed since the previous alert, however recent the last attempt
+func TestSprayingAlertRepeatsOncePerWindow(t *testing.T) {
+	d, clock := testDetector(sprayConfig())
+	spray := func(usernames ...string) int {
+		alerts := 0
+		for _, username := range usernames {
+			alerts += len(d.Observe(attempt(d, "203.0.113.5", username)))
+		}
+		return alerts
+	}
+
+	if got := spray("alice", "bob", "carol"); got != 1 {
+		t.Fatalf("first burst raised %d alerts, want 1", got)
+	}
+	if got := spray("dave"); got != 0 {
+		t.Errorf("fourth username raised %d alerts within the window, want 0", got)
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	if got := spray("erin", "frank"); got != 0 {
+		t.Errorf("attempts 5 minutes after the alert raised %d alerts, want 0", got)
+	}
+
+	// 11 minutes after the alert: the first burst has expired, the later usernames still count
+	*clock = clock.Add(6 * time.Minute)
+	if got := spray("grace"); got != 1 {
+		t.Errorf("continued spraying a window after the alert raised %d alerts, want 1", got)
+	}
+}
@@ -0,0 +1,104 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Number of usernames or addresses quoted in an alert
+const alertSamples = 5
+
+// Observe applies the cross-username rules to an attempt and returns any alerts it raises
+//   - Password spraying: one address (or aggregated network, see blocking.ipv6_prefix) trying
+//     detection.spray_usernames distinct usernames within the window; the caller blocks it
+//   - Credential stuffing: detection.stuffing_sources distinct addresses failing on one
+//     username within the window; the account needs protecting, no address is blocked
+//
+// An alert fires once per address or username; while the pattern continues it repeats
+// at most once per window, counted from the previous alert
+func (d *Detector) Observe(attempt *models.AttackAttempt) []*models.Alert {
+	username := normalizeUsername(attempt.Username)
+	if attempt.IP == "" || d.IsWhitelisted(attempt.IP) {
+		return nil
+	}
+
+	detection := d.config.DetectionFor(attempt.Service)
+	service := strings.ToLower(attempt.Service)
+	now := d.now()
+	seen := attempt.Timestamp
+	if seen.IsZero() || seen.After(now) {
+		seen = now
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var alerts []*models.Alert
+	if detection.SprayUsernames > 0 {
+		// Counted per blocking key, so an IPv6 attacker rotating through its /64 is one sprayer
+		source, err := d.config.Blocking.AggregationKey(attempt.IP)
+		if err != nil {
+			source = attempt.IP
+		}
+		key := service + "\x00" + source
+		count := d.spraying.add(key, username, seen, now, detection.Window)
+		if count >= detection.SprayUsernames && d.shouldAlert(models.AlertPasswordSpraying, key, now, detection.Window) {
+			samples := d.spraying.samples(key, alertSamples)
+			alerts = append(alerts, &models.Alert{
+				Timestamp: now,
+				Kind:      models.AlertPasswordSpraying,
+				Service:   attempt.Service,
+				IP:        attempt.IP,
+				Count:     count,
+				Window:    detection.Window,
+				Samples:   samples,
+				Severity:  models.SeverityHigh,
+				Message: fmt.Sprintf("Password spraying: %s tried %d usernames on %s within %s (%s)",
+					source, count, attempt.Service, detection.Window, strings.Join(samples, ", ")),
+			})
+		}
+	}
+
+	if detection.StuffingSources > 0 && username != "" {
+		key := service + "\x00" + username
+		count := d.stuffing.add(key, attempt.IP, seen, now, detection.Window)
+		if count >= detection.StuffingSources && d.shouldAlert(models.AlertCredentialStuffing, key, now, detection.Window) {
+			samples := d.stuffing.samples(key, alertSamples)
+			alerts = append(alerts, &models.Alert{
+				Timestamp: now,
+				Kind:      models.AlertCredentialStuffing,
+				Service:   attempt.Service,
+				Username:  attempt.Username,
+				Count:     count,
+				Window:    detection.Window,
+				Samples:   samples,
+				Severity:  models.SeverityCritical,
+				Message: fmt.Sprintf("Credential stuffing: account %q failed on %s from %d addresses within %s (%s); consider resetting its password or enabling MFA",
+					attempt.Username, attempt.Service, count, detection.Window, strings.Join(samples, ", ")),
+			})
+		}
+	}
+	return alerts
+}
+
+// shouldAlert records an alert for kind+key unless one fired within the window
+// Caller must hold d.mu
+func (d *Detector) shouldAlert(kind, key string, now time.Time, window time.Duration) bool {
+	id := kind + "\x00" + key
+	if last, exists := d.alerted[id]; exists && now.Sub(last) < window {
+		return false
+	}
+
+	if len(d.alerted) >= maxTrackedIPs {
+		for existing, last := range d.alerted {
+			if now.Sub(last) >= window {
+				delete(d.alerted, existing)
+			}
+		}
+	}
+	d.alerted[id] = now
+	return true
+}
@@ -0,0 +1,109 @@
+package detector
+
+import (
+	"slices"
+	"time"
+)
+
+// distinctTracker counts distinct values per key within a sliding window
+// (usernames per address for spraying, addresses per username for stuffing)
+// Event timestamps are used, so rescanning the same log window does not inflate counts
+type distinctTracker struct {
+	maxKeys int
+	keys    map[string]*distinctValues
+}
+
+type distinctValues struct {
+	values   map[string]time.Time
+	lastSeen time.Time
+}
+
+func newDistinctTracker(maxKeys int) *distinctTracker {
+	return &distinctTracker{maxKeys: maxKeys, keys: make(map[string]*distinctValues)}
+}
+
+// add records value under key and returns the number of distinct values seen since now-window
+// An empty value only refreshes the key
+func (t *distinctTracker) add(key, value string, seen, now time.Time, window time.Duration) int {
+	entry, exists := t.keys[key]
+	if !exists {
+		if len(t.keys) >= t.maxKeys {
+			t.evict(now, window)
+		}
+		entry = &distinctValues{values: make(map[string]time.Time)}
+		t.keys[key] = entry
+	}
+
+	if value != "" {
+		if previous, exists := entry.values[value]; !exists || seen.After(previous) {
+			entry.values[value] = seen
+		}
+	}
+	if seen.After(entry.lastSeen) {
+		entry.lastSeen = seen
+	}
+	return t.expire(entry, now, window)
+}
+
+// count returns the number of distinct values for key within the window
+func (t *distinctTracker) count(key string, now time.Time, window time.Duration) int {
+	entry, exists := t.keys[key]
+	if !exists {
+		return 0
+	}
+	return t.expire(entry, now, window)
+}
+
+// samples returns up to limit values for key, most recent first
+func (t *distinctTracker) samples(key string, limit int) []string {
+	entry, exists := t.keys[key]
+	if !exists {
+		return nil
+	}
+	values := make([]string, 0, len(entry.values))
+	for value := range entry.values {
+		values = append(values, value)
+	}
+	slices.SortFunc(values, func(a, b string) int {
+		return entry.values[b].Compare(entry.values[a])
+	})
+	if len(values) > limit {
+		values = values[:limit]
+	}
+	return values
+}
+
+// expire drops values last seen before the window and returns how many remain
+func (t *distinctTracker) expire(entry *distinctValues, now time.Time, window time.Duration) int {
+	cutoff := now.Add(-window)
+	for value, seen := range entry.values {
+		if seen.Before(cutoff) {
+			delete(entry.values, value)
+		}
+	}
+	return len(entry.values)
+}
+
+// evict drops keys idle for longer than the window, or the longer-idle half if none are
+func (t *distinctTracker) evict(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	for key, entry := range t.keys {
+		if entry.lastSeen.Before(cutoff) {
+			delete(t.keys, key)
+		}
+	}
+	if len(t.keys) < t.maxKeys {
+		return
+	}
+
+	var total time.Duration
+	for _, entry := range t.keys {
+		total += now.Sub(entry.lastSeen)
+	}
+	average := total / time.Duration(len(t.keys))
+	for key, entry := range t.keys {
+		if now.Sub(entry.lastSeen) >= average {
+			delete(t.keys, key)
+		}
+	}
+}
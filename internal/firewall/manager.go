@@ -0,0 +1,463 @@
+package firewall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// Eviction policies applied when blocking.max_concurrent_blocks is reached
+const (
+	EvictOldest         = "oldest"          // Remove the block that has been active the longest
+	EvictLowestSeverity = "lowest_severity" // Remove the least severe block (oldest first on ties)
+	EvictAggregate      = "aggregate"       // Merge neighbouring blocks into one wider network block
+)
+
+// Backend is the platform firewall driven by the Manager
+// Platform providers satisfy it with their BlockIP/UnblockIP methods
+type Backend interface {
+	BlockIP(ip string, duration time.Duration, reason string) error
+	UnblockIP(ip string) error
+}
+
+// AttributedBackend is a Backend that records which service and severity caused a block
+// The Manager prefers it, so stored records and ip_blocked notifications carry the attack's severity
+type AttributedBackend interface {
+	Backend
+	BlockAttributed(ip string, duration time.Duration, reason, service string, severity models.Severity) error
+}
+
+// Manager implements core.FirewallManager on top of a platform Backend
+// It owns the active block set and enforces blocking.max_concurrent_blocks
+type Manager struct {
+	mu        sync.Mutex
+	config    *models.Config
+	backend   Backend
+	blocks    map[string]*models.BlockRecord
+	evictions int64
+
+	override *ModeOverride         // runtime mode from the CLI, TUI or tray
+	observer Observer              // records observe-mode decisions
+	observed map[string]*time.Time // targets recorded in observe mode, with the expiry a real block would have
+}
+
+// Observer receives the blocks that observe mode decided on but did not carry out
+type Observer func(record *models.BlockRecord)
+
+// NewManager creates a block manager for the given backend
+func NewManager(config *models.Config, backend Backend) *Manager {
+	validateModes(config)
+	return &Manager{
+		config:   config,
+		backend:  backend,
+		blocks:   make(map[string]*models.BlockRecord),
+		observed: make(map[string]*time.Time),
+	}
+}
+
+// SetObserver sets where observe-mode decisions are recorded
+func (m *Manager) SetObserver(observer Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observer = observer
+}
+
+// Restore registers blocks that were active before a restart
+// The backend is expected to still hold the matching rules
+func (m *Manager) Restore(records []*models.BlockRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, record := range records {
+		if !record.IsActive {
+			continue
+		}
+		copied := *record
+		m.blocks[record.IP] = &copied
+	}
+}
+
+// Forget drops a block without touching the backend
+// Used by reconciliation after it removed the rule itself
+func (m *Manager) Forget(ip string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocks, ip)
+}
+
+// Block blocks an address or network with default (medium) severity
+func (m *Manager) Block(ip string, duration time.Duration, reason string) error {
+	return m.BlockAttempt(ip, duration, reason, "", models.SeverityMedium)
+}
+
+// BlockAttempt blocks an address or network on behalf of a service
+// When the concurrent block cap is reached, room is made according to the eviction policy.
+// In observe mode the decision is handed to the observer instead; paused refuses it
+func (m *Manager) BlockAttempt(ip string, duration time.Duration, reason, service string, severity models.Severity) error {
+	target, err := utils.NormalizeBlockTarget(ip)
+	if err != nil {
+		return core.NewError(core.ErrInvalidIP, "invalid IP address", err)
+	}
+	if err := m.config.Blocking.CheckBlockTarget(target); err != nil {
+		return core.NewError(core.ErrInvalidIP, "refusing to block network", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.coveredBy(target) != nil {
+		return core.NewError(core.ErrIPAlreadyBlocked, fmt.Sprintf("IP %s is already blocked", target), nil)
+	}
+
+	switch m.modeLocked(service, time.Now()) {
+	case models.ModePaused:
+		return core.NewErrorf(core.ErrProtectionPaused, nil, "protection is paused, %s was not blocked", target)
+	case models.ModeObserve:
+		return m.observeLocked(target, duration, reason, service, severity)
+	}
+
+	var absorbed []string
+	if limit := m.config.Blocking.MaxConcurrentBlocks; limit > 0 && m.activeCount() >= limit {
+		target, absorbed, err = m.makeRoom(target, severity)
+		if err != nil {
+			return err
+		}
+	}
+
+	if attributed, ok := m.backend.(AttributedBackend); ok {
+		err = attributed.BlockAttributed(target, duration, reason, service, severity)
+	} else {
+		err = m.backend.BlockIP(target, duration, reason)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record := &models.BlockRecord{
+		IP:          target,
+		Family:      utils.TargetFamily(target),
+		BlockedAt:   now,
+		Reason:      reason,
+		Service:     service,
+		Severity:    severity,
+		AttackCount: 1,
+		IsActive:    true,
+	}
+	if duration > 0 {
+		expiry := now.Add(duration)
+		record.ExpiresAt = &expiry
+	}
+	m.blocks[target] = record
+
+	// Merged blocks are only lifted once the network covering them is in place
+	for _, member := range absorbed {
+		if err := m.evict(member, EvictAggregate, target); err != nil {
+			logger.Warn("Failed to lift block merged into a network block", "ip", member, "network", target, "error", err)
+		}
+	}
+	return nil
+}
+
+// Unblock removes an active block
+func (m *Manager) Unblock(ip string) error {
+	target, err := utils.NormalizeBlockTarget(ip)
+	if err != nil {
+		return core.NewError(core.ErrInvalidIP, "invalid IP address", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.unblockLocked(target)
+}
+
+// IsBlocked reports whether an address is covered by an active block
+func (m *Manager) IsBlocked(ip string) (bool, error) {
+	target, err := utils.NormalizeBlockTarget(ip)
+	if err != nil {
+		return false, core.NewError(core.ErrInvalidIP, "invalid IP address", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.coveredBy(target) != nil, nil
+}
+
+// ListBlocked returns copies of all active block records
+func (m *Manager) ListBlocked() ([]*models.BlockRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := make([]*models.BlockRecord, 0, len(m.blocks))
+	for _, record := range m.blocks {
+		if record.IsActive {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].BlockedAt.Before(records[j].BlockedAt)
+	})
+	return records, nil
+}
+
+// Cleanup lifts expired blocks through the backend and forgets them
+// Run by the platform cleanup schedulers after their own sweep; until then an expired block
+// is still installed and keeps counting against the cap
+func (m *Manager) Cleanup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for target, record := range m.blocks {
+		if !record.IsActive || record.ExpiresAt == nil || now.Before(*record.ExpiresAt) {
+			continue
+		}
+		// The platform cleanup scheduler may already have removed the rule
+		err := m.backend.UnblockIP(target)
+		if err != nil && !core.IsErrorCode(err, core.ErrIPNotBlocked) {
+			logger.Warn("Failed to remove expired block", "ip", target, "error", err)
+			continue
+		}
+		delete(m.blocks, target)
+		if err == nil {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		logger.LogCleanupOperation(m.config, removed, len(m.blocks))
+	}
+	return nil
+}
+
+// ActiveCount returns the number of active blocks
+func (m *Manager) ActiveCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.activeCount()
+}
+
+// Evictions returns how many blocks were evicted to respect the concurrent block cap
+func (m *Manager) Evictions() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.evictions
+}
+
+// makeRoom frees a slot for target and returns the target that should actually be blocked
+// The aggregate policy may widen the target to a network covering existing blocks; those
+// are returned rather than evicted, so they stay blocked until the network block succeeds
+func (m *Manager) makeRoom(target string, severity models.Severity) (string, []string, error) {
+	policy := m.config.Blocking.EvictionPolicy
+	if policy == "" {
+		policy = EvictOldest
+	}
+
+	switch policy {
+	case EvictAggregate:
+		if network, members := m.aggregateCandidates(target); len(members) > 0 {
+			return network, members, nil
+		}
+		// Nothing nearby to merge with, fall back to evicting the oldest block
+		return target, nil, m.evict(m.oldest(), EvictOldest, target)
+
+	case EvictLowestSeverity:
+		victim := m.lowestSeverity()
+		if victim == "" || m.blocks[victim].Severity > severity {
+			return "", nil, core.NewError(core.ErrBlockLimitReached,
+				fmt.Sprintf("block limit of %d reached and no block is less severe than %s", m.config.Blocking.MaxConcurrentBlocks, target), nil)
+		}
+		return target, nil, m.evict(victim, policy, target)
+
+	case EvictOldest:
+		return target, nil, m.evict(m.oldest(), policy, target)
+
+	default:
+		return "", nil, core.NewError(core.ErrConfigInvalid, fmt.Sprintf("unknown eviction policy: %s", policy), nil)
+	}
+}
+
+// evict removes a block to make room for replacement and records the eviction
+func (m *Manager) evict(victim, policy, replacement string) error {
+	if victim == "" {
+		return core.NewError(core.ErrBlockLimitReached,
+			fmt.Sprintf("block limit of %d reached", m.config.Blocking.MaxConcurrentBlocks), nil)
+	}
+	if err := m.unblockLocked(victim); err != nil {
+		return err
+	}
+	m.evictions++
+
+	logger.LogIPEvicted(m.config, victim, policy, replacement)
+	logger.Warn("Evicted block to respect max_concurrent_blocks",
+		"evicted", victim,
+		"replacement", replacement,
+		"policy", policy,
+		"limit", m.config.Blocking.MaxConcurrentBlocks,
+		"totalEvictions", m.evictions)
+	return nil
+}
+
+// aggregateCandidates returns the wider network around target and the active blocks it would absorb
+func (m *Manager) aggregateCandidates(target string) (string, []string) {
+	network, err := utils.AggregateIP(stripPrefix(target),
+		m.aggregatePrefix(utils.FamilyIPv4), m.aggregatePrefix(utils.FamilyIPv6))
+	if err != nil {
+		return "", nil
+	}
+	networkOnes, _ := utils.TargetPrefix(network)
+	if targetOnes, _ := utils.TargetPrefix(target); networkOnes >= targetOnes || m.config.Blocking.CheckBlockTarget(network) != nil {
+		return "", nil
+	}
+
+	var members []string
+	for blocked, record := range m.blocks {
+		ones, _ := utils.TargetPrefix(blocked)
+		if record.IsActive && ones >= networkOnes && utils.TargetContains(network, stripPrefix(blocked)) {
+			members = append(members, blocked)
+		}
+	}
+	sort.Strings(members)
+	return network, members
+}
+
+// aggregatePrefix returns the network size used by the aggregate eviction policy
+func (m *Manager) aggregatePrefix(family string) int {
+	if family == utils.FamilyIPv4 {
+		if m.config.Blocking.EvictionIPv4Prefix > 0 {
+			return m.config.Blocking.EvictionIPv4Prefix
+		}
+		return models.DefaultEvictionIPv4Prefix
+	}
+	if m.config.Blocking.EvictionIPv6Prefix > 0 {
+		return m.config.Blocking.EvictionIPv6Prefix
+	}
+	return models.DefaultEvictionIPv6Prefix
+}
+
+func (m *Manager) unblockLocked(target string) error {
+	record, exists := m.blocks[target]
+	if !exists || !record.IsActive {
+		return core.NewError(core.ErrIPNotBlocked, fmt.Sprintf("IP %s is not blocked", target), nil)
+	}
+	if err := m.backend.UnblockIP(target); err != nil && !core.IsErrorCode(err, core.ErrIPNotBlocked) {
+		return err
+	}
+	delete(m.blocks, target)
+	return nil
+}
+
+// observeLocked records a block decision without touching the firewall
+// The target then counts as observed for the block duration, so an address that keeps failing
+// is recorded once per duration, as it would be blocked once
+func (m *Manager) observeLocked(target string, duration time.Duration, reason, service string, severity models.Severity) error {
+	now := time.Now()
+	for observed, expiry := range m.observed {
+		if expiry != nil && now.After(*expiry) {
+			delete(m.observed, observed)
+		}
+	}
+	if _, seen := m.observed[target]; seen {
+		return core.NewErrorf(core.ErrIPAlreadyBlocked, nil, "IP %s was already recorded in observe mode", target)
+	}
+
+	record := &models.BlockRecord{
+		IP:          target,
+		Family:      utils.TargetFamily(target),
+		BlockedAt:   now,
+		Reason:      reason,
+		Service:     service,
+		Severity:    severity,
+		AttackCount: 1,
+	}
+	if duration > 0 {
+		expiry := now.Add(duration)
+		record.ExpiresAt = &expiry
+	}
+	m.observed[target] = record.ExpiresAt
+	logger.Info("Observe mode: would have blocked", "ip", target, "service", service, "reason", reason)
+	if m.observer != nil {
+		m.observer(record)
+	}
+	return nil
+}
+
+// coveredBy returns the active block that covers target, if any
+func (m *Manager) coveredBy(target string) *models.BlockRecord {
+	if record, exists := m.blocks[target]; exists && record.IsActive {
+		return record
+	}
+	host := stripPrefix(target)
+	targetOnes, _ := utils.TargetPrefix(target)
+	for blocked, record := range m.blocks {
+		ones, _ := utils.TargetPrefix(blocked)
+		if record.IsActive && blocked != target && utils.TargetContains(blocked, host) && ones <= targetOnes {
+			return record
+		}
+	}
+	return nil
+}
+
+func (m *Manager) activeCount() int {
+	count := 0
+	for _, record := range m.blocks {
+		if record.IsActive {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *Manager) oldest() string {
+	var victim string
+	var victimTime time.Time
+	for target, record := range m.blocks {
+		if !record.IsActive {
+			continue
+		}
+		if victim == "" || record.BlockedAt.Before(victimTime) {
+			victim, victimTime = target, record.BlockedAt
+		}
+	}
+	return victim
+}
+
+func (m *Manager) lowestSeverity() string {
+	var victim string
+	for target, record := range m.blocks {
+		if !record.IsActive {
+			continue
+		}
+		if victim == "" {
+			victim = target
+			continue
+		}
+		current := m.blocks[victim]
+		if record.Severity < current.Severity ||
+			(record.Severity == current.Severity && record.BlockedAt.Before(current.BlockedAt)) {
+			victim = target
+		}
+	}
+	return victim
+}
+
+// stripPrefix returns the address part of an address or CIDR target
+func stripPrefix(target string) string {
+	host, _, _ := strings.Cut(target, "/")
+	return host
+}
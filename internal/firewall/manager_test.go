@@ -0,0 +1,132 @@
+package firewall
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// fakeBackend records firewall calls and can be told to fail blocks
+type fakeBackend struct {
+	blocked   map[string]bool
+	blocks    int
+	failBlock bool
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{blocked: make(map[string]bool)}
+}
+
+func (f *fakeBackend) BlockIP(ip string, duration time.Duration, reason string) error {
+	if f.failBlock {
+		return errors.New("firewall unavailable")
+	}
+	f.blocks++
+	f.blocked[ip] = true
+	return nil
+}
+
+func (f *fakeBackend) UnblockIP(ip string) error {
+	if !f.blocked[ip] {
+		return core.NewError(core.ErrIPNotBlocked, "not blocked", nil)
+	}
+	delete(f.blocked, ip)
+	return nil
+}
+
+func aggregateConfig(limit int) *models.Config {
+	return &models.Config{Blocking: models.BlockingConfig{
+		MaxConcurrentBlocks: limit,
+		EvictionPolicy:      EvictAggregate,
+	}}
+}
+
+func TestAggregateKeepsMembersWhenNetworkBlockFails(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(aggregateConfig(2), backend)
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if err := m.Block(ip, time.Hour, "test"); err != nil {
+			t.Fatalf("Block(%s): %v", ip, err)
+		}
+	}
+
+	backend.failBlock = true
+	if err := m.Block("203.0.113.3", time.Hour, "test"); err == nil {
+		t.Fatal("expected the network block to fail")
+	}
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if blocked, _ := m.IsBlocked(ip); !blocked || !backend.blocked[ip] {
+			t.Errorf("%s was lifted although the network block failed", ip)
+		}
+	}
+	if m.Evictions() != 0 {
+		t.Errorf("Evictions() = %d, want 0", m.Evictions())
+	}
+}
+
+func TestAggregateLiftsMembersAfterNetworkBlock(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(aggregateConfig(2), backend)
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if err := m.Block(ip, time.Hour, "test"); err != nil {
+			t.Fatalf("Block(%s): %v", ip, err)
+		}
+	}
+
+	if err := m.Block("203.0.113.3", time.Hour, "test"); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	if !backend.blocked["203.0.113.0/24"] || backend.blocked["203.0.113.1"] || backend.blocked["203.0.113.2"] {
+		t.Errorf("firewall holds %v, want only 203.0.113.0/24", backend.blocked)
+	}
+	if m.ActiveCount() != 1 || m.Evictions() != 2 {
+		t.Errorf("ActiveCount() = %d, Evictions() = %d; want 1 and 2", m.ActiveCount(), m.Evictions())
+	}
+}
+
+func TestLiftedBlocksAreDropped(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(&models.Config{}, backend)
+	for _, ip := range []string{"198.51.100.7", "198.51.100.8", "198.51.100.9"} {
+		if err := m.Block(ip, time.Millisecond, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Unblock("198.51.100.7"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired blocks are still installed until cleanup lifts them
+	if got := m.ActiveCount(); got != 2 {
+		t.Errorf("ActiveCount() = %d before cleanup, want 2", got)
+	}
+	if blocked, _ := m.IsBlocked("198.51.100.8"); !blocked {
+		t.Error("an expired block still in the firewall should count as blocked")
+	}
+
+	// The platform sweep already removed one rule; cleanup lifts the other
+	delete(backend.blocked, "198.51.100.9")
+	if err := m.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.blocks) != 0 || len(backend.blocked) != 0 {
+		t.Errorf("after cleanup manager holds %d blocks, firewall %v", len(m.blocks), backend.blocked)
+	}
+}
+
+func TestRefusesWideNetworks(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(&models.Config{}, backend)
+	for _, target := range []string{"10.0.0.0/8", "2001:db8::/16", "0.0.0.0/0"} {
+		if err := m.Block(target, time.Hour, "test"); err == nil {
+			t.Errorf("Block(%s) succeeded", target)
+		}
+	}
+	if backend.blocks != 0 {
+		t.Errorf("firewall received %d blocks", backend.blocks)
+	}
+}
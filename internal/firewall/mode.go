@@ -0,0 +1,188 @@
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// modeWatchInterval is how often the daemon reads the runtime mode file
+const modeWatchInterval = 2 * time.Second
+
+// ModeOverride is a mode switched at runtime from the CLI, TUI or tray
+// It applies to every service until it expires or is cleared; then the configured modes apply again
+type ModeOverride struct {
+	Mode  string    `json:"mode"`
+	Until time.Time `json:"until,omitempty"` // zero: until changed
+	SetBy string    `json:"set_by,omitempty"`
+	SetAt time.Time `json:"set_at"`
+}
+
+// Active reports whether the override is in effect at t
+func (o *ModeOverride) Active(t time.Time) bool {
+	return o != nil && (o.Until.IsZero() || t.Before(o.Until))
+}
+
+// String describes the override for logs and status output
+func (o *ModeOverride) String() string {
+	text := o.Mode
+	if !o.Until.IsZero() {
+		text += " until " + o.Until.Format(time.DateTime)
+	}
+	if o.SetBy != "" {
+		text += " (set from " + o.SetBy + ")"
+	}
+	return text
+}
+
+// ModePath is where the runtime mode override is kept
+func ModePath() string {
+	return filepath.Join(utils.NewPlatformPaths().GetDefaultDataDir(), "mode.json")
+}
+
+// LoadModeOverride reads the runtime override; nil when there is none
+// An expired override is returned as is, so callers can report it
+func LoadModeOverride(path string) (*ModeOverride, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var override ModeOverride
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("invalid mode file %s: %w", path, err)
+	}
+	if !models.ValidMode(override.Mode) {
+		return nil, fmt.Errorf("invalid mode %q in %s", override.Mode, path)
+	}
+	return &override, nil
+}
+
+// SaveModeOverride writes the runtime override; nil removes it
+func SaveModeOverride(path string, override *ModeOverride) error {
+	if override == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// WatchMode applies the runtime mode file until ctx is done
+// A timed override that has expired is removed, so protection resumes without the CLI
+func (m *Manager) WatchMode(ctx context.Context, path string) {
+	ticker := time.NewTicker(modeWatchInterval)
+	defer ticker.Stop()
+	for {
+		override, err := LoadModeOverride(path)
+		if err != nil {
+			logger.Warn("Ignoring runtime mode file", "path", path, "error", err)
+			override = nil
+		}
+		if override != nil && !override.Active(time.Now()) {
+			logger.Info("Timed protection mode ended; configured modes apply again", "mode", override.Mode)
+			if err := SaveModeOverride(path, nil); err != nil {
+				logger.Warn("Failed to remove expired mode file", "path", path, "error", err)
+			}
+			override = nil
+		}
+		m.SetOverride(override)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SetOverride applies a runtime mode to every service; nil returns to the configured modes
+func (m *Manager) SetOverride(override *ModeOverride) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sameOverride(m.override, override) {
+		return
+	}
+	m.override = override
+	// Decisions recorded under the previous mode do not hold back the next observation
+	m.observed = make(map[string]*time.Time)
+	if override == nil {
+		logger.Info("Protection mode override cleared", "mode", m.config.ModeFor(""))
+	} else {
+		logger.Info("Protection mode changed", "mode", override.String())
+	}
+}
+
+// Override returns the runtime mode in effect, or nil
+func (m *Manager) Override() *ModeOverride {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.override.Active(time.Now()) {
+		return nil
+	}
+	copied := *m.override
+	return &copied
+}
+
+// Mode returns the mode applied to a service's blocks: the runtime override, the service's
+// mode or the global mode. An empty service name gives the global mode
+func (m *Manager) Mode(service string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.modeLocked(service, time.Now())
+}
+
+func (m *Manager) modeLocked(service string, now time.Time) string {
+	if m.override.Active(now) {
+		return m.override.Mode
+	}
+	mode := m.config.ModeFor(service)
+	if !models.ValidMode(mode) {
+		return models.ModeEnforce // reported when the manager was created
+	}
+	return mode
+}
+
+// validateModes reports configured modes that are not recognised; they enforce
+func validateModes(config *models.Config) {
+	if config.Mode != "" && !models.ValidMode(config.Mode) {
+		logger.Warn("Unknown protection mode, enforcing instead", "mode", config.Mode)
+	}
+	for _, service := range config.Services {
+		if service.Mode != "" && !models.ValidMode(service.Mode) {
+			logger.Warn("Unknown protection mode, enforcing instead", "service", service.Name, "mode", service.Mode)
+		}
+	}
+}
+
+func sameOverride(a, b *ModeOverride) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Mode == b.Mode && a.Until.Equal(b.Until) && a.SetBy == b.SetBy && a.SetAt.Equal(b.SetAt)
+}
@@ -0,0 +1,213 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// errorCode returns the GuardianError code of err, or ""
+func errorCode(err error) core.ErrorCode {
+	var guardianErr *core.GuardianError
+	if errors.As(err, &guardianErr) {
+		return guardianErr.Code
+	}
+	return ""
+}
+
+func modeConfig() *models.Config {
+	return &models.Config{
+		Mode: models.ModeObserve,
+		Services: []models.ServiceConfig{
+			{Name: "SSH", Mode: models.ModeEnforce},
+			{Name: "RDP", Mode: models.ModePaused},
+			{Name: "nginx"},
+		},
+	}
+}
+
+func TestModeOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mode.json")
+
+	if override, err := LoadModeOverride(path); override != nil || err != nil {
+		t.Fatalf("LoadModeOverride without a file = %v, %v; want nil, nil", override, err)
+	}
+
+	saved := &ModeOverride{
+		Mode:  models.ModePaused,
+		Until: time.Now().Add(time.Hour).Truncate(time.Second),
+		SetBy: "cli",
+		SetAt: time.Now().Truncate(time.Second),
+	}
+	if err := SaveModeOverride(path, saved); err != nil {
+		t.Fatalf("SaveModeOverride: %v", err)
+	}
+	loaded, err := LoadModeOverride(path)
+	if err != nil {
+		t.Fatalf("LoadModeOverride: %v", err)
+	}
+	if !sameOverride(saved, loaded) {
+		t.Errorf("loaded %+v, want %+v", loaded, saved)
+	}
+
+	if err := SaveModeOverride(path, nil); err != nil {
+		t.Fatalf("clearing the override: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("mode file still present after clearing: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"mode": "relaxed"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModeOverride(path); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestModeForService(t *testing.T) {
+	m := NewManager(modeConfig(), newFakeBackend())
+
+	for service, want := range map[string]string{
+		"SSH":   models.ModeEnforce,
+		"ssh":   models.ModeEnforce,
+		"RDP":   models.ModePaused,
+		"nginx": models.ModeObserve, // no mode of its own: the global one
+		"":      models.ModeObserve,
+	} {
+		if got := m.Mode(service); got != want {
+			t.Errorf("Mode(%q) = %s, want %s", service, got, want)
+		}
+	}
+
+	// A runtime override applies to every service until cleared
+	m.SetOverride(&ModeOverride{Mode: models.ModePaused, SetAt: time.Now()})
+	for _, service := range []string{"SSH", "nginx"} {
+		if got := m.Mode(service); got != models.ModePaused {
+			t.Errorf("with a paused override Mode(%q) = %s", service, got)
+		}
+	}
+	m.SetOverride(nil)
+	if got := m.Mode("SSH"); got != models.ModeEnforce {
+		t.Errorf("after clearing Mode(SSH) = %s, want enforce", got)
+	}
+}
+
+func TestBlockAttemptFollowsServiceMode(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(modeConfig(), backend)
+	var observed []*models.BlockRecord
+	m.SetObserver(func(record *models.BlockRecord) { observed = append(observed, record) })
+
+	if err := m.BlockAttempt("203.0.113.5", time.Hour, "brute force", "SSH", models.SeverityHigh); err != nil {
+		t.Fatalf("enforced block: %v", err)
+	}
+	err := m.BlockAttempt("203.0.113.6", time.Hour, "brute force", "RDP", models.SeverityHigh)
+	if errorCode(err) != core.ErrProtectionPaused {
+		t.Errorf("paused service: err = %v, want %s", err, core.ErrProtectionPaused)
+	}
+	if err := m.BlockAttempt("203.0.113.7", time.Hour, "brute force", "nginx", models.SeverityHigh); err != nil {
+		t.Fatalf("observed block: %v", err)
+	}
+
+	if backend.blocks != 1 || !backend.blocked["203.0.113.5"] {
+		t.Errorf("firewall holds %v, want only the enforced 203.0.113.5", backend.blocked)
+	}
+	if len(observed) != 1 || observed[0].IP != "203.0.113.7" || observed[0].Service != "nginx" {
+		t.Errorf("observer received %v, want the nginx decision", observed)
+	}
+}
+
+func TestObserveModeRecordsWithoutFirewall(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(&models.Config{Mode: models.ModeObserve}, backend)
+	var observed []*models.BlockRecord
+	m.SetObserver(func(record *models.BlockRecord) { observed = append(observed, record) })
+
+	if err := m.BlockAttempt("198.51.100.7", time.Hour, "5 failed logins", "SSH", models.SeverityHigh); err != nil {
+		t.Fatalf("BlockAttempt: %v", err)
+	}
+	if backend.blocks != 0 {
+		t.Errorf("observe mode made %d firewall calls", backend.blocks)
+	}
+	if len(observed) != 1 {
+		t.Fatalf("observer received %d records, want 1", len(observed))
+	}
+	record := observed[0]
+	if record.IP != "198.51.100.7" || record.Reason != "5 failed logins" || record.ExpiresAt == nil {
+		t.Errorf("unexpected observed record %+v", record)
+	}
+	if blocked, _ := m.IsBlocked("198.51.100.7"); blocked {
+		t.Error("an observed address reports as blocked")
+	}
+
+	// Later failures from the same address are not recorded again for the block duration
+	err := m.BlockAttempt("198.51.100.7", time.Hour, "6 failed logins", "SSH", models.SeverityHigh)
+	if errorCode(err) != core.ErrIPAlreadyBlocked || len(observed) != 1 {
+		t.Errorf("repeat decision: err = %v, %d records; want %s and 1 record", err, len(observed), core.ErrIPAlreadyBlocked)
+	}
+}
+
+func TestTimedOverrideExpires(t *testing.T) {
+	backend := newFakeBackend()
+	m := NewManager(&models.Config{}, backend)
+	m.SetOverride(&ModeOverride{Mode: models.ModeObserve, Until: time.Now().Add(50 * time.Millisecond), SetAt: time.Now()})
+
+	if got := m.Mode("SSH"); got != models.ModeObserve {
+		t.Fatalf("Mode = %s during the override, want observe", got)
+	}
+	if err := m.BlockAttempt("203.0.113.5", time.Hour, "test", "SSH", models.SeverityHigh); err != nil || backend.blocks != 0 {
+		t.Fatalf("block during observe override: err = %v, firewall calls = %d", err, backend.blocks)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := m.Mode("SSH"); got != models.ModeEnforce {
+		t.Errorf("Mode = %s after expiry, want the configured enforce", got)
+	}
+	if m.Override() != nil {
+		t.Error("Override() still reports the expired override")
+	}
+	if err := m.BlockAttempt("203.0.113.5", time.Hour, "test", "SSH", models.SeverityHigh); err != nil || backend.blocks != 1 {
+		t.Errorf("block after expiry: err = %v, firewall calls = %d; want a real block", err, backend.blocks)
+	}
+}
+
+func TestWatchModeAppliesAndRemovesExpiredFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mode.json")
+	m := NewManager(&models.Config{}, newFakeBackend())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A file left from a timed pause that ended while the daemon was down
+	expired := &ModeOverride{Mode: models.ModePaused, Until: time.Now().Add(-time.Minute), SetAt: time.Now().Add(-time.Hour)}
+	if err := SaveModeOverride(path, expired); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.WatchMode(ctx, path)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired mode file was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.Mode("SSH"); got != models.ModeEnforce {
+		t.Errorf("Mode = %s after the expired file, want enforce", got)
+	}
+	cancel()
+	<-done
+}
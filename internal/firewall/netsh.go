@@ -0,0 +1,471 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// GuardianRuleTag marks every firewall rule created by Guardian
+// It is written into the rule description so rules can be identified after restarts
+const GuardianRuleTag = "GuardianTag=Guardian"
+
+const (
+	// NetshMaxRemoteIPs is the largest remoteip list Guardian writes into a single rule
+	NetshMaxRemoteIPs = 1000
+
+	// netshMaxRemoteIPLength keeps the remoteip argument well inside the
+	// 32767 character CreateProcess command line limit (IPv6 lists grow quickly)
+	netshMaxRemoteIPLength = 24000
+
+	// DefaultBatchRulePrefix names batch rules "Guardian Batch 001", "Guardian Batch 002", ...
+	DefaultBatchRulePrefix = "Guardian Batch"
+)
+
+// CommandExecutor runs an external command and returns its combined output
+// The Windows backend uses ExecCommandExecutor; tests can inject a recorder
+type CommandExecutor interface {
+	Run(name string, args ...string) ([]byte, error)
+}
+
+// ExecCommandExecutor runs commands with os/exec
+type ExecCommandExecutor struct{}
+
+// Run executes the command and returns its combined stdout/stderr
+func (ExecCommandExecutor) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// NetshRule is a firewall rule as reported by "netsh advfirewall firewall show rule"
+type NetshRule struct {
+	Name        string
+	Description string
+	RemoteIPs   []string // canonical addresses/networks
+}
+
+// IsGuardianRule reports whether the rule carries the Guardian description tag
+func (r NetshRule) IsGuardianRule() bool {
+	return strings.Contains(r.Description, GuardianRuleTag)
+}
+
+// batchRule is one Guardian rule holding many remote addresses
+type batchRule struct {
+	name      string
+	addresses map[string]struct{}
+	length    int  // length of the joined remoteip list
+	exists    bool // rule is present in the firewall
+	dirty     bool // address list changed since the last flush
+}
+
+// NetshBatcher consolidates blocked addresses into a small number of Guardian rules
+// Add/Remove only change the in-memory rule set; Flush rewrites each changed rule
+// with a single netsh call, so a scan that blocks 200 addresses costs one call per rule
+type NetshBatcher struct {
+	mu         sync.Mutex
+	exec       CommandExecutor
+	prefix     string
+	maxPerRule int
+	rules      []*batchRule
+	assigned   map[string]*batchRule // address -> owning rule
+	loaded     bool                  // existing rules were read from the firewall
+}
+
+// NewNetshBatcher creates a batcher writing rules named "<prefix> NNN"
+// maxPerRule is clamped to NetshMaxRemoteIPs; zero selects the maximum
+func NewNetshBatcher(executor CommandExecutor, prefix string, maxPerRule int) *NetshBatcher {
+	if executor == nil {
+		executor = ExecCommandExecutor{}
+	}
+	if prefix == "" {
+		prefix = DefaultBatchRulePrefix
+	}
+	if maxPerRule <= 0 || maxPerRule > NetshMaxRemoteIPs {
+		maxPerRule = NetshMaxRemoteIPs
+	}
+	return &NetshBatcher{
+		exec:       executor,
+		prefix:     prefix,
+		maxPerRule: maxPerRule,
+		assigned:   make(map[string]*batchRule),
+	}
+}
+
+// ListRules returns all inbound firewall rules as reported by netsh
+// Listing every rule is slow with large rule sets, so it is only used at startup and by reconciliation
+func (b *NetshBatcher) ListRules() ([]NetshRule, error) {
+	output, err := b.exec.Run("netsh", "advfirewall", "firewall", "show", "rule", "name=all", "dir=in", "verbose")
+	if err != nil {
+		return nil, core.NewError(core.ErrFirewallAccess, "failed to list firewall rules", err)
+	}
+	return ParseNetshRules(string(output)), nil
+}
+
+// IsBatchRule reports whether a rule name belongs to this batcher
+func (b *NetshBatcher) IsBatchRule(name string) bool {
+	return strings.HasPrefix(name, b.prefix)
+}
+
+// Load imports existing Guardian batch rules from the firewall
+func (b *NetshBatcher) Load() error {
+	rules, err := b.ListRules()
+	if err != nil {
+		return err
+	}
+	b.Import(rules)
+	return nil
+}
+
+// Import registers the addresses of already existing Guardian batch rules
+func (b *NetshBatcher) Import(rules []NetshRule) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.importLocked(rules)
+}
+
+func (b *NetshBatcher) importLocked(rules []NetshRule) {
+	b.loaded = true
+	for _, rule := range rules {
+		if !rule.IsGuardianRule() || !b.IsBatchRule(rule.Name) {
+			continue
+		}
+		batch := b.ruleNamed(rule.Name)
+		batch.exists = true
+		for _, address := range rule.RemoteIPs {
+			if _, taken := b.assigned[address]; taken {
+				continue
+			}
+			batch.add(address)
+			b.assigned[address] = batch
+		}
+	}
+}
+
+// Sync brings the batch rule set in line with the rules actually in the firewall
+// Rules with unflushed changes are kept as they are. A rule deleted by hand is marked
+// for re-creation, and addresses listed by two rules are dropped from the second one
+// on the next flush
+func (b *NetshBatcher) Sync(rules []NetshRule) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.loaded = true
+	actual := make(map[string]NetshRule)
+	for _, rule := range rules {
+		if rule.IsGuardianRule() && b.IsBatchRule(rule.Name) {
+			actual[rule.Name] = rule
+		}
+	}
+
+	for _, rule := range b.rules {
+		if rule.dirty {
+			continue
+		}
+		listed, exists := actual[rule.name]
+		if !exists {
+			rule.exists = false
+			rule.dirty = len(rule.addresses) > 0
+			continue
+		}
+		for address := range rule.addresses {
+			if !slices.Contains(listed.RemoteIPs, address) {
+				// Removed by hand: the next flush writes it back
+				rule.dirty = true
+			}
+		}
+	}
+
+	for name, listed := range actual {
+		rule := b.ruleNamed(name)
+		rule.exists = true
+		for _, address := range listed.RemoteIPs {
+			owner, taken := b.assigned[address]
+			if taken && owner != rule {
+				rule.dirty = true
+				continue
+			}
+			if !taken {
+				rule.add(address)
+				b.assigned[address] = rule
+			}
+		}
+	}
+}
+
+// DeleteRule removes a rule by its exact name (used for rules outside the batch set)
+func (b *NetshBatcher) DeleteRule(name string) error {
+	if output, err := b.exec.Run("netsh", NetshDeleteRuleArgs(name)...); err != nil {
+		return core.NewError(core.ErrFirewallOperation,
+			fmt.Sprintf("failed to delete firewall rule %q (%s)", name, strings.TrimSpace(string(output))), err)
+	}
+	return nil
+}
+
+// RuleOf returns the batch rule currently holding an address
+func (b *NetshBatcher) RuleOf(target string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rule, exists := b.assigned[target]
+	if !exists {
+		return "", false
+	}
+	return rule.name, true
+}
+
+// Contains reports whether an address is held by a batch rule
+func (b *NetshBatcher) Contains(target string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, exists := b.assigned[target]
+	return exists
+}
+
+// Add places an address in the first batch rule with room and returns that rule's name
+// Until the existing batch rules have been read from the firewall, Add tries to read them
+// and refuses the address if that fails, so it never creates a second "<prefix> 001"
+func (b *NetshBatcher) Add(target string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.loaded {
+		rules, err := b.ListRules()
+		if err != nil {
+			return "", core.NewError(core.ErrFirewallAccess, "existing Guardian batch rules could not be loaded", err)
+		}
+		b.importLocked(rules)
+	}
+
+	if rule, exists := b.assigned[target]; exists {
+		return rule.name, nil
+	}
+
+	var rule *batchRule
+	for _, candidate := range b.rules {
+		if len(candidate.addresses) < b.maxPerRule && candidate.length+len(target)+1 <= netshMaxRemoteIPLength {
+			rule = candidate
+			break
+		}
+	}
+	if rule == nil {
+		rule = b.ruleNamed(b.nextRuleName())
+	}
+
+	rule.add(target)
+	rule.dirty = true
+	b.assigned[target] = rule
+	return rule.name, nil
+}
+
+// Remove takes an address out of its batch rule and returns that rule's name
+func (b *NetshBatcher) Remove(target string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rule, exists := b.assigned[target]
+	if !exists {
+		return "", false
+	}
+	rule.remove(target)
+	rule.dirty = true
+	delete(b.assigned, target)
+	return rule.name, true
+}
+
+// RuleNames returns the names of all batch rules currently known
+func (b *NetshBatcher) RuleNames() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.rules))
+	for _, rule := range b.rules {
+		names = append(names, rule.name)
+	}
+	return names
+}
+
+// Flush writes every changed batch rule to the firewall
+// Rules that became empty are deleted; the remaining errors are collected and returned
+func (b *NetshBatcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var failures []string
+	for _, rule := range b.rules {
+		if err := b.flushLocked(rule); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return core.NewError(core.ErrFirewallOperation,
+			fmt.Sprintf("failed to update %d batch rule(s)", len(failures)), fmt.Errorf("%s", strings.Join(failures, "; ")))
+	}
+	return nil
+}
+
+// FlushRule writes one batch rule, leaving changes to the other rules for a later flush
+// A single block or unblock then only fails when its own rule cannot be written
+func (b *NetshBatcher) FlushRule(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, rule := range b.rules {
+		if rule.name != name {
+			continue
+		}
+		if err := b.flushLocked(rule); err != nil {
+			return core.NewError(core.ErrFirewallOperation, fmt.Sprintf("failed to update batch rule %q", name), err)
+		}
+	}
+	return nil
+}
+
+// flushLocked writes a rule if it changed since the last flush
+func (b *NetshBatcher) flushLocked(rule *batchRule) error {
+	if !rule.dirty {
+		return nil
+	}
+
+	var args []string
+	switch {
+	case len(rule.addresses) == 0 && !rule.exists:
+		rule.dirty = false
+		return nil
+	case len(rule.addresses) == 0:
+		args = NetshDeleteRuleArgs(rule.name)
+	case rule.exists:
+		args = NetshSetRemoteIPArgs(rule.name, rule.sortedAddresses())
+	default:
+		args = NetshAddRuleArgs(rule.name, rule.sortedAddresses())
+	}
+
+	if output, err := b.exec.Run("netsh", args...); err != nil {
+		return fmt.Errorf("%s: %v (%s)", rule.name, err, strings.TrimSpace(string(output)))
+	}
+	rule.exists = len(rule.addresses) > 0
+	rule.dirty = false
+	return nil
+}
+
+// ruleNamed returns the batch rule with the given name, creating it if needed
+func (b *NetshBatcher) ruleNamed(name string) *batchRule {
+	for _, rule := range b.rules {
+		if rule.name == name {
+			return rule
+		}
+	}
+	rule := &batchRule{name: name, addresses: make(map[string]struct{})}
+	b.rules = append(b.rules, rule)
+	return rule
+}
+
+// nextRuleName returns the first unused "<prefix> NNN" name
+func (b *NetshBatcher) nextRuleName() string {
+	used := make(map[string]bool, len(b.rules))
+	for _, rule := range b.rules {
+		used[rule.name] = true
+	}
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s %03d", b.prefix, i)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+func (r *batchRule) add(address string) {
+	if _, exists := r.addresses[address]; exists {
+		return
+	}
+	r.addresses[address] = struct{}{}
+	r.length += len(address) + 1
+}
+
+func (r *batchRule) remove(address string) {
+	if _, exists := r.addresses[address]; !exists {
+		return
+	}
+	delete(r.addresses, address)
+	r.length -= len(address) + 1
+}
+
+func (r *batchRule) sortedAddresses() []string {
+	addresses := make([]string, 0, len(r.addresses))
+	for address := range r.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// NetshAddRuleArgs builds the netsh arguments that create a batch block rule
+// IPv4 and IPv6 addresses can share one remoteip list; netsh applies each to its own family
+func NetshAddRuleArgs(name string, addresses []string) []string {
+	return []string{"advfirewall", "firewall", "add", "rule",
+		fmt.Sprintf("name=%s", name),
+		"dir=in",
+		"action=block",
+		fmt.Sprintf("remoteip=%s", strings.Join(addresses, ",")),
+		fmt.Sprintf("description=Guardian IPS - Blocked due to failed login attempts (%s)", GuardianRuleTag),
+	}
+}
+
+// NetshSetRemoteIPArgs builds the netsh arguments that rewrite a rule's remoteip list
+func NetshSetRemoteIPArgs(name string, addresses []string) []string {
+	return []string{"advfirewall", "firewall", "set", "rule",
+		fmt.Sprintf("name=%s", name),
+		"new",
+		fmt.Sprintf("remoteip=%s", strings.Join(addresses, ",")),
+	}
+}
+
+// NetshDeleteRuleArgs builds the netsh arguments that delete a rule by name
+func NetshDeleteRuleArgs(name string) []string {
+	return []string{"advfirewall", "firewall", "delete", "rule", fmt.Sprintf("name=%s", name)}
+}
+
+// ParseNetshRules parses "netsh advfirewall firewall show rule ... verbose" output
+// RemoteIP values are canonicalized ("203.0.113.5/32" becomes "203.0.113.5")
+func ParseNetshRules(output string) []NetshRule {
+	var rules []NetshRule
+	var current *NetshRule
+
+	for _, raw := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(raw)
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "rule name":
+			rules = append(rules, NetshRule{Name: value})
+			current = &rules[len(rules)-1]
+		case "description":
+			if current != nil {
+				current.Description = value
+			}
+		case "remoteip":
+			if current == nil {
+				continue
+			}
+			for _, candidate := range strings.Split(value, ",") {
+				if normalized, err := utils.NormalizeBlockTarget(candidate); err == nil {
+					current.RemoteIPs = append(current.RemoteIPs, normalized)
+				}
+			}
+		}
+	}
+
+	return rules
+}
@@ -0,0 +1,159 @@
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeNetsh records netsh calls and answers "show rule" with a fixed listing
+type fakeNetsh struct {
+	listing   string
+	listErr   error
+	failRules map[string]bool // rule names whose add/set/delete fails
+	calls     []string
+}
+
+func (f *fakeNetsh) Run(name string, args ...string) ([]byte, error) {
+	call := strings.Join(args, " ")
+	f.calls = append(f.calls, call)
+	if len(args) > 3 && args[2] == "show" {
+		return []byte(f.listing), f.listErr
+	}
+	for rule := range f.failRules {
+		if strings.Contains(call, "name="+rule+" ") || strings.HasSuffix(call, "name="+rule) {
+			return []byte("An error occurred"), errors.New("exit status 1")
+		}
+	}
+	return []byte("Ok."), nil
+}
+
+// writes returns the calls that changed the firewall
+func (f *fakeNetsh) writes() []string {
+	var writes []string
+	for _, call := range f.calls {
+		if !strings.Contains(call, " show ") {
+			writes = append(writes, call)
+		}
+	}
+	return writes
+}
+
+const netshListing = `
+Rule Name:                            Guardian Batch 001
+----------------------------------------------------------------------
+Description:                          Guardian IPS - Blocked due to failed login attempts (GuardianTag=Guardian)
+Enabled:                              Yes
+Direction:                            In
+RemoteIP:                             203.0.113.5/32,2001:db8::/64
+Action:                               Block
+
+Rule Name:                            Remote Desktop - User Mode (TCP-In)
+----------------------------------------------------------------------
+Description:                          Inbound rule for Remote Desktop
+RemoteIP:                             Any
+Action:                               Allow
+`
+
+func TestParseNetshRules(t *testing.T) {
+	rules := ParseNetshRules(netshListing)
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if !rules[0].IsGuardianRule() || rules[1].IsGuardianRule() {
+		t.Errorf("Guardian tag detection wrong: %+v", rules)
+	}
+	if got := strings.Join(rules[0].RemoteIPs, ","); got != "203.0.113.5,2001:db8::/64" {
+		t.Errorf("RemoteIPs = %s", got)
+	}
+}
+
+func TestBatcherAddsToExistingRule(t *testing.T) {
+	netsh := &fakeNetsh{listing: netshListing}
+	batcher := NewNetshBatcher(netsh, "", 0)
+
+	rule, err := batcher.Add("198.51.100.7")
+	if err != nil || rule != "Guardian Batch 001" {
+		t.Fatalf("Add = %q, %v", rule, err)
+	}
+	if err := batcher.FlushRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	want := "advfirewall firewall set rule name=Guardian Batch 001 new remoteip=198.51.100.7,2001:db8::/64,203.0.113.5"
+	if writes := netsh.writes(); len(writes) != 1 || writes[0] != want {
+		t.Errorf("writes = %q, want %q", writes, want)
+	}
+}
+
+func TestBatcherRefusesAddUntilRulesLoad(t *testing.T) {
+	netsh := &fakeNetsh{listErr: errors.New("access denied")}
+	batcher := NewNetshBatcher(netsh, "", 0)
+
+	if _, err := batcher.Add("198.51.100.7"); err == nil {
+		t.Fatal("Add succeeded although the existing rules could not be read")
+	}
+	if err := batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := netsh.writes(); len(writes) != 0 {
+		t.Errorf("firewall was changed: %q", writes)
+	}
+
+	// Once the listing works the existing rule is reused instead of duplicated
+	netsh.listErr = nil
+	netsh.listing = netshListing
+	if rule, err := batcher.Add("198.51.100.7"); err != nil || rule != "Guardian Batch 001" {
+		t.Errorf("Add = %q, %v", rule, err)
+	}
+}
+
+func TestBatcherFlushRuleIgnoresOtherRules(t *testing.T) {
+	netsh := &fakeNetsh{failRules: map[string]bool{"Guardian Batch 001": true}}
+	batcher := NewNetshBatcher(netsh, "", 2)
+	batcher.Import(nil)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := batcher.Add(fmt.Sprintf("198.51.100.%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batcher.FlushRule("Guardian Batch 002"); err != nil {
+		t.Errorf("FlushRule(002) failed because of another rule: %v", err)
+	}
+	if err := batcher.FlushRule("Guardian Batch 001"); err == nil {
+		t.Error("FlushRule(001) succeeded, want its own failure")
+	}
+	if err := batcher.Flush(); err == nil {
+		t.Error("Flush succeeded, want the failing rule retried and reported")
+	}
+}
+
+func TestBatcherConsolidatesAndDeletesEmptyRules(t *testing.T) {
+	netsh := &fakeNetsh{}
+	batcher := NewNetshBatcher(netsh, "Test", 0)
+	batcher.Import(nil)
+
+	for i := 1; i <= 50; i++ {
+		if _, err := batcher.Add(fmt.Sprintf("198.51.100.%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if writes := netsh.writes(); len(writes) != 1 || !strings.Contains(writes[0], " add rule name=Test 001 ") {
+		t.Fatalf("writes = %q, want one add rule", writes)
+	}
+
+	for i := 1; i <= 50; i++ {
+		batcher.Remove(fmt.Sprintf("198.51.100.%d", i))
+	}
+	if err := batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	writes := netsh.writes()
+	if last := writes[len(writes)-1]; last != "advfirewall firewall delete rule name=Test 001" {
+		t.Errorf("last write = %q, want the empty rule deleted", last)
+	}
+}
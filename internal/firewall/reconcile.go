@@ -0,0 +1,364 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Drift kinds found when comparing storage, provider state and firewall rules
+const (
+	DriftMissingRule   = "missing_rule"   // active block without a firewall rule
+	DriftOrphanRule    = "orphan_rule"    // Guardian rule entry without an active block
+	DriftExpiredRule   = "expired_rule"   // expired block whose rule is still present
+	DriftRuleMismatch  = "rule_mismatch"  // block is held by a different rule than recorded
+	DriftUntracked     = "untracked"      // stored block the provider does not know about
+	DriftUnsavedRecord = "unsaved_record" // provider block missing from storage
+)
+
+// RuleEntry is one blocked address or network held by a Guardian firewall rule
+type RuleEntry struct {
+	RuleName string
+	Target   string
+}
+
+// ReconcileBackend is a Backend that can report and repair the rules it owns
+// Rule changes may be deferred until CommitRules (the netsh backend batches them)
+type ReconcileBackend interface {
+	Backend
+
+	// FirewallRules lists the Guardian rule entries actually present in the firewall
+	FirewallRules() ([]RuleEntry, error)
+
+	// BlockRecords returns copies of the provider's in-memory block records
+	BlockRecords() []*models.BlockRecord
+
+	// TrackRecord replaces the provider's in-memory record for record.IP with a copy of record
+	// Changes to records from BlockRecords only reach the provider through it
+	TrackRecord(record *models.BlockRecord)
+
+	// RestoreRule recreates the rule for an active record and returns the rule name
+	RestoreRule(record *models.BlockRecord) (string, error)
+
+	// RemoveRuleEntry removes one target from a rule (deleting the rule when nothing is left)
+	RemoveRuleEntry(entry RuleEntry) error
+
+	// CommitRules writes rule changes deferred by RestoreRule/RemoveRuleEntry
+	CommitRules() error
+}
+
+// Reconcilable is implemented by providers that support firewall reconciliation
+type Reconcilable interface {
+	Reconcile(dryRun bool) (*ReconcileReport, error)
+}
+
+// Drift is a single disagreement between storage, provider state and the firewall
+type Drift struct {
+	Kind     string `json:"kind"`
+	Target   string `json:"target"`
+	RuleName string `json:"rule_name,omitempty"`
+	Detail   string `json:"detail"`
+	Fixed    bool   `json:"fixed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReconcileReport summarizes one reconciliation run
+type ReconcileReport struct {
+	CheckedAt    time.Time `json:"checked_at"`
+	DryRun       bool      `json:"dry_run"`
+	StoredBlocks int       `json:"stored_blocks"`
+	ActiveBlocks int       `json:"active_blocks"`
+	RuleEntries  int       `json:"rule_entries"`
+	Drifts       []Drift   `json:"drifts"`
+}
+
+// Fixed returns how many drifts were repaired
+func (r *ReconcileReport) Fixed() int {
+	fixed := 0
+	for _, drift := range r.Drifts {
+		if drift.Fixed {
+			fixed++
+		}
+	}
+	return fixed
+}
+
+// Reconciler compares storage, provider state and actual firewall rules
+// In dry-run mode it only reports; otherwise it recreates missing rules,
+// removes orphaned and expired entries and brings storage and provider state in line.
+// With memory storage orphaned and untracked blocks are only reported
+type Reconciler struct {
+	mu      sync.Mutex
+	backend ReconcileBackend
+	store   core.Storage
+	manager *Manager
+}
+
+// NewReconciler creates a reconciler for a backend, its storage and its block manager
+func NewReconciler(backend ReconcileBackend, store core.Storage, manager *Manager) *Reconciler {
+	return &Reconciler{
+		backend: backend,
+		store:   store,
+		manager: manager,
+	}
+}
+
+// Reconcile runs one comparison and, unless dryRun is set, repairs the drift it finds
+func (r *Reconciler) Reconcile(dryRun bool) (*ReconcileReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := r.backend.FirewallRules()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := r.store.GetActiveBlocks()
+	if err != nil {
+		return nil, core.NewError(core.ErrStorageOperation, "failed to load block records", err)
+	}
+
+	report := &ReconcileReport{
+		CheckedAt:    time.Now(),
+		DryRun:       dryRun,
+		StoredBlocks: len(stored),
+		RuleEntries:  len(entries),
+	}
+
+	rulesByTarget := make(map[string][]RuleEntry)
+	for _, entry := range entries {
+		rulesByTarget[entry.Target] = append(rulesByTarget[entry.Target], entry)
+	}
+
+	storedByTarget := make(map[string]*models.BlockRecord, len(stored))
+	for _, record := range stored {
+		storedByTarget[record.IP] = record // later records win
+	}
+
+	providerByTarget := make(map[string]*models.BlockRecord)
+	for _, record := range r.backend.BlockRecords() {
+		if record.IsActive {
+			providerByTarget[record.IP] = record
+		}
+	}
+
+	targets := make([]string, 0, len(storedByTarget)+len(providerByTarget))
+	for target := range storedByTarget {
+		targets = append(targets, target)
+	}
+	for target := range providerByTarget {
+		if _, exists := storedByTarget[target]; !exists {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+
+	owned := make(map[RuleEntry]bool)
+	now := time.Now()
+
+	// Without persistent storage a restarted process knows none of its earlier blocks, so
+	// their rules only look orphaned; those drifts are reported but never repaired
+	persistent := r.persistent()
+
+	for _, target := range targets {
+		record, known := providerByTarget[target]
+		storedRecord, saved := storedByTarget[target]
+
+		switch {
+		case !known:
+			record = storedRecord
+			r.apply(report, dryRun || !persistent, Drift{
+				Kind:     DriftUntracked,
+				Target:   target,
+				RuleName: record.RuleName,
+				Detail:   "block is in storage but not in provider state",
+			}, func() error {
+				r.backend.TrackRecord(record)
+				r.manager.Restore([]*models.BlockRecord{record})
+				return nil
+			})
+		case !saved:
+			r.apply(report, dryRun, Drift{
+				Kind:     DriftUnsavedRecord,
+				Target:   target,
+				RuleName: record.RuleName,
+				Detail:   "block is in provider state but not in storage",
+			}, func() error {
+				return r.persist(record)
+			})
+		}
+
+		present := rulesByTarget[target]
+
+		if record.ExpiresAt != nil && now.After(*record.ExpiresAt) {
+			if len(present) == 0 {
+				// The cleanup scheduler deactivates it on its next run
+				continue
+			}
+			r.apply(report, dryRun, Drift{
+				Kind:     DriftExpiredRule,
+				Target:   target,
+				RuleName: present[0].RuleName,
+				Detail:   fmt.Sprintf("block expired at %s but its rule is still present", record.ExpiresAt.Format(time.RFC3339)),
+			}, func() error {
+				for _, entry := range present {
+					if err := r.backend.RemoveRuleEntry(entry); err != nil {
+						return err
+					}
+				}
+				return r.deactivate(record, now)
+			})
+			for _, entry := range present {
+				owned[entry] = true
+			}
+			continue
+		}
+
+		report.ActiveBlocks++
+
+		if len(present) == 0 {
+			r.apply(report, dryRun, Drift{
+				Kind:     DriftMissingRule,
+				Target:   target,
+				RuleName: record.RuleName,
+				Detail:   "active block has no firewall rule",
+			}, func() error {
+				ruleName, err := r.backend.RestoreRule(record)
+				if err != nil {
+					return err
+				}
+				record.RuleName = ruleName
+				return r.persist(record)
+			})
+			continue
+		}
+
+		holder := present[0]
+		for _, entry := range present {
+			if entry.RuleName == record.RuleName {
+				holder = entry
+			}
+		}
+		owned[holder] = true
+
+		if holder.RuleName != record.RuleName {
+			r.apply(report, dryRun, Drift{
+				Kind:     DriftRuleMismatch,
+				Target:   target,
+				RuleName: holder.RuleName,
+				Detail:   fmt.Sprintf("record names rule %q", record.RuleName),
+			}, func() error {
+				record.RuleName = holder.RuleName
+				return r.persist(record)
+			})
+		}
+	}
+
+	for _, entry := range entries {
+		if owned[entry] {
+			continue
+		}
+		r.apply(report, dryRun || !persistent, Drift{
+			Kind:     DriftOrphanRule,
+			Target:   entry.Target,
+			RuleName: entry.RuleName,
+			Detail:   "Guardian rule entry has no active block",
+		}, func() error {
+			return r.backend.RemoveRuleEntry(entry)
+		})
+	}
+
+	if !dryRun {
+		if err := r.backend.CommitRules(); err != nil {
+			return report, err
+		}
+	}
+
+	if len(report.Drifts) > 0 {
+		logger.Info("Firewall reconciliation found drift",
+			"drifts", len(report.Drifts),
+			"fixed", report.Fixed(),
+			"dryRun", dryRun)
+	} else {
+		logger.Debug("Firewall reconciliation found no drift",
+			"activeBlocks", report.ActiveBlocks,
+			"ruleEntries", report.RuleEntries)
+	}
+
+	return report, nil
+}
+
+// Run reconciles immediately and then on every interval until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	if _, err := r.Reconcile(false); err != nil {
+		logger.Warn("Firewall reconciliation failed", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reconcile(false); err != nil {
+				logger.Warn("Firewall reconciliation failed", "error", err)
+			}
+		}
+	}
+}
+
+// persistent reports whether block records survive a restart (storage.type json)
+// Mirrors the check `guardian firewall reconcile` makes before repairing
+func (r *Reconciler) persistent() bool {
+	storageType := strings.ToLower(r.manager.config.Storage.Type)
+	return storageType != "" && storageType != "memory"
+}
+
+// apply records a drift and runs its fix unless this is a dry run
+func (r *Reconciler) apply(report *ReconcileReport, dryRun bool, drift Drift, fix func() error) {
+	if !dryRun {
+		if err := fix(); err != nil {
+			drift.Error = err.Error()
+			logger.Warn("Failed to repair firewall drift",
+				"kind", drift.Kind,
+				"target", drift.Target,
+				"error", err)
+		} else {
+			drift.Fixed = true
+		}
+	}
+	report.Drifts = append(report.Drifts, drift)
+}
+
+// deactivate marks an expired record inactive in provider state, storage and the manager
+func (r *Reconciler) deactivate(record *models.BlockRecord, now time.Time) error {
+	updated := *record
+	updated.IsActive = false
+	updated.UnblockedAt = &now
+	r.manager.Forget(record.IP)
+	return r.persist(&updated)
+}
+
+// persist writes a record to storage and then to provider state, so the provider keeps the stored ID
+func (r *Reconciler) persist(record *models.BlockRecord) error {
+	var err error
+	if record.ID == 0 {
+		err = r.store.SaveBlock(record)
+	} else {
+		err = r.store.UpdateBlock(record)
+	}
+	r.backend.TrackRecord(record)
+	return err
+}
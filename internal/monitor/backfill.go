@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/logger"
+)
+
+// Suffixes logrotate and newsyslog give rotated files: ".1", ".2.gz", "-20240115", "-2024011503.gz"
+var rotatedSuffix = regexp.MustCompile(`^(\.\d+|-\d{8}(\d{2})?)(\.gz)?$`)
+
+// rotatedSiblings returns the rotated copies of path modified within the window, oldest first
+func rotatedSiblings(path string, cutoff time.Time) []string {
+	numbered, _ := filepath.Glob(path + ".*")
+	dated, _ := filepath.Glob(path + "-*")
+	matches := append(numbered, dated...)
+
+	type sibling struct {
+		path     string
+		modified time.Time
+	}
+	var siblings []sibling
+	for _, match := range matches {
+		if !rotatedSuffix.MatchString(strings.TrimPrefix(match, path)) {
+			continue
+		}
+		info, err := os.Stat(match)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(cutoff) {
+			continue // last written before the window, so nothing in it is recent enough
+		}
+		siblings = append(siblings, sibling{match, info.ModTime()})
+	}
+
+	// Modification time orders both numbered (.3 before .2) and dated names
+	sort.Slice(siblings, func(i, j int) bool {
+		if !siblings[i].modified.Equal(siblings[j].modified) {
+			return siblings[i].modified.Before(siblings[j].modified)
+		}
+		return siblings[i].path > siblings[j].path
+	})
+	paths := make([]string, 0, len(siblings))
+	for _, sibling := range siblings {
+		paths = append(paths, sibling.path)
+	}
+	return paths
+}
+
+// backfill reads a file's history before live tailing took over: its rotated siblings within
+// the lookback window, then the current file up to end. Lines are sent as backfill events so
+// the pipeline can tell them from live ones and ignore those older than the window
+func (m *Monitor) backfill(ctx context.Context, path string, end int64, emitFrom func(source string) func(line string) bool) {
+	siblings := rotatedSiblings(path, time.Now().Add(-m.backfillWindow))
+	logger.Info("Backfilling log history", "path", path, "rotated_files", len(siblings), "window", m.backfillWindow.String())
+
+	lines := 0
+	counted := func(emit func(line string) bool) func(line string) bool {
+		return func(line string) bool {
+			lines++
+			return emit(line)
+		}
+	}
+
+	for _, sibling := range siblings {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := readHistory(sibling, -1, counted(emitFrom(sibling))); err != nil {
+			logger.Warn("Failed to backfill rotated log", "path", sibling, "error", err)
+		}
+	}
+	if end > 0 && ctx.Err() == nil {
+		if err := readHistory(path, end, counted(emitFrom(path))); err != nil {
+			logger.Warn("Failed to backfill log", "path", path, "error", err)
+		}
+	}
+	if ctx.Err() == nil {
+		logger.Info("Backfill finished", "path", path, "lines", lines)
+	}
+}
+
+// readHistory hands over the lines of a plain or gzip-compressed file
+// A positive limit reads only that many bytes; a final line without a newline is then left to the tail
+func readHistory(path string, limit int64, emit func(line string) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		decompressed, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		reader = decompressed
+	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit)
+	}
+
+	var lines fileTail
+	buffer := make([]byte, 32*1024)
+	var position int64
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if !lines.split(buffer[:n], position, emit) {
+				return nil
+			}
+			position += int64(n)
+		}
+		if err == io.EOF {
+			if limit <= 0 {
+				lines.flushPartial(emit)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
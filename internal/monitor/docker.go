@@ -0,0 +1,382 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// DockerPath selects container logs as a service's log_path
+// "docker:<socket>" uses another Engine API socket
+const DockerPath = "docker"
+
+// DefaultDockerSocket is where the Docker Engine API listens on Linux
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// Delay before the Engine API is contacted again after the event stream fails
+const dockerReconnectDelay = 5 * time.Second
+
+// Stream identifiers in the multiplexed log format
+const (
+	dockerStdout = 1
+	dockerStderr = 2
+)
+
+// Upper bound on one multiplexed frame
+const maxDockerFrameSize = 1 << 20
+
+// IsDockerPath reports whether a log_path selects container logs
+func IsDockerPath(logPath string) bool {
+	return logPath == DockerPath || strings.HasPrefix(logPath, DockerPath+":")
+}
+
+// DockerSource follows the logs of the containers selected for one service
+// Containers are selected by name (docker_containers) or label (docker_labels) and picked up
+// again when they restart; addresses found in their logs are blocked on the host firewall
+type DockerSource struct {
+	service  string
+	socket   string
+	names    []string // path.Match patterns
+	labels   []string // "key" or "key=value"
+	lookback time.Duration
+	client   *http.Client
+
+	mu       sync.Mutex
+	followed map[string]bool      // container ID -> log stream open
+	restart  map[string]bool      // container ID -> started again while its old stream was open
+	since    map[string]time.Time // container ID -> last line read, so reconnects skip what was sent
+}
+
+// NewDockerSource creates a container log source for a service whose log_path is "docker" or "docker:<socket>"
+func NewDockerSource(service models.ServiceConfig, lookback time.Duration) (*DockerSource, error) {
+	if !IsDockerPath(service.LogPath) {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "service %s: log_path %q is not a docker source", service.Name, service.LogPath)
+	}
+	if len(service.DockerContainers) == 0 && len(service.DockerLabels) == 0 {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+			"service %s: docker sources need docker_containers or docker_labels", service.Name)
+	}
+
+	socket := strings.TrimPrefix(strings.TrimPrefix(service.LogPath, DockerPath), ":")
+	if socket == "" {
+		socket = DefaultDockerSocket
+	}
+	return &DockerSource{
+		service:  service.Name,
+		socket:   socket,
+		names:    service.DockerContainers,
+		labels:   service.DockerLabels,
+		lookback: lookback,
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}},
+		followed: make(map[string]bool),
+		restart:  make(map[string]bool),
+		since:    make(map[string]time.Time),
+	}, nil
+}
+
+// Name identifies the source
+func (s *DockerSource) Name() string {
+	return DockerPath + ":" + strings.Join(append(append([]string{}, s.names...), s.labels...), ",")
+}
+
+// Run follows the selected containers until ctx is done
+// The container list is read again whenever the Engine API event stream reconnects
+func (s *DockerSource) Run(ctx context.Context, events chan<- core.LogEvent) error {
+	var followers sync.WaitGroup
+	defer followers.Wait()
+
+	for {
+		err := s.watch(ctx, events, &followers)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Warn("Docker event stream lost, reconnecting", "source", s.Name(), "socket", s.socket, "error", err, "retry", dockerReconnectDelay.String())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(dockerReconnectDelay):
+		}
+	}
+}
+
+// watch follows the running containers, then every container that starts later
+func (s *DockerSource) watch(ctx context.Context, events chan<- core.LogEvent, followers *sync.WaitGroup) error {
+	// Subscribe first so a container starting during the listing is not missed
+	query := url.Values{"filters": {`{"type":["container"],"event":["start"]}`}}
+	response, err := s.get(ctx, "/events", query)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var running []struct {
+		ID string `json:"Id"`
+	}
+	if err := s.getJSON(ctx, "/containers/json", nil, &running); err != nil {
+		return err
+	}
+	for _, container := range running {
+		s.start(ctx, container.ID, events, followers)
+	}
+	logger.Info("Following container logs", "source", s.Name(), "socket", s.socket, "running", len(running))
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event struct {
+			Action string `json:"Action"`
+			Actor  struct {
+				ID string `json:"ID"`
+			} `json:"Actor"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			return err
+		}
+		if event.Action == "start" {
+			s.start(ctx, event.Actor.ID, events, followers)
+		}
+	}
+}
+
+// start follows a container's logs if it is selected and not already followed
+func (s *DockerSource) start(ctx context.Context, id string, events chan<- core.LogEvent, followers *sync.WaitGroup) {
+	var container struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Config struct {
+			Tty    bool              `json:"Tty"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := s.getJSON(ctx, "/containers/"+url.PathEscape(id)+"/json", nil, &container); err != nil {
+		logger.Warn("Cannot inspect container", "source", s.Name(), "container", id, "error", err)
+		return
+	}
+	name := strings.TrimPrefix(container.Name, "/")
+	if !s.selects(name, container.Config.Labels) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.followed[container.ID] {
+		// The old stream may not have seen the stop yet; its follower reopens the logs
+		s.restart[container.ID] = true
+		return
+	}
+	s.followed[container.ID] = true
+
+	followers.Add(1)
+	go func() {
+		defer followers.Done()
+		for {
+			s.mu.Lock()
+			since, seen := s.since[container.ID]
+			s.mu.Unlock()
+			if !seen && s.lookback > 0 {
+				since = time.Now().Add(-s.lookback)
+			}
+
+			logger.Info("Following container", "source", s.Name(), "container", name)
+			if err := s.follow(ctx, container.ID, name, container.Config.Tty, since, events); err != nil && ctx.Err() == nil {
+				logger.Warn("Container log stream ended", "source", s.Name(), "container", name, "error", err)
+			}
+
+			s.mu.Lock()
+			again := s.restart[container.ID] && ctx.Err() == nil
+			delete(s.restart, container.ID)
+			if !again {
+				delete(s.followed, container.ID)
+			}
+			s.mu.Unlock()
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+// follow streams one container's stdout and stderr until it stops
+func (s *DockerSource) follow(ctx context.Context, id, name string, tty bool, since time.Time, events chan<- core.LogEvent) error {
+	query := url.Values{
+		"follow":     {"1"},
+		"stdout":     {"1"},
+		"stderr":     {"1"},
+		"timestamps": {"1"},
+	}
+	if !since.IsZero() {
+		// since is inclusive to the second; lines up to the last one read are skipped below
+		query.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
+	response, err := s.get(ctx, "/containers/"+url.PathEscape(id)+"/logs", query)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return ReadDockerLogs(response.Body, tty, func(stream int, line string) error {
+		timestamp, message := splitDockerTimestamp(line)
+		if !timestamp.After(since) && !since.IsZero() {
+			return nil
+		}
+		s.mu.Lock()
+		s.since[id] = timestamp
+		s.mu.Unlock()
+
+		select {
+		case events <- core.LogEvent{
+			Timestamp: timestamp,
+			Source:    DockerPath + ":" + name,
+			Line:      message,
+			Service:   s.service,
+		}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// selects reports whether a container matches the name or label selectors
+func (s *DockerSource) selects(name string, labels map[string]string) bool {
+	for _, pattern := range s.names {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	for _, selector := range s.labels {
+		key, value, hasValue := strings.Cut(selector, "=")
+		if actual, exists := labels[key]; exists && (!hasValue || actual == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// get performs an Engine API request and checks its status
+func (s *DockerSource) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, core.NewErrorf(core.ErrPlatformRequirements, err, "cannot reach the Docker Engine API at %s", s.socket)
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		response.Body.Close()
+		return nil, fmt.Errorf("docker %s: %s: %s", path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return response, nil
+}
+
+func (s *DockerSource) getJSON(ctx context.Context, path string, query url.Values, target any) error {
+	response, err := s.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// ReadDockerLogs splits a container log stream into lines
+// Without a TTY the Engine API multiplexes stdout and stderr: each frame is an 8-byte header
+// (stream, three zero bytes, big-endian payload size) followed by the payload; a frame may
+// end mid-line, so partial lines are kept per stream. With a TTY the stream is raw output
+func ReadDockerLogs(r io.Reader, tty bool, handle func(stream int, line string) error) error {
+	reader := bufio.NewReader(r)
+	if tty {
+		for {
+			line, err := reader.ReadString('\n')
+			if line = strings.TrimRight(line, "\r\n"); line != "" {
+				if handleErr := handle(dockerStdout, line); handleErr != nil {
+					return handleErr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	partial := map[int]string{}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return flushDockerPartial(partial, handle)
+			}
+			return err
+		}
+		stream := int(header[0])
+		size := binary.BigEndian.Uint32(header[4:])
+		if size > maxDockerFrameSize {
+			return core.NewErrorf(core.ErrLogParseError, nil, "docker log frame too large (%d bytes)", size)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return err
+		}
+
+		data := partial[stream] + string(payload)
+		for {
+			line, rest, found := strings.Cut(data, "\n")
+			if !found {
+				break
+			}
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				if err := handle(stream, line); err != nil {
+					return err
+				}
+			}
+			data = rest
+		}
+		partial[stream] = data
+	}
+}
+
+// flushDockerPartial hands over lines left without a trailing newline when the stream ends
+func flushDockerPartial(partial map[int]string, handle func(stream int, line string) error) error {
+	for _, stream := range []int{dockerStdout, dockerStderr} {
+		if line := strings.TrimRight(partial[stream], "\r"); line != "" {
+			if err := handle(stream, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitDockerTimestamp separates the RFC 3339 timestamp added by timestamps=1
+func splitDockerTimestamp(line string) (time.Time, string) {
+	token, message, found := strings.Cut(line, " ")
+	if found {
+		if timestamp, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			return timestamp, message
+		}
+	}
+	return time.Now(), line
+}
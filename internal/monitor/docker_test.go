@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// dockerFrame builds one multiplexed log frame: stream, three zero bytes, big-endian size, payload
+func dockerFrame(stream int, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = byte(stream)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// fakeDockerAPI serves the Engine API endpoints DockerSource uses from a unix socket
+type fakeDockerAPI struct {
+	containers map[string]fakeContainer // ID -> container
+	running    []string                 // IDs listed by /containers/json
+	started    []string                 // IDs announced on /events after the listing
+
+	mu      sync.Mutex
+	queries map[string]string // container ID -> raw query of its /logs request
+}
+
+type fakeContainer struct {
+	name   string
+	tty    bool
+	labels map[string]string
+	logs   []byte
+}
+
+func (f *fakeDockerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for _, id := range f.started {
+			json.NewEncoder(w).Encode(map[string]any{"Action": "start", "Actor": map[string]string{"ID": id}})
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+
+	case path == "/containers/json":
+		var list []map[string]string
+		for _, id := range f.running {
+			list = append(list, map[string]string{"Id": id})
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case strings.HasPrefix(path, "/containers/"):
+		id, endpoint, _ := strings.Cut(strings.TrimPrefix(path, "/containers/"), "/")
+		container, exists := f.containers[id]
+		if !exists {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		switch endpoint {
+		case "json":
+			json.NewEncoder(w).Encode(map[string]any{
+				"Id":     id,
+				"Name":   "/" + container.name,
+				"Config": map[string]any{"Tty": container.tty, "Labels": container.labels},
+			})
+		case "logs":
+			f.mu.Lock()
+			f.queries[id] = r.URL.RawQuery
+			f.mu.Unlock()
+			w.Write(container.logs)
+		default:
+			http.NotFound(w, r)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveFakeDocker starts api on a unix socket and returns the socket path
+func serveFakeDocker(t *testing.T, api *fakeDockerAPI) string {
+	t.Helper()
+	// Unix socket paths are limited to ~100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "guardian-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	server := httptest.NewUnstartedServer(api)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+func TestDockerSourceFollowsSelectedContainers(t *testing.T) {
+	// Lines newer than the lookback; older ones would be skipped
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	// stdout and stderr frames interleave, and a stdout line is split across two frames
+	var webLogs []byte
+	webLogs = append(webLogs, dockerFrame(dockerStdout, stamp+" Failed password for root from 203.0.113.5 ")...)
+	webLogs = append(webLogs, dockerFrame(dockerStderr, stamp+" auth error from 203.0.113.9\n")...)
+	webLogs = append(webLogs, dockerFrame(dockerStdout, "port 22 ssh2\n"+stamp+" no newline at the end")...)
+
+	api := &fakeDockerAPI{
+		containers: map[string]fakeContainer{
+			"a1": {name: "web", logs: webLogs},
+			"b2": {name: "db", logs: dockerFrame(dockerStdout, stamp+" not followed\n")},
+			"c3": {name: "worker", tty: true, labels: map[string]string{"guardian.watch": "true"},
+				logs: []byte(stamp + " tty line from 198.51.100.7\r\n")},
+		},
+		running: []string{"a1", "b2"},
+		started: []string{"c3"},
+		queries: make(map[string]string),
+	}
+	socket := serveFakeDocker(t, api)
+
+	source, err := NewDockerSource(models.ServiceConfig{
+		Name:             "ssh",
+		LogPath:          "docker:" + socket,
+		DockerContainers: []string{"web"},
+		DockerLabels:     []string{"guardian.watch=true"},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDockerSource: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan core.LogEvent, 16)
+	done := make(chan error, 1)
+	go func() { done <- source.Run(ctx, events) }()
+
+	var lines []string
+	timeout := time.After(5 * time.Second)
+	for len(lines) < 4 {
+		select {
+		case event := <-events:
+			if event.Service != "ssh" {
+				t.Errorf("event service = %q, want ssh", event.Service)
+			}
+			lines = append(lines, event.Source+" "+event.Line)
+		case <-timeout:
+			t.Fatalf("timed out; got %q", lines)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	slices.Sort(lines)
+	want := []string{
+		"docker:web Failed password for root from 203.0.113.5 port 22 ssh2",
+		"docker:web auth error from 203.0.113.9",
+		"docker:web no newline at the end",
+		"docker:worker tty line from 198.51.100.7",
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("lines = %q\nwant %q", lines, want)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if _, followed := api.queries["b2"]; followed {
+		t.Error("unselected container db was followed")
+	}
+	query := api.queries["a1"]
+	for _, param := range []string{"follow=1", "stderr=1", "timestamps=1", "since="} {
+		if !strings.Contains(query, param) {
+			t.Errorf("logs query %q lacks %s", query, param)
+		}
+	}
+}
+
+func TestReadDockerLogsRejectsOversizedFrame(t *testing.T) {
+	header := make([]byte, 8)
+	header[0] = dockerStdout
+	binary.BigEndian.PutUint32(header[4:], maxDockerFrameSize+1)
+
+	err := ReadDockerLogs(strings.NewReader(string(header)), false, func(int, string) error { return nil })
+	if err == nil {
+		t.Fatal("expected an error for an oversized frame")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package monitor
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of an open file
+func fileIdentity(file *os.File, info os.FileInfo) (device, inode uint64) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), uint64(stat.Ino)
+	}
+	return 0, 0
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package monitor
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the volume serial number and file index of an open file
+func fileIdentity(file *os.File, info os.FileInfo) (device, inode uint64) {
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &data); err != nil {
+		return 0, 0
+	}
+	return uint64(data.VolumeSerialNumber), uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)
+}
@@ -0,0 +1,339 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// JournalPath selects the systemd journal as a service's log_path
+// "journal:<file>" reads a captured `journalctl -o export` stream instead of the live journal
+const JournalPath = "journal"
+
+// Delay before journalctl is restarted after it exits
+const journalRestartDelay = 5 * time.Second
+
+// Cursor writes are coalesced to at most one per interval
+const journalCursorInterval = 2 * time.Second
+
+// Upper bound on a single binary field in the export stream
+const maxJournalFieldSize = 1 << 20
+
+// IsJournalPath reports whether a log_path selects the journal
+func IsJournalPath(logPath string) bool {
+	return logPath == JournalPath || strings.HasPrefix(logPath, JournalPath+":")
+}
+
+// JournalEntry is one entry of the journal export format, keyed by field name
+type JournalEntry map[string]string
+
+// Cursor returns the entry's position in the journal
+func (e JournalEntry) Cursor() string {
+	return e["__CURSOR"]
+}
+
+// Timestamp returns when the entry was logged (__REALTIME_TIMESTAMP, microseconds)
+func (e JournalEntry) Timestamp() time.Time {
+	for _, field := range []string{"_SOURCE_REALTIME_TIMESTAMP", "__REALTIME_TIMESTAMP"} {
+		if usec, err := strconv.ParseInt(e[field], 10, 64); err == nil {
+			return time.UnixMicro(usec)
+		}
+	}
+	return time.Now()
+}
+
+// Identifier returns the program name, as syslog would write it
+func (e JournalEntry) Identifier() string {
+	for _, field := range []string{"SYSLOG_IDENTIFIER", "_COMM"} {
+		if value := e[field]; value != "" {
+			return value
+		}
+	}
+	return strings.TrimSuffix(e["_SYSTEMD_UNIT"], ".service")
+}
+
+// Line renders the entry as a syslog line so the existing parsers apply unchanged
+// e.g. "2024-01-15T10:23:45.123456+00:00 web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2"
+func (e JournalEntry) Line() string {
+	host := e["_HOSTNAME"]
+	if host == "" {
+		host = "localhost"
+	}
+	tag := e.Identifier()
+	if pid := e["SYSLOG_PID"]; pid != "" {
+		tag += "[" + pid + "]"
+	} else if pid := e["_PID"]; pid != "" {
+		tag += "[" + pid + "]"
+	}
+	return syslogLine(e.Timestamp(), host, tag, e["MESSAGE"])
+}
+
+// JournalReader decodes the journal export format
+// Text fields are "NAME=value\n"; binary fields are "NAME\n", a little-endian uint64 size,
+// the data and "\n"; an empty line ends an entry
+type JournalReader struct {
+	reader *bufio.Reader
+}
+
+// NewJournalReader reads export-format entries from r
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next returns the next entry, or io.EOF when the stream ends between entries
+func (r *JournalReader) Next() (JournalEntry, error) {
+	entry := make(JournalEntry)
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(line) == 0 {
+				if len(entry) > 0 {
+					return entry, nil
+				}
+				return nil, io.EOF
+			}
+			if err != io.EOF {
+				return nil, err
+			}
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+
+		if len(line) == 0 {
+			if len(entry) == 0 {
+				continue // stray separator
+			}
+			return entry, nil
+		}
+
+		if name, value, found := bytes.Cut(line, []byte("=")); found {
+			entry[string(name)] = string(value)
+			continue
+		}
+
+		// Binary field: the name alone on its line, then size and data
+		var size uint64
+		if err := binary.Read(r.reader, binary.LittleEndian, &size); err != nil {
+			return nil, core.NewErrorf(core.ErrLogParseError, err, "truncated journal field %s", line)
+		}
+		if size > maxJournalFieldSize {
+			return nil, core.NewErrorf(core.ErrLogParseError, nil, "journal field %s too large (%d bytes)", line, size)
+		}
+		data := make([]byte, size+1) // trailing newline
+		if _, err := io.ReadFull(r.reader, data); err != nil {
+			return nil, core.NewErrorf(core.ErrLogParseError, err, "truncated journal field %s", line)
+		}
+		entry[string(line)] = string(data[:size])
+	}
+}
+
+// JournalSource follows the systemd journal for one service
+// Entries are filtered by _SYSTEMD_UNIT (journal_units) or SYSLOG_IDENTIFIER (journal_identifiers)
+// and the cursor of the last entry read is kept so a restart resumes where it stopped
+type JournalSource struct {
+	service     string
+	units       []string
+	identifiers []string
+	file        string        // captured export stream instead of journalctl
+	cursorPath  string        // empty when the cursor is not persisted
+	lookback    time.Duration // how far back to start without a cursor
+
+	cursor      string
+	cursorSaved time.Time
+}
+
+// NewJournalSource creates a journal source for a service whose log_path is "journal" or "journal:<file>"
+// The cursor is kept in stateDir; an empty stateDir disables resuming
+func NewJournalSource(service models.ServiceConfig, stateDir string, lookback time.Duration) (*JournalSource, error) {
+	if !IsJournalPath(service.LogPath) {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "service %s: log_path %q is not a journal source", service.Name, service.LogPath)
+	}
+
+	source := &JournalSource{
+		service:     service.Name,
+		units:       service.JournalUnits,
+		identifiers: service.JournalIdentifiers,
+		file:        strings.TrimPrefix(strings.TrimPrefix(service.LogPath, JournalPath), ":"),
+		lookback:    lookback,
+	}
+	if source.file == "" && len(source.units) == 0 && len(source.identifiers) == 0 {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+			"service %s: journal sources need journal_units or journal_identifiers", service.Name)
+	}
+
+	// A captured file is read whole each time; only the live journal resumes
+	if stateDir != "" && source.file == "" {
+		source.cursorPath = stateFile(stateDir, "journal-"+service.Name, ".cursor")
+		if data, err := os.ReadFile(source.cursorPath); err == nil {
+			source.cursor = strings.TrimSpace(string(data))
+		}
+	}
+	return source, nil
+}
+
+// Name identifies the source
+func (s *JournalSource) Name() string {
+	if s.file != "" {
+		return JournalPath + ":" + s.file
+	}
+	return JournalPath + ":" + strings.Join(append(append([]string{}, s.units...), s.identifiers...), ",")
+}
+
+// Cursor returns the cursor of the last entry read
+func (s *JournalSource) Cursor() string {
+	return s.cursor
+}
+
+// Run follows the journal, restarting journalctl if it exits, until ctx is done
+// A captured export file is read once
+func (s *JournalSource) Run(ctx context.Context, events chan<- core.LogEvent) error {
+	defer s.saveCursor(true)
+
+	if s.file != "" {
+		file, err := os.Open(s.file)
+		if err != nil {
+			return core.NewErrorf(core.ErrLogFileNotFound, err, "cannot open journal export %s", s.file)
+		}
+		defer file.Close()
+		return s.read(ctx, file, events)
+	}
+
+	for {
+		err := s.follow(ctx, events)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Warn("journalctl stopped, restarting", "source", s.Name(), "error", err, "retry", journalRestartDelay.String())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(journalRestartDelay):
+		}
+	}
+}
+
+// follow runs journalctl in export mode from the saved cursor
+func (s *JournalSource) follow(ctx context.Context, events chan<- core.LogEvent) error {
+	cmd := exec.CommandContext(ctx, "journalctl", s.journalctlArgs()...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return core.NewError(core.ErrPlatformRequirements, "cannot start journalctl", err)
+	}
+
+	logger.Info("Following systemd journal", "source", s.Name(), "service", s.service, "cursor", s.cursor != "")
+	readErr := s.read(ctx, stdout, events)
+	waitErr := cmd.Wait()
+	switch {
+	case readErr != nil:
+		return readErr
+	case waitErr != nil && stderr.Len() > 0:
+		return fmt.Errorf("%w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	default:
+		return waitErr
+	}
+}
+
+// journalctlArgs builds the journalctl command line
+// Matches on the same field are ORed by journalctl; "+" ORs the unit and identifier groups
+func (s *JournalSource) journalctlArgs() []string {
+	args := []string{"--output=export", "--follow", "--no-pager", "--quiet"}
+	if s.cursor != "" {
+		args = append(args, "--after-cursor="+s.cursor)
+	} else if s.lookback > 0 {
+		args = append(args, "--since=-"+strconv.Itoa(int(s.lookback.Seconds()))+"s")
+	}
+
+	for _, unit := range s.units {
+		args = append(args, "_SYSTEMD_UNIT="+unit)
+	}
+	if len(s.units) > 0 && len(s.identifiers) > 0 {
+		args = append(args, "+")
+	}
+	for _, identifier := range s.identifiers {
+		args = append(args, "SYSLOG_IDENTIFIER="+identifier)
+	}
+	return args
+}
+
+// read decodes an export stream and sends the matching entries as log events
+func (s *JournalSource) read(ctx context.Context, r io.Reader, events chan<- core.LogEvent) error {
+	reader := NewJournalReader(r)
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if s.matches(entry) && entry["MESSAGE"] != "" {
+			event := core.LogEvent{
+				Timestamp: entry.Timestamp(),
+				Source:    JournalPath + ":" + entry.Identifier(),
+				Line:      entry.Line(),
+				Service:   s.service,
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if cursor := entry.Cursor(); cursor != "" {
+			s.cursor = cursor
+			s.saveCursor(false)
+		}
+	}
+}
+
+// matches applies the unit and identifier filters (journalctl already does for live reads)
+func (s *JournalSource) matches(entry JournalEntry) bool {
+	if len(s.units) == 0 && len(s.identifiers) == 0 {
+		return true
+	}
+	for _, unit := range s.units {
+		if entry["_SYSTEMD_UNIT"] == unit {
+			return true
+		}
+	}
+	for _, identifier := range s.identifiers {
+		if entry["SYSLOG_IDENTIFIER"] == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// saveCursor persists the cursor, at most once per interval unless forced
+func (s *JournalSource) saveCursor(force bool) {
+	if s.cursorPath == "" || s.cursor == "" {
+		return
+	}
+	if !force && time.Since(s.cursorSaved) < journalCursorInterval {
+		return
+	}
+	if err := writeStateFile(s.cursorPath, []byte(s.cursor+"\n")); err != nil {
+		logger.Warn("Failed to save journal cursor", "source", s.Name(), "path", s.cursorPath, "error", err)
+		return
+	}
+	s.cursorSaved = time.Now()
+}
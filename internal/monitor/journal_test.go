@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Recorded `journalctl -o export` output: an sshd entry, a cron entry and an sshd entry whose
+// MESSAGE and _CMDLINE are binary fields (the message ends in a newline, the command line holds a NUL)
+const journalFixture = "testdata/journal/sshd.export"
+
+const lastFixtureCursor = "s=7d1c;i=103;b=a1;m=12;t=60f3;x=3"
+
+func openJournalFixture(t *testing.T) *os.File {
+	t.Helper()
+	file, err := os.Open(journalFixture)
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// readJournal runs source.read over the fixture and collects the events
+func readJournal(t *testing.T, source *JournalSource) []core.LogEvent {
+	t.Helper()
+	events := make(chan core.LogEvent, 16)
+	if err := source.read(context.Background(), openJournalFixture(t), events); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	close(events)
+
+	var collected []core.LogEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestJournalReaderDecodesExport(t *testing.T) {
+	reader := NewJournalReader(openJournalFixture(t))
+
+	var entries []JournalEntry
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("decoded %d entries, want 3", len(entries))
+	}
+
+	first := entries[0]
+	if got := first["MESSAGE"]; got != "Failed password for root from 203.0.113.5 port 22 ssh2" {
+		t.Errorf("text MESSAGE = %q", got)
+	}
+	if got := first.Timestamp(); !got.Equal(time.UnixMicro(1705314225123456)) {
+		t.Errorf("Timestamp = %v", got)
+	}
+	if got := first.Identifier(); got != "sshd" {
+		t.Errorf("Identifier = %q, want sshd", got)
+	}
+
+	binary := entries[2]
+	if got := binary["MESSAGE"]; got != "Invalid user admin from 198.51.100.7 port 4242\n" {
+		t.Errorf("binary MESSAGE = %q", got)
+	}
+	if got := binary["_CMDLINE"]; got != "sshd: admin [priv]\x00extra\n" {
+		t.Errorf("binary _CMDLINE = %q", got)
+	}
+	if got := binary.Cursor(); got != lastFixtureCursor {
+		t.Errorf("Cursor = %q, want %q", got, lastFixtureCursor)
+	}
+}
+
+func TestJournalReaderRejectsTruncatedBinaryField(t *testing.T) {
+	stream := "__CURSOR=s=1\nMESSAGE\n\x20\x00\x00\x00\x00\x00\x00\x00short"
+	reader := NewJournalReader(strings.NewReader(stream))
+	if _, err := reader.Next(); err == nil {
+		t.Fatal("expected an error for a truncated binary field")
+	}
+}
+
+func TestJournalSourceReadsCapturedExport(t *testing.T) {
+	source, err := NewJournalSource(models.ServiceConfig{
+		Name:               "ssh",
+		LogPath:            "journal:" + journalFixture,
+		JournalIdentifiers: []string{"sshd"},
+	}, t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewJournalSource: %v", err)
+	}
+
+	events := readJournal(t, source)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want the 2 sshd entries", len(events))
+	}
+
+	suffixes := []string{
+		" web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2",
+		" web01 sshd[1240]: Invalid user admin from 198.51.100.7 port 4242",
+	}
+	for i, event := range events {
+		if !strings.HasSuffix(event.Line, suffixes[i]) {
+			t.Errorf("event %d line = %q, want suffix %q", i, event.Line, suffixes[i])
+		}
+		if event.Source != "journal:sshd" || event.Service != "ssh" {
+			t.Errorf("event %d source/service = %q/%q", i, event.Source, event.Service)
+		}
+	}
+
+	// A captured file is read whole each run, so no cursor is written for it
+	source.saveCursor(true)
+	if source.cursorPath != "" {
+		t.Errorf("captured export should not persist a cursor, got %s", source.cursorPath)
+	}
+}
+
+func TestJournalSourceResumesFromCursor(t *testing.T) {
+	stateDir := t.TempDir()
+	service := models.ServiceConfig{
+		Name:         "ssh",
+		LogPath:      JournalPath,
+		JournalUnits: []string{"ssh.service"},
+	}
+
+	source, err := NewJournalSource(service, stateDir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJournalSource: %v", err)
+	}
+	if args := source.journalctlArgs(); !slices.Contains(args, "--since=-3600s") {
+		t.Errorf("without a cursor the lookback should apply, got %v", args)
+	}
+
+	if events := readJournal(t, source); len(events) != 2 {
+		t.Fatalf("got %d events, want the 2 ssh.service entries", len(events))
+	}
+	source.saveCursor(true)
+
+	// A restarted source picks up after the last entry read, not the lookback
+	resumed, err := NewJournalSource(service, stateDir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJournalSource: %v", err)
+	}
+	if got := resumed.Cursor(); got != lastFixtureCursor {
+		t.Fatalf("resumed cursor = %q, want %q", got, lastFixtureCursor)
+	}
+	args := resumed.journalctlArgs()
+	if !slices.Contains(args, "--after-cursor="+lastFixtureCursor) {
+		t.Errorf("journalctl args %v lack --after-cursor", args)
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--since") {
+			t.Errorf("a saved cursor should replace --since, got %v", args)
+		}
+	}
+}
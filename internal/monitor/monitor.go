@@ -0,0 +1,560 @@
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/parser"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Default cadences; files are polled rather than watched so rotation works the same everywhere
+const (
+	defaultPollInterval     = 500 * time.Millisecond
+	defaultDiscoverInterval = 10 * time.Second
+	checkpointInterval      = 30 * time.Second
+	checkpointRetention     = 30 * 24 * time.Hour // positions of files no longer read are kept this long
+)
+
+// CheckpointStore persists file positions across restarts; core.Storage implements it
+type CheckpointStore interface {
+	SaveCheckpoints(checkpoints []*models.TailCheckpoint) error
+	GetCheckpoints() ([]*models.TailCheckpoint, error)
+}
+
+// Monitor implements core.LogMonitor
+// It multiplexes files, glob patterns and other sources (journal, syslog, containers) into one
+// event stream. Each file is read once however many services and patterns name it; its lines
+// are sent once per service. Glob patterns are expanded again periodically to pick up new files
+type Monitor struct {
+	input            chan core.LogEvent // readers hand events to the pump, which applies the queue's policy
+	queue            *queue.Queue[core.LogEvent]
+	pumped           chan struct{}
+	pollInterval     time.Duration
+	discoverInterval time.Duration
+
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	stopped  bool
+
+	patterns map[string]*filePattern // path or glob -> services reading it
+	tails    map[string]*tailRunner  // file -> its reader
+	sources  map[string]*sourceRunner
+
+	backfillWindow time.Duration // 0 disables backfill
+
+	store            CheckpointStore
+	checkpoints      map[string]*models.TailCheckpoint // file -> last position handed over
+	checkpointsDirty bool
+}
+
+// filePattern is a path or glob and the services reading it
+type filePattern struct {
+	services map[string]core.LogParser
+	seen     bool // expanded at least once; files found later are new and read from the start
+}
+
+var errMonitorStopped = core.NewError(core.ErrConfigInvalid, "log monitor stopped", nil)
+
+// tailRunner reads one file for the services listed
+type tailRunner struct {
+	cancel   context.CancelFunc
+	services []string // guarded by Monitor.mu
+}
+
+// sourceRunner runs one non-file source
+type sourceRunner struct {
+	source Source
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a monitor whose events wait in a queue with the given capacity and overflow policy
+func New(options queue.Options) *Monitor {
+	if options.Name == "" {
+		options.Name = "events"
+	}
+	return &Monitor{
+		input:            make(chan core.LogEvent),
+		queue:            queue.New[core.LogEvent](options),
+		pumped:           make(chan struct{}),
+		pollInterval:     defaultPollInterval,
+		discoverInterval: defaultDiscoverInterval,
+		patterns:         make(map[string]*filePattern),
+		tails:            make(map[string]*tailRunner),
+		sources:          make(map[string]*sourceRunner),
+		checkpoints:      make(map[string]*models.TailCheckpoint),
+	}
+}
+
+// SetBackfill reads the history of newly monitored files: rotated copies modified within
+// window and what the file held before tailing began. 0 disables it
+func (m *Monitor) SetBackfill(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backfillWindow = window
+}
+
+// SetCheckpointStore saves file positions to store and resumes from them on Start
+func (m *Monitor) SetCheckpointStore(store CheckpointStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// Start begins reading everything registered so far; later additions start immediately
+func (m *Monitor) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx != nil || m.stopped {
+		return core.NewError(core.ErrConfigInvalid, "log monitor already started", nil)
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.loadCheckpointsLocked()
+
+	go func() {
+		defer close(m.pumped)
+		// Blocking pushes are not cut short on Stop: the consumer reads until Events is closed
+		for event := range m.input {
+			m.queue.Push(context.Background(), event)
+		}
+	}()
+
+	m.syncFilesLocked()
+	for name, runner := range m.sources {
+		m.startSourceLocked(name, runner)
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.discoverInterval)
+		defer ticker.Stop()
+		checkpoints := time.NewTicker(checkpointInterval)
+		defer checkpoints.Stop()
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+				m.mu.Lock()
+				m.syncFilesLocked()
+				m.mu.Unlock()
+			case <-checkpoints.C:
+				m.saveCheckpoints()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops every reader, delivers the queued events and closes the event channel
+func (m *Monitor) Stop() error {
+	m.stopOnce.Do(func() {
+		m.mu.Lock()
+		m.stopped = true
+		started := m.cancel != nil
+		if started {
+			m.cancel()
+		}
+		m.mu.Unlock()
+		m.wg.Wait()
+		m.saveCheckpoints()
+		close(m.input)
+		if started {
+			<-m.pumped
+		}
+		m.queue.Close()
+	})
+	return nil
+}
+
+// Events returns the multiplexed event stream; it is closed by Stop
+func (m *Monitor) Events() <-chan core.LogEvent {
+	return m.queue.Out()
+}
+
+// QueueStats returns the event queue's counters
+func (m *Monitor) QueueStats() queue.Stats {
+	return m.queue.Stats()
+}
+
+// AddLogFile reads a file or glob pattern (e.g. /var/log/nginx/*.log) for parser's service
+// Adding the same path for another service shares the reader
+func (m *Monitor) AddLogFile(path string, parser core.LogParser) error {
+	if _, err := filepath.Match(path, ""); err != nil {
+		return core.NewErrorf(core.ErrConfigInvalid, err, "invalid log path pattern %q", path)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return errMonitorStopped
+	}
+	pattern, exists := m.patterns[path]
+	if !exists {
+		pattern = &filePattern{services: make(map[string]core.LogParser)}
+		m.patterns[path] = pattern
+	}
+	pattern.services[parser.ServiceName()] = parser
+	if m.ctx != nil {
+		m.syncFilesLocked()
+	}
+	return nil
+}
+
+// RemoveLogFile stops reading a path or pattern for every service
+func (m *Monitor) RemoveLogFile(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.patterns[path]; !exists {
+		return core.NewErrorf(core.ErrLogFileNotFound, nil, "log path %s is not monitored", path)
+	}
+	delete(m.patterns, path)
+	if m.ctx != nil {
+		m.syncFilesLocked()
+	}
+	return nil
+}
+
+// RemoveService stops reading a path or pattern for one service, keeping it for the others
+func (m *Monitor) RemoveService(path, service string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pattern, exists := m.patterns[path]
+	if !exists {
+		return core.NewErrorf(core.ErrLogFileNotFound, nil, "log path %s is not monitored", path)
+	}
+	delete(pattern.services, service)
+	if len(pattern.services) == 0 {
+		delete(m.patterns, path)
+	}
+	if m.ctx != nil {
+		m.syncFilesLocked()
+	}
+	return nil
+}
+
+// AddSource runs a non-file source under a unique name
+func (m *Monitor) AddSource(name string, source Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return errMonitorStopped
+	}
+	if _, exists := m.sources[name]; exists {
+		return core.NewErrorf(core.ErrConfigInvalid, nil, "log source %s already added", name)
+	}
+	runner := &sourceRunner{source: source}
+	m.sources[name] = runner
+	if m.ctx != nil {
+		m.startSourceLocked(name, runner)
+	}
+	return nil
+}
+
+// RemoveSource stops a source and waits for it to finish, so its listeners are released
+func (m *Monitor) RemoveSource(name string) error {
+	m.mu.Lock()
+	runner, exists := m.sources[name]
+	delete(m.sources, name)
+	m.mu.Unlock()
+	if !exists {
+		return core.NewErrorf(core.ErrLogFileNotFound, nil, "log source %s is not running", name)
+	}
+	if runner.cancel != nil {
+		runner.cancel()
+		<-runner.done
+	}
+	return nil
+}
+
+// hasSource reports whether a source is registered under name
+func (m *Monitor) hasSource(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.sources[name]
+	return exists
+}
+
+// Checkpoints returns the last position handed over for each file, sorted by path
+func (m *Monitor) Checkpoints() []*models.TailCheckpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpointListLocked()
+}
+
+// Files returns the files currently read and the services reading each
+func (m *Monitor) Files() map[string][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make(map[string][]string, len(m.tails))
+	for path, runner := range m.tails {
+		files[path] = append([]string(nil), runner.services...)
+	}
+	return files
+}
+
+// startSourceLocked runs a source until it returns or is removed
+func (m *Monitor) startSourceLocked(name string, runner *sourceRunner) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	runner.cancel, runner.done = cancel, make(chan struct{})
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(runner.done)
+		logger.Info("Log source started", "source", name)
+		if err := runner.source.Run(ctx, m.input); err != nil && ctx.Err() == nil {
+			logger.Error("Log source stopped", "source", name, "error", err)
+		}
+	}()
+}
+
+// syncFilesLocked expands the patterns and starts, updates or stops file readers to match
+func (m *Monitor) syncFilesLocked() {
+	if m.stopped {
+		return
+	}
+	wanted := make(map[string]map[string]bool)
+	fromStart := make(map[string]bool)
+	for path, pattern := range m.patterns {
+		files := []string{path}
+		if hasGlob(path) {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				continue
+			}
+			files = matches
+		}
+		for _, file := range files {
+			if wanted[file] == nil {
+				wanted[file] = make(map[string]bool)
+			}
+			for service := range pattern.services {
+				wanted[file][service] = true
+			}
+			if pattern.seen {
+				fromStart[file] = true
+			}
+		}
+		pattern.seen = true
+	}
+
+	for file, runner := range m.tails {
+		if _, keep := wanted[file]; !keep {
+			runner.cancel()
+			delete(m.tails, file)
+			logger.Info("Stopped reading log file", "path", file)
+		}
+	}
+
+	for file, serviceSet := range wanted {
+		services := make([]string, 0, len(serviceSet))
+		for service := range serviceSet {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+
+		if runner, exists := m.tails[file]; exists {
+			runner.services = services
+			continue
+		}
+		m.startTailLocked(file, services, fromStart[file])
+	}
+}
+
+// startTailLocked starts reading a file
+// Files present at startup are read from their end; files that appear later from the start
+func (m *Monitor) startTailLocked(file string, services []string, fromStart bool) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	runner := &tailRunner{cancel: cancel, services: services}
+	m.tails[file] = runner
+	logger.Info("Reading log file", "path", file, "services", strings.Join(services, ","), "from_start", fromStart)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.mu.Lock()
+		checkpoint := m.checkpoints[file]
+		m.mu.Unlock()
+		tail := newFileTail(file, fromStart, checkpoint)
+		defer tail.close()
+
+		ticker := time.NewTicker(m.pollInterval)
+		defer ticker.Stop()
+		emitFrom := func(source string, backfill bool) func(line string) bool {
+			return func(line string) bool {
+				m.mu.Lock()
+				services := runner.services
+				m.mu.Unlock()
+				now := time.Now()
+				for _, service := range services {
+					select {
+					case m.input <- core.LogEvent{Timestamp: now, Source: source, Line: line, Service: service, Backfill: backfill}:
+					case <-ctx.Done():
+						return false
+					}
+				}
+				return true
+			}
+		}
+		emit := emitFrom(file, false)
+
+		// History is read once, when a file is first monitored; later starts resume from the checkpoint
+		m.mu.Lock()
+		window := m.backfillWindow
+		m.mu.Unlock()
+		if window > 0 && checkpoint == nil && !fromStart && tail.file != nil {
+			m.wg.Add(1)
+			go func(end int64) {
+				defer m.wg.Done()
+				m.backfill(ctx, file, end, func(source string) func(line string) bool { return emitFrom(source, true) })
+			}(tail.offset)
+		}
+
+		for {
+			if err := tail.poll(emit); err != nil {
+				logger.Warn("Failed to read log file", "path", file, "error", err)
+			}
+			m.recordCheckpoint(tail.checkpoint())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recordCheckpoint keeps a file's latest position for the next save
+func (m *Monitor) recordCheckpoint(checkpoint *models.TailCheckpoint) {
+	if checkpoint == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if previous := m.checkpoints[checkpoint.Path]; previous != nil && previous.Offset == checkpoint.Offset &&
+		previous.Device == checkpoint.Device && previous.Inode == checkpoint.Inode && previous.Fingerprint == checkpoint.Fingerprint {
+		return
+	}
+	m.checkpoints[checkpoint.Path] = checkpoint
+	m.checkpointsDirty = true
+}
+
+// loadCheckpointsLocked reads the positions saved by the previous run
+func (m *Monitor) loadCheckpointsLocked() {
+	if m.store == nil {
+		return
+	}
+	checkpoints, err := m.store.GetCheckpoints()
+	if err != nil {
+		logger.Warn("Failed to load log file checkpoints", "error", err)
+		return
+	}
+	for _, checkpoint := range checkpoints {
+		if time.Since(checkpoint.UpdatedAt) < checkpointRetention {
+			m.checkpoints[checkpoint.Path] = checkpoint
+		}
+	}
+}
+
+// saveCheckpoints stores the file positions if any changed since the last save
+func (m *Monitor) saveCheckpoints() {
+	m.mu.Lock()
+	if m.store == nil || !m.checkpointsDirty {
+		m.mu.Unlock()
+		return
+	}
+	for path, checkpoint := range m.checkpoints {
+		if _, reading := m.tails[path]; !reading && time.Since(checkpoint.UpdatedAt) >= checkpointRetention {
+			delete(m.checkpoints, path)
+		}
+	}
+	checkpoints := m.checkpointListLocked()
+	m.checkpointsDirty = false
+	store := m.store
+	m.mu.Unlock()
+
+	if err := store.SaveCheckpoints(checkpoints); err != nil {
+		logger.Warn("Failed to save log file checkpoints", "error", err)
+		m.mu.Lock()
+		m.checkpointsDirty = true
+		m.mu.Unlock()
+	}
+}
+
+func (m *Monitor) checkpointListLocked() []*models.TailCheckpoint {
+	checkpoints := make([]*models.TailCheckpoint, 0, len(m.checkpoints))
+	for _, checkpoint := range m.checkpoints {
+		copied := *checkpoint
+		checkpoints = append(checkpoints, &copied)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Path < checkpoints[j].Path })
+	return checkpoints
+}
+
+// hasGlob reports whether a path contains glob metacharacters
+func hasGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// namedParser reports a configured service name instead of the parser's own
+type namedParser struct {
+	core.LogParser
+	service string
+}
+
+// ServiceParser wraps a parser so events and attempts carry the configured service name
+// ("Nginx Edge" rather than the parser's "nginx")
+func ServiceParser(parser core.LogParser, service string) core.LogParser {
+	return &namedParser{LogParser: parser, service: service}
+}
+
+// ServiceName returns the configured service name
+func (p *namedParser) ServiceName() string {
+	return p.service
+}
+
+// ParseLine parses with the wrapped parser and labels the attempt with the configured service
+func (p *namedParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	attempt, err := p.LogParser.ParseLine(line)
+	if attempt != nil {
+		attempt.Service = p.service
+	}
+	return attempt, err
+}
+
+// ParseLineFrom passes the originating file to parsers that keep per-file state
+// (parser.SourceParser), so two IIS sites with different #Fields headers parse independently
+func (p *namedParser) ParseLineFrom(source, line string) (*models.AttackAttempt, error) {
+	sourceParser, ok := p.LogParser.(parser.SourceParser)
+	if !ok {
+		return p.ParseLine(line)
+	}
+	attempt, err := sourceParser.ParseLineFrom(source, line)
+	if attempt != nil {
+		attempt.Service = p.service
+	}
+	return attempt, err
+}
+
+// clientAddress asks the wrapped parser where a line keeps its client address
+// (parser.ClientAddressParser); located is false when the parser does not say
+func (p *namedParser) clientAddress(source, line string) (address string, located bool) {
+	addressParser, ok := p.LogParser.(parser.ClientAddressParser)
+	if !ok {
+		return "", false
+	}
+	return addressParser.ClientAddress(source, line), true
+}
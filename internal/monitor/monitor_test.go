@@ -0,0 +1,227 @@
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/internal/storage"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// serviceOnly is a parser that only names a service; the monitor never parses
+type serviceOnly string
+
+func (s serviceOnly) ParseLine(string) (*models.AttackAttempt, error) { return nil, nil }
+func (s serviceOnly) ServiceName() string                             { return string(s) }
+func (s serviceOnly) Patterns() []string                              { return nil }
+
+// newTestMonitor returns a monitor polling every few milliseconds, stopped when the test ends
+func newTestMonitor(t *testing.T, store CheckpointStore) *Monitor {
+	t.Helper()
+	monitor := New(queue.Options{Capacity: 64, Policy: queue.PolicyBlock})
+	monitor.pollInterval = 5 * time.Millisecond
+	monitor.discoverInterval = 20 * time.Millisecond
+	if store != nil {
+		monitor.SetCheckpointStore(store)
+	}
+	t.Cleanup(func() { monitor.Stop() })
+	return monitor
+}
+
+// nextEvents waits for count events
+func nextEvents(t *testing.T, monitor *Monitor, count int) []core.LogEvent {
+	t.Helper()
+	var events []core.LogEvent
+	timeout := time.After(5 * time.Second)
+	for len(events) < count {
+		select {
+		case event := <-monitor.Events():
+			events = append(events, event)
+		case <-timeout:
+			t.Fatalf("timed out after %d of %d events: %v", len(events), count, events)
+		}
+	}
+	return events
+}
+
+// waitFor polls condition until it holds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitReading waits until a reader has opened path, so what is appended next counts as new
+func waitReading(t *testing.T, monitor *Monitor, path string) {
+	t.Helper()
+	waitFor(t, "a reader of "+path, func() bool {
+		for _, checkpoint := range monitor.Checkpoints() {
+			if checkpoint.Path == path {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestMonitorDiscoversGlobMatches(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.log")
+	appendFile(t, existing, "history\n")
+	appendFile(t, filepath.Join(dir, "ignored.txt"), "not matched\n")
+
+	monitor := newTestMonitor(t, nil)
+	if err := monitor.AddLogFile(filepath.Join(dir, "*.log"), serviceOnly("nginx")); err != nil {
+		t.Fatalf("AddLogFile: %v", err)
+	}
+	if err := monitor.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if files := monitor.Files(); len(files) != 1 || files[existing] == nil {
+		t.Fatalf("files = %v, want only %s", files, existing)
+	}
+
+	// A file present at startup is read from its end; one that appears later from its start
+	waitReading(t, monitor, existing)
+	appendFile(t, existing, "appended\n")
+	if event := nextEvents(t, monitor, 1)[0]; event.Line != "appended" || event.Source != existing || event.Service != "nginx" {
+		t.Errorf("event = %+v", event)
+	}
+
+	created := filepath.Join(dir, "b.log")
+	appendFile(t, created, "written before discovery\n")
+	if event := nextEvents(t, monitor, 1)[0]; event.Line != "written before discovery" || event.Source != created {
+		t.Errorf("event = %+v", event)
+	}
+	if files := monitor.Files(); len(files) != 2 {
+		t.Errorf("files = %v, want both logs", files)
+	}
+}
+
+func TestMonitorSharesOneReaderPerFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	glob := filepath.Join(dir, "*.log")
+	appendFile(t, path, "")
+
+	monitor := newTestMonitor(t, nil)
+	if err := monitor.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for _, add := range []struct{ path, service string }{{path, "nginx"}, {path, "scanner"}, {glob, "web"}, {path, "nginx"}} {
+		if err := monitor.AddLogFile(add.path, serviceOnly(add.service)); err != nil {
+			t.Fatalf("AddLogFile: %v", err)
+		}
+	}
+	expectServices := func(want ...string) {
+		t.Helper()
+		files := monitor.Files()
+		if len(want) == 0 {
+			if len(files) != 0 {
+				t.Errorf("files = %v, want none", files)
+			}
+			return
+		}
+		if len(files) != 1 || !slices.Equal(files[path], want) {
+			t.Errorf("files = %v, want one reader of %s for %v", files, path, want)
+		}
+	}
+	expectServices("nginx", "scanner", "web")
+
+	// Each line is read once and handed to every service
+	waitReading(t, monitor, path)
+	appendFile(t, path, "GET /\n")
+	var services []string
+	for _, event := range nextEvents(t, monitor, 3) {
+		services = append(services, event.Service)
+	}
+	slices.Sort(services)
+	if !slices.Equal(services, []string{"nginx", "scanner", "web"}) {
+		t.Errorf("line went to %v, want each service once", services)
+	}
+
+	if err := monitor.RemoveService(path, "nginx"); err != nil {
+		t.Fatalf("RemoveService: %v", err)
+	}
+	expectServices("scanner", "web")
+	if err := monitor.RemoveService(glob, "web"); err != nil {
+		t.Fatalf("RemoveService: %v", err)
+	}
+	expectServices("scanner")
+	if err := monitor.RemoveService(glob, "web"); err == nil {
+		t.Error("removing a pattern twice should fail")
+	}
+	if err := monitor.RemoveService(path, "scanner"); err != nil {
+		t.Fatalf("RemoveService: %v", err)
+	}
+	expectServices()
+}
+
+func TestMonitorResumesFromSavedCheckpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	appendFile(t, path, "before the first run\n")
+	store := storage.NewMemoryStorage()
+
+	first := newTestMonitor(t, store)
+	if err := first.AddLogFile(path, serviceOnly("ssh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitReading(t, first, path)
+	appendFile(t, path, "first run\n")
+	if event := nextEvents(t, first, 1)[0]; event.Line != "first run" {
+		t.Errorf("event = %+v", event)
+	}
+	want := int64(len("before the first run\nfirst run\n"))
+	waitFor(t, "the checkpoint", func() bool {
+		checkpoints := first.Checkpoints()
+		return len(checkpoints) == 1 && checkpoints[0].Offset == want
+	})
+	first.Stop()
+
+	saved, err := store.GetCheckpoints()
+	if err != nil || len(saved) != 1 || saved[0].Path != path || saved[0].Offset != want {
+		t.Fatalf("saved checkpoints = %v (%v), want %s at %d", saved, err, path, want)
+	}
+
+	// Lines written while stopped are read by the next run, and nothing before them
+	appendFile(t, path, "while stopped\n")
+	second := newTestMonitor(t, store)
+	if err := second.AddLogFile(path, serviceOnly("ssh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvents(t, second, 1)[0]; event.Line != "while stopped" {
+		t.Errorf("resumed with %q, want the line written while stopped", event.Line)
+	}
+	select {
+	case event := <-second.Events():
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMonitorRejectsAdditionsAfterStop(t *testing.T) {
+	monitor := newTestMonitor(t, nil)
+	monitor.Stop()
+	if err := monitor.AddLogFile(filepath.Join(t.TempDir(), "auth.log"), serviceOnly("ssh")); err == nil {
+		t.Error("AddLogFile after Stop should fail")
+	}
+	if err := monitor.AddLogFile("[", serviceOnly("ssh")); err == nil {
+		t.Error("an invalid pattern should be rejected")
+	}
+}
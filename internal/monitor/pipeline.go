@@ -0,0 +1,501 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/detector"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/notify"
+	"github.com/sr-tamim/guardian/internal/parser"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// Upper bound on the service/address pairs whose failures are being counted
+const maxTrackedFailures = 10000
+
+// syslogSourceName is the monitor source shared by every service with log_path "syslog"
+const syslogSourceName = SyslogPath
+
+// blockingProvider is what the pipeline needs from a platform provider
+type blockingProvider interface {
+	FirewallManager() *firewall.Manager
+	Storage() core.Storage
+}
+
+// notifyingProvider shares its alert dispatcher, so both pipelines feed one alert queue
+type notifyingProvider interface {
+	Notifier() *notify.Dispatcher
+}
+
+// maintainedProvider is implemented by providers whose cleanup and reconciliation
+// loops normally start with event log monitoring
+type maintainedProvider interface {
+	StartMaintenance(ctx context.Context)
+}
+
+// serviceKey identifies a registered service; a name may be reused for several files
+// (an nginx access and error log both named "Nginx")
+type serviceKey struct{ name, path string }
+
+// failureKey counts failures per service so each service's custom_threshold applies
+type failureKey struct{ service, key string }
+
+// Pipeline feeds the lines of file, journal, container and syslog services through their
+// parsers, the detector and the block manager. Event log services stay with the provider
+type Pipeline struct {
+	monitor  *Monitor
+	firewall *firewall.Manager
+	store    core.Storage
+	detector *detector.Detector
+	notifier *notify.Dispatcher
+	provider core.PlatformProvider
+
+	ownsNotifier bool // the dispatcher was created here and is closed by Stop
+
+	applyMu sync.Mutex // serialises Start and Apply
+
+	mu           sync.Mutex
+	config       *models.Config
+	parsers      map[serviceKey]core.LogParser
+	services     map[serviceKey]models.ServiceConfig // registered services
+	syslog       []models.ServiceConfig              // services routed by the shared receiver
+	syslogConfig models.SyslogConfig                 // listeners of the running receiver
+	failures     map[failureKey][]time.Time
+	done         chan struct{}
+}
+
+// HandlesService reports whether the pipeline reads a service
+// Event log services (event_ids or a numeric log_pattern) are read by the platform provider
+func HandlesService(service models.ServiceConfig) bool {
+	if IsJournalPath(service.LogPath) || IsDockerPath(service.LogPath) || service.LogPath == SyslogPath {
+		return true
+	}
+	_, err := parser.ServiceEventIDs(service)
+	return err != nil && service.LogPath != ""
+}
+
+// NewPipeline creates a pipeline blocking through the provider's block manager
+func NewPipeline(config *models.Config, provider core.PlatformProvider) (*Pipeline, error) {
+	blocker, ok := provider.(blockingProvider)
+	if !ok {
+		return nil, core.NewErrorf(core.ErrPlatformNotSupported, nil, "%s cannot block addresses found in log files", provider.Name())
+	}
+	overflow := config.Monitoring.Overflow
+	monitor := New(queue.FromConfig("events", config.Monitoring.LogBufferSize, overflow.Events, queue.PolicyBlock, overflow))
+	// Files resume where the last run stopped
+	monitor.SetCheckpointStore(blocker.Storage())
+	if config.Monitoring.Backfill {
+		lookback := config.Monitoring.LookbackDuration
+		if lookback <= 0 {
+			lookback = time.Hour
+		}
+		monitor.SetBackfill(lookback)
+	}
+
+	pipeline := &Pipeline{
+		monitor:  monitor,
+		firewall: blocker.FirewallManager(),
+		store:    blocker.Storage(),
+		detector: detector.New(config),
+		provider: provider,
+		config:   config,
+		parsers:  make(map[serviceKey]core.LogParser),
+		services: make(map[serviceKey]models.ServiceConfig),
+		failures: make(map[failureKey][]time.Time),
+	}
+	if notifying, ok := provider.(notifyingProvider); ok {
+		pipeline.notifier = notifying.Notifier()
+	} else {
+		pipeline.notifier = notify.NewDispatcher(queue.FromConfig("alerts", notify.QueueSize, overflow.Alerts, queue.PolicyDropOldest, overflow),
+			notify.Notifiers(config, blocker.Storage())...)
+		pipeline.ownsNotifier = true
+	}
+	return pipeline, nil
+}
+
+// Monitor returns the monitor the pipeline reads from
+func (p *Pipeline) Monitor() *Monitor {
+	return p.monitor
+}
+
+// Start registers the enabled services and processes their lines until Stop
+func (p *Pipeline) Start(ctx context.Context) error {
+	p.mu.Lock()
+	config := p.config
+	p.mu.Unlock()
+	p.apply(config)
+
+	if maintained, ok := p.provider.(maintainedProvider); ok {
+		maintained.StartMaintenance(ctx)
+	}
+	if p.ownsNotifier {
+		p.notifier.Start(ctx)
+	}
+	if err := p.monitor.Start(ctx); err != nil {
+		return err
+	}
+
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		p.process(workerCount(config))
+	}()
+	return nil
+}
+
+// Apply brings the registered sources in line with a reloaded configuration
+// Unchanged services keep their readers and positions
+func (p *Pipeline) Apply(config *models.Config) {
+	p.mu.Lock()
+	p.config = config
+	p.mu.Unlock()
+	p.apply(config)
+}
+
+// Stop stops reading and waits for the queued lines to be processed
+func (p *Pipeline) Stop() error {
+	err := p.monitor.Stop()
+	if p.done != nil {
+		<-p.done
+	}
+	if p.ownsNotifier {
+		p.notifier.Close()
+	}
+	return err
+}
+
+// apply registers new or changed services and removes the others
+func (p *Pipeline) apply(config *models.Config) {
+	p.applyMu.Lock()
+	defer p.applyMu.Unlock()
+
+	wanted := make(map[serviceKey]models.ServiceConfig)
+	for _, service := range config.Services {
+		if service.Enabled && HandlesService(service) {
+			wanted[serviceKey{service.Name, service.LogPath}] = service
+		}
+	}
+
+	p.mu.Lock()
+	registered := p.services
+	p.mu.Unlock()
+
+	for key, service := range registered {
+		if current, keep := wanted[key]; !keep || !reflect.DeepEqual(current, service) {
+			p.unregister(service)
+		}
+	}
+
+	for _, service := range config.Services {
+		key := serviceKey{service.Name, service.LogPath}
+		if _, isWanted := wanted[key]; !isWanted {
+			continue
+		}
+		p.mu.Lock()
+		_, exists := p.services[key]
+		p.mu.Unlock()
+		if exists {
+			continue
+		}
+		if err := p.register(config, service); err != nil {
+			logger.Warn("Service not monitored", "service", service.Name, "log_path", service.LogPath, "error", err)
+		}
+	}
+
+	p.syncSyslog(config)
+}
+
+// register adds a service's parser and source
+func (p *Pipeline) register(config *models.Config, service models.ServiceConfig) error {
+	logParser, err := parser.New(service)
+	if err != nil {
+		return err
+	}
+	logParser = ServiceParser(logParser, service.Name)
+
+	lookback := config.Monitoring.LookbackDuration
+	switch {
+	case service.LogPath == SyslogPath:
+		// Routed by the shared receiver, see syncSyslog
+	case IsJournalPath(service.LogPath):
+		source, err := NewJournalSource(service, utils.NewPlatformPaths().GetDefaultDataDir(), lookback)
+		if err != nil {
+			return err
+		}
+		if err := p.monitor.AddSource(sourceName(service), source); err != nil {
+			return err
+		}
+	case IsDockerPath(service.LogPath):
+		source, err := NewDockerSource(service, lookback)
+		if err != nil {
+			return err
+		}
+		if err := p.monitor.AddSource(sourceName(service), source); err != nil {
+			return err
+		}
+	default:
+		if err := p.monitor.AddLogFile(service.LogPath, logParser); err != nil {
+			return err
+		}
+	}
+
+	key := serviceKey{service.Name, service.LogPath}
+	p.mu.Lock()
+	p.parsers[key] = logParser
+	p.services[key] = service
+	p.mu.Unlock()
+	logger.LogMonitoringStart(config, service.Name, service.LogPath, "LogMonitor")
+	return nil
+}
+
+// unregister removes a service's parser and source
+func (p *Pipeline) unregister(service models.ServiceConfig) {
+	key := serviceKey{service.Name, service.LogPath}
+	p.mu.Lock()
+	delete(p.parsers, key)
+	delete(p.services, key)
+	p.mu.Unlock()
+
+	var err error
+	switch {
+	case service.LogPath == SyslogPath:
+		// syncSyslog rebuilds the receiver
+	case IsJournalPath(service.LogPath), IsDockerPath(service.LogPath):
+		err = p.monitor.RemoveSource(sourceName(service))
+	default:
+		err = p.monitor.RemoveService(service.LogPath, service.Name)
+	}
+	if err != nil {
+		logger.Warn("Failed to stop monitoring service", "service", service.Name, "error", err)
+		return
+	}
+	logger.Info("Stopped monitoring service", "service", service.Name, "log_path", service.LogPath)
+}
+
+// syncSyslog restarts the shared receiver when its services or listeners changed
+func (p *Pipeline) syncSyslog(config *models.Config) {
+	var services []models.ServiceConfig
+	p.mu.Lock()
+	for _, service := range p.services {
+		if service.LogPath == SyslogPath {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	running := p.monitor.hasSource(syslogSourceName)
+	unchanged := reflect.DeepEqual(services, p.syslog) && reflect.DeepEqual(config.Syslog, p.syslogConfig) &&
+		running == (len(services) > 0 && config.Syslog.Enabled())
+	p.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	if running {
+		if err := p.monitor.RemoveSource(syslogSourceName); err != nil {
+			logger.Warn("Failed to stop syslog receiver", "error", err)
+		}
+	}
+	p.mu.Lock()
+	p.syslog, p.syslogConfig = services, config.Syslog
+	p.mu.Unlock()
+	if len(services) == 0 {
+		return
+	}
+	if !config.Syslog.Enabled() {
+		logger.Warn("Services use log_path syslog but no syslog listener is configured", "services", len(services))
+		return
+	}
+
+	receiver, err := NewSyslogReceiver(config.Syslog, services)
+	if err == nil {
+		err = p.monitor.AddSource(syslogSourceName, receiver)
+	}
+	if err != nil {
+		logger.Error("Failed to start syslog receiver", "error", err)
+	}
+}
+
+// handle parses one line and blocks its address once the service's threshold is reached
+func (p *Pipeline) handle(event core.LogEvent) {
+	p.mu.Lock()
+	logParser := p.parserFor(event)
+	config := p.config
+	p.mu.Unlock()
+	if logParser == nil {
+		return
+	}
+
+	var attempt *models.AttackAttempt
+	var err error
+	if sourceParser, ok := logParser.(parser.SourceParser); ok {
+		attempt, err = sourceParser.ParseLineFrom(event.Source, event.Line)
+	} else {
+		attempt, err = logParser.ParseLine(event.Line)
+	}
+	if err != nil || attempt == nil || attempt.IP == "" {
+		return
+	}
+	if attempt.Timestamp.IsZero() {
+		attempt.Timestamp = event.Timestamp
+	}
+
+	// Whitelist is checked per address, before IPv6 addresses collapse into their /64
+	if p.detector.IsWhitelisted(attempt.IP) {
+		return
+	}
+
+	window := config.Monitoring.LookbackDuration
+	if window <= 0 {
+		window = time.Hour
+	}
+	now := time.Now()
+	seen := now
+	if event.Backfill {
+		// History older than the window is kept for reference but never counts toward a block
+		if attempt.Timestamp.Before(now.Add(-window)) {
+			if err := p.store.SaveAttack(attempt); err != nil {
+				logger.Warn("Failed to store attack attempt", "ip", attempt.IP, "error", err)
+			}
+			return
+		}
+		seen = attempt.Timestamp
+	}
+
+	assessment := p.detector.AnalyzeAttack(attempt)
+	attempt.Severity = assessment.Severity
+	if err := p.store.SaveAttack(attempt); err != nil {
+		logger.Warn("Failed to store attack attempt", "ip", attempt.IP, "error", err)
+	}
+	if !event.Backfill {
+		logger.LogAttackAttempt(config, attempt.IP, attempt.Service, attempt.Username, attempt.Severity.String())
+		p.notifier.Notify(notify.NewEvent(models.EventAttackDetected, attempt.Service, attempt.IP, attempt.Severity,
+			fmt.Sprintf("%s failure from %s (user %q): %s", attempt.Service, attempt.IP, attempt.Username, assessment.Reason)))
+	}
+
+	// Cross-username rules: spraying blocks the address, stuffing only alerts
+	var spraying *models.Alert
+	for _, alert := range p.detector.Observe(attempt) {
+		if err := p.store.SaveAlert(alert); err != nil {
+			logger.Warn("Failed to store detection alert", "kind", alert.Kind, "error", err)
+		}
+		p.notifier.Notify(alert)
+		if alert.Kind == models.AlertPasswordSpraying {
+			spraying = alert
+		}
+	}
+
+	key, err := config.Blocking.AggregationKey(attempt.IP)
+	if err != nil {
+		return
+	}
+	counter := failureKey{service: attempt.Service, key: key}
+	count := p.countFailure(counter, seen, now, window)
+
+	threshold := config.ThresholdFor(attempt.Service)
+	var reason string
+	switch {
+	case spraying != nil:
+		reason = spraying.Message
+	case assessment.ShouldBlock:
+		reason = fmt.Sprintf("%s high-confidence attack (%.0f%%): %s", attempt.Service, assessment.Confidence*100, assessment.Reason)
+	case count >= threshold:
+		reason = fmt.Sprintf("%s failure threshold exceeded: %d attempts in %s", attempt.Service, count, window)
+	default:
+		if count == threshold-1 && threshold > 1 {
+			p.notifier.Notify(notify.NewEvent(models.EventThresholdNear, attempt.Service, key, attempt.Severity,
+				fmt.Sprintf("%s: %d of %d failures from %s within %s; the next one blocks it", attempt.Service, count, threshold, key, window)))
+		}
+		return
+	}
+
+	err = p.firewall.BlockAttempt(key, config.Blocking.BlockDuration, reason, attempt.Service, attempt.Severity)
+	var guardianErr *core.GuardianError
+	if errors.As(err, &guardianErr) && guardianErr.Code == core.ErrProtectionPaused {
+		// The count is kept, so the address is blocked on its next failure after protection resumes
+		logger.Debug("Block skipped while protection is paused", "ip", key, "service", attempt.Service)
+		return
+	}
+	if err != nil && !(errors.As(err, &guardianErr) && guardianErr.Code == core.ErrIPAlreadyBlocked) {
+		logger.Warn("Failed to block IP after threshold exceeded", "ip", key, "service", attempt.Service, "error", err)
+		return
+	}
+	p.mu.Lock()
+	delete(p.failures, counter)
+	p.mu.Unlock()
+}
+
+// parserFor finds the parser of the service an event was read for
+// A file event goes to the service whose path or pattern names the file
+func (p *Pipeline) parserFor(event core.LogEvent) core.LogParser {
+	var fallback core.LogParser
+	for key, logParser := range p.parsers {
+		if key.name != event.Service {
+			continue
+		}
+		if key.path == event.Source {
+			return logParser
+		}
+		if matched, _ := filepath.Match(key.path, event.Source); matched {
+			return logParser
+		}
+		fallback = logParser
+	}
+	return fallback
+}
+
+// countFailure records a failure seen at the given time and returns how many fell within the window
+func (p *Pipeline) countFailure(counter failureKey, seen, now time.Time, window time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := now.Add(-window)
+	times := p.failures[counter]
+	kept := times[:0]
+	for _, seen := range times {
+		if seen.After(cutoff) {
+			kept = append(kept, seen)
+		}
+	}
+
+	if _, exists := p.failures[counter]; !exists && len(p.failures) >= maxTrackedFailures {
+		p.evictFailures(cutoff)
+	}
+	p.failures[counter] = append(kept, seen)
+	return len(p.failures[counter])
+}
+
+// evictFailures drops expired counters, or the stalest one if none expired
+func (p *Pipeline) evictFailures(cutoff time.Time) {
+	var stalest failureKey
+	var stalestSeen time.Time
+	for counter, times := range p.failures {
+		last := times[len(times)-1]
+		if !last.After(cutoff) {
+			delete(p.failures, counter)
+			continue
+		}
+		if stalestSeen.IsZero() || last.Before(stalestSeen) {
+			stalest, stalestSeen = counter, last
+		}
+	}
+	if len(p.failures) >= maxTrackedFailures {
+		delete(p.failures, stalest)
+	}
+}
+
+// sourceName names the monitor source of a journal or container service
+func sourceName(service models.ServiceConfig) string {
+	return strings.ToLower(service.Name) + "/" + service.LogPath
+}
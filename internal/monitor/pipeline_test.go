@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/detector"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/notify"
+	"github.com/sr-tamim/guardian/internal/parser"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/internal/storage"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// recordingBackend remembers the addresses the pipeline blocked
+type recordingBackend struct {
+	mu      sync.Mutex
+	blocked []string
+}
+
+func (b *recordingBackend) BlockIP(ip string, duration time.Duration, reason string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked = append(b.blocked, ip)
+	return nil
+}
+
+func (b *recordingBackend) UnblockIP(ip string) error {
+	return nil
+}
+
+func (b *recordingBackend) addresses() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.blocked...)
+}
+
+// newTestPipeline builds a pipeline around one service without starting any sources
+func newTestPipeline(t testing.TB, service models.ServiceConfig) (*Pipeline, *recordingBackend) {
+	t.Helper()
+	config := &models.Config{
+		Monitoring: models.MonitoringConfig{LookbackDuration: time.Hour},
+		Blocking:   models.BlockingConfig{FailureThreshold: 1, BlockDuration: time.Hour},
+		Services:   []models.ServiceConfig{service},
+	}
+	logParser, err := parser.New(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &recordingBackend{}
+	notifier := notify.NewDispatcher(queue.Options{Name: "alerts", Capacity: 16, Policy: queue.PolicyDropOldest})
+	t.Cleanup(notifier.Close)
+	key := serviceKey{service.Name, service.LogPath}
+	return &Pipeline{
+		firewall: firewall.NewManager(config, backend),
+		store:    storage.NewMemoryStorage(),
+		detector: detector.New(config),
+		notifier: notifier,
+		config:   config,
+		parsers:  map[serviceKey]core.LogParser{key: ServiceParser(logParser, service.Name)},
+		services: map[serviceKey]models.ServiceConfig{key: service},
+		failures: make(map[failureKey][]time.Time),
+	}, backend
+}
+
+func TestPipelineKeepsW3CHeadersPerFile(t *testing.T) {
+	service := models.ServiceConfig{Name: "IIS", LogPath: `C:\inetpub\logs\LogFiles\*\*.log`, LogPattern: "iis", Enabled: true}
+	pipeline, backend := newTestPipeline(t, service)
+
+	siteA := `C:\inetpub\logs\LogFiles\W3SVC1\u_ex240115.log`
+	siteB := `C:\inetpub\logs\LogFiles\W3SVC2\u_ex240115.log`
+	events := []core.LogEvent{
+		{Source: siteA, Line: "#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) sc-status sc-substatus sc-win32-status time-taken"},
+		{Source: siteB, Line: "#Fields: date time c-ip s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username cs(User-Agent) sc-status sc-substatus sc-win32-status time-taken"},
+		{Source: siteA, Line: `2024-01-15 10:23:45 10.0.0.1 GET /Microsoft-Server-ActiveSync/default.eas User=bob 443 CONTOSO\bob 203.0.113.5 Android-Mail 401 1 1326 15`},
+		{Source: siteB, Line: `2024-01-15 10:23:46 198.51.100.7 10.0.0.2 GET /Microsoft-Server-ActiveSync/default.eas User=eve 443 CONTOSO\eve Android-Mail 401 1 1326 15`},
+	}
+	for _, event := range events {
+		event.Service = service.Name
+		event.Timestamp = time.Now()
+		pipeline.handle(event)
+	}
+
+	got := backend.addresses()
+	if len(got) != 2 || got[0] != "203.0.113.5" || got[1] != "198.51.100.7" {
+		t.Errorf("blocked %v, want the client addresses [203.0.113.5 198.51.100.7]", got)
+	}
+}
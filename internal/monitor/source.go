@@ -0,0 +1,49 @@
+// Package monitor reads log sources and turns them into core.LogEvent streams
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+)
+
+// Source produces log events until its context is cancelled
+type Source interface {
+	// Name identifies the source in logs and LogEvent.Source
+	Name() string
+	// Run reads the source and sends its events; it returns when ctx is done or the source cannot continue
+	Run(ctx context.Context, events chan<- core.LogEvent) error
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// stateFile returns a file in dir named after a source, safe on every platform
+func stateFile(dir, name, extension string) string {
+	return filepath.Join(dir, strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_")+extension)
+}
+
+// writeStateFile replaces path atomically so a crash never leaves half a state file
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// syslogLine renders a message the way rsyslog writes it with high-precision timestamps
+// Sources that are not plain files use it so the syslog-based parsers apply unchanged
+// Multi-line messages are joined, as syslog daemons do
+func syslogLine(timestamp time.Time, host, tag, message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	return fmt.Sprintf("%s %s %s: %s", timestamp.Format("2006-01-02T15:04:05.000000Z07:00"), host, tag, message)
+}
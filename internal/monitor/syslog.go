@@ -0,0 +1,430 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// SyslogPath selects the built-in syslog receiver as a service's log_path
+const SyslogPath = "syslog"
+
+const (
+	defaultSyslogMessageSize = 64 * 1024
+	maxSyslogConnections     = 512
+	syslogIdleTimeout        = 10 * time.Minute
+)
+
+// syslogRoute sends matching messages to one service
+// Empty pattern lists match everything
+type syslogRoute struct {
+	service  string
+	appNames []string // lower-cased path.Match patterns
+	hosts    []string // lower-cased hostname patterns, or sender addresses/CIDRs
+}
+
+// SyslogReceiver listens for syslog over UDP, TCP and TLS and routes messages to services
+// TCP and TLS accept both octet-counted (RFC 6587/5425) and newline-delimited framing
+type SyslogReceiver struct {
+	config  models.SyslogConfig
+	routes  []syslogRoute
+	maxSize int
+	tls     *tls.Config
+
+	mu          sync.Mutex
+	listeners   []io.Closer
+	connections map[net.Conn]struct{}
+}
+
+// NewSyslogReceiver creates a receiver feeding the services whose log_path is "syslog"
+func NewSyslogReceiver(config models.SyslogConfig, services []models.ServiceConfig) (*SyslogReceiver, error) {
+	if !config.Enabled() {
+		return nil, core.NewError(core.ErrConfigInvalid, "syslog receiver has no udp_address, tcp_address or tls_address", nil)
+	}
+
+	// Anyone who can reach the receiver could forge lines that get addresses blocked
+	if len(config.AllowedSenders) == 0 {
+		for _, address := range []string{config.UDPAddress, config.TCPAddress, config.TLSAddress} {
+			if address != "" && !loopbackAddress(address) {
+				return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+					"syslog receiver listens on %s but syslog.allowed_senders is empty; list the senders or listen on 127.0.0.1", address)
+			}
+		}
+	}
+
+	receiver := &SyslogReceiver{
+		config:      config,
+		maxSize:     config.MaxMessageSize,
+		connections: make(map[net.Conn]struct{}),
+	}
+	if receiver.maxSize <= 0 {
+		receiver.maxSize = defaultSyslogMessageSize
+	}
+
+	for _, service := range services {
+		if !service.Enabled || service.LogPath != SyslogPath {
+			continue
+		}
+		receiver.routes = append(receiver.routes, syslogRoute{
+			service:  service.Name,
+			appNames: lowerAll(service.SyslogAppNames),
+			hosts:    lowerAll(service.SyslogHosts),
+		})
+	}
+	if len(receiver.routes) == 0 {
+		return nil, core.NewError(core.ErrConfigInvalid, `syslog receiver enabled but no service has log_path "syslog"`, nil)
+	}
+
+	if config.TLSAddress != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, core.NewError(core.ErrConfigInvalid, "cannot load syslog TLS certificate", err)
+		}
+		receiver.tls = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	}
+	return receiver, nil
+}
+
+// Name identifies the source
+func (r *SyslogReceiver) Name() string {
+	return SyslogPath
+}
+
+// Run listens until ctx is done
+func (r *SyslogReceiver) Run(ctx context.Context, events chan<- core.LogEvent) error {
+	var wg sync.WaitGroup
+	if r.config.UDPAddress != "" {
+		conn, err := net.ListenPacket("udp", r.config.UDPAddress)
+		if err != nil {
+			r.closeAll()
+			return core.NewErrorf(core.ErrPlatformRequirements, err, "cannot listen for syslog on udp %s", r.config.UDPAddress)
+		}
+		r.trackListener(conn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.serveUDP(ctx, conn, events)
+		}()
+	}
+
+	for _, listen := range []struct {
+		address string
+		tls     bool
+	}{{r.config.TCPAddress, false}, {r.config.TLSAddress, true}} {
+		if listen.address == "" {
+			continue
+		}
+		listener, err := net.Listen("tcp", listen.address)
+		if err != nil {
+			r.closeAll()
+			wg.Wait()
+			return core.NewErrorf(core.ErrPlatformRequirements, err, "cannot listen for syslog on tcp %s", listen.address)
+		}
+		if listen.tls {
+			listener = tls.NewListener(listener, r.tls)
+		}
+		r.trackListener(listener)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.serveStream(ctx, listener, events)
+		}()
+	}
+
+	logger.Info("Syslog receiver listening",
+		"udp", r.config.UDPAddress,
+		"tcp", r.config.TCPAddress,
+		"tls", r.config.TLSAddress,
+		"routes", len(r.routes))
+
+	<-ctx.Done()
+	r.closeAll()
+	wg.Wait()
+	return nil
+}
+
+// serveUDP handles one message per datagram
+func (r *SyslogReceiver) serveUDP(ctx context.Context, conn net.PacketConn, events chan<- core.LogEvent) {
+	buffer := make([]byte, r.maxSize)
+	for {
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logger.Warn("Syslog UDP read failed", "error", err)
+				continue
+			}
+			return
+		}
+		peerHost := hostOf(peer)
+		if !r.allowed(peerHost) {
+			continue
+		}
+		r.dispatch(ctx, string(buffer[:n]), peerHost, events)
+	}
+}
+
+// serveStream accepts TCP or TLS connections
+func (r *SyslogReceiver) serveStream(ctx context.Context, listener net.Listener, events chan<- core.LogEvent) {
+	var active sync.WaitGroup
+	defer active.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Warn("Syslog accept failed", "error", err)
+			continue
+		}
+
+		peerHost := hostOf(conn.RemoteAddr())
+		if !r.allowed(peerHost) {
+			logger.Debug("Syslog connection from a sender outside allowed_senders refused", "peer", peerHost)
+			conn.Close()
+			continue
+		}
+		if !r.trackConnection(conn) {
+			logger.Warn("Too many syslog connections, refusing", "peer", peerHost, "limit", maxSyslogConnections)
+			conn.Close()
+			continue
+		}
+
+		active.Add(1)
+		go func() {
+			defer active.Done()
+			defer r.untrack(conn)
+			r.serveConnection(ctx, conn, peerHost, events)
+		}()
+	}
+}
+
+// serveConnection reads frames until the sender disconnects or goes idle
+func (r *SyslogReceiver) serveConnection(ctx context.Context, conn net.Conn, peerHost string, events chan<- core.LogEvent) {
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+		frame, err := readSyslogFrame(reader, r.maxSize)
+		if frame != "" {
+			r.dispatch(ctx, frame, peerHost, events)
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logger.Debug("Syslog connection closed", "peer", peerHost, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// readSyslogFrame reads one message: "<length> <message>" when the frame starts with a digit
+// (octet counting), otherwise up to the next newline; messages beyond max are truncated
+func readSyslogFrame(reader *bufio.Reader, max int) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		// At most 10 digits, so a sender cannot stream an endless length
+		var header []byte
+		for {
+			c, err := reader.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || len(header) == 10 {
+				return "", core.NewErrorf(core.ErrLogParseError, nil, "invalid syslog frame length %q", append(header, c))
+			}
+			header = append(header, c)
+		}
+		length, err := strconv.Atoi(string(header))
+		if err != nil || length <= 0 {
+			return "", core.NewErrorf(core.ErrLogParseError, err, "invalid syslog frame length %q", header)
+		}
+		keep := min(length, max)
+		frame := make([]byte, keep)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return "", err
+		}
+		if _, err := reader.Discard(length - keep); err != nil {
+			return string(frame), err
+		}
+		return string(frame), nil
+	}
+
+	var frame []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(frame) < max {
+			frame = append(frame, chunk[:min(len(chunk), max-len(frame))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return strings.TrimRight(string(frame), "\r\n"), err
+	}
+}
+
+// dispatch parses a message and sends it to every service whose route matches
+func (r *SyslogReceiver) dispatch(ctx context.Context, raw, peerHost string, events chan<- core.LogEvent) {
+	message, err := ParseSyslogMessage(raw)
+	if err != nil {
+		logger.Debug("Unparseable syslog message", "peer", peerHost, "error", err)
+		return
+	}
+
+	host := message.Hostname
+	if host == "" {
+		host = peerHost
+	}
+	line := syslogLine(message.Timestamp, host, message.Tag(), message.Message)
+
+	for _, route := range r.routes {
+		if !route.matches(message, peerHost) {
+			continue
+		}
+		event := core.LogEvent{
+			Timestamp: message.Timestamp,
+			Source:    SyslogPath + ":" + host,
+			Line:      line,
+			Service:   route.service,
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// matches applies a route's app-name and host rules
+// Host rules match the message HOSTNAME by pattern or the sender by address/CIDR
+func (route syslogRoute) matches(message *SyslogMessage, peerHost string) bool {
+	if len(route.appNames) > 0 && !matchAny(route.appNames, strings.ToLower(message.AppName)) {
+		return false
+	}
+	if len(route.hosts) == 0 {
+		return true
+	}
+	hostname := strings.ToLower(message.Hostname)
+	for _, host := range route.hosts {
+		if utils.TargetContains(host, peerHost) || (hostname != "" && matchPattern(host, hostname)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed checks a sender against syslog.allowed_senders
+// An empty list is only accepted for loopback listeners (see NewSyslogReceiver)
+func (r *SyslogReceiver) allowed(peerHost string) bool {
+	if len(r.config.AllowedSenders) == 0 {
+		return true
+	}
+	for _, sender := range r.config.AllowedSenders {
+		if utils.TargetContains(strings.TrimSpace(sender), peerHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackListener registers a listener for shutdown
+func (r *SyslogReceiver) trackListener(listener io.Closer) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, listener)
+	r.mu.Unlock()
+}
+
+// trackConnection registers a connection for shutdown; connections beyond the limit are refused
+func (r *SyslogReceiver) trackConnection(conn net.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.connections) >= maxSyslogConnections {
+		return false
+	}
+	r.connections[conn] = struct{}{}
+	return true
+}
+
+func (r *SyslogReceiver) untrack(conn net.Conn) {
+	r.mu.Lock()
+	delete(r.connections, conn)
+	r.mu.Unlock()
+	conn.Close()
+}
+
+// closeAll closes every listener and open connection
+func (r *SyslogReceiver) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, listener := range r.listeners {
+		listener.Close()
+	}
+	r.listeners = nil
+	for conn := range r.connections {
+		conn.Close()
+	}
+}
+
+// loopbackAddress reports whether a listen address only accepts local senders
+func loopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := utils.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func hostOf(address net.Addr) string {
+	host, _, err := net.SplitHostPort(address.String())
+	if err != nil {
+		return address.String()
+	}
+	return host
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches shell-style patterns such as "postfix/*" or "fw-*"
+func matchPattern(pattern, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+	return lowered
+}
@@ -0,0 +1,267 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestReadSyslogFrame(t *testing.T) {
+	stream := "58 <34>1 2024-01-15T10:23:45Z fw01 sshd 1234 - - Failed login" +
+		"<13>Jan 15 10:23:45 web01 cron[1]: job\r\n" +
+		"20 0123456789abcdefghij" +
+		"<13>too long for the limit\n" +
+		"<13>no newline at the end"
+	reader := bufio.NewReader(strings.NewReader(stream))
+
+	want := []string{
+		"<34>1 2024-01-15T10:23:45Z fw01 sshd 1234 - - Failed login",
+		"<13>Jan 15 10:23:45 web01 cron[1]: job",
+		"0123456789abcdef", // octet counted, truncated, the rest discarded
+		"<13>too long for th",
+		"<13>no newline at th",
+	}
+	limits := []int{1024, 1024, 16, 19, 20}
+	for i, expected := range want {
+		frame, err := readSyslogFrame(reader, limits[i])
+		if err != nil && !(err == io.EOF && i == len(want)-1) {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if frame != expected {
+			t.Errorf("frame %d = %q, want %q", i, frame, expected)
+		}
+	}
+	if _, err := readSyslogFrame(reader, 1024); err != io.EOF {
+		t.Errorf("after the last frame err = %v, want EOF", err)
+	}
+}
+
+func TestReadSyslogFrameRejectsBadFrames(t *testing.T) {
+	for name, stream := range map[string]string{
+		"truncated":      "40 <34>1 - fw01 sshd - - - short",
+		"endless length": "12345678901 <34>message",
+		"not a length":   "12a <34>message",
+		"missing space":  "12",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readSyslogFrame(bufio.NewReader(strings.NewReader(stream)), 1024); err == nil {
+				t.Errorf("expected an error for %q", stream)
+			}
+		})
+	}
+}
+
+func TestParseSyslogMessage(t *testing.T) {
+	tests := []struct {
+		name                           string
+		raw                            string
+		facility, severity             int
+		hostname, appName, procID      string
+		msgID, structuredData, message string
+		timestamp                      time.Time
+	}{
+		{
+			name:     "RFC 5424",
+			raw:      "<34>1 2024-01-15T10:23:45.123Z fw01 sshd 1234 ID47 - Failed password for root from 203.0.113.5 port 22 ssh2",
+			facility: 4, severity: 2,
+			hostname: "fw01", appName: "sshd", procID: "1234", msgID: "ID47",
+			message:   "Failed password for root from 203.0.113.5 port 22 ssh2",
+			timestamp: time.Date(2024, 1, 15, 10, 23, 45, 123000000, time.UTC),
+		},
+		{
+			name:     "RFC 5424 structured data and BOM",
+			raw:      `<165>1 2024-01-15T10:23:45Z mail01 postfix/smtpd - - [origin ip="192.0.2.1"][meta note="a \] b"] ` + "\ufeffwarning: unknown[203.0.113.9]: SASL LOGIN authentication failed",
+			facility: 20, severity: 5,
+			hostname: "mail01", appName: "postfix/smtpd",
+			structuredData: `[origin ip="192.0.2.1"][meta note="a \] b"]`,
+			message:        "warning: unknown[203.0.113.9]: SASL LOGIN authentication failed",
+			timestamp:      time.Date(2024, 1, 15, 10, 23, 45, 0, time.UTC),
+		},
+		{
+			name:     "RFC 5424 nil values",
+			raw:      "<14>1 - - - - - -",
+			facility: 1, severity: 6,
+		},
+		{
+			name:     "RFC 3164",
+			raw:      "<38>Jan 15 10:23:45 web01 sshd[1234]: Invalid user admin from 198.51.100.7",
+			facility: 4, severity: 6,
+			hostname: "web01", appName: "sshd", procID: "1234",
+			message: "Invalid user admin from 198.51.100.7",
+		},
+		{
+			name:     "RFC 3164 without hostname",
+			raw:      "<13>Jan  5 01:02:03 cron: job started",
+			facility: 1, severity: 5,
+			appName: "cron",
+			message: "job started",
+		},
+		{
+			name:     "RFC 3164 with RFC 3339 timestamp",
+			raw:      "<13>2024-01-15T10:23:45Z fw01 kernel: DROP IN=eth0",
+			facility: 1, severity: 5,
+			hostname: "fw01", appName: "kernel",
+			message:   "DROP IN=eth0",
+			timestamp: time.Date(2024, 1, 15, 10, 23, 45, 0, time.UTC),
+		},
+		{
+			name:     "no priority",
+			raw:      "sshd[7]: Connection closed by 192.0.2.4\n",
+			facility: 1, severity: 5,
+			appName: "sshd", procID: "7",
+			message: "Connection closed by 192.0.2.4",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			message, err := ParseSyslogMessage(tc.raw)
+			if err != nil {
+				t.Fatalf("ParseSyslogMessage: %v", err)
+			}
+			if message.Facility != tc.facility || message.Severity != tc.severity {
+				t.Errorf("priority = %d.%d, want %d.%d", message.Facility, message.Severity, tc.facility, tc.severity)
+			}
+			if message.Hostname != tc.hostname || message.AppName != tc.appName || message.ProcID != tc.procID {
+				t.Errorf("hostname/app/procid = %q/%q/%q, want %q/%q/%q",
+					message.Hostname, message.AppName, message.ProcID, tc.hostname, tc.appName, tc.procID)
+			}
+			if message.MsgID != tc.msgID || message.StructuredData != tc.structuredData {
+				t.Errorf("msgid/sd = %q/%q, want %q/%q", message.MsgID, message.StructuredData, tc.msgID, tc.structuredData)
+			}
+			if message.Message != tc.message {
+				t.Errorf("message = %q, want %q", message.Message, tc.message)
+			}
+			if !tc.timestamp.IsZero() && !message.Timestamp.Equal(tc.timestamp) {
+				t.Errorf("timestamp = %v, want %v", message.Timestamp, tc.timestamp)
+			}
+			if message.Timestamp.IsZero() {
+				t.Error("timestamp should default to the receive time")
+			}
+		})
+	}
+}
+
+func TestParseSyslogMessageRejectsMalformed(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"<>message",
+		"<999>message",
+		"<34>1 2024-01-15T10:23:45Z fw01",
+		"<34>1 yesterday fw01 sshd - - - message",
+		`<34>1 - fw01 sshd - - [origin ip="192.0.2.1" message`,
+		"<34>1 - fw01 sshd - - message",
+	} {
+		if _, err := ParseSyslogMessage(raw); err == nil {
+			t.Errorf("expected an error for %q", raw)
+		}
+	}
+}
+
+func TestSyslogRouteMatches(t *testing.T) {
+	routes := map[string]syslogRoute{
+		"any":      {service: "any"},
+		"ssh":      {service: "ssh", appNames: lowerAll([]string{"SSHD"})},
+		"postfix":  {service: "postfix", appNames: lowerAll([]string{"postfix/*"})},
+		"firewall": {service: "firewall", hosts: lowerAll([]string{"fw-*", "10.0.0.0/8"})},
+		"both":     {service: "both", appNames: []string{"sshd"}, hosts: []string{"bastion"}},
+	}
+	tests := []struct {
+		hostname, appName, peer string
+		want                    []string
+	}{
+		{"web01", "sshd", "192.0.2.1", []string{"any", "ssh"}},
+		{"mail01", "postfix/smtpd", "192.0.2.2", []string{"any", "postfix"}},
+		{"FW-Edge", "kernel", "192.0.2.3", []string{"any", "firewall"}},
+		{"", "kernel", "10.1.2.3", []string{"any", "firewall"}},
+		{"bastion", "sshd", "192.0.2.4", []string{"any", "ssh", "both"}},
+		{"bastion", "cron", "192.0.2.4", []string{"any"}},
+	}
+	for _, tc := range tests {
+		message := &SyslogMessage{Hostname: tc.hostname, AppName: tc.appName}
+		var matched []string
+		for _, name := range []string{"any", "ssh", "postfix", "firewall", "both"} {
+			if routes[name].matches(message, tc.peer) {
+				matched = append(matched, name)
+			}
+		}
+		if strings.Join(matched, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s/%s from %s matched %v, want %v", tc.hostname, tc.appName, tc.peer, matched, tc.want)
+		}
+	}
+}
+
+func TestSyslogReceiverDispatchesToRoutes(t *testing.T) {
+	receiver, err := NewSyslogReceiver(models.SyslogConfig{UDPAddress: "127.0.0.1:5514"}, []models.ServiceConfig{
+		{Name: "ssh", Enabled: true, LogPath: SyslogPath, SyslogAppNames: []string{"sshd"}},
+		{Name: "mail", Enabled: true, LogPath: SyslogPath, SyslogAppNames: []string{"postfix/*"}},
+		{Name: "file", Enabled: true, LogPath: "/var/log/auth.log"},
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogReceiver: %v", err)
+	}
+
+	events := make(chan core.LogEvent, 4)
+	receiver.dispatch(context.Background(), "<38>Jan 15 10:23:45 web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2", "192.0.2.1", events)
+	receiver.dispatch(context.Background(), "<38>1 - - sshd - - - Invalid user admin from 198.51.100.7", "192.0.2.2", events)
+	receiver.dispatch(context.Background(), "<38>1 - - cron - - - ignored", "192.0.2.2", events)
+	close(events)
+
+	var got []core.LogEvent
+	for event := range events {
+		got = append(got, event)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(got), got)
+	}
+	if got[0].Service != "ssh" || got[0].Source != "syslog:web01" ||
+		!strings.HasSuffix(got[0].Line, " web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2") {
+		t.Errorf("first event = %+v", got[0])
+	}
+	// Without a HOSTNAME the sender address stands in
+	if got[1].Source != "syslog:192.0.2.2" || !strings.Contains(got[1].Line, " 192.0.2.2 sshd: ") {
+		t.Errorf("second event = %+v", got[1])
+	}
+}
+
+func TestSyslogReceiverRequiresAllowedSenders(t *testing.T) {
+	services := []models.ServiceConfig{{Name: "ssh", Enabled: true, LogPath: SyslogPath}}
+	tests := []struct {
+		config models.SyslogConfig
+		valid  bool
+	}{
+		{models.SyslogConfig{UDPAddress: ":514"}, false},
+		{models.SyslogConfig{UDPAddress: "0.0.0.0:514"}, false},
+		{models.SyslogConfig{UDPAddress: "127.0.0.1:514", TCPAddress: "192.0.2.10:514"}, false},
+		{models.SyslogConfig{UDPAddress: "127.0.0.1:514", TCPAddress: "[::1]:514"}, true},
+		{models.SyslogConfig{TCPAddress: "localhost:514"}, true},
+		{models.SyslogConfig{UDPAddress: ":514", AllowedSenders: []string{"10.0.0.0/8"}}, true},
+	}
+	for _, tc := range tests {
+		_, err := NewSyslogReceiver(tc.config, services)
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%+v: err = %v, want valid=%v", tc.config, err, tc.valid)
+		}
+	}
+}
+
+func TestSyslogReceiverAllowedSenders(t *testing.T) {
+	receiver := &SyslogReceiver{config: models.SyslogConfig{AllowedSenders: []string{"10.0.0.0/8", " 192.0.2.7 ", "2001:db8::/32"}}}
+	for peer, want := range map[string]bool{
+		"10.20.30.40": true,
+		"192.0.2.7":   true,
+		"192.0.2.8":   false,
+		"2001:db8::1": true,
+		"203.0.113.5": false,
+		"not-an-ip":   false,
+	} {
+		if got := receiver.allowed(peer); got != want {
+			t.Errorf("allowed(%s) = %v, want %v", peer, got, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package monitor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+)
+
+// SyslogMessage is a received syslog message in either wire format
+type SyslogMessage struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string // "" when the sender left it out
+	AppName        string // RFC 5424 APP-NAME or RFC 3164 TAG
+	ProcID         string
+	MsgID          string // RFC 5424 only
+	StructuredData string // RFC 5424 only, raw
+	Message        string
+}
+
+// Tag returns "app[procid]" as syslog writes it
+func (m *SyslogMessage) Tag() string {
+	tag := m.AppName
+	if tag == "" {
+		tag = "-"
+	}
+	if m.ProcID != "" {
+		tag += "[" + m.ProcID + "]"
+	}
+	return tag
+}
+
+// ParseSyslogMessage parses an RFC 5424 or RFC 3164 message
+//   - RFC 5424: "<34>1 2024-01-15T10:23:45.123Z fw01 sshd 1234 - - Failed password ..."
+//   - RFC 3164: "<34>Jan 15 10:23:45 fw01 sshd[1234]: Failed password ..."
+//
+// Messages without a PRI are accepted as RFC 3164 with the default user.notice priority
+func ParseSyslogMessage(raw string) (*SyslogMessage, error) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	if raw == "" {
+		return nil, core.NewError(core.ErrLogParseError, "empty syslog message", nil)
+	}
+
+	message := &SyslogMessage{Facility: 1, Severity: 5}
+	rest := raw
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, core.NewErrorf(core.ErrLogParseError, nil, "invalid syslog priority in %q", truncate(raw, 40))
+		}
+		priority, err := strconv.Atoi(rest[1:end])
+		if err != nil || priority > 191 {
+			return nil, core.NewErrorf(core.ErrLogParseError, err, "invalid syslog priority in %q", truncate(raw, 40))
+		}
+		message.Facility, message.Severity = priority/8, priority%8
+		rest = rest[end+1:]
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		return parseRFC5424(message, rest[2:])
+	}
+	return parseRFC3164(message, rest), nil
+}
+
+// parseRFC5424 parses the fields after the version
+func parseRFC5424(message *SyslogMessage, rest string) (*SyslogMessage, error) {
+	fields := make([]string, 0, 5)
+	for len(fields) < 5 {
+		token, remainder, _ := strings.Cut(rest, " ")
+		if token == "" {
+			return nil, core.NewErrorf(core.ErrLogParseError, nil, "truncated RFC 5424 header")
+		}
+		fields = append(fields, token)
+		rest = remainder
+	}
+
+	if fields[0] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return nil, core.NewErrorf(core.ErrLogParseError, err, "invalid RFC 5424 timestamp %q", fields[0])
+		}
+		message.Timestamp = timestamp
+	} else {
+		message.Timestamp = time.Now()
+	}
+	message.Hostname = nilValue(fields[1])
+	message.AppName = nilValue(fields[2])
+	message.ProcID = nilValue(fields[3])
+	message.MsgID = nilValue(fields[4])
+
+	// STRUCTURED-DATA: "-" or one or more [id param="value"...] elements; "]" may be escaped in values
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "["):
+		end := structuredDataEnd(rest)
+		if end < 0 {
+			return nil, core.NewErrorf(core.ErrLogParseError, nil, "unterminated RFC 5424 structured data")
+		}
+		message.StructuredData = rest[:end]
+		rest = rest[end:]
+	default:
+		return nil, core.NewErrorf(core.ErrLogParseError, nil, "missing RFC 5424 structured data")
+	}
+
+	message.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff") // UTF-8 BOM
+	return message, nil
+}
+
+// structuredDataEnd returns the index just past the last SD element, or -1 if one is unterminated
+func structuredDataEnd(data string) int {
+	index := 0
+	for index < len(data) && data[index] == '[' {
+		inQuotes := false
+		closed := false
+		for index++; index < len(data); index++ {
+			switch c := data[index]; {
+			case c == '\\' && inQuotes:
+				index++ // escaped '"', '\' or ']'
+			case c == '"':
+				inQuotes = !inQuotes
+			case c == ']' && !inQuotes:
+				closed = true
+			}
+			if closed {
+				index++
+				break
+			}
+		}
+		if !closed {
+			return -1
+		}
+	}
+	return index
+}
+
+// parseRFC3164 parses a BSD syslog message; every part is optional in practice
+func parseRFC3164(message *SyslogMessage, rest string) *SyslogMessage {
+	message.Timestamp = time.Now()
+	if timestamp, length := parseBSDTimestamp(rest); length > 0 {
+		message.Timestamp = timestamp
+		rest = strings.TrimLeft(rest[length:], " ")
+	} else if token, remainder, found := strings.Cut(rest, " "); found {
+		// rsyslog and syslog-ng can send RFC 3339 timestamps in the BSD format
+		if timestamp, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			message.Timestamp = timestamp
+			rest = remainder
+		}
+	}
+
+	// A hostname is the first word unless that word is already the tag ("sshd[1]:" or "sshd:")
+	if token, remainder, found := strings.Cut(rest, " "); found && !isSyslogTag(token) && isSyslogTag(firstWord(remainder)) {
+		message.Hostname = token
+		rest = remainder
+	}
+
+	if token := firstWord(rest); isSyslogTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			message.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		message.AppName = tag
+		rest = strings.TrimPrefix(rest[len(token):], " ")
+	}
+	message.Message = rest
+	return message
+}
+
+// parseBSDTimestamp parses "Mmm dd hh:mm:ss" at the start of s, returning its length or 0
+// The year is not sent; a date more than a day ahead is taken to be from last year
+func parseBSDTimestamp(s string) (time.Time, int) {
+	const layout = "Jan _2 15:04:05"
+	if len(s) < len(layout) {
+		return time.Time{}, 0
+	}
+	parsed, err := time.ParseInLocation(layout, s[:len(layout)], time.Local)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	now := time.Now()
+	parsed = parsed.AddDate(now.Year(), 0, 0)
+	if parsed.After(now.Add(24 * time.Hour)) {
+		parsed = parsed.AddDate(-1, 0, 0)
+	}
+	return parsed, len(layout)
+}
+
+// isSyslogTag reports whether a word looks like "app:" or "app[pid]:"
+func isSyslogTag(word string) bool {
+	if len(word) < 2 || len(word) > 64 || !strings.HasSuffix(word, ":") {
+		return false
+	}
+	word = strings.TrimSuffix(word, ":")
+	if open := strings.IndexByte(word, '['); open >= 0 {
+		return open > 0 && strings.HasSuffix(word, "]")
+	}
+	return !strings.ContainsAny(word, "=\"")
+}
+
+func firstWord(s string) string {
+	word, _, _ := strings.Cut(s, " ")
+	return word
+}
+
+// nilValue maps the RFC 5424 NILVALUE "-" to ""
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
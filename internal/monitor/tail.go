@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Lines longer than this are cut; the rest up to the newline is dropped
+const maxLineLength = 64 * 1024
+
+// Bytes at the start of a file hashed into its checkpoint fingerprint
+const fingerprintLength = 1024
+
+// fileTail reads lines appended to one file, following rotation and truncation
+//   - Rotation (the path now names another file): the old file is read to its end, then the new one from the start
+//   - Truncation (the file shrank below the read offset): reading restarts at the beginning
+//   - Missing file: opened from the start once it appears
+type fileTail struct {
+	path      string
+	file      *os.File
+	info      os.FileInfo // identity of the open file
+	offset    int64       // next byte to read
+	lineStart int64       // start of the line being assembled; everything before it was handed over
+	partial   []byte
+	skip      bool // dropping the rest of an over-long line
+	buffer    []byte
+
+	device, inode   uint64
+	fingerprint     string
+	fingerprintSize int
+}
+
+// newFileTail opens path; fromStart false skips what the file already holds
+// A checkpoint for the same file (identity and fingerprint) resumes where the last run stopped;
+// a checkpoint for a file that was since replaced or truncated reads the current one from the start
+func newFileTail(path string, fromStart bool, checkpoint *models.TailCheckpoint) *fileTail {
+	tail := &fileTail{path: path, buffer: make([]byte, 32*1024)}
+	if err := tail.open(); err != nil {
+		return tail
+	}
+
+	switch {
+	case checkpoint != nil && tail.resumes(checkpoint):
+		tail.offset, tail.lineStart = checkpoint.Offset, checkpoint.Offset
+	case checkpoint != nil:
+		// Replaced or truncated while stopped: everything in the current file is new
+	case !fromStart:
+		tail.offset, tail.lineStart = tail.info.Size(), tail.info.Size()
+	}
+	return tail
+}
+
+// resumes reports whether a checkpoint was taken on the open file
+func (t *fileTail) resumes(checkpoint *models.TailCheckpoint) bool {
+	if checkpoint.Device != t.device || checkpoint.Inode != t.inode {
+		return false
+	}
+	if checkpoint.Offset > t.info.Size() {
+		return false
+	}
+	fingerprint, _ := t.hashPrefix(checkpoint.FingerprintSize)
+	return fingerprint == checkpoint.Fingerprint
+}
+
+// open opens the file at path and records its identity
+func (t *fileTail) open() error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	t.file, t.info, t.offset, t.lineStart, t.partial, t.skip = file, info, 0, 0, t.partial[:0], false
+	t.device, t.inode = fileIdentity(file, info)
+	t.fingerprint, t.fingerprintSize = "", 0
+	return nil
+}
+
+// poll reads what was appended since the last call and hands over complete lines
+func (t *fileTail) poll(emit func(line string) bool) error {
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	if err := t.drain(emit); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil // moved away; the replacement is picked up when it appears
+	case err != nil:
+		return err
+	case !os.SameFile(current, t.info):
+		t.flushPartial(emit)
+		t.file.Close()
+		t.file = nil
+		if err := t.open(); err != nil {
+			return nil
+		}
+		return t.drain(emit)
+	case current.Size() < t.offset:
+		t.offset, t.lineStart, t.partial, t.skip = 0, 0, t.partial[:0], false
+		t.fingerprint, t.fingerprintSize = "", 0
+		return t.drain(emit)
+	}
+	return nil
+}
+
+// drain reads from the offset to the end of the open file
+func (t *fileTail) drain(emit func(line string) bool) error {
+	for {
+		n, err := t.file.ReadAt(t.buffer, t.offset)
+		if n > 0 {
+			if !t.split(t.buffer[:n], t.offset, emit) {
+				// Stopping: lines not handed over are read again by the next run
+				t.offset, t.partial, t.skip = t.lineStart, t.partial[:0], false
+				return nil
+			}
+			t.offset += int64(n)
+		}
+		if err == io.EOF || n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// split hands over the complete lines in data, which starts at file position base, and keeps the remainder
+// It stops early when emit returns false (the monitor is stopping)
+func (t *fileTail) split(data []byte, base int64, emit func(line string) bool) bool {
+	position := base
+	for len(data) > 0 {
+		newline := bytes.IndexByte(data, '\n')
+		if newline < 0 {
+			t.keep(data)
+			return true
+		}
+		t.keep(data[:newline])
+		line := string(bytes.TrimRight(t.partial, "\r"))
+		if line != "" && !emit(line) {
+			return false
+		}
+		position += int64(newline + 1)
+		t.lineStart, t.partial, t.skip = position, t.partial[:0], false
+		data = data[newline+1:]
+	}
+	return true
+}
+
+// keep appends to the current line, up to maxLineLength
+func (t *fileTail) keep(data []byte) {
+	if t.skip {
+		return
+	}
+	if room := maxLineLength - len(t.partial); len(data) > room {
+		data, t.skip = data[:room], true
+	}
+	t.partial = append(t.partial, data...)
+}
+
+// flushPartial hands over a last line without a newline before the file is replaced
+func (t *fileTail) flushPartial(emit func(line string) bool) {
+	if line := string(bytes.TrimRight(t.partial, "\r")); line != "" {
+		emit(line)
+	}
+	t.partial, t.skip = t.partial[:0], false
+}
+
+// checkpoint records how far the open file was handed over, or nil if no file is open
+func (t *fileTail) checkpoint() *models.TailCheckpoint {
+	if t.file == nil {
+		return nil
+	}
+	if t.fingerprintSize < fingerprintLength && t.lineStart > int64(t.fingerprintSize) {
+		t.fingerprint, t.fingerprintSize = t.hashPrefix(fingerprintLength)
+	}
+	return &models.TailCheckpoint{
+		Path:            t.path,
+		Device:          t.device,
+		Inode:           t.inode,
+		Offset:          t.lineStart,
+		Fingerprint:     t.fingerprint,
+		FingerprintSize: t.fingerprintSize,
+		UpdatedAt:       time.Now(),
+	}
+}
+
+// hashPrefix hashes up to size bytes from the start of the open file and returns the length hashed
+func (t *fileTail) hashPrefix(size int) (string, int) {
+	if size <= 0 {
+		return "", 0
+	}
+	prefix := make([]byte, size)
+	n, err := t.file.ReadAt(prefix, 0)
+	if err != nil && err != io.EOF {
+		return "", 0
+	}
+	sum := sha256.Sum256(prefix[:n])
+	return hex.EncodeToString(sum[:]), n
+}
+
+// close releases the file
+func (t *fileTail) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
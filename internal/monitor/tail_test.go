@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// appendFile writes data to the end of path, creating it if needed
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pollLines runs one poll and returns the lines handed over
+func pollLines(t *testing.T, tail *fileTail) []string {
+	t.Helper()
+	var lines []string
+	if err := tail.poll(func(line string) bool {
+		lines = append(lines, line)
+		return true
+	}); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	return lines
+}
+
+func expectLines(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestFileTailFollowsAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	appendFile(t, path, "already there\n")
+
+	tail := newFileTail(path, false, nil)
+	defer tail.close()
+	expectLines(t, pollLines(t, tail))
+
+	appendFile(t, path, "first\r\nsecond\nthi")
+	expectLines(t, pollLines(t, tail), "first", "second")
+	appendFile(t, path, "rd\n\n")
+	expectLines(t, pollLines(t, tail), "third")
+}
+
+func TestFileTailWaitsForMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	tail := newFileTail(path, false, nil)
+	defer tail.close()
+	expectLines(t, pollLines(t, tail))
+
+	// A file that appears later is read from the start
+	appendFile(t, path, "created\n")
+	expectLines(t, pollLines(t, tail), "created")
+}
+
+func TestFileTailFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	appendFile(t, path, "before\n")
+	tail := newFileTail(path, true, nil)
+	defer tail.close()
+	expectLines(t, pollLines(t, tail), "before")
+
+	// Written to the old file after it was renamed, then a new file takes the path
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "late write\nunterminated")
+	appendFile(t, path, "rotated\n")
+	expectLines(t, pollLines(t, tail), "late write", "unterminated", "rotated")
+
+	appendFile(t, path, "next\n")
+	expectLines(t, pollLines(t, tail), "next")
+}
+
+func TestFileTailRestartsAfterTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	appendFile(t, path, "one\ntwo\n")
+	tail := newFileTail(path, true, nil)
+	defer tail.close()
+	expectLines(t, pollLines(t, tail), "one", "two")
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new\n")
+	expectLines(t, pollLines(t, tail), "new")
+}
+
+func TestFileTailCutsLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	long := make([]byte, maxLineLength+100)
+	for i := range long {
+		long[i] = 'x'
+	}
+	appendFile(t, path, string(long)+"\nshort\n")
+	tail := newFileTail(path, true, nil)
+	defer tail.close()
+
+	lines := pollLines(t, tail)
+	if len(lines) != 2 || len(lines[0]) != maxLineLength || lines[1] != "short" {
+		t.Errorf("got %d lines, first %d bytes, want the long line cut to %d", len(lines), len(lines[0]), maxLineLength)
+	}
+}
+
+func TestFileTailStopsWithoutLosingLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	appendFile(t, path, "one\ntwo\nthree\n")
+	tail := newFileTail(path, true, nil)
+	defer tail.close()
+
+	// The monitor stops after the first line; the checkpoint points at the second
+	var handed []string
+	tail.poll(func(line string) bool {
+		if len(handed) == 1 {
+			return false
+		}
+		handed = append(handed, line)
+		return true
+	})
+	expectLines(t, handed, "one")
+	if offset := tail.checkpoint().Offset; offset != int64(len("one\n")) {
+		t.Errorf("checkpoint offset = %d, want %d", offset, len("one\n"))
+	}
+}
+
+func TestFileTailResumesFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	appendFile(t, path, "old\n")
+	first := newFileTail(path, true, nil)
+	expectLines(t, pollLines(t, first), "old")
+	appendFile(t, path, "partial")
+	expectLines(t, pollLines(t, first))
+	checkpoint := first.checkpoint()
+	first.close()
+
+	if checkpoint.Offset != int64(len("old\n")) || checkpoint.Fingerprint == "" {
+		t.Fatalf("checkpoint = %+v, want the offset after the last complete line and a fingerprint", checkpoint)
+	}
+
+	// Written while stopped: only what follows the checkpoint is read, including the unfinished line
+	appendFile(t, path, " line\nnew\n")
+	resumed := newFileTail(path, false, checkpoint)
+	defer resumed.close()
+	expectLines(t, pollLines(t, resumed), "partial line", "new")
+}
+
+func TestFileTailIgnoresStaleCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	appendFile(t, path, "original-first-line\nsecond\n")
+	tail := newFileTail(path, true, nil)
+	pollLines(t, tail)
+	checkpoint := tail.checkpoint()
+	tail.close()
+
+	tests := []struct {
+		name    string
+		replace func()
+	}{
+		{"rewritten in place", func() {
+			// Same identity but the start of the file differs from the fingerprint
+			if err := os.WriteFile(path, []byte("different-content-here\nmore-lines\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"truncated", func() {
+			if err := os.WriteFile(path, []byte("short\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"rotated", func() {
+			// Another file renamed over the path: same prefix, but another identity
+			appendFile(t, filepath.Join(dir, "next.log"), "original-first-line\nsecond\nrotated-in\n")
+			if err := os.Rename(filepath.Join(dir, "next.log"), path); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.replace()
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Fields(string(content))
+
+			// fromStart false: a mismatched checkpoint still reads the current file whole
+			replaced := newFileTail(path, false, checkpoint)
+			defer replaced.close()
+			expectLines(t, pollLines(t, replaced), want...)
+		})
+	}
+}
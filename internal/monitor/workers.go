@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"hash/maphash"
+	"net/netip"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Lines queued per worker before the dispatcher waits for it
+const workerBuffer = 64
+
+// workerCount returns monitoring.parser_workers, defaulting to the number of CPUs
+func workerCount(config *models.Config) int {
+	if config.Monitoring.ParserWorkers > 0 {
+		return config.Monitoring.ParserWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// process hands the monitor's events to parser workers until the stream is closed
+// Events are sharded by their client address, so the lines of one address (or one aggregated
+// network) are parsed, counted and blocked in the order they were read. Directive lines
+// ("#Fields:" in W3C logs) change how the lines after them parse, so they wait until the
+// workers have finished everything before them
+func (p *Pipeline) process(workers int) {
+	if workers <= 1 {
+		for event := range p.monitor.Events() {
+			p.handle(event)
+		}
+		return
+	}
+
+	shards := make([]chan core.LogEvent, workers)
+	var running sync.WaitGroup
+	var pending sync.WaitGroup // events handed to a worker and not yet handled
+	for i := range shards {
+		shards[i] = make(chan core.LogEvent, workerBuffer)
+		running.Add(1)
+		go func(events <-chan core.LogEvent) {
+			defer running.Done()
+			for event := range events {
+				p.handle(event)
+				pending.Done()
+			}
+		}(shards[i])
+	}
+
+	seed := maphash.MakeSeed()
+	for event := range p.monitor.Events() {
+		if strings.HasPrefix(event.Line, "#") {
+			pending.Wait()
+			p.handle(event)
+			continue
+		}
+		pending.Add(1)
+		shards[maphash.String(seed, p.shardKey(event))%uint64(workers)] <- event
+	}
+	for _, shard := range shards {
+		close(shard)
+	}
+	running.Wait()
+}
+
+// shardKey picks the worker of an event: its client address's aggregation key, or its source
+// if the line holds no address (such lines cannot lead to a block)
+func (p *Pipeline) shardKey(event core.LogEvent) string {
+	p.mu.Lock()
+	blocking := p.config.Blocking
+	logParser := p.parserFor(event)
+	p.mu.Unlock()
+
+	address := ""
+	located := false
+	if named, ok := logParser.(*namedParser); ok {
+		address, located = named.clientAddress(event.Source, event.Line)
+	}
+	if !located {
+		address = firstAddress(event.Line)
+	}
+	if address == "" {
+		return event.Source
+	}
+	if key, err := blocking.AggregationKey(address); err == nil {
+		return key
+	}
+	return address
+}
+
+// firstAddress returns the first IPv4 or IPv6 address in a line, or ""
+// It is the client address for the built-in formats; W3C logs lead with the server's, so their
+// parser locates the client address itself (parser.ClientAddressParser)
+func firstAddress(line string) string {
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && isAddressByte(line[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			if address, ok := parseAddressToken(line[start:i]); ok {
+				return address
+			}
+			start = -1
+		}
+	}
+	return ""
+}
+
+// parseAddressToken accepts "1.2.3.4", "2001:db8::1", "1.2.3.4:22" and a trailing '.' or ':'
+func parseAddressToken(token string) (string, bool) {
+	if !strings.ContainsAny(token, ".:") {
+		return "", false
+	}
+	if address, err := netip.ParseAddr(token); err == nil {
+		return address.Unmap().String(), true
+	}
+	if addressPort, err := netip.ParseAddrPort(token); err == nil {
+		return addressPort.Addr().Unmap().String(), true
+	}
+	if trimmed := strings.TrimRight(token, ".:"); trimmed != token && trimmed != "" {
+		if address, err := netip.ParseAddr(trimmed); err == nil {
+			return address.Unmap().String(), true
+		}
+	}
+	return "", false
+}
+
+func isAddressByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '.' || c == ':'
+}
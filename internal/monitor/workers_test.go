@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestShardKeyUsesIISClientAddress(t *testing.T) {
+	service := models.ServiceConfig{Name: "IIS", LogPath: `C:\inetpub\logs\LogFiles\*\*.log`, LogPattern: "iis", Enabled: true}
+	pipeline, _ := newTestPipeline(t, service)
+	site := `C:\inetpub\logs\LogFiles\W3SVC1\u_ex240115.log`
+
+	key := func(line string) string {
+		event := core.LogEvent{Source: site, Line: line, Service: service.Name}
+		if line[0] == '#' {
+			pipeline.handle(event)
+			return ""
+		}
+		return pipeline.shardKey(event)
+	}
+
+	key("#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip sc-status sc-substatus sc-win32-status time-taken")
+	if got := key("2024-01-15 10:23:45 10.0.0.1 POST /owa/auth.owa - 443 - 203.0.113.5 401 1 1326 15"); got != "203.0.113.5" {
+		t.Errorf("shard key = %q, want the c-ip 203.0.113.5 rather than the s-ip", got)
+	}
+
+	// A new header moves c-ip; the key follows it
+	key("#Fields: date time c-ip s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username sc-status sc-substatus sc-win32-status time-taken")
+	if got := key("2024-01-15 10:23:46 198.51.100.7 10.0.0.1 POST /owa/auth.owa - 443 - 401 1 1326 15"); got != "198.51.100.7" {
+		t.Errorf("shard key = %q, want 198.51.100.7 after the header change", got)
+	}
+
+	// Without a client address the line stays with its file
+	if got := key("2024-01-15 10:23:47 - 10.0.0.1 GET / - 443 - 200 0 0 1"); got != site {
+		t.Errorf("shard key = %q, want the source file", got)
+	}
+}
+
+func TestShardKeyUsesFirstAddress(t *testing.T) {
+	service := models.ServiceConfig{Name: "nginx", LogPath: "/var/log/nginx/access.log", LogPattern: "nginx", Enabled: true}
+	pipeline, _ := newTestPipeline(t, service)
+
+	event := core.LogEvent{Source: service.LogPath, Service: service.Name,
+		Line: `203.0.113.5 - - [15/Jan/2024:10:23:45 +0000] "POST /wp-login.php HTTP/1.1" 401 0 "-" "curl/8.0"`}
+	if got := pipeline.shardKey(event); got != "203.0.113.5" {
+		t.Errorf("shard key = %q, want 203.0.113.5", got)
+	}
+}
+
+// benchmarkProcess measures how many lines per second process handles with the given workers
+// Lines come from 4096 addresses and the threshold is never reached, so the firewall stays idle
+func benchmarkProcess(b *testing.B, workers int) {
+	service := models.ServiceConfig{Name: "nginx", LogPath: "/var/log/nginx/access.log", LogPattern: "nginx", Enabled: true}
+	pipeline, _ := newTestPipeline(b, service)
+	pipeline.config.Blocking.FailureThreshold = 1 << 30
+
+	lines := make([]string, 4096)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`198.18.%d.%d - - [15/Jan/2024:10:23:45 +0000] "POST /wp-login.php HTTP/1.1" 401 0 "-" "curl/8.0"`, i/256, i%256)
+	}
+
+	events := queue.New[core.LogEvent](queue.Options{Name: "bench", Capacity: 4096, Policy: queue.PolicyBlock})
+	pipeline.monitor = &Monitor{queue: events}
+	now := time.Now()
+
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			events.Push(b.Context(), core.LogEvent{Timestamp: now, Source: service.LogPath, Line: lines[i%len(lines)], Service: service.Name})
+		}
+		events.Close()
+	}()
+	pipeline.process(workers)
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkProcessSingleWorker(b *testing.B) {
+	benchmarkProcess(b, 1)
+}
+
+func BenchmarkProcessWorkers(b *testing.B) {
+	benchmarkProcess(b, runtime.GOMAXPROCS(0))
+}
@@ -0,0 +1,602 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Email defaults
+const (
+	defaultEmailTimeout   = 30 * time.Second
+	defaultEmailRateLimit = 20 // per hour
+	defaultDigestTime     = "08:00"
+	digestAttempts        = 3
+	digestRetryDelay      = 5 * time.Minute
+	digestPage            = 1000 // attempts read from storage at a time
+	digestTop             = 10   // rows in each ranking
+	digestBlockRows       = 50   // blocks listed in full before the rest is counted
+)
+
+// SMTP connection security
+const (
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+)
+
+// Kinds emailed immediately when events is not set
+var defaultEmailEvents = []string{models.EventIPBlocked}
+
+// EmailNotifier emails alerts over SMTP and sends a daily digest drawn from storage
+// Immediate emails are limited to the kinds and severities configured; the rest reach the digest
+type EmailNotifier struct {
+	host        string
+	address     string // host:port
+	security    string
+	username    string
+	password    string
+	from        string // header form, "Guardian <guardian@example.com>"
+	envelope    string // bare sender address for MAIL FROM
+	to          []string
+	recipients  []string // bare addresses for RCPT TO
+	events      map[string]bool
+	minSeverity models.Severity
+	timeout     time.Duration
+	tls         *tls.Config
+	hostname    string
+
+	limiter    *rateLimiter
+	suppressed atomic.Int64 // immediate emails refused by the rate limit since the last digest
+
+	store      core.Storage
+	digest     bool
+	digestHour int
+	digestMin  int
+}
+
+// NewEmailNotifier validates the email configuration; store feeds the daily digest
+func NewEmailNotifier(config models.EmailConfig, store core.Storage) (*EmailNotifier, error) {
+	if config.Host == "" {
+		return nil, core.NewError(core.ErrConfigInvalid, "email: host is required", nil)
+	}
+	if len(config.To) == 0 {
+		return nil, core.NewError(core.ErrConfigInvalid, "email: at least one recipient (to) is required", nil)
+	}
+
+	notifier := &EmailNotifier{
+		host:     config.Host,
+		security: strings.ToLower(config.Security),
+		username: config.Username,
+		password: config.Password,
+		timeout:  config.Timeout,
+		store:    store,
+	}
+	notifier.hostname, _ = os.Hostname()
+	if notifier.hostname == "" {
+		notifier.hostname = "localhost"
+	}
+	if notifier.timeout <= 0 {
+		notifier.timeout = defaultEmailTimeout
+	}
+
+	port := config.Port
+	switch notifier.security {
+	case "", SecurityStartTLS:
+		notifier.security = SecurityStartTLS
+		if port == 0 {
+			port = 587
+		}
+	case SecurityTLS:
+		if port == 0 {
+			port = 465
+		}
+	case SecurityNone:
+		if port == 0 {
+			port = 25
+		}
+		if config.Username != "" && !isLocalhost(config.Host) {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+				"email: credentials are only sent over starttls or tls, except to localhost (host %s)", config.Host)
+		}
+	default:
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "email: unknown security %q (starttls, tls or none)", config.Security)
+	}
+	notifier.address = net.JoinHostPort(config.Host, strconv.Itoa(port))
+	notifier.tls = &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+
+	from := config.From
+	if from == "" {
+		from = "Guardian <guardian@" + notifier.hostname + ">"
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, err, "email: invalid from address %q", from)
+	}
+	notifier.from = sender.String()
+	notifier.envelope = sender.Address
+	for _, to := range config.To {
+		recipient, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, err, "email: invalid recipient %q", to)
+		}
+		notifier.to = append(notifier.to, recipient.String())
+		notifier.recipients = append(notifier.recipients, recipient.Address)
+	}
+
+	events := config.Events
+	if len(events) == 0 {
+		events = defaultEmailEvents
+	}
+	notifier.events = make(map[string]bool)
+	for _, kind := range events {
+		if kind == "*" {
+			notifier.events = nil
+			break
+		}
+		if !knownKinds[kind] {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "email: unknown event %q", kind)
+		}
+		notifier.events[kind] = true
+	}
+
+	notifier.minSeverity = models.SeverityCritical
+	if config.MinSeverity != "" {
+		var known bool
+		if notifier.minSeverity, known = parseSeverity(config.MinSeverity); !known {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+				"email: unknown min_severity %q (low, medium, high or critical)", config.MinSeverity)
+		}
+	}
+
+	rate := config.RateLimit
+	if rate <= 0 {
+		rate = defaultEmailRateLimit
+	}
+	notifier.limiter = newRateLimiter(rate, time.Hour)
+
+	digestTime := config.DigestTime
+	if digestTime == "" {
+		digestTime = defaultDigestTime
+	}
+	if !strings.EqualFold(digestTime, "off") {
+		clock, err := time.Parse("15:04", digestTime)
+		if err != nil {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, err, "email: digest_time must be HH:MM or off, got %q", config.DigestTime)
+		}
+		notifier.digest = true
+		notifier.digestHour, notifier.digestMin = clock.Hour(), clock.Minute()
+	}
+	return notifier, nil
+}
+
+// Name returns the notifier name
+func (e *EmailNotifier) Name() string {
+	return "email " + e.host
+}
+
+// Notify emails the alert if its kind and severity qualify and the rate limit allows
+func (e *EmailNotifier) Notify(alert *models.Alert) error {
+	if e.events != nil && !e.events[alert.Kind] {
+		return nil
+	}
+	if alert.Severity < e.minSeverity {
+		return nil
+	}
+	allowed, refused := e.limiter.allow()
+	if !allowed {
+		e.suppressed.Add(1)
+		return nil
+	}
+	if refused > 0 {
+		logger.Warn("Email rate limit held back alerts; they are counted in the daily digest", "email", e.host, "suppressed", refused)
+	}
+
+	title := strings.ReplaceAll(alert.Kind, "_", " ")
+	subject := fmt.Sprintf("Guardian: %s %s on %s", title, alert.IP, e.hostname)
+	if alert.IP == "" {
+		subject = fmt.Sprintf("Guardian: %s on %s", title, e.hostname)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\n\n", alert.Message)
+	table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(table, "%s:\t%s\n", label, value)
+		}
+	}
+	timestamp := alert.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	row("Event", title)
+	row("Severity", alert.Severity.String())
+	row("Service", alert.Service)
+	row("Address", alert.IP)
+	row("Username", alert.Username)
+	if alert.Count > 0 {
+		row("Count", strconv.Itoa(alert.Count))
+	}
+	if alert.Window > 0 {
+		row("Window", alert.Window.String())
+	}
+	row("Host", e.hostname)
+	row("Time", timestamp.Format(time.RFC1123))
+	table.Flush()
+	for _, sample := range alert.Samples {
+		fmt.Fprintf(&body, "  %s\n", sample)
+	}
+	return e.send(subject, body.String())
+}
+
+// Run sends the daily digest until ctx is done
+// A digest that cannot be sent is retried; if it still fails, the next one covers both days
+func (e *EmailNotifier) Run(ctx context.Context) {
+	if !e.digest || e.store == nil {
+		return
+	}
+	var since time.Time
+	for {
+		next := nextDigest(time.Now(), e.digestHour, e.digestMin)
+		if since.IsZero() {
+			since = next.AddDate(0, 0, -1)
+		}
+		logger.Debug("Next email digest scheduled", "email", e.host, "at", next.Format(time.DateTime))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		until := time.Now()
+		for attempt := 1; ; attempt++ {
+			err := e.SendDigest(since, until)
+			if err == nil {
+				since = until
+				break
+			}
+			if attempt >= digestAttempts {
+				logger.Error("Daily digest not sent", "email", e.host, "error", err)
+				break
+			}
+			logger.Warn("Daily digest failed, retrying", "email", e.host, "attempt", attempt, "delay", digestRetryDelay.String(), "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(digestRetryDelay):
+			}
+		}
+	}
+}
+
+// SendDigest emails the summary of attacks, blocks and alerts between since and until
+func (e *EmailNotifier) SendDigest(since, until time.Time) error {
+	summary, err := collectDigest(e.store, since, until)
+	if err != nil {
+		return core.NewError(core.ErrNotificationFailed, "email digest: failed to read storage", err)
+	}
+	suppressed := e.suppressed.Load()
+	summary.suppressed = suppressed
+
+	subject := fmt.Sprintf("Guardian daily digest for %s: %d failed attempts, %d blocked",
+		e.hostname, summary.attempts, len(summary.placed))
+	if err := e.send(subject, summary.render(e.hostname)); err != nil {
+		return err
+	}
+	e.suppressed.Add(-suppressed)
+	logger.Info("Daily digest sent", "email", e.host, "attempts", summary.attempts, "blocks", len(summary.placed))
+	return nil
+}
+
+// send delivers one plain-text message in a single SMTP session
+func (e *EmailNotifier) send(subject, body string) error {
+	message := e.message(subject, body)
+	dialer := &net.Dialer{Timeout: e.timeout}
+
+	var conn net.Conn
+	var err error
+	if e.security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.address, e.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", e.address)
+	}
+	if err != nil {
+		return core.NewErrorf(core.ErrNotificationFailed, err, "email: cannot reach %s", e.address)
+	}
+	conn.SetDeadline(time.Now().Add(e.timeout))
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return core.NewErrorf(core.ErrNotificationFailed, err, "email: %s did not greet", e.address)
+	}
+	defer client.Close()
+
+	step := func(action string, err error) error {
+		if err == nil {
+			return nil
+		}
+		return core.NewErrorf(core.ErrNotificationFailed, err, "email: %s failed on %s", action, e.address)
+	}
+	if err := step("EHLO", client.Hello(e.hostname)); err != nil {
+		return err
+	}
+	if e.security == SecurityStartTLS {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return core.NewErrorf(core.ErrNotificationFailed, nil,
+				"email: %s does not offer STARTTLS (set security: tls or none)", e.address)
+		}
+		if err := step("STARTTLS", client.StartTLS(e.tls)); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		if supported, _ := client.Extension("AUTH"); !supported {
+			return core.NewErrorf(core.ErrNotificationFailed, nil, "email: %s does not offer AUTH", e.address)
+		}
+		if err := step("AUTH", client.Auth(smtp.PlainAuth("", e.username, e.password, e.host))); err != nil {
+			return err
+		}
+	}
+	if err := step("MAIL FROM", client.Mail(e.envelope)); err != nil {
+		return err
+	}
+	for _, recipient := range e.recipients {
+		if err := step("RCPT TO "+recipient, client.Rcpt(recipient)); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err := step("DATA", err); err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return step("DATA", err)
+	}
+	if err := step("DATA", writer.Close()); err != nil {
+		return err
+	}
+	return step("QUIT", client.Quit())
+}
+
+// message builds the headers and quoted-printable body
+func (e *EmailNotifier) message(subject, body string) []byte {
+	var message bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&message, "%s: %s\r\n", name, value)
+	}
+	now := time.Now()
+	header("From", e.from)
+	header("To", strings.Join(e.to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%d.%d@%s>", now.UnixNano(), os.Getpid(), e.hostname))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	header("Auto-Submitted", "auto-generated")
+	message.WriteString("\r\n")
+
+	encoder := quotedprintable.NewWriter(&message)
+	encoder.Write([]byte(body))
+	encoder.Close()
+	return message.Bytes()
+}
+
+// digestSummary is one period of activity as reported by the digest
+type digestSummary struct {
+	since, until time.Time
+	attempts     int
+	ips          []rankedCount
+	services     []rankedCount
+	usernames    []rankedCount
+	placed       []*models.BlockRecord
+	lifted       []*models.BlockRecord
+	alerts       map[string]int
+	suppressed   int64
+}
+
+type rankedCount struct {
+	name  string
+	count int
+}
+
+// collectDigest reads the attempts, blocks and alerts of a period from storage
+func collectDigest(store core.Storage, since, until time.Time) (*digestSummary, error) {
+	summary := &digestSummary{since: since, until: until, alerts: make(map[string]int)}
+	within := func(t time.Time) bool {
+		return !t.Before(since) && t.Before(until)
+	}
+
+	ips := make(map[string]int)
+	services := make(map[string]int)
+	usernames := make(map[string]int)
+	// Attempts come newest first by the time they were stored; backfilled ones carry older
+	// timestamps, so every page is read rather than stopping at the first old attempt
+	for offset := 0; ; offset += digestPage {
+		attempts, err := store.GetAttacks(digestPage, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, attempt := range attempts {
+			if !within(attempt.Timestamp) {
+				continue
+			}
+			summary.attempts++
+			ips[attempt.IP]++
+			if attempt.Service != "" {
+				services[attempt.Service]++
+			}
+			if attempt.Username != "" && attempt.Username != "-" && attempt.Username != "unknown" {
+				usernames[attempt.Username]++
+			}
+		}
+		if len(attempts) < digestPage {
+			break
+		}
+	}
+	summary.ips = rank(ips)
+	summary.services = rank(services)
+	summary.usernames = rank(usernames)
+
+	blocks, err := store.GetBlocks(since)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		if within(block.BlockedAt) {
+			summary.placed = append(summary.placed, block)
+		}
+		if block.UnblockedAt != nil && within(*block.UnblockedAt) {
+			summary.lifted = append(summary.lifted, block)
+		}
+	}
+	sort.SliceStable(summary.lifted, func(i, j int) bool {
+		return summary.lifted[i].UnblockedAt.Before(*summary.lifted[j].UnblockedAt)
+	})
+
+	alerts, err := store.GetAlerts(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, alert := range alerts {
+		if within(alert.Timestamp) {
+			summary.alerts[alert.Kind]++
+		}
+	}
+	return summary, nil
+}
+
+// rank orders counts highest first, keeping the top entries
+func rank(counts map[string]int) []rankedCount {
+	ranked := make([]rankedCount, 0, len(counts))
+	for name, count := range counts {
+		ranked = append(ranked, rankedCount{name, count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			return ranked[i].count > ranked[j].count
+		}
+		return ranked[i].name < ranked[j].name
+	})
+	if len(ranked) > digestTop {
+		ranked = ranked[:digestTop]
+	}
+	return ranked
+}
+
+// render formats the digest as plain text
+func (d *digestSummary) render(hostname string) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Guardian digest for %s\n", hostname)
+	fmt.Fprintf(&body, "%s to %s\n\n", d.since.Format(time.DateTime), d.until.Format(time.DateTime))
+
+	table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "Failed attempts:\t%d\n", d.attempts)
+	fmt.Fprintf(table, "Blocks placed:\t%d\n", len(d.placed))
+	fmt.Fprintf(table, "Blocks lifted:\t%d\n", len(d.lifted))
+	fmt.Fprintf(table, "Password spraying alerts:\t%d\n", d.alerts[models.AlertPasswordSpraying])
+	fmt.Fprintf(table, "Credential stuffing alerts:\t%d\n", d.alerts[models.AlertCredentialStuffing])
+	if d.alerts[models.AlertWouldBlock] > 0 {
+		fmt.Fprintf(table, "Would have blocked (observe mode):\t%d\n", d.alerts[models.AlertWouldBlock])
+	}
+	if d.suppressed > 0 {
+		fmt.Fprintf(table, "Alert emails held back by the rate limit:\t%d\n", d.suppressed)
+	}
+	table.Flush()
+
+	section := func(title string, rows []rankedCount) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(&body, "\n%s\n", title)
+		table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+		for _, row := range rows {
+			fmt.Fprintf(table, "  %s\t%d\n", row.name, row.count)
+		}
+		table.Flush()
+	}
+	section("Top attacking addresses", d.ips)
+	section("Top services", d.services)
+	section("Top usernames tried", d.usernames)
+
+	if len(d.placed) > 0 {
+		fmt.Fprintf(&body, "\nBlocks placed\n")
+		table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+		for i, block := range d.placed {
+			if i == digestBlockRows {
+				fmt.Fprintf(table, "  ... and %d more\n", len(d.placed)-i)
+				break
+			}
+			expires := "permanent"
+			if block.ExpiresAt != nil {
+				expires = "until " + block.ExpiresAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\t%s\n", block.BlockedAt.Format(time.TimeOnly),
+				block.IP, block.Service, block.Severity, expires, truncate(block.Reason, 80))
+		}
+		table.Flush()
+	}
+	if len(d.lifted) > 0 {
+		fmt.Fprintf(&body, "\nBlocks lifted\n")
+		table := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
+		for i, block := range d.lifted {
+			if i == digestBlockRows {
+				fmt.Fprintf(table, "  ... and %d more\n", len(d.lifted)-i)
+				break
+			}
+			fmt.Fprintf(table, "  %s\t%s\tblocked for %s\n", block.UnblockedAt.Format(time.TimeOnly),
+				block.IP, block.UnblockedAt.Sub(block.BlockedAt).Truncate(time.Second))
+		}
+		table.Flush()
+	}
+	if d.attempts == 0 && len(d.placed) == 0 && len(d.lifted) == 0 {
+		body.WriteString("\nNo attacks were recorded.\n")
+	}
+	return body.String()
+}
+
+// nextDigest returns the next local occurrence of hour:minute after now
+func nextDigest(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// parseSeverity maps a severity name to its level
+func parseSeverity(name string) (models.Severity, bool) {
+	for severity := models.SeverityLow; severity <= models.SeverityCritical; severity++ {
+		if strings.EqualFold(name, severity.String()) {
+			return severity, true
+		}
+	}
+	return models.SeverityLow, false
+}
+
+// isLocalhost reports whether net/smtp would send credentials to host without TLS
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
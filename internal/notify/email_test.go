@@ -0,0 +1,344 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/storage"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// smtpMessage is one message received by smtpStub
+type smtpMessage struct {
+	auth       string // decoded AUTH PLAIN credentials, "\x00user\x00password"
+	from       string
+	recipients []string
+	data       string
+}
+
+// smtpStub is a minimal in-process SMTP server (EHLO, AUTH PLAIN, MAIL, RCPT, DATA, QUIT)
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &smtpStub{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+// config returns an email configuration sending to the stub
+func (s *smtpStub) config() models.EmailConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return models.EmailConfig{
+		Host:     host,
+		Port:     portNumber,
+		Security: SecurityNone,
+		Username: "guardian",
+		Password: "hunter2",
+		From:     "Guardian <guardian@example.com>",
+		To:       []string{"Ops <ops@example.com>", "soc@example.com"},
+		Timeout:  5 * time.Second,
+	}
+}
+
+func (s *smtpStub) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var message smtpMessage
+	reply("220 stub ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250-stub")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			encoded := strings.TrimPrefix(argument, "PLAIN ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			message.auth = string(decoded)
+			reply("235 accepted")
+		case "MAIL":
+			message.from = strings.Trim(strings.TrimPrefix(argument, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			message.recipients = append(message.recipients, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			message.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			message = smtpMessage{auth: message.auth}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// parseMail splits a received message into its decoded subject and body
+func parseMail(t *testing.T, data string) (*mail.Message, string, string) {
+	t.Helper()
+	message, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid message: %v\n%s", err, data)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("subject: %v", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	return message, subject, strings.ReplaceAll(string(body), "\r\n", "\n")
+}
+
+func newTestEmail(t *testing.T, config models.EmailConfig, store core.Storage) *EmailNotifier {
+	t.Helper()
+	notifier, err := NewEmailNotifier(config, store)
+	if err != nil {
+		t.Fatalf("NewEmailNotifier: %v", err)
+	}
+	notifier.hostname = "web01"
+	return notifier
+}
+
+func TestEmailSendsAlert(t *testing.T) {
+	stub := newSMTPStub(t)
+	config := stub.config()
+	config.MinSeverity = "high"
+	notifier := newTestEmail(t, config, nil)
+
+	if err := notifier.Notify(testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	messages := stub.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	received := messages[0]
+	if received.auth != "\x00guardian\x00hunter2" {
+		t.Errorf("AUTH PLAIN = %q", received.auth)
+	}
+	if received.from != "guardian@example.com" {
+		t.Errorf("MAIL FROM = %q", received.from)
+	}
+	if strings.Join(received.recipients, ",") != "ops@example.com,soc@example.com" {
+		t.Errorf("RCPT TO = %v", received.recipients)
+	}
+
+	message, subject, body := parseMail(t, received.data)
+	if subject != "Guardian: ip blocked 203.0.113.5 on web01" {
+		t.Errorf("subject = %q", subject)
+	}
+	if to := message.Header.Get("To"); !strings.Contains(to, "ops@example.com") || !strings.Contains(to, "soc@example.com") {
+		t.Errorf("To = %q", to)
+	}
+	if message.Header.Get("Auto-Submitted") != "auto-generated" {
+		t.Error("missing Auto-Submitted header")
+	}
+	for _, want := range []string{
+		"Blocked 203.0.113.5 after 5 failed logins\n",
+		"Severity:  high",
+		"Service:   SSH",
+		"Address:   203.0.113.5",
+		"Host:      web01",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEmailSkipsAlertsBelowThreshold(t *testing.T) {
+	stub := newSMTPStub(t)
+	notifier := newTestEmail(t, stub.config(), nil) // default min_severity critical, events ip_blocked
+
+	high := testAlert()
+	spraying := testAlert()
+	spraying.Kind = models.AlertPasswordSpraying
+	spraying.Severity = models.SeverityCritical
+	for _, alert := range []*models.Alert{high, spraying} {
+		if err := notifier.Notify(alert); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if got := len(stub.received()); got != 0 {
+		t.Errorf("got %d messages, want none below critical or outside the events", got)
+	}
+}
+
+func TestEmailRateLimitFeedsDigest(t *testing.T) {
+	stub := newSMTPStub(t)
+	config := stub.config()
+	config.MinSeverity = "low"
+	config.RateLimit = 1
+	notifier := newTestEmail(t, config, storage.NewMemoryStorage())
+
+	for range 3 {
+		if err := notifier.Notify(testAlert()); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if got := len(stub.received()); got != 1 {
+		t.Fatalf("got %d messages, want 1 within the rate limit", got)
+	}
+
+	now := time.Now()
+	if err := notifier.SendDigest(now.Add(-time.Hour), now); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+	_, _, body := parseMail(t, stub.received()[1].data)
+	if !strings.Contains(body, "Alert emails held back by the rate limit:  2") {
+		t.Errorf("digest does not report the held back emails:\n%s", body)
+	}
+	if notifier.suppressed.Load() != 0 {
+		t.Error("held back count should reset once the digest is sent")
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	stub := newSMTPStub(t)
+	store := storage.NewMemoryStorage()
+	until := time.Now()
+	since := until.Add(-24 * time.Hour)
+
+	attempts := []models.AttackAttempt{
+		{IP: "203.0.113.5", Service: "SSH", Username: "root"},
+		{IP: "203.0.113.5", Service: "SSH", Username: "admin"},
+		{IP: "203.0.113.5", Service: "SSH", Username: "root"},
+		{IP: "198.51.100.7", Service: "RDP", Username: "administrator"},
+	}
+	for i := range attempts {
+		attempts[i].Timestamp = until.Add(-time.Duration(i+1) * time.Minute)
+		if err := store.SaveAttack(&attempts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Outside the period: not counted
+	if err := store.SaveAttack(&models.AttackAttempt{Timestamp: since.Add(-time.Hour), IP: "192.0.2.1", Service: "SSH"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expires := until.Add(19 * time.Hour)
+	lifted := until.Add(-10 * time.Minute)
+	blocks := []*models.BlockRecord{
+		{IP: "203.0.113.5", BlockedAt: until.Add(-time.Hour), ExpiresAt: &expires, Service: "SSH", Severity: models.SeverityHigh, Reason: "5 failed logins", IsActive: true},
+		{IP: "192.0.2.50", BlockedAt: since.Add(-2 * time.Hour), Service: "RDP", Severity: models.SeverityMedium, Reason: "brute force", UnblockedAt: &lifted},
+	}
+	for _, block := range blocks {
+		if err := store.SaveBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, kind := range []string{models.AlertPasswordSpraying, models.AlertPasswordSpraying, models.AlertCredentialStuffing} {
+		if err := store.SaveAlert(&models.Alert{Timestamp: until.Add(-time.Hour), Kind: kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifier := newTestEmail(t, stub.config(), store)
+	if err := notifier.SendDigest(since, until); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+
+	messages := stub.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	_, subject, body := parseMail(t, messages[0].data)
+	if subject != "Guardian daily digest for web01: 4 failed attempts, 1 blocked" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{
+		"Failed attempts:             4",
+		"Blocks placed:               1",
+		"Blocks lifted:               1",
+		"Password spraying alerts:    2",
+		"Credential stuffing alerts:  1",
+		"Top attacking addresses\n  203.0.113.5   3\n  198.51.100.7  1\n",
+		"Top services\n  SSH  3\n  RDP  1\n",
+		"Top usernames tried\n  root           2\n",
+		"203.0.113.5  SSH  high  until " + expires.Format(time.DateTime) + "  5 failed logins",
+		"192.0.2.50  blocked for ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("digest lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "192.0.2.1 ") {
+		t.Errorf("digest counts an attempt from before the period:\n%s", body)
+	}
+}
+
+func TestEmailRefusesPlaintextCredentialsToRemoteHost(t *testing.T) {
+	_, err := NewEmailNotifier(models.EmailConfig{
+		Host:     "mail.example.com",
+		Security: SecurityNone,
+		Username: "guardian",
+		Password: "hunter2",
+		To:       []string{"ops@example.com"},
+	}, nil)
+	if err == nil {
+		t.Error("expected credentials over an unencrypted connection to be refused")
+	}
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket allowing bursts of up to one period's worth of deliveries
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // deliveries per second
+	burst   float64
+	tokens  float64
+	last    time.Time
+	refused int // since the last allowed delivery
+}
+
+func newRateLimiter(count int, period time.Duration) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(count) / period.Seconds(),
+		burst:  float64(count),
+		tokens: float64(count),
+	}
+}
+
+// allow takes a token; once a delivery is allowed again it also returns how many were refused before it
+func (r *rateLimiter) allow() (bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if !r.last.IsZero() {
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+	if r.tokens < 1 {
+		r.refused++
+		return false, 0
+	}
+	r.tokens--
+	refused := r.refused
+	r.refused = 0
+	return true, refused
+}
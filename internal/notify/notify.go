@@ -0,0 +1,301 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Notifier delivers detection alerts to one destination
+type Notifier interface {
+	Name() string
+	Notify(alert *models.Alert) error
+}
+
+// scheduledNotifier is a Notifier with periodic work of its own, such as the email digest
+type scheduledNotifier interface {
+	Run(ctx context.Context)
+}
+
+// QueueSize is the default number of alerts waiting for delivery
+const QueueSize = 256
+
+// How long Close waits for the notifiers to deliver what is queued before cancelling
+// their retries and requests, and how long it then waits for them to return
+// (variables so tests can shorten them)
+var (
+	closeTimeout = 10 * time.Second
+	cancelGrace  = 2 * time.Second
+)
+
+// contextNotifier is a Notifier whose deliveries can be cancelled, such as a webhook waiting to retry
+type contextNotifier interface {
+	NotifyContext(ctx context.Context, alert *models.Alert) error
+}
+
+// Dispatcher fans alerts out to its notifiers
+// Each notifier is fed by its own goroutine and buffer, so a slow or retrying destination
+// delays and drops only its own alerts
+type Dispatcher struct {
+	queue    *queue.Queue[*models.Alert]
+	capacity int // alerts buffered per notifier
+	workers  []*notifierWorker
+	ctx      context.Context // cancelled when Close gives up waiting on the notifiers
+	cancel   context.CancelFunc
+	done     chan struct{}
+	running  sync.WaitGroup // notifier goroutines
+	mu       sync.RWMutex
+	closed   bool
+	started  sync.Once
+}
+
+// notifierWorker delivers to one notifier from its own buffer
+type notifierWorker struct {
+	notifier Notifier
+	alerts   chan *models.Alert
+	dropped  atomic.Int64 // alerts pushed out of a full buffer since the last delivery
+}
+
+// NewDispatcher starts delivering to the given notifiers
+// Alerts wait in a queue with the given options; drop-oldest keeps detection from waiting on a slow notifier
+func NewDispatcher(options queue.Options, notifiers ...Notifier) *Dispatcher {
+	if options.Name == "" {
+		options.Name = "alerts"
+	}
+	if options.Capacity <= 0 {
+		options.Capacity = QueueSize
+	}
+	if options.Policy == "" {
+		options.Policy = queue.PolicyDropOldest
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		queue:    queue.New[*models.Alert](options),
+		capacity: options.Capacity,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	for _, notifier := range notifiers {
+		d.addLocked(notifier)
+	}
+	go d.run()
+	return d
+}
+
+// Start runs the notifiers' periodic work (the email digest) until ctx is done
+// Only the monitoring process calls it, so commands that build a provider send no digests
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.started.Do(func() {
+		d.mu.RLock()
+		workers := d.workers
+		d.mu.RUnlock()
+		for _, worker := range workers {
+			if scheduled, ok := worker.notifier.(scheduledNotifier); ok {
+				go scheduled.Run(ctx)
+			}
+		}
+	})
+}
+
+// Add delivers alerts to another notifier from now on, such as the system tray
+func (d *Dispatcher) Add(notifier Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.addLocked(notifier)
+}
+
+func (d *Dispatcher) addLocked(notifier Notifier) {
+	worker := &notifierWorker{notifier: notifier, alerts: make(chan *models.Alert, d.capacity)}
+	d.workers = append(d.workers, worker)
+	d.running.Add(1)
+	go func() {
+		defer d.running.Done()
+		worker.run(d.ctx)
+	}()
+}
+
+// Notify queues an alert according to the queue's overflow policy
+func (d *Dispatcher) Notify(alert *models.Alert) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	d.queue.Push(context.Background(), alert)
+}
+
+// Close delivers the queued alerts and stops the dispatcher
+// Notifiers still busy after closeTimeout have their deliveries cancelled, and any that
+// ignore the cancellation are abandoned, so shutdown never waits on an unreachable destination
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.mu.Unlock()
+	d.queue.Close()
+	<-d.done
+
+	finished := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(finished)
+	}()
+	defer d.cancel()
+
+	select {
+	case <-finished:
+		return
+	case <-time.After(closeTimeout):
+	}
+	logger.Warn("Notifiers still delivering at shutdown, cancelling", "waited", closeTimeout.String())
+	d.cancel()
+	select {
+	case <-finished:
+	case <-time.After(cancelGrace):
+		logger.Warn("Notifiers did not stop, abandoning their queued alerts")
+	}
+}
+
+// run hands each queued alert to every notifier's buffer, then closes the buffers
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for alert := range d.queue.Out() {
+		d.mu.RLock()
+		workers := d.workers
+		d.mu.RUnlock()
+		for _, worker := range workers {
+			worker.push(alert)
+		}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, worker := range d.workers {
+		close(worker.alerts)
+	}
+}
+
+// push buffers an alert, pushing out the oldest one when the notifier is behind
+func (w *notifierWorker) push(alert *models.Alert) {
+	for {
+		select {
+		case w.alerts <- alert:
+			return
+		default:
+		}
+		select {
+		case <-w.alerts:
+			w.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// run delivers the buffered alerts until the buffer is closed and drained
+func (w *notifierWorker) run(ctx context.Context) {
+	for alert := range w.alerts {
+		if dropped := w.dropped.Swap(0); dropped > 0 {
+			logger.Warn("Notifier fell behind, dropped the oldest alerts", "notifier", w.notifier.Name(), "dropped", dropped)
+		}
+		if ctx.Err() != nil {
+			continue // Close gave up; drain without delivering
+		}
+
+		var err error
+		if cancellable, ok := w.notifier.(contextNotifier); ok {
+			err = cancellable.NotifyContext(ctx, alert)
+		} else {
+			err = w.notifier.Notify(alert)
+		}
+		if err != nil {
+			logger.Error("Notification failed", "notifier", w.notifier.Name(), "kind", alert.Kind, "error", err)
+		}
+	}
+}
+
+// Notifiers returns the log notifier, the configured webhooks and email
+// A destination with an invalid configuration is reported and skipped; store feeds the email digest
+func Notifiers(config *models.Config, store core.Storage) []Notifier {
+	notifiers := []Notifier{LogNotifier{}}
+	for _, webhook := range config.Notifications.Webhooks {
+		notifier, err := NewWebhookNotifier(webhook)
+		if err != nil {
+			logger.Error("Webhook disabled", "webhook", webhook.Name, "error", err)
+			continue
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	if email := config.Notifications.Email; email.Host != "" {
+		notifier, err := NewEmailNotifier(email, store)
+		if err != nil {
+			logger.Error("Email notifications disabled", "error", err)
+		} else {
+			notifiers = append(notifiers, notifier)
+		}
+	}
+	return notifiers
+}
+
+// NewEvent creates an engine event (models.Event* kinds) for the notifiers
+func NewEvent(kind, service, ip string, severity models.Severity, message string) *models.Alert {
+	return &models.Alert{
+		Timestamp: time.Now(),
+		Kind:      kind,
+		Service:   service,
+		IP:        ip,
+		Severity:  severity,
+		Message:   message,
+	}
+}
+
+// NewWouldBlock creates the alert recording a block that observe mode did not carry out
+func NewWouldBlock(record *models.BlockRecord) *models.Alert {
+	return &models.Alert{
+		Timestamp: record.BlockedAt,
+		Kind:      models.AlertWouldBlock,
+		Service:   record.Service,
+		IP:        record.IP,
+		Severity:  record.Severity,
+		Message:   fmt.Sprintf("Observe mode: would have blocked %s: %s", record.IP, record.Reason),
+	}
+}
+
+// LogNotifier writes alerts to the Guardian log, where the dashboard and log shippers pick them up
+// Engine events are already logged where they happen, so only detection alerts are written
+type LogNotifier struct{}
+
+// Name returns the notifier name
+func (LogNotifier) Name() string {
+	return "log"
+}
+
+// Notify logs the alert as a warning
+func (LogNotifier) Notify(alert *models.Alert) error {
+	if alert.Kind != models.AlertPasswordSpraying && alert.Kind != models.AlertCredentialStuffing {
+		return nil
+	}
+	logger.Warn("Detection alert",
+		"kind", alert.Kind,
+		"service", alert.Service,
+		"ip", alert.IP,
+		"username", alert.Username,
+		"count", alert.Count,
+		"window", alert.Window.String(),
+		"samples", alert.Samples,
+		"message", alert.Message)
+	return nil
+}
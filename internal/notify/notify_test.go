@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// recordingNotifier remembers the alerts delivered to it
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []*models.Alert
+	seen   chan struct{}
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{seen: make(chan struct{}, 64)}
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(alert *models.Alert) error {
+	r.mu.Lock()
+	r.alerts = append(r.alerts, alert)
+	r.mu.Unlock()
+	r.seen <- struct{}{}
+	return nil
+}
+
+func (r *recordingNotifier) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.alerts)
+}
+
+// stuckNotifier blocks every delivery until released
+type stuckNotifier struct {
+	release chan struct{}
+}
+
+func (s *stuckNotifier) Name() string { return "stuck" }
+
+func (s *stuckNotifier) Notify(alert *models.Alert) error {
+	<-s.release
+	return nil
+}
+
+func testQueue() queue.Options {
+	return queue.Options{Name: "alerts-test", Capacity: 16, Policy: queue.PolicyDropOldest}
+}
+
+func TestDispatcherSlowNotifierDoesNotStallOthers(t *testing.T) {
+	stuck := &stuckNotifier{release: make(chan struct{})}
+	recorder := newRecordingNotifier()
+	dispatcher := NewDispatcher(testQueue(), stuck, recorder)
+	defer dispatcher.Close()
+	defer close(stuck.release)
+
+	for range 3 {
+		dispatcher.Notify(testAlert())
+	}
+	for i := range 3 {
+		select {
+		case <-recorder.seen:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 3 alerts delivered while another notifier was stuck", i)
+		}
+	}
+}
+
+func TestDispatcherCloseDeliversQueuedAlerts(t *testing.T) {
+	recorder := newRecordingNotifier()
+	dispatcher := NewDispatcher(testQueue(), recorder)
+	for range 5 {
+		dispatcher.Notify(testAlert())
+	}
+	dispatcher.Close()
+
+	if got := recorder.count(); got != 5 {
+		t.Errorf("delivered %d alerts before Close returned, want 5", got)
+	}
+	dispatcher.Notify(testAlert()) // ignored once closed
+	dispatcher.Close()
+}
+
+func TestDispatcherCloseCancelsRetryingWebhook(t *testing.T) {
+	defer func(wait, grace time.Duration) { closeTimeout, cancelGrace = wait, grace }(closeTimeout, cancelGrace)
+	closeTimeout, cancelGrace = 50*time.Millisecond, time.Second
+
+	server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	server.headers.Set("Retry-After", "3600")
+	webhook := newTestWebhook(t, models.WebhookConfig{URL: server.URL})
+
+	dispatcher := NewDispatcher(testQueue(), webhook)
+	dispatcher.Notify(testAlert())
+	for server.count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		dispatcher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close hung on a webhook waiting to retry")
+	}
+}
+
+func TestDispatcherCloseAbandonsStuckNotifier(t *testing.T) {
+	defer func(wait, grace time.Duration) { closeTimeout, cancelGrace = wait, grace }(closeTimeout, cancelGrace)
+	closeTimeout, cancelGrace = 20*time.Millisecond, 20*time.Millisecond
+
+	stuck := &stuckNotifier{release: make(chan struct{})}
+	defer close(stuck.release)
+	dispatcher := NewDispatcher(testQueue(), stuck)
+	dispatcher.Notify(testAlert())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	closed := make(chan struct{})
+	go func() {
+		dispatcher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		t.Fatal("Close hung on a notifier that ignores cancellation")
+	}
+}
+
+func TestDispatcherDropsOldestForSlowNotifier(t *testing.T) {
+	stuck := &stuckNotifier{release: make(chan struct{})}
+	dispatcher := NewDispatcher(queue.Options{Name: "alerts-test", Capacity: 2, Policy: queue.PolicyDropOldest}, stuck)
+
+	worker := dispatcher.workers[0]
+	for range 10 {
+		worker.push(testAlert())
+	}
+	if got := len(worker.alerts); got != 2 {
+		t.Errorf("buffer holds %d alerts, want its capacity of 2", got)
+	}
+	if worker.dropped.Load() == 0 {
+		t.Error("expected the overflow to be counted")
+	}
+	close(stuck.release)
+	dispatcher.Close()
+}
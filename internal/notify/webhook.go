@@ -0,0 +1,363 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Webhook defaults
+const (
+	defaultWebhookTimeout   = 10 * time.Second
+	defaultWebhookRetries   = 3
+	defaultWebhookRateLimit = 30 // per minute
+	webhookBackoff          = time.Second
+	maxWebhookBackoff       = 30 * time.Second
+)
+
+// Signature headers; the signature covers "<timestamp>.<body>" so a captured request cannot be replayed later
+const (
+	SignatureHeader = "X-Guardian-Signature"
+	TimestampHeader = "X-Guardian-Timestamp"
+)
+
+// Kinds a webhook receives when events is not set: what on-call acts on
+var defaultWebhookEvents = []string{
+	models.EventIPBlocked,
+	models.EventIPUnblocked,
+	models.EventFirewallError,
+	models.AlertPasswordSpraying,
+	models.AlertCredentialStuffing,
+}
+
+// Every kind a webhook can subscribe to
+var knownKinds = map[string]bool{
+	models.EventAttackDetected:     true,
+	models.EventThresholdNear:      true,
+	models.EventIPBlocked:          true,
+	models.EventIPUnblocked:        true,
+	models.EventFirewallError:      true,
+	models.AlertPasswordSpraying:   true,
+	models.AlertCredentialStuffing: true,
+	models.AlertWouldBlock:         true,
+}
+
+// Payload presets for chat services; the generic preset marshals webhookPayload instead
+var webhookPresets = map[string]string{
+	"slack":   `{"text": {{json .Text}}}`,
+	"discord": `{"content": {{json (truncate .Text 2000)}}}`,
+	"teams": `{"@type": "MessageCard", "@context": "https://schema.org/extensions",` +
+		` "summary": {{json .Title}}, "themeColor": {{json .Color}}, "title": {{json .Title}}, "text": {{json .Message}}}`,
+}
+
+// webhookPayload is the generic body and the data templates are executed with
+type webhookPayload struct {
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Text      string    `json:"-"` // one-line summary for chat presets
+	Color     string    `json:"-"` // hex colour by severity, for Teams cards
+	Severity  string    `json:"severity"`
+	Service   string    `json:"service,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Count     int       `json:"count,omitempty"`
+	Window    string    `json:"window,omitempty"`
+	Samples   []string  `json:"samples,omitempty"`
+	Host      string    `json:"host"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookNotifier posts alerts and events to an HTTP endpoint
+// Failed deliveries are retried with exponential backoff; deliveries beyond the rate limit are dropped
+type WebhookNotifier struct {
+	name     string
+	url      string
+	client   *http.Client
+	template *template.Template // nil for the generic payload
+	events   map[string]bool    // nil means every kind
+	secret   []byte
+	headers  map[string]string
+	retries  int
+	backoff  time.Duration
+	host     string
+	limiter  *rateLimiter
+}
+
+// NewWebhookNotifier validates a webhook's configuration
+func NewWebhookNotifier(config models.WebhookConfig) (*WebhookNotifier, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, err, "webhook %q: url must be an http or https URL", config.Name)
+	}
+	name := config.Name
+	if name == "" {
+		name = endpoint.Host
+	}
+
+	notifier := &WebhookNotifier{
+		name:    name,
+		url:     config.URL,
+		client:  &http.Client{Timeout: config.Timeout},
+		secret:  []byte(config.Secret),
+		headers: config.Headers,
+		retries: config.MaxRetries,
+		backoff: webhookBackoff,
+	}
+	if notifier.client.Timeout <= 0 {
+		notifier.client.Timeout = defaultWebhookTimeout
+	}
+	if notifier.retries == 0 {
+		notifier.retries = defaultWebhookRetries
+	}
+	if notifier.retries < 0 {
+		notifier.retries = 0
+	}
+	rate := config.RateLimit
+	if rate <= 0 {
+		rate = defaultWebhookRateLimit
+	}
+	notifier.limiter = newRateLimiter(rate, time.Minute)
+	notifier.host, _ = os.Hostname()
+
+	source := config.Template
+	if source == "" {
+		preset := strings.ToLower(config.Preset)
+		if preset != "" && preset != "generic" {
+			var known bool
+			if source, known = webhookPresets[preset]; !known {
+				return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+					"webhook %s: unknown preset %q (generic, slack, teams or discord)", name, config.Preset)
+			}
+		}
+	}
+	if source != "" {
+		notifier.template, err = template.New(name).Funcs(template.FuncMap{
+			"json":     jsonValue,
+			"truncate": truncate,
+		}).Parse(source)
+		if err != nil {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, err, "webhook %s: invalid template", name)
+		}
+	}
+
+	events := config.Events
+	if len(events) == 0 {
+		events = defaultWebhookEvents
+	}
+	notifier.events = make(map[string]bool)
+	for _, kind := range events {
+		if kind == "*" {
+			notifier.events = nil
+			break
+		}
+		if !knownKinds[kind] {
+			return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "webhook %s: unknown event %q", name, kind)
+		}
+		notifier.events[kind] = true
+	}
+	return notifier, nil
+}
+
+// Name returns the webhook name
+func (w *WebhookNotifier) Name() string {
+	return "webhook " + w.name
+}
+
+// Notify posts the alert if the webhook subscribes to its kind and the rate limit allows
+func (w *WebhookNotifier) Notify(alert *models.Alert) error {
+	return w.NotifyContext(context.Background(), alert)
+}
+
+// NotifyContext is Notify with the request and the waits between retries ending when ctx is done
+func (w *WebhookNotifier) NotifyContext(ctx context.Context, alert *models.Alert) error {
+	if w.events != nil && !w.events[alert.Kind] {
+		return nil
+	}
+	allowed, dropped := w.limiter.allow()
+	if !allowed {
+		return nil
+	}
+	if dropped > 0 {
+		logger.Warn("Webhook rate limit dropped notifications", "webhook", w.name, "dropped", dropped)
+	}
+
+	body, err := w.render(alert)
+	if err != nil {
+		return core.NewErrorf(core.ErrNotificationFailed, err, "webhook %s: failed to render payload", w.name)
+	}
+
+	delay := w.backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if attempt >= w.retries || errors.As(err, &permanent) || ctx.Err() != nil {
+			return err
+		}
+		delay = retryDelay(delay, retryAfter)
+		logger.Debug("Webhook delivery failed, retrying", "webhook", w.name, "attempt", attempt+1, "delay", delay.String(), "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return core.NewErrorf(core.ErrNotificationFailed, err, "webhook %s: retry cancelled", w.name)
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxWebhookBackoff)
+	}
+}
+
+// retryDelay is the wait before the next attempt: the backoff, or a longer Retry-After
+// capped at maxWebhookBackoff so a receiver cannot park the notifier for hours
+func retryDelay(backoff, retryAfter time.Duration) time.Duration {
+	if retryAfter > backoff {
+		return min(retryAfter, maxWebhookBackoff)
+	}
+	return backoff
+}
+
+// render builds the request body from the preset or template, or the generic payload
+func (w *WebhookNotifier) render(alert *models.Alert) ([]byte, error) {
+	payload := w.payload(alert)
+	if w.template == nil {
+		return json.Marshal(payload)
+	}
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, payload); err != nil {
+		return nil, err
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("template output is not valid JSON")
+	}
+	return body.Bytes(), nil
+}
+
+func (w *WebhookNotifier) payload(alert *models.Alert) webhookPayload {
+	title := "Guardian: " + strings.ReplaceAll(alert.Kind, "_", " ")
+	payload := webhookPayload{
+		Kind:      alert.Kind,
+		Title:     title,
+		Message:   alert.Message,
+		Text:      fmt.Sprintf("🛡️ %s on %s: %s", title, w.host, alert.Message),
+		Color:     severityColor(alert.Severity),
+		Severity:  alert.Severity.String(),
+		Service:   alert.Service,
+		IP:        alert.IP,
+		Username:  alert.Username,
+		Count:     alert.Count,
+		Samples:   alert.Samples,
+		Host:      w.host,
+		Timestamp: alert.Timestamp,
+	}
+	if alert.Window > 0 {
+		payload.Window = alert.Window.String()
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now()
+	}
+	return payload
+}
+
+// post sends one request; a 429 or 503 may ask for a delay before the retry
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) (time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Guardian")
+	for key, value := range w.headers {
+		request.Header.Set(key, value)
+	}
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		request.Header.Set(TimestampHeader, timestamp)
+		request.Header.Set(SignatureHeader, "sha256="+Sign(w.secret, timestamp, body))
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return 0, core.NewErrorf(core.ErrNotificationFailed, err, "webhook %s unreachable", w.name)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return 0, nil
+	}
+	err = core.NewErrorf(core.ErrNotificationFailed, nil, "webhook %s answered %s", w.name, response.Status)
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		seconds, _ := strconv.Atoi(response.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, err
+	default:
+		// Other client errors (bad URL, rejected payload) will not succeed on retry
+		return 0, &permanentError{err}
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>", as sent in X-Guardian-Signature
+// Receivers recompute it with the shared secret and compare in constant time
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// permanentError marks a delivery failure that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// jsonValue renders a template value as a JSON literal, so templates cannot produce broken strings
+func jsonValue(value any) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// truncate shortens text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// severityColor picks a card colour for chat presets
+func severityColor(severity models.Severity) string {
+	switch {
+	case severity >= models.SeverityCritical:
+		return "8B0000"
+	case severity >= models.SeverityHigh:
+		return "D9534F"
+	case severity >= models.SeverityMedium:
+		return "F0AD4E"
+	default:
+		return "5BC0DE"
+	}
+}
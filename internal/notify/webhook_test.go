@@ -0,0 +1,279 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// webhookServer records the requests it receives and answers with the next queued status
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int // answered in order; 200 once they run out
+	headers  http.Header
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	t.Helper()
+	server := &webhookServer{statuses: statuses, headers: http.Header{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		server.requests = append(server.requests, r)
+		server.bodies = append(server.bodies, body)
+		status := http.StatusOK
+		if len(server.statuses) > 0 {
+			status, server.statuses = server.statuses[0], server.statuses[1:]
+		}
+		for key, values := range server.headers {
+			w.Header()[key] = values
+		}
+		server.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func (s *webhookServer) body(i int) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[i]
+}
+
+// newTestWebhook creates a webhook for server that retries without waiting
+func newTestWebhook(t *testing.T, config models.WebhookConfig) *WebhookNotifier {
+	t.Helper()
+	notifier, err := NewWebhookNotifier(config)
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+	notifier.host = "web01"
+	return notifier
+}
+
+func testAlert() *models.Alert {
+	return &models.Alert{
+		Timestamp: time.Date(2024, 1, 15, 10, 23, 45, 0, time.UTC),
+		Kind:      models.EventIPBlocked,
+		Service:   "SSH",
+		IP:        "203.0.113.5",
+		Severity:  models.SeverityHigh,
+		Message:   "Blocked 203.0.113.5 after 5 failed logins",
+	}
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	server := newWebhookServer(t)
+	secret := "s3cret"
+	notifier := newTestWebhook(t, models.WebhookConfig{Name: "siem", URL: server.URL, Secret: secret})
+
+	if err := notifier.Notify(testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if server.count() != 1 {
+		t.Fatalf("got %d requests, want 1", server.count())
+	}
+
+	request, body := server.requests[0], server.body(0)
+	timestamp := request.Header.Get(TimestampHeader)
+	signature, found := strings.CutPrefix(request.Header.Get(SignatureHeader), "sha256=")
+	if timestamp == "" || !found {
+		t.Fatalf("missing signature headers: %v", request.Header)
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign([]byte(secret), timestamp, body))) {
+		t.Error("signature does not verify with the shared secret")
+	}
+	if hmac.Equal([]byte(signature), []byte(Sign([]byte("wrong"), timestamp, body))) {
+		t.Error("signature verifies with the wrong secret")
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("generic body is not JSON: %v", err)
+	}
+	if payload["kind"] != models.EventIPBlocked || payload["ip"] != "203.0.113.5" || payload["host"] != "web01" {
+		t.Errorf("unexpected generic payload %v", payload)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	server := newWebhookServer(t, http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusBadGateway)
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL})
+
+	if err := notifier.Notify(testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if server.count() != 4 {
+		t.Errorf("got %d requests, want 3 failures and the successful retry", server.count())
+	}
+}
+
+func TestWebhookGivesUpAfterMaxRetries(t *testing.T) {
+	server := newWebhookServer(t, 500, 500, 500)
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL, MaxRetries: 2})
+
+	if err := notifier.Notify(testAlert()); err == nil {
+		t.Fatal("expected an error once the retries are used up")
+	}
+	if server.count() != 3 {
+		t.Errorf("got %d requests, want 3", server.count())
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		server := newWebhookServer(t, status, status)
+		notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL})
+
+		if err := notifier.Notify(testAlert()); err == nil {
+			t.Errorf("status %d: expected an error", status)
+		}
+		if server.count() != 1 {
+			t.Errorf("status %d: got %d requests, want no retry", status, server.count())
+		}
+	}
+}
+
+func TestWebhookRetryWaitIsCancellable(t *testing.T) {
+	server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	server.headers.Set("Retry-After", "3600")
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := notifier.NotifyContext(ctx, testAlert()); err == nil {
+		t.Fatal("expected an error when the wait is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("NotifyContext returned after %v, want soon after cancellation", elapsed)
+	}
+	if server.count() != 1 {
+		t.Errorf("got %d requests, want 1", server.count())
+	}
+}
+
+func TestRetryDelayCapsRetryAfter(t *testing.T) {
+	cases := []struct {
+		backoff, retryAfter, want time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{4 * time.Second, 2 * time.Second, 4 * time.Second},
+		{time.Second, 10 * time.Second, 10 * time.Second},
+		{time.Second, time.Hour, maxWebhookBackoff},
+	}
+	for _, c := range cases {
+		if got := retryDelay(c.backoff, c.retryAfter); got != c.want {
+			t.Errorf("retryDelay(%v, %v) = %v, want %v", c.backoff, c.retryAfter, got, c.want)
+		}
+	}
+}
+
+func TestWebhookRateLimit(t *testing.T) {
+	server := newWebhookServer(t)
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL, RateLimit: 2})
+
+	for range 5 {
+		if err := notifier.Notify(testAlert()); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if server.count() != 2 {
+		t.Errorf("got %d requests, want the 2 the rate limit allows", server.count())
+	}
+}
+
+func TestWebhookEventFilter(t *testing.T) {
+	server := newWebhookServer(t)
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL})
+
+	attack := testAlert()
+	attack.Kind = models.EventAttackDetected // not in the default subscription
+	if err := notifier.Notify(attack); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if server.count() != 0 {
+		t.Errorf("got %d requests for an unsubscribed kind", server.count())
+	}
+}
+
+func TestWebhookPresets(t *testing.T) {
+	text := "🛡️ Guardian: ip blocked on web01: Blocked 203.0.113.5 after 5 failed logins"
+	cases := []struct {
+		preset string
+		want   map[string]any
+	}{
+		{"slack", map[string]any{"text": text}},
+		{"discord", map[string]any{"content": text}},
+		{"teams", map[string]any{
+			"@type":      "MessageCard",
+			"summary":    "Guardian: ip blocked",
+			"title":      "Guardian: ip blocked",
+			"themeColor": "D9534F",
+			"text":       "Blocked 203.0.113.5 after 5 failed logins",
+		}},
+	}
+	for _, c := range cases {
+		server := newWebhookServer(t)
+		notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL, Preset: c.preset})
+		if err := notifier.Notify(testAlert()); err != nil {
+			t.Fatalf("%s: Notify: %v", c.preset, err)
+		}
+
+		var body map[string]any
+		if err := json.Unmarshal(server.body(0), &body); err != nil {
+			t.Fatalf("%s: body is not JSON: %v", c.preset, err)
+		}
+		for key, want := range c.want {
+			if body[key] != want {
+				t.Errorf("%s: %s = %v, want %v", c.preset, key, body[key], want)
+			}
+		}
+	}
+}
+
+func TestWebhookDiscordTruncatesLongMessages(t *testing.T) {
+	server := newWebhookServer(t)
+	notifier := newTestWebhook(t, models.WebhookConfig{URL: server.URL, Preset: "discord"})
+	alert := testAlert()
+	alert.Message = strings.Repeat("x", 3000)
+	if err := notifier.Notify(alert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(server.body(0), &body); err != nil {
+		t.Fatal(err)
+	}
+	if length := len([]rune(body.Content)); length != 2000 {
+		t.Errorf("content is %d characters, want Discord's limit of 2000", length)
+	}
+}
+
+func TestWebhookRejectsUnknownPreset(t *testing.T) {
+	if _, err := NewWebhookNotifier(models.WebhookConfig{URL: "https://example.com/hook", Preset: "irc"}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
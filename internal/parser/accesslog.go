@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access log format presets, usable as service log_format values
+var accessLogPresets = map[string]string{
+	"common":         `%h %l %u %t "%r" %>s %b`,
+	"combined":       `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`,
+	"vhost_combined": `%v:%p %h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"`,
+	"nginx_combined": `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`,
+}
+
+// DefaultAccessLogFormat is the combined format shared by Apache and Nginx
+const DefaultAccessLogFormat = "combined"
+
+// Access log fields Guardian understands
+const (
+	fieldClient  = "client"
+	fieldUser    = "user"
+	fieldTime    = "time"
+	fieldRequest = "request"
+	fieldMethod  = "method"
+	fieldPath    = "path"
+	fieldStatus  = "status"
+	fieldAgent   = "agent"
+)
+
+// Apache LogFormat directives and Nginx log_format variables mapped to Guardian fields
+var accessLogDirectives = map[string]string{
+	"%h": fieldClient, "%a": fieldClient, "%{c}a": fieldClient,
+	"$remote_addr": fieldClient, "$binary_remote_addr": fieldClient,
+	"%u": fieldUser, "$remote_user": fieldUser,
+	"%t": fieldTime, "$time_local": fieldTime,
+	"%r": fieldRequest, "$request": fieldRequest,
+	"%m": fieldMethod, "$request_method": fieldMethod,
+	"%U": fieldPath, "$uri": fieldPath, "$request_uri": fieldPath,
+	"%>s": fieldStatus, "%s": fieldStatus, "$status": fieldStatus,
+	"%{User-agent}i": fieldAgent, "%{User-Agent}i": fieldAgent, "$http_user_agent": fieldAgent,
+}
+
+// accessLogDirectiveRegex matches one Apache (%h, %>s, %{Referer}i) or Nginx ($remote_addr) directive
+var accessLogDirectiveRegex = regexp.MustCompile(`%(?:>|<)?(?:\{[^}]*\})?[a-zA-Z]|\$[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// AccessLogFormat is a compiled access log format
+type AccessLogFormat struct {
+	format string
+	regex  *regexp.Regexp
+	fields map[string]int // Guardian field -> submatch index
+}
+
+// AccessLogEntry is the part of an access log line Guardian needs
+type AccessLogEntry struct {
+	Client    string
+	User      string
+	Timestamp time.Time
+	Method    string
+	Path      string
+	Status    int
+	UserAgent string
+}
+
+// CompileAccessLogFormat builds a matcher for a preset name or a format string
+// Both Apache LogFormat ("%h %l %u %t \"%r\" %>s %b") and Nginx log_format
+// ("$remote_addr - $remote_user [$time_local] \"$request\" $status ...") syntax are accepted
+func CompileAccessLogFormat(format string) (*AccessLogFormat, error) {
+	if format == "" {
+		format = DefaultAccessLogFormat
+	}
+	if preset, exists := accessLogPresets[strings.ToLower(format)]; exists {
+		format = preset
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	fields := make(map[string]int)
+	group := 0
+	last := 0
+
+	for _, loc := range accessLogDirectiveRegex.FindAllStringIndex(format, -1) {
+		literal := format[last:loc[0]]
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		directive := format[loc[0]:loc[1]]
+		last = loc[1]
+
+		quoted := strings.HasSuffix(literal, `"`)
+		field, known := accessLogDirectives[directive]
+
+		switch {
+		case field == fieldTime && !strings.HasSuffix(literal, "["):
+			// Apache %t includes the brackets
+			pattern.WriteString(`\[([^\]]+)\]`)
+		case field == fieldTime:
+			pattern.WriteString(`([^\]]+)`)
+		case quoted:
+			pattern.WriteString(`((?:[^"\\]|\\.)*)`)
+		default:
+			pattern.WriteString(`(\S*)`)
+		}
+		group++
+
+		if known {
+			if _, taken := fields[field]; !taken {
+				fields[field] = group
+			}
+		}
+	}
+	pattern.WriteString(regexp.QuoteMeta(format[last:]))
+
+	if _, exists := fields[fieldClient]; !exists {
+		return nil, fmt.Errorf("access log format has no client address field: %q", format)
+	}
+	if _, exists := fields[fieldStatus]; !exists {
+		return nil, fmt.Errorf("access log format has no status field: %q", format)
+	}
+
+	regex, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid access log format %q: %w", format, err)
+	}
+
+	return &AccessLogFormat{format: format, regex: regex, fields: fields}, nil
+}
+
+// Parse extracts an entry from an access log line; ok is false if the line does not match
+func (f *AccessLogFormat) Parse(line string) (entry AccessLogEntry, ok bool) {
+	matches := f.regex.FindStringSubmatch(line)
+	if matches == nil {
+		return entry, false
+	}
+
+	get := func(field string) string {
+		if index, exists := f.fields[field]; exists {
+			value := matches[index]
+			if value == "-" {
+				return ""
+			}
+			return value
+		}
+		return ""
+	}
+
+	entry.Client = get(fieldClient)
+	entry.User = get(fieldUser)
+	entry.UserAgent = get(fieldAgent)
+	entry.Method = get(fieldMethod)
+	entry.Path = get(fieldPath)
+
+	// "%r" / "$request" holds "GET /path HTTP/1.1"
+	if request := get(fieldRequest); request != "" {
+		parts := strings.Fields(request)
+		if len(parts) >= 2 {
+			if entry.Method == "" {
+				entry.Method = parts[0]
+			}
+			if entry.Path == "" {
+				entry.Path = parts[1]
+			}
+		} else if entry.Path == "" {
+			entry.Path = request
+		}
+	}
+
+	status, err := strconv.Atoi(get(fieldStatus))
+	if err != nil {
+		return entry, false
+	}
+	entry.Status = status
+
+	entry.Timestamp = time.Now()
+	if raw := get(fieldTime); raw != "" {
+		if parsed, err := time.Parse("02/Jan/2006:15:04:05 -0700", raw); err == nil {
+			entry.Timestamp = parsed
+		}
+	}
+
+	return entry, true
+}
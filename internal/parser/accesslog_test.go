@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileAccessLogFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		line   string
+		want   AccessLogEntry
+	}{
+		{
+			name:   "combined preset",
+			format: "combined",
+			line:   `203.0.113.5 - bob [15/Jan/2024:10:23:45 +0000] "GET /private HTTP/1.1" 401 381 "-" "curl/8.5.0"`,
+			want:   AccessLogEntry{Client: "203.0.113.5", User: "bob", Method: "GET", Path: "/private", Status: 401, UserAgent: "curl/8.5.0"},
+		},
+		{
+			name:   "common preset",
+			format: "common",
+			line:   `2001:db8::7 - - [15/Jan/2024:10:23:45 +0000] "POST /login HTTP/1.1" 403 12`,
+			want:   AccessLogEntry{Client: "2001:db8::7", Method: "POST", Path: "/login", Status: 403},
+		},
+		{
+			name:   "vhost_combined preset",
+			format: "vhost_combined",
+			line:   `www.example.com:443 198.51.100.7 - - [15/Jan/2024:10:23:45 +0000] "GET /.env HTTP/1.1" 404 196 "-" "Mozilla/5.0"`,
+			want:   AccessLogEntry{Client: "198.51.100.7", Method: "GET", Path: "/.env", Status: 404, UserAgent: "Mozilla/5.0"},
+		},
+		{
+			name:   "nginx log_format",
+			format: `$remote_addr [$time_local] $request_method $uri $status "$http_user_agent"`,
+			line:   `203.0.113.9 [15/Jan/2024:10:23:45 +0000] GET /wp-login.php 404 "sqlmap/1.7 \"quoted\""`,
+			want:   AccessLogEntry{Client: "203.0.113.9", Method: "GET", Path: "/wp-login.php", Status: 404, UserAgent: `sqlmap/1.7 \"quoted\"`},
+		},
+		{
+			name:   "Apache LogFormat with %a and %m %U",
+			format: `%a %t %m %U %>s`,
+			line:   `203.0.113.10 [15/Jan/2024:10:23:45 +0000] HEAD /xmlrpc.php 429`,
+			want:   AccessLogEntry{Client: "203.0.113.10", Method: "HEAD", Path: "/xmlrpc.php", Status: 429},
+		},
+		{
+			name:   "malformed request line",
+			format: "combined",
+			line:   `203.0.113.5 - - [15/Jan/2024:10:23:45 +0000] "\x16\x03\x01" 400 157 "-" "-"`,
+			want:   AccessLogEntry{Client: "203.0.113.5", Path: `\x16\x03\x01`, Status: 400},
+		},
+	}
+	stamp := time.Date(2024, 1, 15, 10, 23, 45, 0, time.UTC)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := CompileAccessLogFormat(tc.format)
+			if err != nil {
+				t.Fatalf("CompileAccessLogFormat: %v", err)
+			}
+			entry, ok := format.Parse(tc.line)
+			if !ok {
+				t.Fatalf("line did not match %q", tc.format)
+			}
+			if !entry.Timestamp.Equal(stamp) {
+				t.Errorf("timestamp = %v, want %v", entry.Timestamp, stamp)
+			}
+			entry.Timestamp = time.Time{}
+			if entry != tc.want {
+				t.Errorf("entry = %+v\nwant    %+v", entry, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileAccessLogFormatRejectsUnusableFormats(t *testing.T) {
+	for _, format := range []string{
+		`%u %t "%r" %>s`,          // no client address
+		`$remote_addr "$request"`, // no status
+	} {
+		if _, err := CompileAccessLogFormat(format); err == nil {
+			t.Errorf("format %q was accepted", format)
+		}
+	}
+}
+
+func TestAccessLogFormatRejectsOtherLines(t *testing.T) {
+	format, err := CompileAccessLogFormat("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`203.0.113.5 - - [15/Jan/2024:10:23:45 +0000] "GET / HTTP/1.1" abc 12 "-" "-"`,
+		`Jan 15 10:23:45 web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2`,
+	} {
+		if entry, ok := format.Parse(line); ok {
+			t.Errorf("Parse(%q) = %+v, want no match", line, entry)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// ApacheParser parses Apache httpd access and error log lines
+// One parser handles both files, so the access and error log can be
+// configured as two services with log_pattern "apache"
+type ApacheParser struct {
+	webParser
+	patterns []string
+
+	errorLineRegex *regexp.Regexp
+	rules          []apacheErrorRule
+}
+
+// apacheErrorRule maps an error log message to an attempt
+type apacheErrorRule struct {
+	regex    *regexp.Regexp // optional first submatch is the username
+	message  string
+	severity models.Severity
+}
+
+// NewApacheParser creates an Apache parser for the given access log format
+// format is a preset name ("combined", "common", "vhost_combined") or a LogFormat string; empty selects combined
+func NewApacheParser(format string) (*ApacheParser, error) {
+	access, err := CompileAccessLogFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApacheParser{
+		webParser: webParser{service: "Apache", access: access},
+		patterns: []string{
+			"AH01617", "AH01618", "AH01614", "AH01797", "AH01630",
+			"authentication failure", "not found", "client denied by server configuration",
+		},
+
+		// 2.4: [Mon Jan 15 10:23:45.123456 2024] [auth_basic:error] [pid 1234] [client 203.0.113.5:54321] AH01618: ...
+		// 2.2: [Mon Jan 15 10:23:45 2024] [error] [client 203.0.113.5] user admin not found: /private
+		// 2.4 tags the level with its module and always appends the client port, also to IPv6 addresses
+		errorLineRegex: regexp.MustCompile(`^\[([^\]]+)\] \[([^\]]+)\](?: \[pid [^\]]+\])?(?: \[[^\]]+\])? \[client ([^\]]+)\] (.*)$`),
+		rules: []apacheErrorRule{
+			{regexp.MustCompile(`(?:AH01617: )?user (\S+): authentication failure`), "HTTP basic auth password mismatch for user", models.SeverityMedium},
+			{regexp.MustCompile(`(?:AH01618: )?user (\S+) not found`), "HTTP basic auth unknown user", models.SeverityMedium},
+			{regexp.MustCompile(`AH01614: client used wrong authentication scheme`), "HTTP auth with wrong scheme", models.SeverityLow},
+			{regexp.MustCompile(`(?:AH01797|AH01630): client denied by server configuration`), "Request denied by server configuration", models.SeverityLow},
+			{regexp.MustCompile(`client denied by server configuration`), "Request denied by server configuration", models.SeverityLow},
+		},
+	}, nil
+}
+
+// ParseLine parses an access or error log line
+// Returns (nil, nil) for lines that are not abuse
+func (p *ApacheParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	if matches := p.errorLineRegex.FindStringSubmatch(line); matches != nil {
+		client := matches[3]
+		if strings.Contains(matches[2], ":") {
+			// "2001:db8::9:54322" would parse as an IPv6 address including the port
+			if i := strings.LastIndex(client, ":"); i > 0 {
+				client = client[:i]
+			}
+		}
+		return p.parseErrorLine(matches[1], client, matches[4])
+	}
+
+	entry, ok := p.access.Parse(line)
+	if !ok {
+		return nil, fmt.Errorf("line does not match access or error log format")
+	}
+	return p.parseAccessEntry(entry)
+}
+
+// parseErrorLine maps mod_auth_basic failures and access control denials
+func (p *ApacheParser) parseErrorLine(rawTime, client, message string) (*models.AttackAttempt, error) {
+	timestamp := time.Now()
+	for _, layout := range []string{"Mon Jan 02 15:04:05.000000 2006", "Mon Jan 02 15:04:05 2006"} {
+		if parsed, err := time.ParseInLocation(layout, rawTime, time.Local); err == nil {
+			timestamp = parsed
+			break
+		}
+	}
+
+	for _, rule := range p.rules {
+		matches := rule.regex.FindStringSubmatch(message)
+		if matches == nil {
+			continue
+		}
+		username := ""
+		text := rule.message
+		if len(matches) > 1 {
+			username = matches[1]
+			text = fmt.Sprintf("%s '%s'", rule.message, username)
+		}
+		return p.newWebAttempt(timestamp, client, username, text, rule.severity)
+	}
+	return nil, nil
+}
+
+// ServiceName returns the service name this parser handles
+func (p *ApacheParser) ServiceName() string {
+	return p.service
+}
+
+// Patterns returns the error log messages this parser reacts to
+func (p *ApacheParser) Patterns() []string {
+	return p.patterns
+}
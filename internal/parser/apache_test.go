@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestApacheParser(t *testing.T) {
+	parser, err := NewApacheParser("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runParserCases(t, parser, []parserCase{
+		{
+			name:     "2.4 password mismatch",
+			line:     `[Mon Jan 15 10:23:45.123456 2024] [auth_basic:error] [pid 1234] [client 203.0.113.5:54321] AH01617: user admin: authentication failure for "/private": Password Mismatch`,
+			ip:       "203.0.113.5",
+			username: "admin",
+			severity: models.SeverityMedium,
+			reason:   "password mismatch for user 'admin'",
+		},
+		{
+			name:     "2.4 unknown user with thread id",
+			line:     `[Mon Jan 15 10:23:46.000001 2024] [auth_basic:error] [pid 1234:tid 140213] [client 2001:db8::9:54322] AH01618: user guest not found: /private`,
+			ip:       "2001:db8::9",
+			username: "guest",
+			severity: models.SeverityMedium,
+			reason:   "unknown user 'guest'",
+		},
+		{
+			name:     "wrong scheme",
+			line:     `[Mon Jan 15 10:23:47.000000 2024] [auth_digest:error] [pid 1234] [client 198.51.100.7:40000] AH01614: client used wrong authentication scheme: /private`,
+			ip:       "198.51.100.7",
+			severity: models.SeverityLow,
+			reason:   "HTTP auth with wrong scheme",
+		},
+		{
+			name:     "access denied",
+			line:     `[Mon Jan 15 10:23:48.000000 2024] [authz_core:error] [pid 1234] [client 203.0.113.9:40001] AH01630: client denied by server configuration: /var/www/html/.htaccess`,
+			ip:       "203.0.113.9",
+			severity: models.SeverityLow,
+			reason:   "Request denied by server configuration",
+		},
+		{
+			name:     "2.2 unknown user",
+			line:     `[Mon Jan 15 10:23:49 2024] [error] [client 203.0.113.10] user root not found: /private`,
+			ip:       "203.0.113.10",
+			username: "root",
+			severity: models.SeverityMedium,
+			reason:   "unknown user 'root'",
+		},
+		{
+			name:     "2.2 access denied",
+			line:     `[Mon Jan 15 10:23:50 2024] [error] [client 203.0.113.11] client denied by server configuration: /var/www/private`,
+			ip:       "203.0.113.11",
+			severity: models.SeverityLow,
+			reason:   "Request denied by server configuration",
+		},
+		{
+			name: "unrelated error",
+			line: `[Mon Jan 15 10:23:51.000000 2024] [core:error] [pid 1234] [client 203.0.113.5:40002] AH00128: File does not exist: /var/www/html/favicon.ico`,
+		},
+		{
+			name:     "scanner probe",
+			line:     `203.0.113.12 - - [15/Jan/2024:10:23:52 +0000] "GET /phpmyadmin/index.php HTTP/1.1" 404 196 "-" "Mozilla/5.0"`,
+			ip:       "203.0.113.12",
+			severity: models.SeverityHigh,
+			reason:   "Scanner probe",
+		},
+		{
+			name:     "basic auth failure",
+			line:     `203.0.113.14 - alice [15/Jan/2024:10:23:54 +0000] "GET /private HTTP/1.1" 401 381 "-" "curl/8.5.0"`,
+			ip:       "203.0.113.14",
+			username: "alice",
+			severity: models.SeverityLow,
+			reason:   "HTTP authentication failed",
+		},
+		{
+			name: "ordinary request",
+			line: `203.0.113.5 - - [15/Jan/2024:10:23:56 +0000] "GET / HTTP/1.1" 200 45 "-" "Mozilla/5.0"`,
+		},
+		{
+			name:    "loopback client",
+			line:    `[Mon Jan 15 10:23:57.000000 2024] [auth_basic:error] [pid 1234] [client 127.0.0.1:40003] AH01618: user bob not found: /private`,
+			wantErr: true,
+		},
+	})
+
+	attempt, _ := parser.ParseLine(`[Mon Jan 15 10:23:49 2024] [error] [client 203.0.113.10] user root not found: /private`)
+	if want := time.Date(2024, 1, 15, 10, 23, 49, 0, time.Local); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("2.2 timestamp = %v, want %v", attempt, want)
+	}
+}
+
+func TestApacheParserCustomLogFormat(t *testing.T) {
+	parser, err := NewApacheParser(`%v %a %u %t "%r" %>s %b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runParserCases(t, parser, []parserCase{
+		{
+			name:     "virtual host first",
+			line:     `www.example.com 203.0.113.20 bob [15/Jan/2024:10:23:45 +0000] "GET /secure HTTP/1.1" 401 381`,
+			ip:       "203.0.113.20",
+			username: "bob",
+			severity: models.SeverityLow,
+			reason:   "HTTP authentication failed",
+		},
+	})
+}
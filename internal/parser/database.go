@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// privilegedDatabaseUsers are the built-in superuser accounts brute-forced first
+var privilegedDatabaseUsers = []string{"root", "sa", "postgres", "admin", "administrator", "mysql"}
+
+// databaseSeverity rates a failed database login by the account it targets
+func databaseSeverity(username string) models.Severity {
+	for _, privileged := range privilegedDatabaseUsers {
+		if strings.EqualFold(username, privileged) {
+			return models.SeverityHigh
+		}
+	}
+	return models.SeverityMedium
+}
+
+// newDatabaseAttempt builds an attempt with the database user as Username
+func newDatabaseAttempt(service string, timestamp time.Time, rawIP, username, reason string) (*models.AttackAttempt, error) {
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AttackAttempt{
+		Timestamp: timestamp,
+		IP:        ip,
+		Service:   service,
+		Username:  username,
+		Message:   fmt.Sprintf("%s login failed for user '%s' from %s: %s", service, username, ip, reason),
+		Severity:  databaseSeverity(username),
+	}, nil
+}
+
+// postgresPrefixEscapes maps log_line_prefix escapes to regex fragments
+// Escapes not listed here match a single non-space token
+var postgresPrefixEscapes = map[byte]string{
+	'h': `(?P<host>[0-9A-Fa-f:\.]+|\[local\])`,
+	'r': `(?P<host>[0-9A-Fa-f:\.]+|\[local\])(?:\(\d+\))?`,
+	'u': `(?P<user>\[unknown\]|[^\s@,\]]*)`,
+	'd': `(?:\[unknown\]|[^\s@,\]]*)`,
+	'a': `.*?`,
+	'i': `.*?`,
+	'b': `.*?`,
+	't': `(?P<time>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \S+`,
+	'm': `(?P<time>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\.\d+ \S+`,
+	'n': `\d+\.\d+`,
+	'p': `\d+`,
+	'l': `\d+`,
+	'q': ``,
+}
+
+// PostgresParser parses PostgreSQL server log lines
+// The client address comes from log_line_prefix, so configure the prefix as log_format, e.g.
+//
+//	log_line_prefix = '%m [%p] %q%u@%d %h '
+//	2024-01-15 10:23:45.123 UTC [1234] bob@app 203.0.113.5 FATAL:  password authentication failed for user "bob"
+//
+// Without a log_format, "client=<addr>" / "host=<addr>" prefixes and pg_hba rejections are recognized
+type PostgresParser struct {
+	prefixRegex   *regexp.Regexp
+	fallbackRegex *regexp.Regexp
+	failureRegex  *regexp.Regexp
+	hbaRegex      *regexp.Regexp
+}
+
+// NewPostgresParser creates a PostgreSQL parser for a log_line_prefix (may be empty)
+func NewPostgresParser(logLinePrefix string) (*PostgresParser, error) {
+	parser := &PostgresParser{
+		fallbackRegex: regexp.MustCompile(`(?:client|host)=([0-9A-Fa-f:\.]+)`),
+		failureRegex:  regexp.MustCompile(`FATAL:\s+(\w+ authentication failed) for user "([^"]*)"|FATAL:\s+role "([^"]*)" does not exist`),
+		hbaRegex:      regexp.MustCompile(`FATAL:\s+no pg_hba\.conf entry for host "([^"]+)", user "([^"]*)"`),
+	}
+
+	if logLinePrefix != "" {
+		regex, err := compilePostgresPrefix(logLinePrefix)
+		if err != nil {
+			return nil, err
+		}
+		parser.prefixRegex = regex
+	}
+	return parser, nil
+}
+
+// compilePostgresPrefix turns a log_line_prefix into a regex with host/user/time groups
+func compilePostgresPrefix(prefix string) (*regexp.Regexp, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(prefix); i++ {
+		if prefix[i] != '%' || i+1 >= len(prefix) {
+			pattern.WriteString(regexp.QuoteMeta(prefix[i : i+1]))
+			continue
+		}
+		i++
+		escape := prefix[i]
+		if escape == '%' {
+			pattern.WriteString("%")
+			continue
+		}
+		fragment, known := postgresPrefixEscapes[escape]
+		if !known {
+			fragment = `\S*`
+		}
+		// A named group may only appear once
+		for _, name := range []string{"host", "user", "time"} {
+			group := "(?P<" + name + ">"
+			if strings.Contains(fragment, group) {
+				if seen[name] {
+					fragment = strings.Replace(fragment, group, "(?:", 1)
+				}
+				seen[name] = true
+			}
+		}
+		pattern.WriteString(fragment)
+	}
+
+	if !seen["host"] {
+		return nil, fmt.Errorf("log_line_prefix %q has no client address (%%h or %%r)", prefix)
+	}
+	return regexp.Compile(pattern.String())
+}
+
+// ParseLine extracts failed authentications and pg_hba rejections
+// Returns (nil, nil) for other lines
+func (p *PostgresParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	if matches := p.hbaRegex.FindStringSubmatch(line); matches != nil {
+		return newDatabaseAttempt("PostgreSQL", p.timestamp(line), matches[1], matches[2], "no pg_hba.conf entry")
+	}
+
+	matches := p.failureRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+	reason, username := matches[1], matches[2]
+	if matches[3] != "" {
+		reason, username = "role does not exist", matches[3]
+	}
+
+	rawIP := ""
+	if p.prefixRegex != nil {
+		if prefix := p.prefixRegex.FindStringSubmatch(line); prefix != nil {
+			rawIP = prefix[p.prefixRegex.SubexpIndex("host")]
+		}
+	}
+	if rawIP == "" {
+		if fallback := p.fallbackRegex.FindStringSubmatch(line); fallback != nil {
+			rawIP = fallback[1]
+		}
+	}
+	if rawIP == "" {
+		return nil, fmt.Errorf("no client address in line; add %%h to log_line_prefix and set log_format")
+	}
+
+	return newDatabaseAttempt("PostgreSQL", p.timestamp(line), rawIP, username, reason)
+}
+
+// timestamp reads the time from the prefix or the start of the line
+func (p *PostgresParser) timestamp(line string) time.Time {
+	raw := line
+	if p.prefixRegex != nil {
+		if index := p.prefixRegex.SubexpIndex("time"); index >= 0 {
+			if prefix := p.prefixRegex.FindStringSubmatch(line); prefix != nil && prefix[index] != "" {
+				raw = prefix[index]
+			}
+		}
+	}
+	if len(raw) >= 19 {
+		if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", raw[:19], time.Local); err == nil {
+			return parsed
+		}
+	}
+	return time.Now()
+}
+
+// ServiceName returns the service name this parser handles
+func (p *PostgresParser) ServiceName() string {
+	return "PostgreSQL"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *PostgresParser) Patterns() []string {
+	return []string{"password authentication failed for user", "no pg_hba.conf entry for host", "does not exist"}
+}
+
+// MySQLParser parses MySQL and MariaDB error log lines
+//
+//	2024-01-15T10:23:45.123456Z 12 [Note] [MY-010926] [Server] Access denied for user 'root'@'203.0.113.5' (using password: YES)
+//	2024-01-15 10:23:45 12 [Warning] Access denied for user 'root'@'203.0.113.5' (using password: YES)
+type MySQLParser struct {
+	deniedRegex *regexp.Regexp
+}
+
+// NewMySQLParser creates a MySQL/MariaDB parser
+func NewMySQLParser() *MySQLParser {
+	return &MySQLParser{
+		deniedRegex: regexp.MustCompile(`Access denied for user '((?:[^']|'')*)'@'([^']*)'(?: \(using password: (YES|NO)\))?`),
+	}
+}
+
+// ParseLine extracts "Access denied" lines
+// Returns (nil, nil) for other lines; hosts logged by name (skip-name-resolve off) cannot be blocked.
+// A quote inside the user name is logged doubled, as in SQL string literals
+func (p *MySQLParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	matches := p.deniedRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+
+	reason := "access denied"
+	if matches[3] == "NO" {
+		reason = "access denied (no password)"
+	}
+
+	timestamp := time.Now()
+	if len(line) >= 19 {
+		for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+			if parsed, err := time.Parse(layout, line[:19]); err == nil {
+				timestamp = parsed
+				break
+			}
+		}
+	}
+
+	username := strings.ReplaceAll(matches[1], "''", "'")
+	return newDatabaseAttempt("MySQL", timestamp, matches[2], username, reason)
+}
+
+// ServiceName returns the service name this parser handles
+func (p *MySQLParser) ServiceName() string {
+	return "MySQL"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *MySQLParser) Patterns() []string {
+	return []string{"Access denied for user"}
+}
+
+// MSSQLParser parses SQL Server ERRORLOG text files (error 18456)
+//
+//	2024-01-15 10:23:45.12 Logon       Error: 18456, Severity: 14, State: 8.
+//	2024-01-15 10:23:45.12 Logon       Login failed for user 'sa'. Reason: Password did not match that for the login provided. [CLIENT: 203.0.113.5]
+//
+// Only the second line carries the user and client, so the Error line itself is ignored
+type MSSQLParser struct {
+	loginRegex *regexp.Regexp
+}
+
+// NewMSSQLParser creates a SQL Server ERRORLOG parser
+func NewMSSQLParser() *MSSQLParser {
+	return &MSSQLParser{
+		loginRegex: regexp.MustCompile(`Login failed for user '([^']*)'\.(?:\s*Reason:\s*([^\[]*?))?\s*\[CLIENT:\s*([^\]]+)\]`),
+	}
+}
+
+// ParseLine extracts "Login failed for user" lines
+// ERRORLOG is UTF-16; NUL bytes and byte order marks left by a byte-oriented reader are dropped
+func (p *MSSQLParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	line = strings.TrimPrefix(strings.ReplaceAll(line, "\x00", ""), "\ufeff")
+
+	matches := p.loginRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+	if strings.TrimSpace(matches[3]) == "<local machine>" {
+		return nil, nil
+	}
+
+	reason := strings.TrimSuffix(strings.TrimSpace(matches[2]), ".")
+	if reason == "" {
+		reason = "error 18456"
+	}
+
+	timestamp := time.Now()
+	if len(line) >= 19 {
+		if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", line[:19], time.Local); err == nil {
+			timestamp = parsed
+		}
+	}
+
+	return newDatabaseAttempt("MSSQL", timestamp, matches[3], matches[1], reason)
+}
+
+// ServiceName returns the service name this parser handles
+func (p *MSSQLParser) ServiceName() string {
+	return "MSSQL"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *MSSQLParser) Patterns() []string {
+	return []string{"Error: 18456", "Login failed for user"}
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// parserCase is one log line and the attempt expected from it
+// An empty ip expects no attempt; wantErr expects the line to be rejected
+type parserCase struct {
+	name     string
+	line     string
+	ip       string
+	username string
+	severity models.Severity
+	reason   string
+	wantErr  bool
+}
+
+func runParserCases(t *testing.T, parser core.LogParser, cases []parserCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempt, err := parser.ParseLine(tc.line)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLine accepted the line: %+v", attempt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLine: %v", err)
+			}
+			if tc.ip == "" {
+				if attempt != nil {
+					t.Fatalf("ParseLine = %+v, want no attempt", attempt)
+				}
+				return
+			}
+			if attempt == nil {
+				t.Fatal("ParseLine found no attempt")
+			}
+			if attempt.IP != tc.ip || attempt.Username != tc.username || attempt.Severity != tc.severity {
+				t.Errorf("attempt = %s / %q / %v, want %s / %q / %v",
+					attempt.IP, attempt.Username, attempt.Severity, tc.ip, tc.username, tc.severity)
+			}
+			if !strings.Contains(attempt.Message, tc.reason) {
+				t.Errorf("message %q does not mention %q", attempt.Message, tc.reason)
+			}
+		})
+	}
+}
+
+func TestPostgresParser(t *testing.T) {
+	prefixed, err := NewPostgresParser("%m [%p] %q%u@%d %h ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runParserCases(t, prefixed, []parserCase{
+		{
+			name:     "password failure",
+			line:     `2024-01-15 10:23:45.123 UTC [1234] bob@app 203.0.113.5 FATAL:  password authentication failed for user "bob"`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "password authentication failed",
+		},
+		{
+			name:     "unknown role",
+			line:     `2024-01-15 10:23:46.001 UTC [1235] admin@postgres 2001:db8::7 FATAL:  role "admin" does not exist`,
+			ip:       "2001:db8::7",
+			username: "admin",
+			severity: models.SeverityHigh,
+			reason:   "role does not exist",
+		},
+		{
+			name:     "pg_hba rejection",
+			line:     `2024-01-15 10:23:47.000 UTC [1236] postgres@postgres 198.51.100.9 FATAL:  no pg_hba.conf entry for host "198.51.100.9", user "postgres", database "postgres", no encryption`,
+			ip:       "198.51.100.9",
+			username: "postgres",
+			severity: models.SeverityHigh,
+			reason:   "no pg_hba.conf entry",
+		},
+		{
+			name: "connection received",
+			line: `2024-01-15 10:23:45.000 UTC [1237] [unknown]@[unknown] 203.0.113.5 LOG:  connection received: host=203.0.113.5 port=51234`,
+		},
+		{
+			name:    "local socket",
+			line:    `2024-01-15 10:23:48.000 UTC [1238] bob@app [local] FATAL:  password authentication failed for user "bob"`,
+			wantErr: true,
+		},
+	})
+
+	attempt, _ := prefixed.ParseLine(`2024-01-15 10:23:45.123 UTC [1234] bob@app 203.0.113.5 FATAL:  password authentication failed for user "bob"`)
+	if want := time.Date(2024, 1, 15, 10, 23, 45, 0, time.Local); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", attempt, want)
+	}
+
+	plain, err := NewPostgresParser("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runParserCases(t, plain, []parserCase{
+		{
+			name:     "client= prefix",
+			line:     `2024-01-15 10:23:45 UTC client=203.0.113.5 FATAL:  password authentication failed for user "bob"`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "password authentication failed",
+		},
+		{
+			name:    "no address",
+			line:    `2024-01-15 10:23:45 UTC [1234] FATAL:  password authentication failed for user "bob"`,
+			wantErr: true,
+		},
+	})
+
+	if _, err := NewPostgresParser("%m [%p] %u@%d "); err == nil {
+		t.Error("a log_line_prefix without %h was accepted")
+	}
+}
+
+func TestMySQLParser(t *testing.T) {
+	runParserCases(t, NewMySQLParser(), []parserCase{
+		{
+			name:     "MySQL 8",
+			line:     `2024-01-15T10:23:45.123456Z 12 [Note] [MY-010926] [Server] Access denied for user 'root'@'203.0.113.5' (using password: YES)`,
+			ip:       "203.0.113.5",
+			username: "root",
+			severity: models.SeverityHigh,
+			reason:   "access denied",
+		},
+		{
+			name:     "MariaDB without password",
+			line:     `2024-01-15 10:23:45 12 [Warning] Access denied for user 'app'@'198.51.100.7' (using password: NO)`,
+			ip:       "198.51.100.7",
+			username: "app",
+			severity: models.SeverityMedium,
+			reason:   "access denied (no password)",
+		},
+		{
+			name:     "escaped quote",
+			line:     `2024-01-15T10:23:46.000000Z 13 [Note] [MY-010926] [Server] Access denied for user 'o''brien'@'203.0.113.8' (using password: YES)`,
+			ip:       "203.0.113.8",
+			username: "o'brien",
+			severity: models.SeverityMedium,
+			reason:   "access denied",
+		},
+		{
+			name:     "empty user",
+			line:     `2024-01-15 10:23:47 14 [Warning] Access denied for user ''@'2001:db8::5' (using password: NO)`,
+			ip:       "2001:db8::5",
+			username: "",
+			severity: models.SeverityMedium,
+			reason:   "access denied",
+		},
+		{
+			name:    "host name",
+			line:    `2024-01-15 10:23:48 15 [Warning] Access denied for user 'root'@'scanner.example.net' (using password: YES)`,
+			wantErr: true,
+		},
+		{
+			name:    "localhost",
+			line:    `2024-01-15 10:23:49 16 [Warning] Access denied for user 'root'@'127.0.0.1' (using password: YES)`,
+			wantErr: true,
+		},
+		{
+			name: "startup",
+			line: `2024-01-15T10:20:00.000000Z 0 [System] [MY-010931] [Server] /usr/sbin/mysqld: ready for connections.`,
+		},
+	})
+}
+
+func TestMSSQLParser(t *testing.T) {
+	runParserCases(t, NewMSSQLParser(), []parserCase{
+		{
+			name:     "wrong password",
+			line:     `2024-01-15 10:23:45.12 Logon       Login failed for user 'sa'. Reason: Password did not match that for the login provided. [CLIENT: 203.0.113.5]`,
+			ip:       "203.0.113.5",
+			username: "sa",
+			severity: models.SeverityHigh,
+			reason:   "Password did not match that for the login provided",
+		},
+		{
+			name:     "unknown login",
+			line:     `2024-01-15 10:23:46.40 Logon       Login failed for user 'backup'. Reason: Could not find a login matching the name provided. [CLIENT: 198.51.100.7]`,
+			ip:       "198.51.100.7",
+			username: "backup",
+			severity: models.SeverityMedium,
+			reason:   "Could not find a login",
+		},
+		{
+			name:     "UTF-16 residue",
+			line:     "\ufeff" + strings.Join(strings.Split(`2024-01-15 10:23:47.00 Logon       Login failed for user 'sa'. [CLIENT: 203.0.113.9]`, ""), "\x00"),
+			ip:       "203.0.113.9",
+			username: "sa",
+			severity: models.SeverityHigh,
+			reason:   "error 18456",
+		},
+		{
+			name: "error line",
+			line: `2024-01-15 10:23:45.12 Logon       Error: 18456, Severity: 14, State: 8.`,
+		},
+		{
+			name: "local machine",
+			line: `2024-01-15 10:23:48.00 Logon       Login failed for user 'sa'. Reason: Password did not match that for the login provided. [CLIENT: <local machine>]`,
+		},
+	})
+}
@@ -0,0 +1,392 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Event log channels with built-in event rules
+const (
+	ChannelSecurity  = "Security"
+	ChannelRDGateway = "Microsoft-Windows-TerminalServices-Gateway/Operational"
+	ChannelOpenSSH   = "OpenSSH/Operational"
+)
+
+// WindowsEvent is one event from `wevtutil qe <channel> /f:text`
+//
+//	Event[0]:
+//	  Log Name: Security
+//	  Event ID: 4771
+//	  Date: 2024-01-15T10:23:45.123
+//	  Description:
+//	Kerberos pre-authentication failed.
+//
+//	Account Information:
+//		Account Name:		bob
+//	Network Information:
+//		Client Address:		::ffff:203.0.113.5
+//
+// Description fields are keyed both by "Section/Label" and by "Label" (last value wins)
+type WindowsEvent struct {
+	ID        int
+	Channel   string
+	Timestamp time.Time
+	Message   string
+	Fields    map[string]string
+}
+
+// windowsEventFieldRegex matches "Label: value" lines (the value may be empty for section headers)
+var windowsEventFieldRegex = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9 ()/\-]*?):\s*(.*?)\s*$`)
+
+// ParseWindowsEvent parses one wevtutil text event block
+func ParseWindowsEvent(block string) (*WindowsEvent, error) {
+	event := &WindowsEvent{Fields: make(map[string]string)}
+
+	var message strings.Builder
+	inDescription := false
+	section := ""
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !inDescription {
+			matches := windowsEventFieldRegex.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			switch matches[1] {
+			case "Log Name":
+				event.Channel = matches[2]
+			case "Event ID":
+				event.ID, _ = strconv.Atoi(matches[2])
+			case "Date":
+				if parsed, err := time.Parse(time.RFC3339Nano, matches[2]); err == nil {
+					event.Timestamp = parsed
+				} else if parsed, err := time.ParseInLocation("2006-01-02T15:04:05.000", matches[2], time.Local); err == nil {
+					event.Timestamp = parsed
+				}
+			case "Description":
+				inDescription = true
+				message.WriteString(matches[2])
+			}
+			continue
+		}
+
+		message.WriteString(line)
+		message.WriteString("\n")
+		matches := windowsEventFieldRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		label, value := matches[1], matches[2]
+		if value == "" && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, " ") {
+			section = label
+			continue
+		}
+		event.Fields[label] = value
+		if section != "" {
+			event.Fields[section+"/"+label] = value
+		}
+	}
+
+	if event.ID == 0 {
+		return nil, fmt.Errorf("no event ID found")
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Message = strings.TrimSpace(message.String())
+	return event, nil
+}
+
+// Field returns the first of the given fields that holds a value ("-" counts as empty)
+func (e *WindowsEvent) Field(names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(e.Fields[name]); value != "" && value != "-" {
+			return value
+		}
+	}
+	return ""
+}
+
+// windowsEventMatch is what an event rule extracts from a failure event
+type windowsEventMatch struct {
+	address     string // client address; may be a host name or empty for account-only events
+	username    string
+	reason      string
+	severity    models.Severity
+	failureCode string
+	logonType   int
+}
+
+// windowsEventRule maps one event ID on one channel to an attack attempt
+// extract returns false for events that are not failures (e.g. success codes)
+type windowsEventRule struct {
+	id      int
+	channel string
+	service string
+	extract func(event *WindowsEvent) (windowsEventMatch, bool)
+
+	// accountOnly rules read the client from a workstation name, which is often not an address;
+	// such events are still reported (without an IP) so account activity is not lost
+	accountOnly bool
+}
+
+// ntStatusReasons describes the NTSTATUS codes logged by 4625 (Sub Status) and 4776 (Error Code)
+var ntStatusReasons = map[string]string{
+	"0xc0000064": "unknown user",
+	"0xc000006a": "bad password",
+	"0xc000006d": "bad username or password",
+	"0xc000006f": "outside logon hours",
+	"0xc0000070": "workstation restriction",
+	"0xc0000071": "password expired",
+	"0xc0000072": "account disabled",
+	"0xc0000193": "account expired",
+	"0xc0000224": "password must change",
+	"0xc0000234": "account locked out",
+}
+
+// kerberosFailureReasons describes the 4771 failure codes
+// 0x25 (clock skew) and 0x20/0x1F (ticket problems) are client faults, not guessing, and are ignored
+var kerberosFailureReasons = map[string]string{
+	"0x6":  "unknown user",
+	"0x12": "account disabled, expired or locked out",
+	"0x17": "password expired",
+	"0x18": "bad password",
+}
+
+// windowsAccountSeverity rates a failed logon by the account it targets
+// Admin accounts are high, service and dictionary accounts medium
+func windowsAccountSeverity(username string) models.Severity {
+	lower := strings.ToLower(username)
+	// "CONTOSO\bob" and "bob@contoso.com" are rated by the account part
+	if index := strings.LastIndex(lower, `\`); index >= 0 {
+		lower = lower[index+1:]
+	}
+	if index := strings.Index(lower, "@"); index >= 0 {
+		lower = lower[:index]
+	}
+	switch lower {
+	case "administrator", "admin", "root", "sa":
+		return models.SeverityHigh
+	case "user", "test", "guest", "demo":
+		return models.SeverityMedium
+	}
+	if strings.HasSuffix(lower, "service") || strings.HasSuffix(lower, "svc") {
+		return models.SeverityMedium
+	}
+	return models.SeverityLow
+}
+
+// windowsAccountName joins "DOMAIN" and "user" and drops machine accounts
+func windowsAccountName(domain, name string) string {
+	if name == "" || strings.HasSuffix(name, "$") {
+		return ""
+	}
+	if domain != "" && domain != "-" && !strings.Contains(name, `\`) && !strings.Contains(name, "@") {
+		return domain + `\` + name
+	}
+	return name
+}
+
+var (
+	rdGatewayClientRegex = regexp.MustCompile(`on client computer "([^"]*)"`)
+	rdGatewayUserRegex   = regexp.MustCompile(`The user "([^"]*)"`)
+	rdGatewayErrorRegex  = regexp.MustCompile(`The following error occurred: "(\d+)"`)
+
+	openSSHFailureRegex = regexp.MustCompile(`Failed (\S+) for (invalid user )?(\S*) from (\S+) port`)
+	openSSHInvalidRegex = regexp.MustCompile(`Invalid user (\S*) from (\S+)`)
+	openSSHMaxAuthRegex = regexp.MustCompile(`maximum authentication attempts exceeded for (invalid user )?(\S*) from (\S+)`)
+)
+
+// rdGatewayEvent extracts RD Gateway events that carry an error code
+// 302 (connected) and 303 (disconnected) are also logged for successful sessions and only count with an error
+func rdGatewayEvent(event *WindowsEvent) (windowsEventMatch, bool) {
+	code := ""
+	if matches := rdGatewayErrorRegex.FindStringSubmatch(event.Message); matches != nil {
+		code = matches[1]
+	}
+	if event.ID != 201 && (code == "" || code == "0") {
+		return windowsEventMatch{}, false
+	}
+	match := windowsEventMatch{reason: "RD Gateway authorization failed", severity: models.SeverityMedium}
+	if client := rdGatewayClientRegex.FindStringSubmatch(event.Message); client != nil {
+		match.address = client[1]
+	}
+	if user := rdGatewayUserRegex.FindStringSubmatch(event.Message); user != nil {
+		match.username = user[1]
+		if severity := windowsAccountSeverity(user[1]); severity > match.severity {
+			match.severity = severity
+		}
+	}
+	if code != "" {
+		match.reason = fmt.Sprintf("RD Gateway connection failed (error %s)", code)
+	}
+	return match, true
+}
+
+// windowsEventRules are the built-in event rules, one per event ID and channel
+var windowsEventRules = []windowsEventRule{
+	{
+		id: 4625, channel: ChannelSecurity, service: "RDP",
+		extract: func(event *WindowsEvent) (windowsEventMatch, bool) {
+			username := windowsAccountName(
+				event.Field("Account For Which Logon Failed/Account Domain"),
+				event.Field("Account For Which Logon Failed/Account Name"))
+			reason := "failed logon"
+			failureCode := ""
+			// Sub Status is more specific than Status (0xC000006D) unless it is 0x0
+			for _, status := range []string{event.Field("Sub Status"), event.Field("Failure Information/Status")} {
+				if description, known := ntStatusReasons[strings.ToLower(status)]; known {
+					reason = "failed logon (" + description + ")"
+					failureCode = status
+					break
+				}
+			}
+			logonType, _ := strconv.Atoi(event.Field("Logon Type"))
+			return windowsEventMatch{
+				address:     event.Field("Network Information/Source Network Address"),
+				username:    username,
+				reason:      reason,
+				severity:    windowsAccountSeverity(username),
+				failureCode: failureCode,
+				logonType:   logonType,
+			}, true
+		},
+	},
+	{
+		id: 4771, channel: ChannelSecurity, service: "Kerberos",
+		extract: func(event *WindowsEvent) (windowsEventMatch, bool) {
+			failureCode := event.Field("Failure Code")
+			description, known := kerberosFailureReasons[strings.ToLower(failureCode)]
+			if !known {
+				return windowsEventMatch{}, false
+			}
+			username := windowsAccountName("", event.Field("Account Information/Account Name"))
+			severity := windowsAccountSeverity(username)
+			if severity < models.SeverityMedium {
+				severity = models.SeverityMedium
+			}
+			return windowsEventMatch{
+				address:     event.Field("Network Information/Client Address"),
+				username:    username,
+				reason:      "Kerberos pre-authentication failed (" + description + ")",
+				severity:    severity,
+				failureCode: failureCode,
+			}, true
+		},
+	},
+	{
+		id: 4776, channel: ChannelSecurity, service: "NTLM", accountOnly: true,
+		extract: func(event *WindowsEvent) (windowsEventMatch, bool) {
+			code := strings.ToLower(event.Field("Error Code"))
+			if code == "" || code == "0x0" {
+				return windowsEventMatch{}, false
+			}
+			reason := "NTLM credential validation failed"
+			if description, known := ntStatusReasons[code]; known {
+				reason += " (" + description + ")"
+			}
+			username := windowsAccountName("", event.Field("Logon Account"))
+			severity := windowsAccountSeverity(username)
+			if code == "0xc0000234" {
+				severity = models.SeverityHigh
+			}
+			// Source Workstation is the client-supplied NetBIOS name, usable only when it is an address
+			return windowsEventMatch{
+				address:     strings.TrimPrefix(event.Field("Source Workstation"), `\\`),
+				username:    username,
+				reason:      reason,
+				severity:    severity,
+				failureCode: event.Field("Error Code"),
+			}, true
+		},
+	},
+	{
+		id: 4740, channel: ChannelSecurity, service: "Account Lockout", accountOnly: true,
+		extract: func(event *WindowsEvent) (windowsEventMatch, bool) {
+			return windowsEventMatch{
+				address: strings.TrimPrefix(event.Field("Caller Computer Name"), `\\`),
+				username: windowsAccountName(
+					event.Field("Account That Was Locked Out/Account Domain"),
+					event.Field("Account That Was Locked Out/Account Name")),
+				reason:      "account locked out",
+				severity:    models.SeverityHigh,
+				failureCode: "0xC0000234",
+			}, true
+		},
+	},
+	{id: 201, channel: ChannelRDGateway, service: "RD Gateway", extract: rdGatewayEvent},
+	{id: 302, channel: ChannelRDGateway, service: "RD Gateway", extract: rdGatewayEvent},
+	{id: 303, channel: ChannelRDGateway, service: "RD Gateway", extract: rdGatewayEvent},
+	{
+		// OpenSSH for Windows writes every sshd message as event 4
+		id: 4, channel: ChannelOpenSSH, service: "SSH",
+		extract: func(event *WindowsEvent) (windowsEventMatch, bool) {
+			if matches := openSSHMaxAuthRegex.FindStringSubmatch(event.Message); matches != nil {
+				return windowsEventMatch{address: matches[3], username: matches[2], reason: "maximum authentication attempts exceeded", severity: models.SeverityHigh}, true
+			}
+			if matches := openSSHFailureRegex.FindStringSubmatch(event.Message); matches != nil {
+				reason := "failed " + matches[1]
+				if matches[2] != "" {
+					reason += " (invalid user)"
+				}
+				return windowsEventMatch{address: matches[4], username: matches[3], reason: reason, severity: ftpSeverity(matches[3])}, true
+			}
+			if matches := openSSHInvalidRegex.FindStringSubmatch(event.Message); matches != nil {
+				return windowsEventMatch{address: matches[2], username: matches[1], reason: "invalid user", severity: models.SeverityMedium}, true
+			}
+			return windowsEventMatch{}, false
+		},
+	},
+}
+
+// findWindowsEventRule returns the rule for an event ID, restricted to a channel when one is given
+func findWindowsEventRule(channel string, id int) (windowsEventRule, bool) {
+	for _, rule := range windowsEventRules {
+		if rule.id == id && (channel == "" || strings.EqualFold(rule.channel, channel)) {
+			return rule, true
+		}
+	}
+	return windowsEventRule{}, false
+}
+
+// WindowsEventIDs returns the event IDs with built-in rules for a channel
+func WindowsEventIDs(channel string) []int {
+	var ids []int
+	for _, rule := range windowsEventRules {
+		if strings.EqualFold(rule.channel, channel) {
+			ids = append(ids, rule.id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// ServiceEventIDs returns the event IDs a service monitors: event_ids, or a numeric log_pattern ("4625")
+func ServiceEventIDs(service models.ServiceConfig) ([]int, error) {
+	ids := service.EventIDs
+	if len(ids) == 0 {
+		if id, err := strconv.Atoi(service.LogPattern); err == nil {
+			ids = []int{id}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("service %q has no event_ids", service.Name)
+	}
+
+	channel := service.LogPath
+	if channel == "" {
+		channel = ChannelSecurity
+	}
+	for _, id := range ids {
+		if _, exists := findWindowsEventRule(channel, id); !exists {
+			return nil, fmt.Errorf("no rule for event %d in channel %q (supported: %v)", id, channel, WindowsEventIDs(channel))
+		}
+	}
+	return ids, nil
+}
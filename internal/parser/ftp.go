@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// newFTPAttempt builds an FTP login failure attempt
+func newFTPAttempt(service string, timestamp time.Time, rawIP, username, reason string, severity models.Severity) (*models.AttackAttempt, error) {
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("%s %s from %s", service, reason, ip)
+	if username != "" {
+		message = fmt.Sprintf("%s %s for user '%s' from %s", service, reason, username, ip)
+	}
+	return &models.AttackAttempt{
+		Timestamp: timestamp,
+		IP:        ip,
+		Service:   service,
+		Username:  username,
+		Message:   message,
+		Severity:  severity,
+	}, nil
+}
+
+// ftpSeverity raises the severity for root/administrator logins
+func ftpSeverity(username string) models.Severity {
+	switch strings.ToLower(username) {
+	case "root", "admin", "administrator":
+		return models.SeverityHigh
+	}
+	return models.SeverityMedium
+}
+
+// VsftpdParser parses vsftpd.log (or syslog) login failures
+//
+//	Mon Jan 15 10:23:45 2024 [pid 1234] [bob] FAIL LOGIN: Client "203.0.113.5"
+//	Jan 15 10:23:45 ftp vsftpd[1234]: [bob] FAIL LOGIN: Client "::ffff:203.0.113.5"
+type VsftpdParser struct {
+	failRegex *regexp.Regexp
+}
+
+// NewVsftpdParser creates a vsftpd parser
+func NewVsftpdParser() *VsftpdParser {
+	return &VsftpdParser{
+		failRegex: regexp.MustCompile(`\[([^\]]*)\] FAIL LOGIN: Client "([^"]+)"`),
+	}
+}
+
+// ParseLine extracts FAIL LOGIN lines; returns (nil, nil) for other lines
+func (p *VsftpdParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	matches := p.failRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+
+	timestamp := parseSyslogTimestamp(line)
+	if len(line) >= 24 {
+		if parsed, err := time.ParseInLocation("Mon Jan _2 15:04:05 2006", line[:24], time.Local); err == nil {
+			timestamp = parsed
+		}
+	}
+
+	return newFTPAttempt("vsftpd", timestamp, matches[2], matches[1], "login failed", ftpSeverity(matches[1]))
+}
+
+// ServiceName returns the service name this parser handles
+func (p *VsftpdParser) ServiceName() string {
+	return "vsftpd"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *VsftpdParser) Patterns() []string {
+	return []string{"FAIL LOGIN"}
+}
+
+// ProFTPDParser parses ProFTPD syslog/SystemLog lines
+//
+//	proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - USER bob: no such user found from 203.0.113.5 [203.0.113.5] to 10.0.0.1:21
+//	proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - USER bob (Login failed): Incorrect password
+//	proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - Maximum login attempts (3) exceeded, connection refused
+//	proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - SECURITY VIOLATION: Root login attempted
+type ProFTPDParser struct {
+	clientRegex *regexp.Regexp
+	rules       []ftpRule
+}
+
+// ftpRule maps a log message to a failure reason; the optional first submatch is the username
+type ftpRule struct {
+	regex      *regexp.Regexp
+	reason     string
+	severity   models.Severity
+	byUsername bool // rate by the username instead of severity
+}
+
+// NewProFTPDParser creates a ProFTPD parser
+func NewProFTPDParser() *ProFTPDParser {
+	return &ProFTPDParser{
+		clientRegex: regexp.MustCompile(`\(\S*\[([0-9A-Fa-f:\.]+)\]\)`),
+		rules: []ftpRule{
+			{regexp.MustCompile(`USER (\S+): no such user found`), "login failed (unknown user)", models.SeverityMedium, true},
+			{regexp.MustCompile(`USER (\S+) \(Login failed\)`), "login failed", models.SeverityMedium, true},
+			{regexp.MustCompile(`Maximum login attempts \(\d+\) exceeded`), "exceeded maximum login attempts", models.SeverityHigh, false},
+			{regexp.MustCompile(`SECURITY VIOLATION: (\S+) login attempted`), "login attempted", models.SeverityHigh, false},
+		},
+	}
+}
+
+// ParseLine extracts ProFTPD login failures; returns (nil, nil) for other lines
+func (p *ProFTPDParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	client := p.clientRegex.FindStringSubmatch(line)
+	if client == nil {
+		return nil, nil
+	}
+
+	for _, rule := range p.rules {
+		matches := rule.regex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		username := ""
+		if len(matches) > 1 {
+			username = matches[1]
+		}
+		severity := rule.severity
+		if rule.byUsername {
+			severity = ftpSeverity(username)
+		}
+		return newFTPAttempt("ProFTPD", parseSyslogTimestamp(line), client[1], username, rule.reason, severity)
+	}
+	return nil, nil
+}
+
+// ServiceName returns the service name this parser handles
+func (p *ProFTPDParser) ServiceName() string {
+	return "ProFTPD"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *ProFTPDParser) Patterns() []string {
+	return []string{"no such user found", "Login failed", "Maximum login attempts", "SECURITY VIOLATION"}
+}
+
+// PureFTPdParser parses Pure-FTPd syslog lines
+//
+//	pure-ftpd: (?@203.0.113.5) [WARNING] Authentication failed for user [bob]
+//	pure-ftpd[1234]: (?@2001:db8::5) [WARNING] Authentication failed for user [root]
+type PureFTPdParser struct {
+	failRegex *regexp.Regexp
+}
+
+// NewPureFTPdParser creates a Pure-FTPd parser
+func NewPureFTPdParser() *PureFTPdParser {
+	return &PureFTPdParser{
+		failRegex: regexp.MustCompile(`pure-ftpd(?:\[\d+\])?: \([^@]*@([^)]+)\) \[WARNING\] Authentication failed for user \[([^\]]*)\]`),
+	}
+}
+
+// ParseLine extracts authentication failures; returns (nil, nil) for other lines
+func (p *PureFTPdParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	matches := p.failRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+	return newFTPAttempt("Pure-FTPd", parseSyslogTimestamp(line), matches[1], matches[2], "login failed", ftpSeverity(matches[2]))
+}
+
+// ServiceName returns the service name this parser handles
+func (p *PureFTPdParser) ServiceName() string {
+	return "Pure-FTPd"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *PureFTPdParser) Patterns() []string {
+	return []string{"Authentication failed for user"}
+}
+
+// fileZillaMaxSessions bounds the USER-per-session memory
+const fileZillaMaxSessions = 4096
+
+// FileZillaParser parses FileZilla Server text logs (0.9.x and 1.x)
+//
+//	(000012)15/01/2024 10:23:45 - (not logged in) (203.0.113.5)> USER bob
+//	(000012)15/01/2024 10:23:45 - (not logged in) (203.0.113.5)> 530 Login or password incorrect!
+//	2024-01-15T10:23:45.123Z == [FTP Session 12 203.0.113.5 bob] Response: 530 Login incorrect.
+//
+// 0.9.x logs the username only on the USER command, so it is remembered per session
+type FileZillaParser struct {
+	mu       sync.Mutex
+	sessions map[string]string // session id -> last USER
+
+	legacyRegex  *regexp.Regexp
+	sessionRegex *regexp.Regexp
+}
+
+// NewFileZillaParser creates a FileZilla Server parser
+func NewFileZillaParser() *FileZillaParser {
+	return &FileZillaParser{
+		sessions:     make(map[string]string),
+		legacyRegex:  regexp.MustCompile(`^\((\d+)\)(\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}) - \(not logged in\) \(([^)]+)\)> (.*)$`),
+		sessionRegex: regexp.MustCompile(`^(\S+) .*\[FTP Session \d+ ([0-9A-Fa-f:\.]+)(?: ([^\]]+))?\] Response: 530 `),
+	}
+}
+
+// ParseLine extracts 530 login failures; returns (nil, nil) for other lines
+func (p *FileZillaParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	if matches := p.sessionRegex.FindStringSubmatch(line); matches != nil {
+		timestamp := time.Now()
+		if parsed, err := time.Parse(time.RFC3339Nano, matches[1]); err == nil {
+			timestamp = parsed
+		}
+		return newFTPAttempt("FileZilla", timestamp, matches[2], matches[3], "login failed", ftpSeverity(matches[3]))
+	}
+
+	matches := p.legacyRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, nil
+	}
+	session, command := matches[1], strings.TrimSpace(matches[4])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if user, found := strings.CutPrefix(command, "USER "); found {
+		if len(p.sessions) >= fileZillaMaxSessions {
+			p.sessions = make(map[string]string)
+		}
+		p.sessions[session] = strings.TrimSpace(user)
+		return nil, nil
+	}
+	if !strings.HasPrefix(command, "530 ") {
+		return nil, nil
+	}
+
+	username := p.sessions[session]
+	timestamp := time.Now()
+	if parsed, err := time.ParseInLocation("02/01/2006 15:04:05", matches[2], time.Local); err == nil {
+		timestamp = parsed
+	}
+	return newFTPAttempt("FileZilla", timestamp, matches[3], username, "login failed", ftpSeverity(username))
+}
+
+// ServiceName returns the service name this parser handles
+func (p *FileZillaParser) ServiceName() string {
+	return "FileZilla"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *FileZillaParser) Patterns() []string {
+	return []string{"530 Login or password incorrect", "Response: 530"}
+}
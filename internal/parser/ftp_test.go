@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestVsftpdParser(t *testing.T) {
+	parser := NewVsftpdParser()
+	runParserCases(t, parser, []parserCase{
+		{
+			name:     "vsftpd.log",
+			line:     `Mon Jan 15 10:23:45 2024 [pid 1234] [bob] FAIL LOGIN: Client "203.0.113.5"`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "vsftpd login failed for user 'bob' from 203.0.113.5",
+		},
+		{
+			name:     "syslog with mapped address",
+			line:     `Jan 15 10:23:46 ftp vsftpd[1234]: [root] FAIL LOGIN: Client "::ffff:198.51.100.7"`,
+			ip:       "198.51.100.7",
+			username: "root",
+			severity: models.SeverityHigh,
+			reason:   "login failed for user 'root'",
+		},
+		{
+			name:     "IPv6 client",
+			line:     `Mon Jan 15 10:23:47 2024 [pid 1235] [admin] FAIL LOGIN: Client "2001:db8::5"`,
+			ip:       "2001:db8::5",
+			username: "admin",
+			severity: models.SeverityHigh,
+			reason:   "login failed",
+		},
+		{
+			name: "successful login",
+			line: `Mon Jan 15 10:23:48 2024 [pid 1236] [bob] OK LOGIN: Client "203.0.113.5"`,
+		},
+		{
+			name:    "local client",
+			line:    `Mon Jan 15 10:23:49 2024 [pid 1237] [bob] FAIL LOGIN: Client "127.0.0.1"`,
+			wantErr: true,
+		},
+	})
+
+	attempt, _ := parser.ParseLine(`Mon Jan 15 10:23:45 2024 [pid 1234] [bob] FAIL LOGIN: Client "203.0.113.5"`)
+	if want := time.Date(2024, 1, 15, 10, 23, 45, 0, time.Local); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("vsftpd.log timestamp = %v, want %v", attempt, want)
+	}
+}
+
+func TestProFTPDParser(t *testing.T) {
+	runParserCases(t, NewProFTPDParser(), []parserCase{
+		{
+			name:     "unknown user",
+			line:     `Jan 15 10:23:45 ftp proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - USER bob: no such user found from 203.0.113.5 [203.0.113.5] to 10.0.0.1:21`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "login failed (unknown user) for user 'bob'",
+		},
+		{
+			name:     "wrong password for root",
+			line:     `Jan 15 10:23:46 ftp proftpd[1234]: ftp.example.com (scanner.example.net[198.51.100.7]) - USER root (Login failed): Incorrect password`,
+			ip:       "198.51.100.7",
+			username: "root",
+			severity: models.SeverityHigh,
+			reason:   "login failed for user 'root'",
+		},
+		{
+			name:     "maximum attempts",
+			line:     `Jan 15 10:23:47 ftp proftpd[1234]: ftp.example.com (2001:db8::9[2001:db8::9]) - Maximum login attempts (3) exceeded, connection refused`,
+			ip:       "2001:db8::9",
+			severity: models.SeverityHigh,
+			reason:   "exceeded maximum login attempts",
+		},
+		{
+			name:     "root login",
+			line:     `Jan 15 10:23:48 ftp proftpd[1234]: ftp.example.com (203.0.113.9[203.0.113.9]) - SECURITY VIOLATION: Root login attempted`,
+			ip:       "203.0.113.9",
+			username: "Root",
+			severity: models.SeverityHigh,
+			reason:   "login attempted",
+		},
+		{
+			name: "session opened",
+			line: `Jan 15 10:23:49 ftp proftpd[1234]: ftp.example.com (203.0.113.5[203.0.113.5]) - FTP session opened.`,
+		},
+		{
+			name: "no client",
+			line: `Jan 15 10:20:00 ftp proftpd[1200]: ProFTPD 1.3.8 (stable) (built Mon Jan 1 2024) standalone mode STARTUP`,
+		},
+	})
+}
+
+func TestPureFTPdParser(t *testing.T) {
+	runParserCases(t, NewPureFTPdParser(), []parserCase{
+		{
+			name:     "authentication failed",
+			line:     `Jan 15 10:23:45 ftp pure-ftpd: (?@203.0.113.5) [WARNING] Authentication failed for user [bob]`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "Pure-FTPd login failed for user 'bob'",
+		},
+		{
+			name:     "IPv6 with pid",
+			line:     `Jan 15 10:23:46 ftp pure-ftpd[1234]: (?@2001:db8::5) [WARNING] Authentication failed for user [root]`,
+			ip:       "2001:db8::5",
+			username: "root",
+			severity: models.SeverityHigh,
+			reason:   "login failed",
+		},
+		{
+			name:     "empty user",
+			line:     `Jan 15 10:23:47 ftp pure-ftpd: (?@198.51.100.7) [WARNING] Authentication failed for user []`,
+			ip:       "198.51.100.7",
+			severity: models.SeverityMedium,
+			reason:   "Pure-FTPd login failed from 198.51.100.7",
+		},
+		{
+			name: "logout",
+			line: `Jan 15 10:23:48 ftp pure-ftpd: (bob@203.0.113.5) [INFO] Logout.`,
+		},
+	})
+}
+
+func TestFileZillaParser(t *testing.T) {
+	// 0.9.x logs the user name on USER; the 530 reply in the same session is attributed to it
+	runParserCases(t, NewFileZillaParser(), []parserCase{
+		{
+			name: "USER command",
+			line: `(000012)15/01/2024 10:23:45 - (not logged in) (203.0.113.5)> USER administrator`,
+		},
+		{
+			name: "other session USER",
+			line: `(000013)15/01/2024 10:23:45 - (not logged in) (198.51.100.7)> USER bob`,
+		},
+		{
+			name:     "530 after USER",
+			line:     `(000012)15/01/2024 10:23:46 - (not logged in) (203.0.113.5)> 530 Login or password incorrect!`,
+			ip:       "203.0.113.5",
+			username: "administrator",
+			severity: models.SeverityHigh,
+			reason:   "FileZilla login failed for user 'administrator'",
+		},
+		{
+			name:     "530 in the other session",
+			line:     `(000013)15/01/2024 10:23:47 - (not logged in) (198.51.100.7)> 530 Login or password incorrect!`,
+			ip:       "198.51.100.7",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "login failed for user 'bob'",
+		},
+		{
+			name:     "530 without USER",
+			line:     `(000014)15/01/2024 10:23:48 - (not logged in) (203.0.113.9)> 530 Please log in with USER and PASS first.`,
+			ip:       "203.0.113.9",
+			severity: models.SeverityMedium,
+			reason:   "FileZilla login failed from 203.0.113.9",
+		},
+		{
+			name:     "1.x response",
+			line:     `2024-01-15T10:23:49.123Z == [FTP Session 12 203.0.113.10 root] Response: 530 Login incorrect.`,
+			ip:       "203.0.113.10",
+			username: "root",
+			severity: models.SeverityHigh,
+			reason:   "login failed for user 'root'",
+		},
+		{
+			name:     "1.x response before USER",
+			line:     `2024-01-15T10:23:50.000Z == [FTP Session 13 2001:db8::7] Response: 530 Login incorrect.`,
+			ip:       "2001:db8::7",
+			severity: models.SeverityMedium,
+			reason:   "login failed",
+		},
+		{
+			name: "1.x other response",
+			line: `2024-01-15T10:23:51.000Z == [FTP Session 12 203.0.113.10 root] Response: 331 Please, specify the password.`,
+		},
+	})
+
+	parser := NewFileZillaParser()
+	attempt, _ := parser.ParseLine(`2024-01-15T10:23:49.123Z == [FTP Session 12 203.0.113.10 root] Response: 530 Login incorrect.`)
+	if want := time.Date(2024, 1, 15, 10, 23, 49, 123000000, time.UTC); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("1.x timestamp = %v, want %v", attempt, want)
+	}
+	attempt, _ = parser.ParseLine(`(000020)15/01/2024 10:23:46 - (not logged in) (203.0.113.5)> 530 Login or password incorrect!`)
+	if want := time.Date(2024, 1, 15, 10, 23, 46, 0, time.Local); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("0.9.x timestamp = %v, want %v", attempt, want)
+	}
+}
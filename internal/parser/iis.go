@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// iisDefaultFields is the field set IIS 8.5+ writes by default
+// Used until a "#Fields:" directive is seen (e.g. when tailing starts mid-file)
+const iisDefaultFields = "date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken"
+
+// Win32 status codes logged with 401.1 that mean the credentials were rejected
+// 2148074254 (SEC_E_NO_CREDENTIALS) is the normal first NTLM/Negotiate leg and is ignored
+var iisLogonFailureWin32 = map[int]string{
+	1326: "bad username or password",
+	1327: "account restriction",
+	1330: "password expired",
+	1331: "account disabled",
+	1385: "logon type not granted",
+	1909: "account locked out",
+}
+
+// SourceParser is implemented by parsers that keep per-file state (such as the IIS #Fields header)
+// Callers that know the originating file should prefer ParseLineFrom over ParseLine
+type SourceParser interface {
+	ParseLineFrom(source, line string) (*models.AttackAttempt, error)
+}
+
+// ClientAddressParser is implemented by parsers whose lines do not lead with the client address
+// ClientAddress returns the client address of a line without parsing it fully, or "" if it has none
+type ClientAddressParser interface {
+	ClientAddress(source, line string) string
+}
+
+// IISParser parses IIS W3C extended log files
+// The "#Fields:" directive is read dynamically and tracked per file, so
+// custom field sets and header changes after an IIS restart are handled
+//
+//	#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken
+//	2024-01-15 10:23:45 10.0.0.1 GET /Microsoft-Server-ActiveSync/default.eas User=bob 443 CONTOSO\bob 203.0.113.5 Android-Mail - 401 1 1326 15
+type IISParser struct {
+	mu       sync.Mutex
+	defaults map[string]int
+	fields   map[string]map[string]int // source -> field name -> column
+}
+
+// NewIISParser creates an IIS W3C parser
+func NewIISParser() *IISParser {
+	return &IISParser{
+		defaults: parseIISFields(iisDefaultFields),
+		fields:   make(map[string]map[string]int),
+	}
+}
+
+// parseIISFields maps field names (lower case) to their column
+func parseIISFields(header string) map[string]int {
+	columns := make(map[string]int)
+	for index, name := range strings.Fields(header) {
+		columns[strings.ToLower(name)] = index
+	}
+	return columns
+}
+
+// columns returns the field positions of the #Fields header last seen in source
+func (p *IISParser) columns(source string) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if columns, exists := p.fields[source]; exists {
+		return columns
+	}
+	return p.defaults
+}
+
+// ClientAddress returns the c-ip field of a line, located by the current #Fields header of source
+// Lines lead with s-ip, so the first address in them is the server's
+func (p *IISParser) ClientAddress(source, line string) string {
+	index, exists := p.columns(source)["c-ip"]
+	if !exists {
+		return ""
+	}
+	values := strings.Fields(line)
+	if index >= len(values) || values[index] == "-" {
+		return ""
+	}
+	return values[index]
+}
+
+// ParseLine parses a line using the header of the unnamed default source
+func (p *IISParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	return p.ParseLineFrom("", line)
+}
+
+// ParseLineFrom parses a line using the #Fields header last seen in source
+// Returns (nil, nil) for directives and requests that are not abuse
+func (p *IISParser) ParseLineFrom(source, line string) (*models.AttackAttempt, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(line, "#") {
+		if header, found := strings.CutPrefix(line, "#Fields:"); found {
+			p.mu.Lock()
+			p.fields[source] = parseIISFields(header)
+			p.mu.Unlock()
+		}
+		return nil, nil
+	}
+
+	columns := p.columns(source)
+	values := strings.Fields(line)
+	get := func(name string) string {
+		index, exists := columns[name]
+		if !exists || index >= len(values) || values[index] == "-" {
+			return ""
+		}
+		return values[index]
+	}
+
+	rawIP := get("c-ip")
+	status, err := strconv.Atoi(get("sc-status"))
+	if rawIP == "" || err != nil {
+		return nil, fmt.Errorf("line does not match the W3C #Fields header")
+	}
+	substatus, _ := strconv.Atoi(get("sc-substatus"))
+	win32, _ := strconv.Atoi(get("sc-win32-status"))
+
+	method := get("cs-method")
+	stem := get("cs-uri-stem")
+	query := get("cs-uri-query")
+	username := get("cs-username")
+	lowerStem := strings.ToLower(stem)
+
+	// 401.2 is the unauthenticated first request of every Windows/Basic auth exchange and
+	// other win32 codes on a 401 are Negotiate/NTLM handshake legs, not failures
+	failure, failed := iisLogonFailureWin32[win32]
+	handshake := status == 401 && (substatus == 2 || (win32 != 0 && !failed))
+
+	var reason string
+	severity := models.SeverityMedium
+
+	switch {
+	case IsScannerProbe(stem):
+		reason = fmt.Sprintf("Scanner probe %s %s (%d)", method, stem, status)
+		severity = models.SeverityHigh
+
+	case lowerStem == "/owa/auth/logon.aspx" && queryValue(query, "reason") == "2":
+		// OWA redirects failed form logins back to the logon page with reason=2
+		reason = "OWA login failed"
+
+	case strings.Contains(lowerStem, "/rdweb/") && strings.HasSuffix(lowerStem, "/login.aspx") && method == "POST" && status == 200:
+		// RD Web Access re-renders the login form (200) on failure and redirects (302) on success
+		reason = "RD Web Access login failed"
+		if username == "" {
+			username = queryValue(query, "DomainUserName")
+		}
+
+	case handshake:
+		return nil, nil
+
+	case strings.HasPrefix(lowerStem, "/microsoft-server-activesync") && status == 401:
+		if username == "" {
+			username = queryValue(query, "User")
+		}
+		reason = "ActiveSync login failed"
+		if failed {
+			reason = fmt.Sprintf("ActiveSync login failed (%s)", failure)
+		}
+
+	case status == 401 && substatus == 1:
+		reason = "HTTP 401.1 logon failed"
+		if failed {
+			reason = fmt.Sprintf("HTTP 401.1 logon failed (%s)", failure)
+		}
+		if win32 == 1909 {
+			severity = models.SeverityHigh
+		}
+
+	case status == 401:
+		reason = fmt.Sprintf("HTTP 401.%d for %s", substatus, stem)
+		severity = models.SeverityLow
+
+	case status == 403 && (substatus == 502 || substatus == 503):
+		reason = fmt.Sprintf("Dynamic IP restriction 403.%d", substatus)
+
+	case status == 403:
+		reason = fmt.Sprintf("HTTP 403.%d for %s", substatus, stem)
+		severity = models.SeverityLow
+
+	default:
+		return nil, nil
+	}
+
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now()
+	if date, clock := get("date"), get("time"); date != "" && clock != "" {
+		// W3C logs are written in UTC
+		if parsed, err := time.Parse("2006-01-02 15:04:05", date+" "+clock); err == nil {
+			timestamp = parsed
+		}
+	}
+
+	message := fmt.Sprintf("%s from %s", reason, ip)
+	if username != "" {
+		message = fmt.Sprintf("%s for user '%s' from %s", reason, username, ip)
+	}
+
+	return &models.AttackAttempt{
+		Timestamp: timestamp,
+		IP:        ip,
+		Service:   "IIS",
+		Username:  username,
+		Message:   message,
+		Severity:  severity,
+		Source:    source,
+	}, nil
+}
+
+// queryValue returns a query parameter from an IIS cs-uri-query value
+func queryValue(query, key string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	for name, value := range values {
+		if strings.EqualFold(name, key) && len(value) > 0 {
+			return value[0]
+		}
+	}
+	return ""
+}
+
+// ServiceName returns the service name this parser handles
+func (p *IISParser) ServiceName() string {
+	return "IIS"
+}
+
+// Patterns returns the W3C fields this parser relies on
+func (p *IISParser) Patterns() []string {
+	return []string{"#Fields:", "c-ip", "sc-status", "sc-substatus", "sc-win32-status"}
+}
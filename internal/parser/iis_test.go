@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// iisExpected is an attempt expected from a W3C sample file
+type iisExpected struct {
+	ip       string
+	username string
+	severity models.Severity
+	reason   string
+	time     string
+}
+
+func readSample(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "iis", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// TestIISParserPerSourceHeaders reads two sites' logs interleaved through one parser
+// The sites use different, reordered #Fields headers and the second changes its header mid-file
+func TestIISParserPerSourceHeaders(t *testing.T) {
+	sources := map[string][]string{
+		"w3svc1.log": readSample(t, "w3svc1.log"),
+		"w3svc2.log": readSample(t, "w3svc2.log"),
+	}
+	want := map[string][]iisExpected{
+		"w3svc1.log": {
+			{ip: "203.0.113.5", username: `CONTOSO\bob`, severity: models.SeverityMedium, reason: "ActiveSync login failed (bad username or password)", time: "2024-01-15 10:23:45"},
+			{ip: "198.51.100.7", severity: models.SeverityMedium, reason: "OWA login failed", time: "2024-01-15 10:23:46"},
+		},
+		"w3svc2.log": {
+			{ip: "2001:db8::66", severity: models.SeverityMedium, reason: "RD Web Access login failed", time: "2024-01-15 10:30:00"},
+			{ip: "203.0.113.9", severity: models.SeverityHigh, reason: "Scanner probe GET /wp-login.php (404)", time: "2024-01-15 10:30:01"},
+			{ip: "203.0.113.10", severity: models.SeverityMedium, reason: "Dynamic IP restriction 403.502", time: "2024-01-15 10:31:00"},
+			{ip: "203.0.113.11", username: `CONTOSO\carol`, severity: models.SeverityHigh, reason: "HTTP 401.1 logon failed (account locked out)", time: "2024-01-15 10:31:01"},
+		},
+	}
+
+	iis := NewIISParser()
+	got := make(map[string][]iisExpected)
+	for i := 0; ; i++ {
+		remaining := false
+		for _, source := range []string{"w3svc1.log", "w3svc2.log"} {
+			lines := sources[source]
+			if i >= len(lines) {
+				continue
+			}
+			remaining = true
+			attempt, err := iis.ParseLineFrom(source, lines[i])
+			if err != nil {
+				t.Fatalf("%s line %d: %v", source, i+1, err)
+			}
+			if attempt == nil {
+				continue
+			}
+			if attempt.Source != source || attempt.Service != "IIS" {
+				t.Errorf("%s line %d: source %q, service %q", source, i+1, attempt.Source, attempt.Service)
+			}
+			got[source] = append(got[source], iisExpected{
+				ip:       attempt.IP,
+				username: attempt.Username,
+				severity: attempt.Severity,
+				reason:   strings.SplitN(attempt.Message, " from ", 2)[0],
+				time:     attempt.Timestamp.UTC().Format(time.DateTime),
+			})
+		}
+		if !remaining {
+			break
+		}
+	}
+
+	for source, expected := range want {
+		attempts := got[source]
+		if len(attempts) != len(expected) {
+			t.Errorf("%s: got %d attempts %+v, want %d", source, len(attempts), attempts, len(expected))
+			continue
+		}
+		for i := range expected {
+			wantReason := expected[i].reason
+			if expected[i].username != "" {
+				wantReason += " for user '" + expected[i].username + "'"
+			}
+			expected[i].reason = wantReason
+			if attempts[i] != expected[i] {
+				t.Errorf("%s attempt %d = %+v, want %+v", source, i+1, attempts[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestIISParserDefaultFields(t *testing.T) {
+	// Tailing may start mid-file, before any #Fields directive: the IIS default field set applies
+	line := `2024-01-15 10:23:45 10.0.0.1 POST /Microsoft-Server-ActiveSync/default.eas User=eve 443 - 203.0.113.20 Apple-iPhone - 401 1 1326 12`
+	attempt, err := NewIISParser().ParseLine(line)
+	if err != nil || attempt == nil {
+		t.Fatalf("ParseLine = %v, %v", attempt, err)
+	}
+	if attempt.IP != "203.0.113.20" || attempt.Username != "eve" {
+		t.Errorf("attempt = %s / %q, want 203.0.113.20 / eve (from the query)", attempt.IP, attempt.Username)
+	}
+
+	if _, err := NewIISParser().ParseLine("2024-01-15 10:23:45 truncated"); err == nil {
+		t.Error("a line that does not match the header was accepted")
+	}
+}
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// PostfixParser parses Postfix smtpd/postscreen lines from the mail log
+//
+//	postfix/smtpd[1234]: warning: unknown[203.0.113.5]: SASL LOGIN authentication failed: UGFzc3dvcmQ6
+//	postfix/smtpd[1234]: warning: unknown[203.0.113.5]: SASL PLAIN authentication failed: authentication failure, sasl_username=bob@example.com
+//	postfix/smtpd[1234]: too many errors after AUTH from unknown[203.0.113.5]
+//	postfix/smtpd[1234]: NOQUEUE: reject: RCPT from unknown[203.0.113.5]: 554 5.7.1 Service unavailable; Client host [203.0.113.5] blocked using zen.spamhaus.org; ...
+type PostfixParser struct {
+	patterns []string
+
+	saslRegex      *regexp.Regexp
+	saslUserRegex  *regexp.Regexp
+	authErrorRegex *regexp.Regexp
+	rblRegex       *regexp.Regexp
+}
+
+// NewPostfixParser creates a Postfix SASL/RBL parser
+func NewPostfixParser() *PostfixParser {
+	return &PostfixParser{
+		patterns: []string{
+			"SASL LOGIN authentication failed",
+			"SASL PLAIN authentication failed",
+			"too many errors after AUTH",
+			"blocked using",
+		},
+
+		saslRegex:      regexp.MustCompile(`postfix/\S+\[\d+\]: warning: [^\[\s]*\[([0-9A-Fa-f:\.]+)\]: SASL (\S+) authentication failed`),
+		saslUserRegex:  regexp.MustCompile(`sasl_username=(\S+)`),
+		authErrorRegex: regexp.MustCompile(`postfix/\S+\[\d+\]: too many errors after AUTH from [^\[\s]*\[([0-9A-Fa-f:\.]+)\]`),
+		rblRegex:       regexp.MustCompile(`postfix/\S+\[\d+\]: NOQUEUE: reject: \S+ from [^\[\s]*\[([0-9A-Fa-f:\.]+)\]: .*blocked using (\S+?);`),
+	}
+}
+
+// ParseLine extracts SASL failures, AUTH error disconnects and RBL rejects
+// Returns (nil, nil) for other mail log lines
+func (p *PostfixParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	var rawIP, username, message string
+	severity := models.SeverityMedium
+
+	if matches := p.saslRegex.FindStringSubmatch(line); matches != nil {
+		rawIP = matches[1]
+		if userMatches := p.saslUserRegex.FindStringSubmatch(line); userMatches != nil {
+			username = userMatches[1]
+		}
+		message = fmt.Sprintf("SMTP AUTH (%s) failed", matches[2])
+	} else if matches := p.authErrorRegex.FindStringSubmatch(line); matches != nil {
+		rawIP = matches[1]
+		message = "Too many errors after SMTP AUTH"
+		severity = models.SeverityHigh
+	} else if matches := p.rblRegex.FindStringSubmatch(line); matches != nil {
+		rawIP = matches[1]
+		message = fmt.Sprintf("Rejected by RBL %s", matches[2])
+		severity = models.SeverityLow
+	} else {
+		return nil, nil
+	}
+
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		message = fmt.Sprintf("%s for user '%s'", message, username)
+	}
+
+	return &models.AttackAttempt{
+		Timestamp: parseSyslogTimestamp(line),
+		IP:        ip,
+		Service:   "Postfix",
+		Username:  username,
+		Message:   fmt.Sprintf("%s from %s", message, ip),
+		Severity:  severity,
+	}, nil
+}
+
+// ServiceName returns the service name this parser handles
+func (p *PostfixParser) ServiceName() string {
+	return "Postfix"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *PostfixParser) Patterns() []string {
+	return p.patterns
+}
+
+// DovecotParser parses Dovecot login and auth process lines
+//
+//	dovecot: imap-login: Disconnected (auth failed, 3 attempts in 12 secs): user=<bob>, method=PLAIN, rip=203.0.113.5, lip=10.0.0.1, TLS
+//	dovecot: pop3-login: Aborted login (auth failed, 1 attempts in 2 secs): user=<bob>, method=PLAIN, rip=203.0.113.5, lip=10.0.0.1
+//	dovecot: auth: passwd-file(bob,203.0.113.5): unknown user
+//	dovecot: auth-worker(123): sql(bob,203.0.113.5,<sess>): Password mismatch
+//	dovecot: auth: pam(bob,203.0.113.5): pam_authenticate() failed: Authentication failure (password mismatch?)
+type DovecotParser struct {
+	patterns []string
+
+	loginRegex *regexp.Regexp
+	authRegex  *regexp.Regexp
+	userRegex  *regexp.Regexp
+}
+
+// NewDovecotParser creates a Dovecot auth failure parser
+func NewDovecotParser() *DovecotParser {
+	return &DovecotParser{
+		patterns: []string{
+			"auth failed",
+			"unknown user",
+			"Password mismatch",
+			"pam_authenticate() failed",
+		},
+
+		loginRegex: regexp.MustCompile(`dovecot(?:\[\d+\])?: (\w+)-login: .*\(auth failed, (\d+) attempts?[^)]*\).*rip=([0-9A-Fa-f:\.]+)`),
+		authRegex:  regexp.MustCompile(`dovecot(?:\[\d+\])?: auth(?:-worker)?(?:\(\d+\))?: \w+(?:-\w+)?\(([^,()]*),([0-9A-Fa-f:\.]+)[^)]*\): (unknown user|[Pp]assword mismatch|pam_authenticate\(\) failed.*)`),
+		userRegex:  regexp.MustCompile(`user=<([^>]*)>`),
+	}
+}
+
+// ParseLine extracts failed logins and auth process failures
+// Returns (nil, nil) for other mail log lines
+func (p *DovecotParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	var rawIP, username, message string
+	severity := models.SeverityMedium
+
+	if matches := p.loginRegex.FindStringSubmatch(line); matches != nil {
+		rawIP = matches[3]
+		if userMatches := p.userRegex.FindStringSubmatch(line); userMatches != nil {
+			username = userMatches[1]
+		}
+		attempts, _ := strconv.Atoi(matches[2])
+		message = fmt.Sprintf("%s login failed (%d attempts)", matches[1], attempts)
+		if attempts >= 3 {
+			severity = models.SeverityHigh
+		}
+	} else if matches := p.authRegex.FindStringSubmatch(line); matches != nil {
+		username = matches[1]
+		rawIP = matches[2]
+		switch {
+		case matches[3] == "unknown user":
+			message = "Dovecot auth failed (unknown user)"
+		default:
+			message = "Dovecot auth failed (password mismatch)"
+		}
+	} else {
+		return nil, nil
+	}
+
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		message = fmt.Sprintf("%s for user '%s'", message, username)
+	}
+
+	return &models.AttackAttempt{
+		Timestamp: parseSyslogTimestamp(line),
+		IP:        ip,
+		Service:   "Dovecot",
+		Username:  username,
+		Message:   fmt.Sprintf("%s from %s", message, ip),
+		Severity:  severity,
+	}, nil
+}
+
+// ServiceName returns the service name this parser handles
+func (p *DovecotParser) ServiceName() string {
+	return "Dovecot"
+}
+
+// Patterns returns the log messages this parser reacts to
+func (p *DovecotParser) Patterns() []string {
+	return p.patterns
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestPostfixParser(t *testing.T) {
+	runParserCases(t, NewPostfixParser(), []parserCase{
+		{
+			name:     "SASL LOGIN",
+			line:     `Jan 15 10:23:45 mail postfix/smtpd[1234]: warning: unknown[203.0.113.5]: SASL LOGIN authentication failed: UGFzc3dvcmQ6`,
+			ip:       "203.0.113.5",
+			severity: models.SeverityMedium,
+			reason:   "SMTP AUTH (LOGIN) failed from 203.0.113.5",
+		},
+		{
+			name:     "SASL PLAIN with sasl_username",
+			line:     `Jan 15 10:23:46 mail postfix/smtpd[1234]: warning: host.example.net[198.51.100.7]: SASL PLAIN authentication failed: authentication failure, sasl_username=bob@example.com`,
+			ip:       "198.51.100.7",
+			username: "bob@example.com",
+			severity: models.SeverityMedium,
+			reason:   "SMTP AUTH (PLAIN) failed for user 'bob@example.com'",
+		},
+		{
+			name:     "submission service, IPv6",
+			line:     `Jan 15 10:23:47 mail postfix/submission/smtpd[1235]: warning: unknown[2001:db8::5]: SASL LOGIN authentication failed: authentication failure`,
+			ip:       "2001:db8::5",
+			severity: models.SeverityMedium,
+			reason:   "SMTP AUTH (LOGIN) failed",
+		},
+		{
+			name:     "too many errors after AUTH",
+			line:     `Jan 15 10:23:48 mail postfix/smtpd[1234]: too many errors after AUTH from unknown[203.0.113.9]`,
+			ip:       "203.0.113.9",
+			severity: models.SeverityHigh,
+			reason:   "Too many errors after SMTP AUTH",
+		},
+		{
+			name:     "RBL reject",
+			line:     `Jan 15 10:23:49 mail postfix/smtpd[1234]: NOQUEUE: reject: RCPT from unknown[203.0.113.10]: 554 5.7.1 Service unavailable; Client host [203.0.113.10] blocked using zen.spamhaus.org; https://www.spamhaus.org/query/ip/203.0.113.10; from=<a@example.net> to=<b@example.com> proto=ESMTP helo=<x>`,
+			ip:       "203.0.113.10",
+			severity: models.SeverityLow,
+			reason:   "Rejected by RBL zen.spamhaus.org",
+		},
+		{
+			name: "delivered mail",
+			line: `Jan 15 10:23:50 mail postfix/smtp[1240]: 4F2A81C0: to=<b@example.com>, relay=mx.example.com[192.0.2.25]:25, status=sent (250 2.0.0 Ok)`,
+		},
+		{
+			name: "connect",
+			line: `Jan 15 10:23:51 mail postfix/smtpd[1234]: connect from unknown[203.0.113.5]`,
+		},
+		{
+			name:    "local client",
+			line:    `Jan 15 10:23:52 mail postfix/smtpd[1234]: warning: localhost[127.0.0.1]: SASL LOGIN authentication failed: authentication failure`,
+			wantErr: true,
+		},
+	})
+}
+
+func TestDovecotParser(t *testing.T) {
+	runParserCases(t, NewDovecotParser(), []parserCase{
+		{
+			name:     "imap-login, 3 attempts",
+			line:     `Jan 15 10:23:45 mail dovecot: imap-login: Disconnected (auth failed, 3 attempts in 12 secs): user=<bob>, method=PLAIN, rip=203.0.113.5, lip=10.0.0.1, TLS, session=<abc>`,
+			ip:       "203.0.113.5",
+			username: "bob",
+			severity: models.SeverityHigh,
+			reason:   "imap login failed (3 attempts) for user 'bob'",
+		},
+		{
+			name:     "pop3-login, 1 attempt",
+			line:     `Jan 15 10:23:46 mail dovecot[900]: pop3-login: Aborted login (auth failed, 1 attempts in 2 secs): user=<alice>, method=PLAIN, rip=2001:db8::7, lip=2001:db8::1`,
+			ip:       "2001:db8::7",
+			username: "alice",
+			severity: models.SeverityMedium,
+			reason:   "pop3 login failed (1 attempts)",
+		},
+		{
+			name:     "login without user",
+			line:     `Jan 15 10:23:47 mail dovecot: imap-login: Disconnected (auth failed, 1 attempt in 0 secs): user=<>, method=LOGIN, rip=198.51.100.7, lip=10.0.0.1`,
+			ip:       "198.51.100.7",
+			severity: models.SeverityMedium,
+			reason:   "imap login failed (1 attempts) from 198.51.100.7",
+		},
+		{
+			name:     "auth-worker sql",
+			line:     `Jan 15 10:23:48 mail dovecot: auth-worker(123): sql(bob,203.0.113.9,<Zx8kP>): Password mismatch`,
+			ip:       "203.0.113.9",
+			username: "bob",
+			severity: models.SeverityMedium,
+			reason:   "Dovecot auth failed (password mismatch) for user 'bob'",
+		},
+		{
+			name:     "auth pam",
+			line:     `Jan 15 10:23:49 mail dovecot: auth: pam(carol,203.0.113.10): pam_authenticate() failed: Authentication failure (password mismatch?)`,
+			ip:       "203.0.113.10",
+			username: "carol",
+			severity: models.SeverityMedium,
+			reason:   "Dovecot auth failed (password mismatch) for user 'carol'",
+		},
+		{
+			name:     "auth passwd-file unknown user",
+			line:     `Jan 15 10:23:50 mail dovecot: auth: passwd-file(admin,203.0.113.11): unknown user`,
+			ip:       "203.0.113.11",
+			username: "admin",
+			severity: models.SeverityMedium,
+			reason:   "Dovecot auth failed (unknown user) for user 'admin'",
+		},
+		{
+			name: "successful login",
+			line: `Jan 15 10:23:51 mail dovecot: imap-login: Login: user=<bob>, method=PLAIN, rip=203.0.113.5, lip=10.0.0.1, mpid=4321, TLS`,
+		},
+		{
+			name:    "local client",
+			line:    `Jan 15 10:23:52 mail dovecot: auth: passwd-file(bob,127.0.0.1): unknown user`,
+			wantErr: true,
+		},
+	})
+}
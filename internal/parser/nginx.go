@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// NginxParser parses Nginx access and error log lines
+// One parser handles both files, so the access and error log can be
+// configured as two services with log_pattern "nginx"
+type NginxParser struct {
+	webParser
+	patterns []string
+
+	errorLineRegex *regexp.Regexp
+	clientRegex    *regexp.Regexp
+	userRegex      *regexp.Regexp
+}
+
+// NewNginxParser creates an Nginx parser for the given access log format
+// format is a preset name ("combined", "nginx_combined", ...) or a log_format string; empty selects combined
+func NewNginxParser(format string) (*NginxParser, error) {
+	access, err := CompileAccessLogFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NginxParser{
+		webParser: webParser{service: "Nginx", access: access},
+		patterns: []string{
+			"no user/password was provided",
+			"password mismatch",
+			"was not found in",
+			"limiting requests",
+			"limiting connections",
+			"access forbidden by rule",
+		},
+
+		// 2024/01/15 10:23:45 [error] 1234#1234: *5 <message>, client: 203.0.113.5, server: ...
+		errorLineRegex: regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(\w+)\] \d+#\d+: (?:\*\d+ )?(.*)$`),
+		clientRegex:    regexp.MustCompile(`, client: ([0-9A-Fa-f:\.\[\]]+)`),
+		userRegex:      regexp.MustCompile(`user "([^"]*)"`),
+	}, nil
+}
+
+// ParseLine parses an access or error log line
+// Returns (nil, nil) for lines that are not abuse
+func (p *NginxParser) ParseLine(line string) (*models.AttackAttempt, error) {
+	if matches := p.errorLineRegex.FindStringSubmatch(line); matches != nil {
+		return p.parseErrorLine(matches[1], matches[3])
+	}
+
+	entry, ok := p.access.Parse(line)
+	if !ok {
+		return nil, fmt.Errorf("line does not match access or error log format")
+	}
+	return p.parseAccessEntry(entry)
+}
+
+// parseErrorLine maps auth_basic failures, limit_req/limit_conn rejections and deny rules
+func (p *NginxParser) parseErrorLine(rawTime, message string) (*models.AttackAttempt, error) {
+	clientMatches := p.clientRegex.FindStringSubmatch(message)
+	if clientMatches == nil {
+		return nil, nil
+	}
+
+	timestamp := time.Now()
+	if parsed, err := time.ParseInLocation("2006/01/02 15:04:05", rawTime, time.Local); err == nil {
+		timestamp = parsed
+	}
+
+	username := ""
+	if userMatches := p.userRegex.FindStringSubmatch(message); userMatches != nil {
+		username = userMatches[1]
+	}
+
+	switch {
+	case strings.Contains(message, "no user/password was provided"):
+		return p.newWebAttempt(timestamp, clientMatches[1], "", "HTTP basic auth without credentials", models.SeverityLow)
+	case strings.Contains(message, "password mismatch"):
+		return p.newWebAttempt(timestamp, clientMatches[1], username, fmt.Sprintf("HTTP basic auth password mismatch for user '%s'", username), models.SeverityMedium)
+	case strings.Contains(message, "was not found in"):
+		return p.newWebAttempt(timestamp, clientMatches[1], username, fmt.Sprintf("HTTP basic auth unknown user '%s'", username), models.SeverityMedium)
+	case strings.Contains(message, "limiting requests"):
+		return p.newWebAttempt(timestamp, clientMatches[1], "", "limit_req rejection", models.SeverityMedium)
+	case strings.Contains(message, "limiting connections"):
+		return p.newWebAttempt(timestamp, clientMatches[1], "", "limit_conn rejection", models.SeverityMedium)
+	case strings.Contains(message, "access forbidden by rule"):
+		return p.newWebAttempt(timestamp, clientMatches[1], "", "Request denied by access rule", models.SeverityLow)
+	}
+	return nil, nil
+}
+
+// ServiceName returns the service name this parser handles
+func (p *NginxParser) ServiceName() string {
+	return p.service
+}
+
+// Patterns returns the error log messages this parser reacts to
+func (p *NginxParser) Patterns() []string {
+	return p.patterns
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestNginxParser(t *testing.T) {
+	parser, err := NewNginxParser("nginx_combined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runParserCases(t, parser, []parserCase{
+		{
+			name:     "basic auth without credentials",
+			line:     `2024/01/15 10:23:45 [error] 1234#1234: *5 no user/password was provided for basic authentication, client: 203.0.113.5, server: example.com, request: "GET /admin HTTP/1.1", host: "example.com"`,
+			ip:       "203.0.113.5",
+			severity: models.SeverityLow,
+			reason:   "HTTP basic auth without credentials",
+		},
+		{
+			name:     "password mismatch",
+			line:     `2024/01/15 10:23:46 [error] 1234#1234: *6 user "admin": password mismatch, client: 198.51.100.7, server: example.com, request: "GET /admin HTTP/1.1"`,
+			ip:       "198.51.100.7",
+			username: "admin",
+			severity: models.SeverityMedium,
+			reason:   "password mismatch for user 'admin'",
+		},
+		{
+			name:     "unknown user",
+			line:     `2024/01/15 10:23:47 [error] 1234#1234: *7 user "guest" was not found in "/etc/nginx/.htpasswd", client: 2001:db8::9, server: example.com`,
+			ip:       "2001:db8::9",
+			username: "guest",
+			severity: models.SeverityMedium,
+			reason:   "unknown user 'guest'",
+		},
+		{
+			name:     "limit_req",
+			line:     `2024/01/15 10:23:48 [error] 1234#1234: *8 limiting requests, excess: 10.500 by zone "login", client: 203.0.113.9, server: example.com`,
+			ip:       "203.0.113.9",
+			severity: models.SeverityMedium,
+			reason:   "limit_req rejection",
+		},
+		{
+			name:     "limit_conn",
+			line:     `2024/01/15 10:23:49 [error] 1234#1234: *9 limiting connections by zone "perip", client: 203.0.113.10, server: example.com`,
+			ip:       "203.0.113.10",
+			severity: models.SeverityMedium,
+			reason:   "limit_conn rejection",
+		},
+		{
+			name:     "deny rule",
+			line:     `2024/01/15 10:23:50 [error] 1234#1234: *10 access forbidden by rule, client: 203.0.113.11, server: example.com`,
+			ip:       "203.0.113.11",
+			severity: models.SeverityLow,
+			reason:   "Request denied by access rule",
+		},
+		{
+			name: "error without client",
+			line: `2024/01/15 10:20:00 [notice] 1234#1234: signal process started`,
+		},
+		{
+			name: "unrelated error",
+			line: `2024/01/15 10:23:51 [error] 1234#1234: *11 open() "/var/www/favicon.ico" failed (2: No such file or directory), client: 203.0.113.5, server: example.com`,
+		},
+		{
+			name:     "scanner probe",
+			line:     `203.0.113.12 - - [15/Jan/2024:10:23:52 +0000] "GET /.git/config HTTP/1.1" 404 153 "-" "Mozilla/5.0"`,
+			ip:       "203.0.113.12",
+			severity: models.SeverityHigh,
+			reason:   "Scanner probe from 203.0.113.12: GET /.git/config (404)",
+		},
+		{
+			name:     "rate limited",
+			line:     `203.0.113.13 - - [15/Jan/2024:10:23:53 +0000] "POST /login HTTP/1.1" 429 0 "-" "python-requests/2.31"`,
+			ip:       "203.0.113.13",
+			severity: models.SeverityMedium,
+			reason:   "Rate limited request",
+		},
+		{
+			name:     "basic auth failure",
+			line:     `203.0.113.14 - alice [15/Jan/2024:10:23:54 +0000] "GET /private HTTP/1.1" 401 179 "-" "curl/8.5.0"`,
+			ip:       "203.0.113.14",
+			username: "alice",
+			severity: models.SeverityLow,
+			reason:   "HTTP authentication failed",
+		},
+		{
+			name:     "forbidden",
+			line:     `203.0.113.15 - - [15/Jan/2024:10:23:55 +0000] "GET /internal HTTP/1.1" 403 153 "-" "curl/8.5.0"`,
+			ip:       "203.0.113.15",
+			severity: models.SeverityLow,
+			reason:   "Forbidden request",
+		},
+		{
+			name: "ordinary request",
+			line: `203.0.113.5 - - [15/Jan/2024:10:23:56 +0000] "GET / HTTP/1.1" 200 612 "-" "Mozilla/5.0"`,
+		},
+		{
+			name:    "proxy address",
+			line:    `127.0.0.1 - - [15/Jan/2024:10:23:57 +0000] "GET /wp-login.php HTTP/1.1" 404 153 "-" "-"`,
+			wantErr: true,
+		},
+		{
+			name:    "other format",
+			line:    `Jan 15 10:23:45 web01 sshd[1234]: Failed password for root from 203.0.113.5 port 22 ssh2`,
+			wantErr: true,
+		},
+	})
+
+	attempt, _ := parser.ParseLine(`2024/01/15 10:23:46 [error] 1234#1234: *6 user "admin": password mismatch, client: 198.51.100.7, server: example.com`)
+	if want := time.Date(2024, 1, 15, 10, 23, 46, 0, time.Local); attempt == nil || !attempt.Timestamp.Equal(want) {
+		t.Errorf("error log timestamp = %v, want %v", attempt, want)
+	}
+	if attempt != nil && attempt.Service != "Nginx" {
+		t.Errorf("service = %q, want Nginx", attempt.Service)
+	}
+}
+
+func TestNginxParserRejectsFormatWithoutClient(t *testing.T) {
+	if _, err := NewNginxParser(`[$time_local] "$request" $status`); err == nil {
+		t.Error("a log_format without $remote_addr was accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// Factory creates a parser for a configured service
+type Factory func(service models.ServiceConfig) (core.LogParser, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"nginx": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewNginxParser(service.LogFormat)
+		},
+		"apache": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewApacheParser(service.LogFormat)
+		},
+		"postfix": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewPostfixParser(), nil
+		},
+		"dovecot": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewDovecotParser(), nil
+		},
+		"postgresql": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewPostgresParser(service.LogFormat)
+		},
+		"mysql": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewMySQLParser(), nil
+		},
+		"mssql": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewMSSQLParser(), nil
+		},
+		"vsftpd": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewVsftpdParser(), nil
+		},
+		"proftpd": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewProFTPDParser(), nil
+		},
+		"pure-ftpd": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewPureFTPdParser(), nil
+		},
+		"filezilla": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewFileZillaParser(), nil
+		},
+		"iis": func(service models.ServiceConfig) (core.LogParser, error) {
+			return NewIISParser(), nil
+		},
+	}
+)
+
+// Register makes a parser selectable through a service's log_pattern
+// Platform-specific parsers register themselves from init functions
+func Register(pattern string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[strings.ToLower(pattern)] = factory
+}
+
+// New creates the parser selected by a service's log_pattern
+func New(service models.ServiceConfig) (core.LogParser, error) {
+	registryMu.RLock()
+	factory, exists := registry[strings.ToLower(service.LogPattern)]
+	registryMu.RUnlock()
+
+	if !exists {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil,
+			"service %s: no parser for log_pattern %q (available: %s)",
+			service.Name, service.LogPattern, strings.Join(Available(), ", "))
+	}
+
+	parser, err := factory(service)
+	if err != nil {
+		return nil, core.NewErrorf(core.ErrConfigInvalid, err, "service %s: invalid parser configuration", service.Name)
+	}
+	return parser, nil
+}
+
+// Available returns the registered log_pattern names
+func Available() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
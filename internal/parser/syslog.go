@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+// syslogPrefixRegex matches the timestamp and host written by syslog daemons
+//   - BSD:     "Jan 15 10:23:45 mail "
+//   - RFC3339: "2024-01-15T10:23:45.123456+00:00 mail " (rsyslog high precision)
+var syslogPrefixRegex = regexp.MustCompile(`^(?:([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})|(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?)) \S+ `)
+
+// parseSyslogTimestamp returns the timestamp of a syslog line, or now if it has none
+// BSD timestamps carry no year; a date in the future is taken to be from last year
+func parseSyslogTimestamp(line string) time.Time {
+	matches := syslogPrefixRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return time.Now()
+	}
+
+	if matches[2] != "" {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700", "2006-01-02T15:04:05"} {
+			if parsed, err := time.Parse(layout, matches[2]); err == nil {
+				return parsed
+			}
+		}
+		return time.Now()
+	}
+
+	now := time.Now()
+	parsed, err := time.ParseInLocation("Jan _2 15:04:05", matches[1], time.Local)
+	if err != nil {
+		return now
+	}
+	parsed = parsed.AddDate(now.Year(), 0, 0)
+	if parsed.After(now.Add(24 * time.Hour)) {
+		parsed = parsed.AddDate(-1, 0, 0)
+	}
+	return parsed
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// scannerProbePaths are requested by vulnerability scanners and bots, never by real users
+// Matched case-insensitively anywhere in the request path (probes are often sent below a prefix)
+var scannerProbePaths = []string{
+	"/wp-login.php",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git/",
+	"/.aws/",
+	"/.ssh/",
+	"/.htpasswd",
+	"/phpmyadmin",
+	"/pma/",
+	"/myadmin",
+	"/mysqladmin",
+	"/adminer.php",
+	"/vendor/phpunit",
+	"/cgi-bin/",
+	"/boaform",
+	"/hnap1",
+	"/actuator/",
+	"/solr/admin",
+	"/manager/html",
+	"/config.php",
+	"/server-status",
+}
+
+// IsScannerProbe reports whether a request path is a known scanner probe
+func IsScannerProbe(path string) bool {
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+	if idx := strings.IndexAny(path, "?#"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.ToLower(path)
+
+	for _, probe := range scannerProbePaths {
+		if strings.Contains(path, probe) {
+			return true
+		}
+	}
+	return false
+}
+
+// webParser holds what the Nginx and Apache parsers share: the access log format
+// and the mapping from access log entries to attack attempts
+type webParser struct {
+	service string
+	access  *AccessLogFormat
+}
+
+// parseAccessEntry turns an access log entry into an attack attempt
+// Returns nil for requests that are not abuse (most lines)
+//   - scanner probe paths (any status): high
+//   - 429 / limit_req rejections: medium
+//   - 401 and 403 responses: low; the failure threshold turns bursts into blocks
+func (p *webParser) parseAccessEntry(entry AccessLogEntry) (*models.AttackAttempt, error) {
+	ip, err := clientIP(entry.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	var severity models.Severity
+	var message string
+	switch {
+	case IsScannerProbe(entry.Path):
+		severity = models.SeverityHigh
+		message = fmt.Sprintf("Scanner probe from %s: %s %s (%d)", ip, entry.Method, entry.Path, entry.Status)
+	case entry.Status == 429:
+		severity = models.SeverityMedium
+		message = fmt.Sprintf("Rate limited request from %s: %s %s", ip, entry.Method, entry.Path)
+	case entry.Status == 401:
+		severity = models.SeverityLow
+		message = fmt.Sprintf("HTTP authentication failed from %s for %s", ip, entry.Path)
+	case entry.Status == 403:
+		severity = models.SeverityLow
+		message = fmt.Sprintf("Forbidden request from %s: %s %s", ip, entry.Method, entry.Path)
+	default:
+		return nil, nil
+	}
+
+	return &models.AttackAttempt{
+		Timestamp: entry.Timestamp,
+		IP:        ip,
+		Service:   p.service,
+		Username:  entry.User,
+		Message:   message,
+		Severity:  severity,
+	}, nil
+}
+
+// newWebAttempt builds an attempt from an error log line
+func (p *webParser) newWebAttempt(timestamp time.Time, rawIP, username, message string, severity models.Severity) (*models.AttackAttempt, error) {
+	ip, err := clientIP(rawIP)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AttackAttempt{
+		Timestamp: timestamp,
+		IP:        ip,
+		Service:   p.service,
+		Username:  username,
+		Message:   fmt.Sprintf("%s from %s", message, ip),
+		Severity:  severity,
+	}, nil
+}
+
+// clientIP canonicalizes a client address ("203.0.113.5", "[2001:db8::1]:443", "203.0.113.5:51234")
+// Loopback and unspecified addresses are rejected: they are proxies, never attackers to block
+func clientIP(raw string) (string, error) {
+	candidate := strings.TrimSpace(raw)
+	ip := utils.ParseIP(candidate)
+	if ip == nil {
+		// Strip a trailing port
+		if host, _, err := net.SplitHostPort(candidate); err == nil {
+			ip = utils.ParseIP(host)
+		}
+	}
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return "", fmt.Errorf("invalid or local IP address: %s", raw)
+	}
+	return ip.String(), nil
+}
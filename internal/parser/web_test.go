@@ -0,0 +1,55 @@
+package parser
+
+import "testing"
+
+func TestIsScannerProbe(t *testing.T) {
+	cases := map[string]bool{
+		"/wp-login.php":                    true,
+		"/blog/WP-LOGIN.PHP?redirect=1":    true,
+		"/%2egit/config":                   true,
+		"/app/.env":                        true,
+		"/vendor/phpunit/phpunit/src/Util": true,
+		"/cgi-bin/luci":                    true,
+		"/":                                false,
+		"/login":                           false,
+		"/environment":                     false,
+		"/search?q=/wp-login.php":          false,
+		"/docs#/.env":                      false,
+	}
+	for path, want := range cases {
+		if got := IsScannerProbe(path); got != want {
+			t.Errorf("IsScannerProbe(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string // empty expects an error
+	}{
+		{"203.0.113.5", "203.0.113.5"},
+		{" 203.0.113.5 ", "203.0.113.5"},
+		{"203.0.113.5:51234", "203.0.113.5"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"::ffff:198.51.100.7", "198.51.100.7"},
+		{"127.0.0.1", ""},
+		{"[::1]:8080", ""},
+		{"0.0.0.0", ""},
+		{"unix:", ""},
+		{"-", ""},
+	}
+	for _, tc := range cases {
+		got, err := clientIP(tc.raw)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("clientIP(%q) = %q, want an error", tc.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("clientIP(%q) = %q, %v; want %q", tc.raw, got, err, tc.want)
+		}
+	}
+}
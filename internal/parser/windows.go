@@ -6,144 +6,162 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
-	"strings"
-	"time"
 
+	"github.com/sr-tamim/guardian/internal/core"
 	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
 )
 
-// WindowsEventLogParser parses Windows Security Event Log entries
-// Specifically designed to process Event ID 4625 (Failed Logon) events
+// WindowsEventLogParser parses Windows Event Log entries exported by wevtutil
+// Each configured event ID is handled by its own rule (field mapping and severity):
+// 4625 failed logon, 4771 Kerberos pre-auth, 4776 NTLM validation, 4740 lockout,
+// RD Gateway 201/302/303 and OpenSSH for Windows (event 4)
 // This mirrors the functionality of your production PowerShell script
 type WindowsEventLogParser struct {
 	name     string
+	service  string
+	channel  string
+	eventIDs []int
 	patterns []string
+}
 
-	// Regex patterns matching your PowerShell script
-	ipRegex        *regexp.Regexp
-	usernameRegex  *regexp.Regexp
-	eventIDRegex   *regexp.Regexp
-	timestampRegex *regexp.Regexp
+func init() {
+	factory := func(service models.ServiceConfig) (core.LogParser, error) {
+		return NewWindowsEventLogParser(service)
+	}
+	Register("4625", factory)
+	Register("eventlog", factory)
 }
 
-// NewWindowsEventLogParser creates a new Windows Event Log parser
-// Uses the exact same regex pattern as your PowerShell script: "Source Network Address:\s+([\d\.]+)"
-func NewWindowsEventLogParser() *WindowsEventLogParser {
-	return &WindowsEventLogParser{
-		name: "Windows Security Event Log",
-		patterns: []string{
-			"4625", // Failed logon event ID
-			"Source Network Address",
-			"Account Name",
-			"Logon Type",
-		},
-
-		// These regex patterns match your PowerShell script exactly
-		ipRegex:        regexp.MustCompile(`Source Network Address:\s+([\d\.]+)`),
-		usernameRegex:  regexp.MustCompile(`Account Name:\s+([^\r\n\s]+)`),
-		eventIDRegex:   regexp.MustCompile(`Event ID:\s+(\d+)`),
-		timestampRegex: regexp.MustCompile(`Time Created:\s+([^\r\n]+)`),
+// NewWindowsEventLogParser creates a Windows Event Log parser for a service
+// The channel comes from log_path (default Security) and the event IDs from event_ids or a numeric log_pattern
+func NewWindowsEventLogParser(service models.ServiceConfig) (*WindowsEventLogParser, error) {
+	if service.LogPath == "" {
+		service.LogPath = ChannelSecurity
 	}
+	if service.Name == "" {
+		service.Name = "RDP"
+	}
+	if service.LogPattern == "" && len(service.EventIDs) == 0 {
+		service.LogPattern = "4625"
+	}
+
+	eventIDs, err := ServiceEventIDs(service)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]string, 0, len(eventIDs))
+	for _, id := range eventIDs {
+		patterns = append(patterns, strconv.Itoa(id))
+	}
+
+	return &WindowsEventLogParser{
+		name:     "Windows Event Log (" + service.LogPath + ")",
+		service:  service.Name,
+		channel:  service.LogPath,
+		eventIDs: eventIDs,
+		patterns: patterns,
+	}, nil
+}
+
+// Channel returns the event log channel this parser reads
+func (p *WindowsEventLogParser) Channel() string {
+	return p.channel
+}
+
+// EventIDs returns the event IDs this parser handles
+func (p *WindowsEventLogParser) EventIDs() []int {
+	return p.eventIDs
+}
+
+// Handles reports whether an event ID is monitored by this parser
+func (p *WindowsEventLogParser) Handles(eventID int) bool {
+	return slices.Contains(p.eventIDs, eventID)
 }
 
 // ParseLine processes a Windows Event Log entry and extracts attack attempt information
 // This is the Go equivalent of your PowerShell script's event processing logic
 func (p *WindowsEventLogParser) ParseLine(line string) (*models.AttackAttempt, error) {
-	// First, check if this is a relevant event (Event ID 4625)
-	eventMatches := p.eventIDRegex.FindStringSubmatch(line)
-	if len(eventMatches) < 2 {
-		return nil, fmt.Errorf("no event ID found")
+	event, err := ParseWindowsEvent(line)
+	if err != nil {
+		return nil, err
 	}
+	return p.ParseEvent(event)
+}
 
-	eventID, err := strconv.Atoi(eventMatches[1])
-	if err != nil || eventID != 4625 {
-		return nil, fmt.Errorf("not a failed logon event (ID: %d)", eventID)
+// ParseEvent maps a parsed event to an attack attempt
+// Returns (nil, nil) for monitored events that are not failures (e.g. success codes);
+// attempts from 4776/4740 have an empty IP when the workstation is not an address
+func (p *WindowsEventLogParser) ParseEvent(event *WindowsEvent) (*models.AttackAttempt, error) {
+	if !p.Handles(event.ID) {
+		return nil, fmt.Errorf("event %d is not monitored", event.ID)
 	}
-
-	// Extract IP address using the same regex as your PowerShell script
-	ipMatches := p.ipRegex.FindStringSubmatch(line)
-	if len(ipMatches) < 2 {
-		return nil, fmt.Errorf("could not extract IP address from event")
+	rule, exists := findWindowsEventRule(p.channel, event.ID)
+	if !exists {
+		return nil, fmt.Errorf("no rule for event %d in channel %s", event.ID, p.channel)
 	}
 
-	sourceIP := strings.TrimSpace(ipMatches[1])
-
-	// Skip invalid or local IPs (like your PowerShell script does)
-	if sourceIP == "" || sourceIP == "-" || sourceIP == "127.0.0.1" || sourceIP == "::1" {
-		return nil, fmt.Errorf("invalid or local IP address: %s", sourceIP)
+	match, failed := rule.extract(event)
+	if !failed {
+		return nil, nil
 	}
 
-	// Extract username
-	usernameMatches := p.usernameRegex.FindStringSubmatch(line)
-	username := "unknown"
-	if len(usernameMatches) >= 2 {
-		username = strings.TrimSpace(usernameMatches[1])
-		// Filter out system accounts (like your PowerShell script)
-		if username == "-" || username == "" || strings.HasSuffix(username, "$") {
-			username = "system_account"
+	// Skip invalid or local IPs (like your PowerShell script does)
+	// IPv4-mapped addresses (::ffff:a.b.c.d) are normalized to plain IPv4 first
+	sourceIP := ""
+	if parsedIP := utils.ParseIP(match.address); parsedIP != nil {
+		if parsedIP.IsLoopback() || parsedIP.IsUnspecified() {
+			return nil, fmt.Errorf("invalid or local IP address: %s", match.address)
 		}
+		sourceIP = parsedIP.String()
+	} else if !rule.accountOnly {
+		return nil, fmt.Errorf("invalid or local IP address: %s", match.address)
 	}
 
-	// Extract timestamp
-	timestamp := time.Now() // Default to current time
-	timestampMatches := p.timestampRegex.FindStringSubmatch(line)
-	if len(timestampMatches) >= 2 {
-		if parsedTime, err := time.Parse("2006-01-02T15:04:05.000000000Z", timestampMatches[1]); err == nil {
-			timestamp = parsedTime
-		}
+	username := match.username
+	if username == "" {
+		username = "unknown"
 	}
 
-	// Determine severity based on username patterns (similar to your PS logic)
-	severity := p.determineSeverity(username, sourceIP)
+	source := event.Channel
+	if source == "" {
+		source = p.channel
+	}
 
 	return &models.AttackAttempt{
-		Timestamp: timestamp,
+		Timestamp: event.Timestamp,
 		IP:        sourceIP,
-		Service:   "RDP",
+		Service:   p.service,
 		Username:  username,
-		Message:   p.formatLogMessage(sourceIP, username),
-		Severity:  severity,
-		Source:    "Security", // Windows Event Log name
-		Blocked:   false,      // Will be set later if blocking occurs
+		Message:   p.formatLogMessage(rule.service, match.reason, sourceIP, username, match.address),
+		Severity:  match.severity,
+		Source:    source, // Windows Event Log name
+		Blocked:   false,  // Will be set later if blocking occurs
+
+		FailureCode: match.failureCode,
+		LogonType:   match.logonType,
 	}, nil
 }
 
-// determineSeverity assesses threat level like your PowerShell script might
-func (p *WindowsEventLogParser) determineSeverity(username, ip string) models.Severity {
-	// High severity for admin accounts
-	adminAccounts := []string{"administrator", "admin", "root", "sa"}
-	for _, admin := range adminAccounts {
-		if strings.EqualFold(username, admin) {
-			return models.SeverityHigh
-		}
-	}
-
-	// Medium severity for service accounts
-	if strings.HasSuffix(strings.ToLower(username), "service") ||
-		strings.HasSuffix(strings.ToLower(username), "svc") {
-		return models.SeverityMedium
-	}
-
-	// Check for common dictionary attack patterns
-	commonTargets := []string{"user", "test", "guest", "demo"}
-	for _, target := range commonTargets {
-		if strings.EqualFold(username, target) {
-			return models.SeverityMedium
+// formatLogMessage creates a readable log message
+// Workstation names that are not addresses are kept so account-only events stay traceable
+func (p *WindowsEventLogParser) formatLogMessage(kind, reason, ip, username, workstation string) string {
+	origin := ip
+	if origin == "" {
+		origin = "workstation '" + workstation + "'"
+		if workstation == "" {
+			origin = "an unknown client"
 		}
 	}
-
-	return models.SeverityLow
-}
-
-// formatLogMessage creates a readable log message
-func (p *WindowsEventLogParser) formatLogMessage(ip, username string) string {
-	return fmt.Sprintf("Failed RDP logon attempt from %s for user '%s'", ip, username)
+	return fmt.Sprintf("%s %s for user '%s' from %s", kind, reason, username, origin)
 }
 
 // ServiceName returns the service name this parser handles
 func (p *WindowsEventLogParser) ServiceName() string {
-	return "RDP"
+	return p.service
 }
 
 // Patterns returns the regex patterns this parser uses
@@ -151,24 +169,14 @@ func (p *WindowsEventLogParser) Patterns() []string {
 	return p.patterns
 }
 
+// RDP indicators, compiled once: network logon (typical for RDP), RemoteInteractive logon (RDP),
+// a client address or a failed logon
+var rdpIndicators = regexp.MustCompile(`Logon Type:\s+(?:3|10)|Source Network Address|Event ID: 4625`)
+
 // IsRDPEvent checks if the log line is an RDP-related event
 // This helps filter events efficiently like your PowerShell script
 func (p *WindowsEventLogParser) IsRDPEvent(line string) bool {
-	// Look for RDP-specific indicators
-	rdpIndicators := []string{
-		`Logon Type:\s+3`,  // Network logon (typical for RDP)
-		`Logon Type:\s+10`, // RemoteInteractive logon (RDP)
-		"Source Network Address",
-		"Event ID: 4625",
-	}
-
-	for _, indicator := range rdpIndicators {
-		if matched, _ := regexp.MatchString(indicator, line); matched {
-			return true
-		}
-	}
-
-	return false
+	return rdpIndicators.MatchString(line)
 }
 
 // ParseEventXML handles structured Windows Event Log XML format
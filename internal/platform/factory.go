@@ -1,10 +1,12 @@
 package platform
 
 import (
+	"fmt"
 	"runtime"
 
 	"github.com/sr-tamim/guardian/internal/core"
 	"github.com/sr-tamim/guardian/internal/platform/mock"
+	"github.com/sr-tamim/guardian/internal/storage"
 	"github.com/sr-tamim/guardian/pkg/models"
 )
 
@@ -17,23 +19,29 @@ func NewFactory() *Factory {
 }
 
 // CreateProvider creates the appropriate platform provider
+// Block records are persisted in the configured storage so rule identity survives restarts
 func (f *Factory) CreateProvider(devMode bool, config *models.Config) (core.PlatformProvider, error) {
+	store, err := storage.New(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
 	if devMode {
-		return mock.NewMockProvider(config), nil
+		return mock.NewMockProvider(config, store), nil
 	}
 
 	// In production, detect the actual platform
 	switch runtime.GOOS {
 	case "windows":
-		return createWindowsProvider(config), nil
+		return createWindowsProvider(config, store), nil
 	case "linux":
 		// TODO: return createLinuxProvider(config), nil
-		return mock.NewMockProvider(config), nil // Use mock for now
+		return mock.NewMockProvider(config, store), nil // Use mock for now
 	case "darwin":
 		// TODO: return createDarwinProvider(config), nil
-		return mock.NewMockProvider(config), nil // Use mock for now
+		return mock.NewMockProvider(config, store), nil // Use mock for now
 	default:
-		return mock.NewMockProvider(config), nil
+		return mock.NewMockProvider(config, store), nil
 	}
 }
 
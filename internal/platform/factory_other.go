@@ -10,6 +10,6 @@ import (
 )
 
 // createWindowsProvider creates a mock provider for non-Windows platforms
-func createWindowsProvider(config *models.Config) core.PlatformProvider {
-	return mock.NewMockProvider(config)
+func createWindowsProvider(config *models.Config, store core.Storage) core.PlatformProvider {
+	return mock.NewMockProvider(config, store)
 }
@@ -10,6 +10,6 @@ import (
 )
 
 // createWindowsProvider creates the Windows-specific provider
-func createWindowsProvider(config *models.Config) core.PlatformProvider {
-	return windows.NewWindowsProvider(config)
+func createWindowsProvider(config *models.Config, store core.Storage) core.PlatformProvider {
+	return windows.NewWindowsProvider(config, store)
 }
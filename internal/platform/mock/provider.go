@@ -3,14 +3,19 @@ package mock
 import (
 	"context"
 	"fmt"
-	"net"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/detector"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/notify"
+	"github.com/sr-tamim/guardian/internal/queue"
+	"github.com/sr-tamim/guardian/internal/storage"
 	"github.com/sr-tamim/guardian/pkg/logger"
 	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
 )
 
 // MockProvider implements PlatformProvider for development and testing
@@ -28,6 +33,23 @@ type MockProvider struct {
 	totalAttacks int64
 	totalBlocks  int64
 
+	// Block manager enforcing max_concurrent_blocks on top of BlockIP/UnblockIP
+	firewall *firewall.Manager
+
+	// Persistent block records (rule identity survives restarts)
+	store        core.Storage
+	restoreState sync.Once
+	maintenance  sync.Once
+
+	// Drift detection between storage, blockedIPs and the simulated rules
+	reconciler *firewall.Reconciler
+
+	// Scores simulated attempts the same way the Windows provider scores real ones
+	detector *detector.Detector
+
+	// Delivers spraying and stuffing alerts
+	notifier *notify.Dispatcher
+
 	// Channels for communication
 	logEvents chan core.LogEvent
 	stopChan  chan struct{}
@@ -37,7 +59,8 @@ type MockProvider struct {
 // Mimics the structure from PowerShell: "Guardian - $(timestamp) - $IPAddr"
 type FirewallRule struct {
 	Name      string     // "Guardian - 20250821073000 - 192.168.1.100"
-	IP        string     // "192.168.1.100"
+	IP        string     // "192.168.1.100" or an aggregated network such as "2001:db8::/64"
+	Family    string     // "ipv4" or "ipv6"
 	CreatedAt time.Time  // When rule was created
 	ExpiresAt *time.Time // When rule expires (like BlockDuration in PS script)
 	Action    string     // "Block"
@@ -46,16 +69,46 @@ type FirewallRule struct {
 }
 
 // NewMockProvider creates a new mock platform provider
-func NewMockProvider(config *models.Config) *MockProvider {
-	return &MockProvider{
+func NewMockProvider(config *models.Config, store core.Storage) *MockProvider {
+	if store == nil {
+		store = storage.NewMemoryStorage()
+	}
+	provider := &MockProvider{
 		name:          "MockProvider",
 		config:        config,
+		store:         store,
 		blockedIPs:    make(map[string]*models.BlockRecord),
 		firewallRules: make(map[string]*FirewallRule),
 		logEvents:     make(chan core.LogEvent, 100),
 		stopChan:      make(chan struct{}),
 		startTime:     time.Now(),
 	}
+	provider.firewall = firewall.NewManager(config, provider)
+	provider.firewall.SetObserver(func(record *models.BlockRecord) {
+		provider.raiseAlert(notify.NewWouldBlock(record))
+	})
+	provider.reconciler = firewall.NewReconciler(provider, store, provider.firewall)
+	provider.detector = detector.New(config)
+	overflow := config.Monitoring.Overflow
+	provider.notifier = notify.NewDispatcher(
+		queue.FromConfig("alerts", notify.QueueSize, overflow.Alerts, queue.PolicyDropOldest, overflow),
+		notify.Notifiers(config, store)...)
+	return provider
+}
+
+// FirewallManager returns the block manager wrapping the simulated firewall
+func (m *MockProvider) FirewallManager() *firewall.Manager {
+	return m.firewall
+}
+
+// Storage returns the store holding block records, attempts and alerts
+func (m *MockProvider) Storage() core.Storage {
+	return m.store
+}
+
+// Notifier returns the dispatcher delivering detection alerts
+func (m *MockProvider) Notifier() *notify.Dispatcher {
+	return m.notifier
 }
 
 // Name returns the provider name
@@ -76,13 +129,24 @@ func (m *MockProvider) RequirementsCheck() error {
 // BlockIP simulates blocking an IP using Windows Firewall
 // Mimics: New-NetFirewallRule -DisplayName $RuleName -Direction Inbound -RemoteAddress $IPAddr -Action Block
 func (m *MockProvider) BlockIP(ip string, duration time.Duration, reason string) error {
+	return m.BlockAttributed(ip, duration, reason, "", models.SeverityMedium)
+}
+
+// BlockAttributed blocks an address on behalf of a service; the severity is stored and notified
+func (m *MockProvider) BlockAttributed(ip string, duration time.Duration, reason, service string, severity models.Severity) error {
+	if service == "" {
+		service = "RDP"
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Validate IP address
-	if net.ParseIP(ip) == nil {
-		return core.NewError(core.ErrInvalidIP, "invalid IP address", nil)
+	// Validate and canonicalize the address (single IPv4/IPv6 address or CIDR network)
+	target, err := utils.NormalizeBlockTarget(ip)
+	if err != nil {
+		return core.NewError(core.ErrInvalidIP, "invalid IP address", err)
 	}
+	ip = target
+	family := utils.TargetFamily(ip)
 
 	// Check if already blocked (like your PowerShell script)
 	if existing, exists := m.blockedIPs[ip]; exists && existing.IsActive {
@@ -90,7 +154,7 @@ func (m *MockProvider) BlockIP(ip string, duration time.Duration, reason string)
 	}
 
 	// Create firewall rule (using configurable naming convention)
-	ruleName := m.config.Blocking.GenerateRuleName(ip, "RDP") // Service can be dynamic too
+	ruleName := m.config.Blocking.GenerateRuleName(ip, service)
 
 	var expiresAt *time.Time
 	if duration > 0 {
@@ -102,6 +166,7 @@ func (m *MockProvider) BlockIP(ip string, duration time.Duration, reason string)
 	rule := &FirewallRule{
 		Name:      ruleName,
 		IP:        ip,
+		Family:    family,
 		CreatedAt: time.Now(),
 		ExpiresAt: expiresAt,
 		Action:    "Block",
@@ -113,21 +178,30 @@ func (m *MockProvider) BlockIP(ip string, duration time.Duration, reason string)
 	// Create block record
 	blockRecord := &models.BlockRecord{
 		IP:          ip,
+		Family:      family,
 		BlockedAt:   time.Now(),
 		ExpiresAt:   expiresAt,
 		Reason:      reason,
-		Service:     "RDP", // Since we're focusing on Windows/RDP first
+		Service:     service,
+		Severity:    severity,
 		AttackCount: 1,
 		IsActive:    true,
+		RuleName:    ruleName, // stored so removal never has to search for the rule
 	}
 	m.blockedIPs[ip] = blockRecord
 	m.totalBlocks++
 
-	fmt.Printf("🚫 [MOCK] Blocked IP %s with rule: %s (expires: %v)\n",
-		ip, ruleName, formatExpiry(expiresAt))
+	if err := m.store.SaveBlock(blockRecord); err != nil {
+		logger.Warn("Failed to persist block record", "ip", ip, "error", err)
+	}
+
+	fmt.Printf("🚫 [MOCK] Blocked %s %s with rule: %s (expires: %v)\n",
+		family, ip, ruleName, formatExpiry(expiresAt))
 
 	// Use structured logging for firewall action if configured
 	logger.LogIPBlocked(m.config, ip, reason, ruleName, duration)
+	m.notifier.Notify(notify.NewEvent(models.EventIPBlocked, service, ip, severity,
+		fmt.Sprintf("Blocked %s (expires: %v): %s", ip, formatExpiry(expiresAt), reason)))
 
 	return nil
 }
@@ -138,19 +212,19 @@ func (m *MockProvider) UnblockIP(ip string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if target, err := utils.NormalizeBlockTarget(ip); err == nil {
+		ip = target
+	}
+
 	blockRecord, exists := m.blockedIPs[ip]
 	if !exists || !blockRecord.IsActive {
 		return core.NewError(core.ErrIPNotBlocked, fmt.Sprintf("IP %s is not blocked", ip), nil)
 	}
 
-	// Find and remove firewall rule
-	var ruleToRemove string
-	for ruleName, rule := range m.firewallRules {
-		if rule.IP == ip && rule.IsActive {
-			rule.IsActive = false
-			ruleToRemove = ruleName
-			break
-		}
+	// Remove the rule stored in the block record
+	ruleToRemove := blockRecord.RuleName
+	if rule, exists := m.firewallRules[ruleToRemove]; exists {
+		rule.IsActive = false
 	}
 
 	// Update block record
@@ -158,32 +232,45 @@ func (m *MockProvider) UnblockIP(ip string) error {
 	now := time.Now()
 	blockRecord.UnblockedAt = &now
 
+	if err := m.store.UpdateBlock(blockRecord); err != nil {
+		logger.Warn("Failed to persist unblock", "ip", ip, "error", err)
+	}
+
 	activeTime := now.Sub(blockRecord.BlockedAt)
 
 	fmt.Printf("✅ [MOCK] Unblocked IP %s (removed rule: %s)\n", ip, ruleToRemove)
 
 	// Use structured logging for firewall action if configured
 	logger.LogIPUnblocked(m.config, ip, ruleToRemove, activeTime)
+	m.notifier.Notify(notify.NewEvent(models.EventIPUnblocked, "", ip, models.SeverityLow,
+		fmt.Sprintf("Unblocked %s after %s", ip, activeTime.Truncate(time.Second))))
 
 	return nil
 }
 
-// IsBlocked checks if an IP is currently blocked
+// IsBlocked checks if an IP is currently blocked, either directly or by an aggregated network block
 func (m *MockProvider) IsBlocked(ip string) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	blockRecord, exists := m.blockedIPs[ip]
-	if !exists {
-		return false, nil
+	if target, err := utils.NormalizeBlockTarget(ip); err == nil {
+		ip = target
 	}
 
-	// Check if expired
-	if blockRecord.ExpiresAt != nil && time.Now().After(*blockRecord.ExpiresAt) {
-		return false, nil
+	for target, blockRecord := range m.blockedIPs {
+		if target != ip && !utils.TargetContains(target, ip) {
+			continue
+		}
+		// Check if expired
+		if blockRecord.ExpiresAt != nil && time.Now().After(*blockRecord.ExpiresAt) {
+			continue
+		}
+		if blockRecord.IsActive {
+			return true, nil
+		}
 	}
 
-	return blockRecord.IsActive, nil
+	return false, nil
 }
 
 // ListBlockedIPs returns all currently blocked IPs
@@ -223,17 +310,145 @@ func (m *MockProvider) StartLogMonitoring(ctx context.Context, logPath string, e
 	m.isRunning = true
 	m.mu.Unlock()
 
+	m.StartMaintenance(ctx)
+
 	fmt.Printf("📊 [MOCK] Started monitoring %s (simulating Windows Security Event Log)\n", logPath)
 
 	// Use structured logging for monitoring events if configured
 	logger.LogMonitoringStart(m.config, "MOCK", logPath, "MockProvider")
 
 	go m.simulateWindowsSecurityEvents(ctx, events)
-	go m.startCleanupScheduler(ctx)
 
 	return nil
 }
 
+// StartMaintenance restores persisted blocks and starts expiry cleanup, reconciliation, the mode watcher and notifier schedules once
+func (m *MockProvider) StartMaintenance(ctx context.Context) {
+	m.restoreBlocks()
+	m.maintenance.Do(func() {
+		go m.startCleanupScheduler(ctx)
+		go m.reconciler.Run(ctx, m.config.Blocking.ReconcileInterval)
+		go m.firewall.WatchMode(ctx, firewall.ModePath())
+		m.notifier.Start(ctx)
+	})
+}
+
+// restoreBlocks recreates simulated rules for blocks persisted by a previous run
+func (m *MockProvider) restoreBlocks() {
+	m.restoreState.Do(func() {
+		records, err := m.store.GetActiveBlocks()
+		if err != nil {
+			logger.Warn("Failed to load persisted block records", "error", err)
+			return
+		}
+
+		m.mu.Lock()
+		for _, record := range records {
+			if _, known := m.blockedIPs[record.IP]; known {
+				continue
+			}
+			m.blockedIPs[record.IP] = record
+			if record.RuleName != "" {
+				m.firewallRules[record.RuleName] = &FirewallRule{
+					Name:      record.RuleName,
+					IP:        record.IP,
+					Family:    record.Family,
+					CreatedAt: record.BlockedAt,
+					ExpiresAt: record.ExpiresAt,
+					Action:    "Block",
+					Direction: "Inbound",
+					IsActive:  true,
+				}
+			}
+		}
+		m.mu.Unlock()
+		m.firewall.Restore(records)
+
+		if len(records) > 0 {
+			fmt.Printf("♻️  [MOCK] Restored %d persisted blocks\n", len(records))
+		}
+	})
+}
+
+// Reconcile compares stored blocks, provider state and the simulated firewall rules
+func (m *MockProvider) Reconcile(dryRun bool) (*firewall.ReconcileReport, error) {
+	m.restoreBlocks()
+	return m.reconciler.Reconcile(dryRun)
+}
+
+// FirewallRules lists the active simulated rules
+func (m *MockProvider) FirewallRules() ([]firewall.RuleEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []firewall.RuleEntry
+	for name, rule := range m.firewallRules {
+		if rule.IsActive {
+			entries = append(entries, firewall.RuleEntry{RuleName: name, Target: rule.IP})
+		}
+	}
+	return entries, nil
+}
+
+// BlockRecords returns copies of the provider's block records
+func (m *MockProvider) BlockRecords() []*models.BlockRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]*models.BlockRecord, 0, len(m.blockedIPs))
+	for _, record := range m.blockedIPs {
+		copied := *record
+		records = append(records, &copied)
+	}
+	return records
+}
+
+// TrackRecord replaces the provider's record for an address
+func (m *MockProvider) TrackRecord(record *models.BlockRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *record
+	m.blockedIPs[record.IP] = &copied
+}
+
+// RestoreRule recreates the simulated rule for an active block
+func (m *MockProvider) RestoreRule(record *models.BlockRecord) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ruleName := m.config.Blocking.GenerateRuleName(record.IP, "RDP")
+	m.firewallRules[ruleName] = &FirewallRule{
+		Name:      ruleName,
+		IP:        record.IP,
+		Family:    record.Family,
+		CreatedAt: time.Now(),
+		ExpiresAt: record.ExpiresAt,
+		Action:    "Block",
+		Direction: "Inbound",
+		IsActive:  true,
+	}
+	fmt.Printf("♻️  [MOCK] Recreated missing rule %s for %s\n", ruleName, record.IP)
+	return ruleName, nil
+}
+
+// RemoveRuleEntry deactivates a simulated rule
+func (m *MockProvider) RemoveRuleEntry(entry firewall.RuleEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rule, exists := m.firewallRules[entry.RuleName]; exists {
+		rule.IsActive = false
+		fmt.Printf("🧹 [MOCK] Removed rule %s (%s) during reconciliation\n", entry.RuleName, entry.Target)
+	}
+	return nil
+}
+
+// CommitRules is a no-op; simulated rules change immediately
+func (m *MockProvider) CommitRules() error {
+	return nil
+}
+
 // Helper functions
 func formatExpiry(expiresAt *time.Time) string {
 	if expiresAt == nil {
@@ -0,0 +1,103 @@
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestObserveModeStoresWouldBlockAlert(t *testing.T) {
+	config := &models.Config{Mode: models.ModeObserve}
+	provider := NewMockProvider(config, nil)
+	defer provider.Notifier().Close()
+
+	err := provider.FirewallManager().BlockAttempt("203.0.113.5", time.Hour, "5 failed logins", "RDP", models.SeverityHigh)
+	if err != nil {
+		t.Fatalf("BlockAttempt: %v", err)
+	}
+
+	if blocked, _ := provider.IsBlocked("203.0.113.5"); blocked {
+		t.Error("observe mode reached the simulated firewall")
+	}
+	if len(provider.firewallRules) != 0 {
+		t.Errorf("observe mode created %d firewall rules", len(provider.firewallRules))
+	}
+
+	alerts, err := provider.Storage().GetAlerts(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != models.AlertWouldBlock || alerts[0].IP != "203.0.113.5" || alerts[0].Service != "RDP" {
+		t.Errorf("stored alerts %+v, want one would_block alert for 203.0.113.5", alerts)
+	}
+}
+
+func TestReconcileRepairsRecordCopies(t *testing.T) {
+	provider := NewMockProvider(&models.Config{}, nil)
+	defer provider.Notifier().Close()
+
+	if err := provider.BlockIP("203.0.113.5", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+	// Drop the rule behind the provider's back so reconciliation restores it
+	provider.mu.Lock()
+	for _, rule := range provider.firewallRules {
+		rule.IsActive = false
+	}
+	provider.mu.Unlock()
+
+	// Blocks placed while reconciling must not race with the repairs (run with -race)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+			provider.BlockIP(ip, time.Hour, "concurrent")
+		}
+	}()
+	report, err := provider.Reconcile(false)
+	<-done
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if report.Fixed() == 0 {
+		t.Fatalf("no drift repaired: %+v", report.Drifts)
+	}
+
+	for _, record := range provider.BlockRecords() {
+		if record.IP == "203.0.113.5" {
+			record.RuleName = "changed by caller"
+		}
+	}
+	provider.mu.RLock()
+	defer provider.mu.RUnlock()
+	record := provider.blockedIPs["203.0.113.5"]
+	if rule, exists := provider.firewallRules[record.RuleName]; !exists || !rule.IsActive {
+		t.Errorf("record names rule %q, which is not active", record.RuleName)
+	}
+}
+
+func TestReconcileKeepsOrphanRulesWithoutPersistentStorage(t *testing.T) {
+	for _, storageType := range []string{"memory", "json"} {
+		config := &models.Config{Storage: models.StorageConfig{Type: storageType}}
+		provider := NewMockProvider(config, nil)
+
+		// A rule left by an earlier run whose block record was not kept
+		provider.firewallRules["Guardian - earlier - 203.0.113.5"] = &FirewallRule{IP: "203.0.113.5", IsActive: true}
+
+		report, err := provider.Reconcile(false)
+		provider.Notifier().Close()
+		if err != nil {
+			t.Fatalf("%s: Reconcile: %v", storageType, err)
+		}
+		if len(report.Drifts) != 1 || report.Drifts[0].Kind != firewall.DriftOrphanRule {
+			t.Fatalf("%s: drifts = %+v, want one orphan_rule", storageType, report.Drifts)
+		}
+
+		removed := !provider.firewallRules["Guardian - earlier - 203.0.113.5"].IsActive
+		if want := storageType == "json"; removed != want || report.Drifts[0].Fixed != want {
+			t.Errorf("%s: orphan removed = %v, fixed = %v; want %v", storageType, removed, report.Drifts[0].Fixed, want)
+		}
+	}
+}
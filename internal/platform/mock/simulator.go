@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/notify"
 	"github.com/sr-tamim/guardian/pkg/logger"
 	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
 )
 
 // simulateWindowsSecurityEvents generates fake Windows Security Event Log entries
@@ -22,6 +25,9 @@ func (m *MockProvider) simulateWindowsSecurityEvents(ctx context.Context, events
 		"172.16.1.200",
 		"203.0.113.15",
 		"198.51.100.23",
+		"2001:db8:85a3::8a2e:370:7334", // IPv6 attacker rotating through its /64
+		"2001:db8:85a3::1f",
+		"::ffff:203.0.113.77", // IPv4-mapped, treated as IPv4
 	}
 
 	// Common usernames attackers try
@@ -34,6 +40,10 @@ func (m *MockProvider) simulateWindowsSecurityEvents(ctx context.Context, events
 		"root",
 	}
 
+	// Sub Status codes and logon types seen in real 4625 events
+	subStatuses := []string{"0xC0000064", "0xC000006A", "0xC000006A", "0xC0000234"}
+	logonTypes := []int{3, 3, 10, 10, 2}
+
 	ticker := time.NewTicker(2 * time.Second) // Generate attack every 2 seconds
 	defer ticker.Stop()
 
@@ -47,7 +57,8 @@ func (m *MockProvider) simulateWindowsSecurityEvents(ctx context.Context, events
 			username := usernames[rand.Intn(len(usernames))]
 
 			// This mimics the Windows Event Log message format that your PowerShell script parses
-			eventMessage := m.generateWindowsSecurityEventMessage(ip, username)
+			eventMessage := m.generateWindowsSecurityEventMessage(ip, username,
+				subStatuses[rand.Intn(len(subStatuses))], logonTypes[rand.Intn(len(logonTypes))])
 
 			event := core.LogEvent{
 				Timestamp: time.Now(),
@@ -56,25 +67,67 @@ func (m *MockProvider) simulateWindowsSecurityEvents(ctx context.Context, events
 				Service:   "RDP",
 			}
 
-			select {
-			case events <- event:
-				m.mu.Lock()
-				m.totalAttacks++
-				m.mu.Unlock()
-				fmt.Printf("🚨 [MOCK] Generated Windows Security Event: Failed RDP logon from %s (user: %s)\n", ip, username)
-
-				// Use structured logging for attack attempts if configured
-				logger.LogAttackAttempt(m.config, ip, "RDP", username, "medium")
-			default:
-				// Channel is full, skip
+			// A caller's channel gets every event; a slow reader slows the simulation rather than losing events
+			if events != nil {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			m.mu.Lock()
+			m.totalAttacks++
+			m.mu.Unlock()
+			severity := models.SeverityMedium.String()
+			if attempt, err := m.parseWindowsSecurityEvent(eventMessage); err == nil {
+				assessment := m.detector.AnalyzeAttack(attempt)
+				severity = assessment.Severity.String()
+				fmt.Printf("🚨 [MOCK] Generated Windows Security Event: Failed RDP logon from %s (user: %s, %s, confidence %.0f%%: %s)\n",
+					ip, username, severity, assessment.Confidence*100, assessment.Reason)
+				m.notifier.Notify(notify.NewEvent(models.EventAttackDetected, "RDP", ip, assessment.Severity,
+					fmt.Sprintf("Failed RDP logon from %s (user %s): %s", ip, username, assessment.Reason)))
+				m.observePatterns(attempt)
 			}
+
+			// Use structured logging for attack attempts if configured
+			logger.LogAttackAttempt(m.config, ip, "RDP", username, severity)
 		}
 	}
 }
 
+// observePatterns runs the spraying and stuffing rules on a simulated attempt
+// Spraying blocks the address in the simulated firewall; stuffing only alerts
+func (m *MockProvider) observePatterns(attempt *models.AttackAttempt) {
+	for _, alert := range m.detector.Observe(attempt) {
+		fmt.Printf("⚠️  [MOCK] %s\n", alert.Message)
+		m.raiseAlert(alert)
+
+		if alert.Kind != models.AlertPasswordSpraying {
+			continue
+		}
+		key, err := m.config.Blocking.AggregationKey(alert.IP)
+		if err != nil {
+			continue
+		}
+		err = m.firewall.BlockAttempt(key, m.config.Blocking.BlockDuration, alert.Message, alert.Service, alert.Severity)
+		if err != nil && !core.IsErrorCode(err, core.ErrProtectionPaused) {
+			logger.Warn("Failed to block spraying address", "ip", key, "error", err)
+		}
+	}
+}
+
+// raiseAlert stores an alert and hands it to the notifiers
+func (m *MockProvider) raiseAlert(alert *models.Alert) {
+	if err := m.store.SaveAlert(alert); err != nil {
+		logger.Warn("Failed to store detection alert", "kind", alert.Kind, "error", err)
+	}
+	m.notifier.Notify(alert)
+}
+
 // generateWindowsSecurityEventMessage creates a realistic Windows Event Log message
 // This matches the format that your PowerShell regex parses: "Source Network Address:\s+([\d\.]+)"
-func (m *MockProvider) generateWindowsSecurityEventMessage(ip, username string) string {
+func (m *MockProvider) generateWindowsSecurityEventMessage(ip, username, subStatus string, logonType int) string {
 	// This is a simplified version of a real Windows Security Event 4625 message
 	return fmt.Sprintf(`Event ID: 4625
 Task Category: Logon
@@ -87,7 +140,7 @@ Subject:
 	Account Domain: -
 	Logon ID: 0x0
 
-Logon Type: 3
+Logon Type: %d
 
 Account For Which Logon Failed:
 	Security ID: S-1-0-0
@@ -97,7 +150,7 @@ Account For Which Logon Failed:
 Failure Information:
 	Failure Reason: Unknown user name or bad password.
 	Status: 0xC000006D
-	Sub Status: 0xC000006A
+	Sub Status: %s
 
 Process Information:
 	Caller Process ID: 0x0
@@ -113,7 +166,7 @@ Detailed Authentication Information:
 	Authentication Package: NTLM
 	Transited Services: -
 	Package Name (NTLM only): -
-	Key Length: 0`, username, ip)
+	Key Length: 0`, logonType, username, subStatus, ip)
 }
 
 // startCleanupScheduler runs periodic cleanup of expired blocks
@@ -131,6 +184,8 @@ func (m *MockProvider) startCleanupScheduler(ctx context.Context) {
 			return
 		case <-ticker.C:
 			m.cleanupExpiredBlocks()
+			// Lift whatever the sweep missed and release the manager's expired blocks
+			m.firewall.Cleanup()
 		}
 	}
 }
@@ -155,14 +210,19 @@ func (m *MockProvider) cleanupExpiredBlocks() {
 				rule.IsActive = false
 
 				// Update corresponding block record
-				if blockRecord, exists := m.blockedIPs[rule.IP]; exists {
+				if blockRecord, exists := m.blockedIPs[rule.IP]; exists && blockRecord.RuleName == ruleName {
 					blockRecord.IsActive = false
 					blockRecord.UnblockedAt = &currentTime
+					if err := m.store.UpdateBlock(blockRecord); err != nil {
+						logger.Warn("Failed to persist expired block", "ip", rule.IP, "error", err)
+					}
 				}
 
 				removedCount++
 				fmt.Printf("🧹 [MOCK] Removed expired rule: %s (was active for %v)\n",
 					ruleName, elapsed.Truncate(time.Second))
+				m.notifier.Notify(notify.NewEvent(models.EventIPUnblocked, "", rule.IP, models.SeverityLow,
+					fmt.Sprintf("Block on %s expired after %s", rule.IP, elapsed.Truncate(time.Second))))
 			}
 		}
 	}
@@ -178,31 +238,45 @@ func (m *MockProvider) cleanupExpiredBlocks() {
 // parseWindowsSecurityEvent simulates parsing a Windows Security Event
 // Uses the same regex pattern as your PowerShell script
 func (m *MockProvider) parseWindowsSecurityEvent(eventMessage string) (*models.AttackAttempt, error) {
-	// This is the exact regex from your PowerShell script!
-	ipRegex := regexp.MustCompile(`Source Network Address:\s+([\d\.]+)`)
-	usernameRegex := regexp.MustCompile(`Account Name:\s+([^\r\n]+)`)
+	// The regex from your PowerShell script, widened to IPv6 and IPv4-mapped addresses
+	ipRegex := regexp.MustCompile(`Source Network Address:\s+([0-9A-Fa-f:\.%\[\]]+)`)
+	usernameRegex := regexp.MustCompile(`Account For Which Logon Failed:\s+Security ID:[^\n]*\n\s+Account Name:\s+([^\r\n]+)`)
+	subStatusRegex := regexp.MustCompile(`Sub Status:\s+(0x[0-9A-Fa-f]+)`)
+	logonTypeRegex := regexp.MustCompile(`Logon Type:\s+(\d+)`)
 
 	ipMatches := ipRegex.FindStringSubmatch(eventMessage)
 	if len(ipMatches) < 2 {
 		return nil, fmt.Errorf("could not extract IP address from event")
 	}
 
+	sourceIP, err := utils.NormalizeIP(ipMatches[1])
+	if err != nil {
+		return nil, err
+	}
+
 	usernameMatches := usernameRegex.FindStringSubmatch(eventMessage)
 	username := "unknown"
 	if len(usernameMatches) >= 2 {
 		username = usernameMatches[1]
 	}
 
-	return &models.AttackAttempt{
+	attempt := &models.AttackAttempt{
 		Timestamp: time.Now(),
-		IP:        ipMatches[1],
+		IP:        sourceIP,
 		Service:   "RDP",
 		Username:  username,
 		Message:   eventMessage,
-		Severity:  models.SeverityMedium,
-		Source:    "Security", // Windows Event Log
+		Severity:  models.SeverityLow, // raised by the detector's assessment
+		Source:    "Security",         // Windows Event Log
 		Blocked:   false,
-	}, nil
+	}
+	if matches := subStatusRegex.FindStringSubmatch(eventMessage); len(matches) >= 2 {
+		attempt.FailureCode = matches[1]
+	}
+	if matches := logonTypeRegex.FindStringSubmatch(eventMessage); len(matches) >= 2 {
+		attempt.LogonType, _ = strconv.Atoi(matches[1])
+	}
+	return attempt, nil
 }
 
 // GetStatistics returns current mock statistics
@@ -221,6 +295,7 @@ func (m *MockProvider) GetStatistics() (*models.Statistics, error) {
 		TotalAttacks:      m.totalAttacks,
 		BlockedIPs:        m.totalBlocks,
 		ActiveBlocks:      activeBlocks,
+		Evictions:         m.firewall.Evictions(),
 		ServicesMonitored: 1, // RDP
 		UptimeSeconds:     int64(time.Since(m.startTime).Seconds()),
 		LastActivity:      time.Now(),
@@ -6,16 +6,20 @@ package windows
 import (
 	"context"
 	"fmt"
-	"net"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/internal/detector"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/notify"
 	"github.com/sr-tamim/guardian/internal/parser"
+	"github.com/sr-tamim/guardian/internal/queue"
 	"github.com/sr-tamim/guardian/pkg/logger"
 	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
 )
 
 // WindowsProvider implements PlatformProvider for Windows systems
@@ -33,25 +37,77 @@ type WindowsProvider struct {
 	totalAttacks int64
 	totalBlocks  int64
 
-	// Event log parser
-	eventParser *parser.WindowsEventLogParser
+	// Event log channels being monitored (one query loop per channel)
+	monitoredChannels map[string]bool
+	startBackground   sync.Once
+
+	// Block manager enforcing max_concurrent_blocks on top of BlockIP/UnblockIP
+	firewall *firewall.Manager
+
+	// Batch rule layer: many addresses per netsh rule instead of one rule per IP
+	batcher   *firewall.NetshBatcher
+	batching  bool // defer netsh writes until endBatch
+	loadRules sync.Once
+
+	// Persistent block records (rule identity survives restarts)
+	store core.Storage
+
+	// Drift detection between storage, blockedIPs and the actual firewall rules
+	reconciler *firewall.Reconciler
+
+	// Scores attempts (failure code, logon type, username diversity) and holds the whitelist
+	detector *detector.Detector
+
+	// Delivers spraying and stuffing alerts and engine events
+	notifier *notify.Dispatcher
+
+	// Newest event per channel already reported to the notifiers; the sliding window re-reads older ones
+	notifiedUntil map[string]time.Time
 
 	// Cleanup scheduler
 	stopCleanup chan struct{}
 }
 
-const guardianRuleTag = "GuardianTag=Guardian"
-
 // NewWindowsProvider creates a new Windows platform provider
-func NewWindowsProvider(config *models.Config) *WindowsProvider {
-	return &WindowsProvider{
-		name:        "Windows Provider",
-		config:      config,
-		blockedIPs:  make(map[string]*models.BlockRecord),
-		startTime:   time.Now(),
-		eventParser: parser.NewWindowsEventLogParser(),
-		stopCleanup: make(chan struct{}),
+func NewWindowsProvider(config *models.Config, store core.Storage) *WindowsProvider {
+	provider := &WindowsProvider{
+		name:              "Windows Provider",
+		config:            config,
+		store:             store,
+		blockedIPs:        make(map[string]*models.BlockRecord),
+		startTime:         time.Now(),
+		monitoredChannels: make(map[string]bool),
+		notifiedUntil:     make(map[string]time.Time),
+		stopCleanup:       make(chan struct{}),
+		batcher: firewall.NewNetshBatcher(firewall.ExecCommandExecutor{},
+			config.Blocking.BatchRulePrefix, config.Blocking.BatchRuleSize),
 	}
+	provider.firewall = firewall.NewManager(config, provider)
+	provider.reconciler = firewall.NewReconciler(provider, store, provider.firewall)
+	provider.firewall.SetObserver(func(record *models.BlockRecord) {
+		provider.raiseAlert(notify.NewWouldBlock(record))
+	})
+	provider.detector = detector.New(config)
+	overflow := config.Monitoring.Overflow
+	provider.notifier = notify.NewDispatcher(
+		queue.FromConfig("alerts", notify.QueueSize, overflow.Alerts, queue.PolicyDropOldest, overflow),
+		notify.Notifiers(config, store)...)
+	return provider
+}
+
+// FirewallManager returns the block manager wrapping this provider's firewall
+func (w *WindowsProvider) FirewallManager() *firewall.Manager {
+	return w.firewall
+}
+
+// Storage returns the store holding block records, attempts and alerts
+func (w *WindowsProvider) Storage() core.Storage {
+	return w.store
+}
+
+// Notifier returns the dispatcher delivering detection alerts
+func (w *WindowsProvider) Notifier() *notify.Dispatcher {
+	return w.notifier
 }
 
 // Name returns the provider name
@@ -87,40 +143,63 @@ func (w *WindowsProvider) RequirementsCheck() error {
 
 // BlockIP creates a Windows Firewall rule to block an IP
 // This mirrors your PowerShell script's New-NetFirewallRule command
+// The target may be a single IPv4/IPv6 address or an aggregated CIDR network
 func (w *WindowsProvider) BlockIP(ip string, duration time.Duration, reason string) error {
+	return w.BlockAttributed(ip, duration, reason, "", models.SeverityMedium)
+}
+
+// BlockAttributed blocks an address on behalf of a service; the severity is stored and notified
+func (w *WindowsProvider) BlockAttributed(ip string, duration time.Duration, reason, service string, severity models.Severity) error {
+	if service == "" {
+		service = "RDP"
+	}
 	w.mu.Lock()
-	defer w.mu.Unlock()
+	alert, err := w.blockLocked(ip, duration, reason, service, severity)
+	w.mu.Unlock()
 
-	// Validate IP address
-	if net.ParseIP(ip) == nil {
-		return core.NewError(core.ErrInvalidIP, "invalid IP address", nil)
+	// Notifiers may block, so the alert is dispatched once the lock is released
+	if alert != nil {
+		w.notifier.Notify(alert)
 	}
+	return err
+}
+
+// blockLocked adds the rule and record for a block and returns the alert to send for it
+// Must be called with w.mu held
+func (w *WindowsProvider) blockLocked(ip string, duration time.Duration, reason, service string, severity models.Severity) (*models.Alert, error) {
+	// Validate and canonicalize the address so records key on a single form
+	target, err := utils.NormalizeBlockTarget(ip)
+	if err != nil {
+		return nil, core.NewError(core.ErrInvalidIP, "invalid IP address", err)
+	}
+	ip = target
+	family := utils.TargetFamily(ip)
 
 	// Check if already blocked
 	if existing, exists := w.blockedIPs[ip]; exists && existing.IsActive {
-		return core.NewError(core.ErrIPAlreadyBlocked, fmt.Sprintf("IP %s is already blocked", ip), nil)
+		return nil, core.NewError(core.ErrIPAlreadyBlocked, fmt.Sprintf("IP %s is already blocked", ip), nil)
 	}
 
-	// Check firewall for existing Guardian rule (avoid duplicate rules after restarts)
-	if w.guardianRuleExists(ip) {
-		return core.NewError(core.ErrIPAlreadyBlocked, fmt.Sprintf("IP %s is already blocked (firewall rule exists)", ip), nil)
+	// Check batch rules loaded from the firewall (avoid duplicates after restarts)
+	if w.batcher.Contains(ip) {
+		return nil, core.NewError(core.ErrIPAlreadyBlocked, fmt.Sprintf("IP %s is already blocked (firewall rule exists)", ip), nil)
 	}
 
-	// Generate rule name using the configurable template (like your PS script)
-	ruleName := w.config.Blocking.GenerateRuleName(ip, "RDP")
-
-	// Create Windows Firewall rule using netsh (PowerShell equivalent)
+	// Add the address to a batch rule; the rule's remoteip list is rewritten by one netsh call
 	// Your script uses: New-NetFirewallRule -DisplayName $RuleName -Direction Inbound -RemoteAddress $IPAddr -Action Block
-	cmd := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
-		fmt.Sprintf("name=%s", ruleName),
-		"dir=in",
-		"action=block",
-		fmt.Sprintf("remoteip=%s", ip),
-		fmt.Sprintf("description=Guardian IPS - Blocked due to failed login attempts (%s)", guardianRuleTag))
-
-	if err := cmd.Run(); err != nil {
-		return core.NewError(core.ErrFirewallOperation,
-			fmt.Sprintf("failed to create firewall rule for %s", ip), err)
+	ruleName, err := w.batcher.Add(ip)
+	if err != nil {
+		return notify.NewEvent(models.EventFirewallError, "", ip, models.SeverityHigh,
+			fmt.Sprintf("Failed to create firewall rule for %s: %v", ip, err)), err
+	}
+	if !w.batching {
+		if err := w.batcher.FlushRule(ruleName); err != nil {
+			w.batcher.Remove(ip)
+			alert := notify.NewEvent(models.EventFirewallError, "", ip, models.SeverityHigh,
+				fmt.Sprintf("Failed to create firewall rule for %s: %v", ip, err))
+			return alert, core.NewError(core.ErrFirewallOperation,
+				fmt.Sprintf("failed to create firewall rule for %s", ip), err)
+		}
 	}
 
 	// Calculate expiration time
@@ -133,25 +212,38 @@ func (w *WindowsProvider) BlockIP(ip string, duration time.Duration, reason stri
 	// Create block record
 	blockRecord := &models.BlockRecord{
 		IP:          ip,
+		Family:      family,
 		BlockedAt:   time.Now(),
 		ExpiresAt:   expiresAt,
 		Reason:      reason,
-		Service:     "RDP",
+		Service:     service,
+		Severity:    severity,
 		AttackCount: 1,
 		IsActive:    true,
+		RuleName:    ruleName, // stored so removal never has to regenerate the name
 	}
 	w.blockedIPs[ip] = blockRecord
 	w.totalBlocks++
 
+	if err := w.store.SaveBlock(blockRecord); err != nil {
+		logger.Warn("Failed to persist block record", "ip", ip, "error", err)
+	}
+
 	// Use structured logging for firewall action
 	logger.LogIPBlocked(w.config, ip, reason, ruleName, duration)
+	until := "permanently"
+	if expiresAt != nil {
+		until = "until " + expiresAt.Format(time.DateTime)
+	}
 	logger.Info("IP blocked with Windows Firewall",
 		"ip", ip,
+		"family", family,
 		"rule", ruleName,
 		"reason", reason,
 		"duration", duration)
 
-	return nil
+	return notify.NewEvent(models.EventIPBlocked, service, ip, severity,
+		fmt.Sprintf("Blocked %s %s: %s", ip, until, reason)), nil
 }
 
 // UnblockIP removes a Windows Firewall rule
@@ -160,19 +252,20 @@ func (w *WindowsProvider) UnblockIP(ip string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if target, err := utils.NormalizeBlockTarget(ip); err == nil {
+		ip = target
+	}
+
 	blockRecord, exists := w.blockedIPs[ip]
 	if !exists || !blockRecord.IsActive {
 		return core.NewError(core.ErrIPNotBlocked, fmt.Sprintf("IP %s is not blocked", ip), nil)
 	}
 
-	// Find the rule name (we stored it in our records)
-	ruleName := w.config.Blocking.GenerateRuleName(ip, "RDP")
-
-	// Remove Windows Firewall rule
-	cmd := exec.Command("netsh", "advfirewall", "firewall", "delete", "rule",
-		fmt.Sprintf("name=%s", ruleName))
-
-	if err := cmd.Run(); err != nil {
+	// Remove the rule using the identity stored when the block was created
+	ruleName := blockRecord.RuleName
+	if err := w.removeRuleEntry(ip, ruleName); err != nil {
+		w.notifier.Notify(notify.NewEvent(models.EventFirewallError, "", ip, models.SeverityHigh,
+			fmt.Sprintf("Failed to remove firewall rule %s for %s: %v", ruleName, ip, err)))
 		return core.NewError(core.ErrFirewallOperation,
 			fmt.Sprintf("failed to remove firewall rule for %s", ip), err)
 	}
@@ -183,8 +276,14 @@ func (w *WindowsProvider) UnblockIP(ip string) error {
 	blockRecord.UnblockedAt = &now
 	activeTime := now.Sub(blockRecord.BlockedAt)
 
+	if err := w.store.UpdateBlock(blockRecord); err != nil {
+		logger.Warn("Failed to persist unblock", "ip", ip, "error", err)
+	}
+
 	// Use structured logging for firewall action
 	logger.LogIPUnblocked(w.config, ip, ruleName, activeTime)
+	w.notifier.Notify(notify.NewEvent(models.EventIPUnblocked, "", ip, models.SeverityLow,
+		fmt.Sprintf("Unblocked %s after %s", ip, activeTime.Truncate(time.Second))))
 	logger.Info("IP unblocked from Windows Firewall",
 		"ip", ip,
 		"rule", ruleName,
@@ -193,22 +292,29 @@ func (w *WindowsProvider) UnblockIP(ip string) error {
 	return nil
 }
 
-// IsBlocked checks if an IP is currently blocked
+// IsBlocked checks if an IP is currently blocked, either directly or by an aggregated network block
 func (w *WindowsProvider) IsBlocked(ip string) (bool, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	blockRecord, exists := w.blockedIPs[ip]
-	if !exists {
-		return false, nil
+	if target, err := utils.NormalizeBlockTarget(ip); err == nil {
+		ip = target
 	}
 
-	// Check if expired
-	if blockRecord.ExpiresAt != nil && time.Now().After(*blockRecord.ExpiresAt) {
-		return false, nil
+	for target, blockRecord := range w.blockedIPs {
+		if target != ip && !utils.TargetContains(target, ip) {
+			continue
+		}
+		// Check if expired
+		if blockRecord.ExpiresAt != nil && time.Now().After(*blockRecord.ExpiresAt) {
+			continue
+		}
+		if blockRecord.IsActive {
+			return true, nil
+		}
 	}
 
-	return blockRecord.IsActive, nil
+	return false, nil
 }
 
 // ListBlockedIPs returns all currently blocked IPs
@@ -230,40 +336,139 @@ func (w *WindowsProvider) ListBlockedIPs() ([]string, error) {
 }
 
 // GetLogPaths returns Windows Event Log paths
+// Event log services use their log_path channel (Security by default)
 func (w *WindowsProvider) GetLogPaths(service string) ([]string, error) {
+	for _, configured := range w.config.Services {
+		if !strings.EqualFold(configured.Name, service) {
+			continue
+		}
+		if _, err := parser.ServiceEventIDs(w.eventService(configured)); err == nil {
+			return []string{w.eventService(configured).LogPath}, nil
+		}
+	}
+
 	switch strings.ToLower(service) {
 	case "rdp", "windows":
-		return []string{"Security"}, nil // Windows Event Log name
+		return []string{parser.ChannelSecurity}, nil // Windows Event Log name
 	default:
 		return []string{}, fmt.Errorf("unsupported service: %s", service)
 	}
 }
 
-// StartLogMonitoring begins monitoring Windows Event Log
+// eventService fills in the Security channel for event log services without a log_path
+func (w *WindowsProvider) eventService(service models.ServiceConfig) models.ServiceConfig {
+	if service.LogPath == "" {
+		service.LogPath = parser.ChannelSecurity
+	}
+	return service
+}
+
+// channelParsers creates the event parsers of the enabled services reading a channel
+// Without a matching service the channel falls back to 4625 for RDP (Security only)
+func (w *WindowsProvider) channelParsers(channel string) ([]*parser.WindowsEventLogParser, error) {
+	var parsers []*parser.WindowsEventLogParser
+	for _, service := range w.config.Services {
+		service = w.eventService(service)
+		if !service.Enabled || !strings.EqualFold(service.LogPath, channel) {
+			continue
+		}
+		if _, err := parser.ServiceEventIDs(service); err != nil {
+			continue // not an event log service
+		}
+		eventParser, err := parser.NewWindowsEventLogParser(service)
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, eventParser)
+	}
+
+	if len(parsers) == 0 && strings.EqualFold(channel, parser.ChannelSecurity) {
+		eventParser, err := parser.NewWindowsEventLogParser(models.ServiceConfig{Name: "RDP", LogPath: channel, LogPattern: "4625"})
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, eventParser)
+	}
+	if len(parsers) == 0 {
+		return nil, fmt.Errorf("no enabled service with event_ids reads channel %s", channel)
+	}
+	return parsers, nil
+}
+
+// StartLogMonitoring begins monitoring a Windows Event Log channel
 // This mimics your PowerShell script's Get-WinEvent -FilterHashtable approach
 func (w *WindowsProvider) StartLogMonitoring(ctx context.Context, logPath string, events chan<- core.LogEvent) error {
+	parsers, err := w.channelParsers(logPath)
+	if err != nil {
+		return err
+	}
+
 	w.mu.Lock()
 	w.isRunning = true
+	// Services sharing a channel are served by one query loop
+	alreadyMonitored := w.monitoredChannels[strings.ToLower(logPath)]
+	w.monitoredChannels[strings.ToLower(logPath)] = true
 	w.mu.Unlock()
+	if alreadyMonitored {
+		return nil
+	}
+
+	// Import existing batch rules before the first scan so restarts don't duplicate them
+	w.StartMaintenance(ctx)
 
 	// Use structured logging for monitoring events
-	logger.LogMonitoringStart(w.config, "RDP", logPath, "WindowsProvider")
-	logger.Info("Started monitoring Windows Event Log for RDP failures",
+	for _, eventParser := range parsers {
+		logger.LogMonitoringStart(w.config, eventParser.ServiceName(), logPath, "WindowsProvider")
+	}
+	logger.Info("Started monitoring Windows Event Log",
 		"logPath", logPath,
+		"eventIDs", eventIDFilter(parsers),
 		"provider", "WindowsProvider")
 
 	// Start the event monitoring goroutine
-	go w.monitorWindowsEventLog(ctx, events)
-
-	// Start cleanup scheduler (like your PowerShell script's Remove-ExpiredRules)
-	go w.startCleanupScheduler(ctx)
+	go w.monitorWindowsEventLog(ctx, logPath, parsers, events)
 
 	return nil
 }
 
-// monitorWindowsEventLog monitors Windows Security Event Log for Event ID 4625
+// StartMaintenance loads existing rules and starts expiry cleanup, reconciliation, the mode watcher and notifier schedules once
+// Event log monitoring calls it; services read by the log monitor need it without a channel
+func (w *WindowsProvider) StartMaintenance(ctx context.Context) {
+	w.ensureRulesLoaded()
+
+	w.startBackground.Do(func() {
+		// Start cleanup scheduler (like your PowerShell script's Remove-ExpiredRules)
+		go w.startCleanupScheduler(ctx)
+
+		// Reconciliation lists every firewall rule, so it runs far less often than cleanup
+		go w.reconciler.Run(ctx, w.config.Blocking.ReconcileInterval)
+
+		// The CLI, TUI and tray switch modes through the mode file
+		go w.firewall.WatchMode(ctx, firewall.ModePath())
+
+		// Scheduled notifications (the email digest) run in the monitoring process only
+		w.notifier.Start(ctx)
+	})
+}
+
+// eventIDFilter builds the XPath EventID condition for all parsers of a channel
+func eventIDFilter(parsers []*parser.WindowsEventLogParser) string {
+	var conditions []string
+	seen := make(map[int]bool)
+	for _, eventParser := range parsers {
+		for _, id := range eventParser.EventIDs() {
+			if !seen[id] {
+				seen[id] = true
+				conditions = append(conditions, fmt.Sprintf("EventID=%d", id))
+			}
+		}
+	}
+	return "(" + strings.Join(conditions, " or ") + ")"
+}
+
+// monitorWindowsEventLog monitors a Windows Event Log channel for the configured event IDs
 // This is the Go equivalent of your PowerShell Get-WinEvent command
-func (w *WindowsProvider) monitorWindowsEventLog(ctx context.Context, events chan<- core.LogEvent) {
+func (w *WindowsProvider) monitorWindowsEventLog(ctx context.Context, channel string, parsers []*parser.WindowsEventLogParser, events chan<- core.LogEvent) {
 	// Use configurable check interval from configuration
 	checkInterval := w.config.Monitoring.CheckInterval
 	if checkInterval <= 0 {
@@ -287,8 +492,9 @@ func (w *WindowsProvider) monitorWindowsEventLog(ctx context.Context, events cha
 
 	// Log startup information
 	logger.Info("Windows Event Log monitoring started",
+		"channel", channel,
 		"checkInterval", checkInterval.String(),
-		"eventID", "4625",
+		"eventIDs", eventIDFilter(parsers),
 		"lookbackDuration", lookbackDuration.String(),
 		"initialLookback", lastEventTime.Format("2006-01-02 15:04:05"))
 
@@ -301,7 +507,7 @@ func (w *WindowsProvider) monitorWindowsEventLog(ctx context.Context, events cha
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Info("Windows Event Log monitoring stopped")
+			logger.Info("Windows Event Log monitoring stopped", "channel", channel)
 			return
 		case <-ticker.C:
 			// Use sliding window approach - always look back the full configured duration
@@ -310,19 +516,20 @@ func (w *WindowsProvider) monitorWindowsEventLog(ctx context.Context, events cha
 			windowStart := currentTime.Add(-lookbackDuration)
 
 			logger.Info("Checking Windows Event Log with sliding window",
+				"channel", channel,
 				"windowStart", windowStart.Format("15:04:05"),
 				"windowEnd", currentTime.Format("15:04:05"),
 				"windowDuration", lookbackDuration.String(),
 				"timezone", currentTime.Location().String())
 
-			w.queryRecentEvents(windowStart, lookbackDuration, events)
+			w.queryRecentEvents(ctx, channel, parsers, windowStart, lookbackDuration, events)
 		}
 	}
 }
 
-// queryRecentEvents queries Windows Event Log for recent failed logon events
-// Equivalent to your PowerShell: Get-WinEvent -FilterHashtable @{LogName='Security'; ID=4625}
-func (w *WindowsProvider) queryRecentEvents(since time.Time, windowDuration time.Duration, events chan<- core.LogEvent) {
+// queryRecentEvents queries a Windows Event Log channel for recent failure events
+// Equivalent to your PowerShell: Get-WinEvent -FilterHashtable @{LogName='Security'; ID=4625,4771}
+func (w *WindowsProvider) queryRecentEvents(ctx context.Context, channel string, parsers []*parser.WindowsEventLogParser, since time.Time, windowDuration time.Duration, events chan<- core.LogEvent) {
 	// Windows Event Log @SystemTime queries require UTC format with Z suffix
 	// This was confirmed by manual testing: UTC works, local time doesn't
 	sinceUTC := since.UTC().Format("2006-01-02T15:04:05.000Z")
@@ -332,15 +539,16 @@ func (w *WindowsProvider) queryRecentEvents(since time.Time, windowDuration time
 
 	// Use UTC format (this is what @SystemTime expects)
 	sinceStr := sinceUTC
+	idFilter := eventIDFilter(parsers)
 
-	cmd := exec.Command("wevtutil", "qe", "Security",
-		"/q:*[System[EventID=4625 and TimeCreated[@SystemTime>='"+sinceStr+"']]]",
+	cmd := exec.Command("wevtutil", "qe", channel,
+		"/q:*[System["+idFilter+" and TimeCreated[@SystemTime>='"+sinceStr+"']]]",
 		"/f:text",
 		"/c:50") // Limit to 50 events per query
 
 	logger.Info("Executing Windows Event Log query",
-		"command", "wevtutil qe Security",
-		"filter", fmt.Sprintf("EventID=4625 and TimeCreated>='%s'", sinceStr),
+		"command", "wevtutil qe "+channel,
+		"filter", fmt.Sprintf("%s and TimeCreated>='%s'", idFilter, sinceStr),
 		"limit", 50,
 		"localTimeQuery", sinceLocal,
 		"utcTimeQuery", sinceUTC,
@@ -374,7 +582,7 @@ func (w *WindowsProvider) queryRecentEvents(since time.Time, windowDuration time
 		if len(sampleOutput) > 200 {
 			sampleOutput = sampleOutput[:200] + "..."
 		}
-		logger.Info("Event Log query found data",
+		logger.Debug("Event Log query found data",
 			"sampleOutput", sampleOutput,
 			"totalBytes", len(output))
 	} else {
@@ -383,29 +591,43 @@ func (w *WindowsProvider) queryRecentEvents(since time.Time, windowDuration time
 	}
 
 	// Parse the output and send events
-	w.parseEventLogOutput(string(output), windowDuration, events)
+	w.parseEventLogOutput(ctx, channel, parsers, string(output), windowDuration, events)
 }
 
 // parseEventLogOutput processes wevtutil output and creates LogEvent entries
-func (w *WindowsProvider) parseEventLogOutput(output string, windowDuration time.Duration, events chan<- core.LogEvent) {
+// Each event goes to the parser of the service monitoring its event ID
+// A caller's channel receives every event; sending waits for room rather than dropping
+func (w *WindowsProvider) parseEventLogOutput(ctx context.Context, channel string, parsers []*parser.WindowsEventLogParser, output string, windowDuration time.Duration, events chan<- core.LogEvent) {
 	// Split output into individual events
 	eventBlocks := strings.Split(output, "Event[")
 
 	logger.Info("Parsing Event Log output",
+		"channel", channel,
 		"totalBlocks", len(eventBlocks),
 		"outputLength", len(output))
 
+	// Failures are counted per service so each service's custom_threshold applies
+	type serviceKey struct{ service, key string }
+
 	parsedEvents := 0
-	rdpEvents := 0
-	attackCounts := make(map[string]int)
+	matchedEvents := 0
+	attackCounts := make(map[serviceKey]int)
+	attackSeverity := make(map[serviceKey]models.Severity)
+	immediate := make(map[serviceKey]core.ThreatAssessment)
+	spraying := make(map[serviceKey]*models.Alert)
 	uniqueIPs := make(map[string]struct{})
+	fresh := make(map[serviceKey]bool) // counters with events not reported in an earlier cycle
+	w.mu.RLock()
+	notifiedUntil := w.notifiedUntil[channel]
+	w.mu.RUnlock()
+	newest := notifiedUntil
 	for i, eventBlock := range eventBlocks {
 		if strings.TrimSpace(eventBlock) == "" {
 			continue
 		}
 
-		// Log each event block for debugging
-		logger.Info("Processing event block",
+		// Per-event details are Debug only: a brute-force run produces thousands of blocks
+		logger.Debug("Processing event block",
 			"blockNumber", i,
 			"blockLength", len(eventBlock),
 			"preview", func() string {
@@ -415,45 +637,109 @@ func (w *WindowsProvider) parseEventLogOutput(output string, windowDuration time
 				return eventBlock
 			}())
 
-		// Check if this looks like an RDP event
-		isRDP := w.eventParser.IsRDPEvent(eventBlock)
-		logger.Info("RDP event check result",
+		event, err := parser.ParseWindowsEvent(eventBlock)
+		if err != nil {
+			continue
+		}
+		var eventParser *parser.WindowsEventLogParser
+		for _, candidate := range parsers {
+			if candidate.Handles(event.ID) {
+				eventParser = candidate
+				break
+			}
+		}
+		logger.Debug("Event match result",
 			"blockNumber", i,
-			"isRDPEvent", isRDP)
+			"eventID", event.ID,
+			"monitored", eventParser != nil)
+		if eventParser == nil {
+			continue
+		}
+		matchedEvents++
 
-		if isRDP {
-			rdpEvents++
-			event := core.LogEvent{
-				Timestamp: time.Now(),
-				Source:    "Security",
+		if events != nil {
+			logEvent := core.LogEvent{
+				Timestamp: event.Timestamp,
+				Source:    channel,
 				Line:      eventBlock,
-				Service:   "RDP",
+				Service:   eventParser.ServiceName(),
+			}
+			select {
+			case events <- logEvent:
+			case <-ctx.Done():
+				return
 			}
+			w.mu.Lock()
+			w.totalAttacks++
+			w.mu.Unlock()
+			parsedEvents++
+			logger.Debug("Attack event detected and sent for processing",
+				"eventNumber", parsedEvents,
+				"totalAttacks", w.totalAttacks)
+			continue
+		}
 
-			if events != nil {
-				select {
-				case events <- event:
-					w.mu.Lock()
-					w.totalAttacks++
-					w.mu.Unlock()
-					parsedEvents++
-					logger.Info("RDP attack event detected and sent for processing",
-						"eventNumber", parsedEvents,
-						"totalAttacks", w.totalAttacks)
-				default:
-					logger.Warn("Event channel full, dropping RDP attack event")
-				}
-			} else {
-				attempt, err := w.eventParser.ParseLine(eventBlock)
-				if err != nil {
-					continue
-				}
+		attempt, err := eventParser.ParseEvent(event)
+		if err != nil || attempt == nil {
+			continue
+		}
+
+		if attempt.IP == "" {
+			// 4776/4740 name a workstation, not an address: nothing to block, but worth knowing
+			logger.Warn("Account event without a client address",
+				"service", attempt.Service,
+				"username", attempt.Username,
+				"message", attempt.Message)
+			continue
+		}
+
+		// Whitelist is checked per address, before IPv6 addresses collapse into their /64
+		if w.detector.IsWhitelisted(attempt.IP) {
+			continue
+		}
+
+		// Failure code, logon type, username class and username diversity decide the severity
+		assessment := w.detector.AnalyzeAttack(attempt)
+		attempt.Severity = assessment.Severity
+
+		key, err := w.config.Blocking.AggregationKey(attempt.IP)
+		if err != nil {
+			continue
+		}
+		counter := serviceKey{service: attempt.Service, key: key}
+		attackCounts[counter]++
+		if attempt.Severity > attackSeverity[counter] {
+			attackSeverity[counter] = attempt.Severity
+		}
+		if assessment.ShouldBlock {
+			immediate[counter] = assessment
+		}
+
+		// Cross-username rules: spraying blocks the address, stuffing only alerts
+		for _, alert := range w.detector.Observe(attempt) {
+			w.raiseAlert(alert)
+			if alert.Kind == models.AlertPasswordSpraying {
+				spraying[counter] = alert
+			}
+		}
+		uniqueIPs[attempt.IP] = struct{}{}
+
+		logger.Debug("Threat assessment",
+			"ip", attempt.IP,
+			"service", attempt.Service,
+			"confidence", fmt.Sprintf("%.2f", assessment.Confidence),
+			"severity", assessment.Severity.String(),
+			"reason", assessment.Reason)
 
-				attackCounts[attempt.IP]++
-				uniqueIPs[attempt.IP] = struct{}{}
+		logger.LogAttackAttempt(w.config, attempt.IP, attempt.Service, attempt.Username, attempt.Severity.String())
 
-				logger.LogAttackAttempt(w.config, attempt.IP, attempt.Service, attempt.Username, attempt.Severity.String())
+		if event.Timestamp.After(notifiedUntil) {
+			fresh[counter] = true
+			if event.Timestamp.After(newest) {
+				newest = event.Timestamp
 			}
+			w.notifier.Notify(notify.NewEvent(models.EventAttackDetected, attempt.Service, attempt.IP, attempt.Severity,
+				fmt.Sprintf("%s failure from %s (user %q): %s", attempt.Service, attempt.IP, attempt.Username, assessment.Reason)))
 		}
 	}
 
@@ -463,121 +749,209 @@ func (w *WindowsProvider) parseEventLogOutput(output string, windowDuration time
 			ips = append(ips, ip)
 		}
 
-		logger.LogEventLookup(w.config, "RDP", "Security", rdpEvents, ips)
-
-		threshold := w.config.Blocking.FailureThreshold
-		if threshold <= 0 {
-			threshold = 1
+		for _, eventParser := range parsers {
+			logger.LogEventLookup(w.config, eventParser.ServiceName(), channel, matchedEvents, ips)
 		}
 
-		for ip, count := range attackCounts {
-			if count < threshold {
-				continue
-			}
-			if w.isWhitelistedIP(ip) {
-				logger.Info("Skipping whitelisted IP", "ip", ip)
+		w.beginBatch()
+		for counter, count := range attackCounts {
+			reason := fmt.Sprintf("%s failure threshold exceeded: %d attempts in %s", counter.service, count, windowDuration.Truncate(time.Second))
+			if alert, found := spraying[counter]; found {
+				reason = alert.Message
+			} else if assessment, found := immediate[counter]; found {
+				reason = fmt.Sprintf("%s high-confidence attack (%.0f%%): %s", counter.service, assessment.Confidence*100, assessment.Reason)
+			} else if threshold := w.serviceThreshold(counter.service); count < threshold {
+				if fresh[counter] && count == threshold-1 && threshold > 1 {
+					w.notifier.Notify(notify.NewEvent(models.EventThresholdNear, counter.service, counter.key, attackSeverity[counter],
+						fmt.Sprintf("%s: %d of %d failures from %s within %s; the next one blocks it",
+							counter.service, count, threshold, counter.key, windowDuration.Truncate(time.Second))))
+				}
 				continue
 			}
 
-			reason := fmt.Sprintf("Failed logon threshold exceeded: %d attempts in %s", count, windowDuration.Truncate(time.Second))
-			if err := w.BlockIP(ip, w.config.Blocking.BlockDuration, reason); err != nil {
-				logger.Warn("Failed to block IP after threshold exceeded", "ip", ip, "error", err)
+			// Route through the block manager so max_concurrent_blocks is enforced
+			err := w.firewall.BlockAttempt(counter.key, w.config.Blocking.BlockDuration, reason, counter.service, attackSeverity[counter])
+			if core.IsErrorCode(err, core.ErrProtectionPaused) {
+				logger.Debug("Block skipped while protection is paused", "ip", counter.key, "service", counter.service)
+			} else if core.IsErrorCode(err, core.ErrIPAlreadyBlocked) {
+				// Attempts stay in the window after the block (or observe-mode record) that they caused
+				logger.Debug("Address already blocked", "ip", counter.key, "service", counter.service)
+			} else if err != nil {
+				logger.Warn("Failed to block IP after threshold exceeded", "ip", counter.key, "service", counter.service, "error", err)
 			}
 		}
+		w.endBatch()
+
+		w.mu.Lock()
+		w.notifiedUntil[channel] = newest
+		w.mu.Unlock()
 	}
 
 	if parsedEvents > 0 {
-		logger.Info("Parsed and processed RDP attack events",
+		logger.Info("Parsed and processed attack events",
+			"channel", channel,
 			"eventCount", parsedEvents,
 			"totalAttacks", w.totalAttacks)
-	} else if rdpEvents == 0 && len(eventBlocks) > 1 {
-		logger.Info("Event blocks found but no RDP events detected",
-			"totalBlocks", len(eventBlocks),
-			"rdpEvents", 0)
+	} else if matchedEvents == 0 && len(eventBlocks) > 1 {
+		logger.Info("Event blocks found but no monitored events detected",
+			"channel", channel,
+			"totalBlocks", len(eventBlocks))
+	}
+}
+
+// raiseAlert stores a detection alert for the dashboard and hands it to the notifiers
+func (w *WindowsProvider) raiseAlert(alert *models.Alert) {
+	if err := w.store.SaveAlert(alert); err != nil {
+		logger.Warn("Failed to store detection alert", "kind", alert.Kind, "error", err)
 	}
+	w.notifier.Notify(alert)
+}
+
+// serviceThreshold returns a service's custom_threshold, falling back to blocking.failure_threshold
+func (w *WindowsProvider) serviceThreshold(service string) int {
+	return w.config.ThresholdFor(service)
 }
 
-func (w *WindowsProvider) isWhitelistedIP(ip string) bool {
-	parsed := net.ParseIP(ip)
-	if parsed == nil {
-		return false
+// removeRuleEntry removes an address from the rule named in its block record
+// Batch rules are rewritten without the address; any other Guardian rule
+// (e.g. a per-IP rule from an older version) is deleted by its exact name
+func (w *WindowsProvider) removeRuleEntry(ip, ruleName string) error {
+	if batch, held := w.batcher.Remove(ip); held {
+		if w.batching {
+			return nil
+		}
+		if err := w.batcher.FlushRule(batch); err != nil {
+			w.batcher.Add(ip)
+			return err
+		}
+		return nil
 	}
+	if ruleName == "" || w.batcher.IsBatchRule(ruleName) {
+		// Already gone from its batch rule
+		return nil
+	}
+	return w.batcher.DeleteRule(ruleName)
+}
 
-	for _, entry := range w.config.Blocking.WhitelistedIPs {
-		candidate := strings.TrimSpace(entry)
-		if candidate == "" {
-			continue
+// ensureRulesLoaded restores persisted blocks and imports existing Guardian rules once per process
+// Must be called without w.mu held
+func (w *WindowsProvider) ensureRulesLoaded() {
+	w.loadRules.Do(func() {
+		records, err := w.store.GetActiveBlocks()
+		if err != nil {
+			logger.Warn("Failed to load persisted block records", "error", err)
 		}
-		if strings.Contains(candidate, "/") {
-			_, network, err := net.ParseCIDR(candidate)
-			if err == nil && network.Contains(parsed) {
-				return true
-			}
-			continue
+
+		w.mu.Lock()
+		for _, record := range records {
+			w.blockedIPs[record.IP] = record
 		}
-		if candidate == ip {
-			return true
+		w.mu.Unlock()
+		w.firewall.Restore(records)
+
+		rules, err := w.batcher.ListRules()
+		if err != nil {
+			logger.Warn("Failed to load existing Guardian firewall rules", "error", err)
+			return
 		}
-	}
+		w.batcher.Sync(rules)
 
-	return false
+		logger.Info("Restored Windows Firewall state",
+			"activeBlocks", len(records),
+			"batchRules", len(w.batcher.RuleNames()))
+	})
 }
 
-func (w *WindowsProvider) guardianRuleExists(ip string) bool {
-	cmd := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=all")
-	output, err := cmd.Output()
+// Reconcile compares stored blocks, provider state and the actual Guardian rules
+// With dryRun set the drift is only reported
+func (w *WindowsProvider) Reconcile(dryRun bool) (*firewall.ReconcileReport, error) {
+	w.ensureRulesLoaded()
+	return w.reconciler.Reconcile(dryRun)
+}
+
+// FirewallRules lists every address held by a Guardian-tagged rule (GuardianTag=Guardian)
+func (w *WindowsProvider) FirewallRules() ([]firewall.RuleEntry, error) {
+	rules, err := w.batcher.ListRules()
 	if err != nil {
-		logger.Warn("Failed to query firewall rules", "error", err)
-		return false
+		return nil, err
+	}
+	w.batcher.Sync(rules)
+
+	var entries []firewall.RuleEntry
+	for _, rule := range rules {
+		if !rule.IsGuardianRule() {
+			continue
+		}
+		for _, address := range rule.RemoteIPs {
+			entries = append(entries, firewall.RuleEntry{RuleName: rule.Name, Target: address})
+		}
 	}
+	return entries, nil
+}
 
-	lines := strings.Split(string(output), "\n")
-	currentHasTag := false
-	currentMatchesIP := false
+// BlockRecords returns copies of the provider's block records
+func (w *WindowsProvider) BlockRecords() []*models.BlockRecord {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 
-	checkRule := func() bool {
-		return currentHasTag && currentMatchesIP
+	records := make([]*models.BlockRecord, 0, len(w.blockedIPs))
+	for _, record := range w.blockedIPs {
+		copied := *record
+		records = append(records, &copied)
 	}
+	return records
+}
 
-	for _, raw := range lines {
-		line := strings.TrimSpace(raw)
-		if line == "" {
-			if checkRule() {
-				return true
-			}
-			currentHasTag = false
-			currentMatchesIP = false
-			continue
-		}
+// TrackRecord replaces the provider's record for an address
+func (w *WindowsProvider) TrackRecord(record *models.BlockRecord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-		lower := strings.ToLower(line)
-		if strings.HasPrefix(lower, "rule name") {
-			if checkRule() {
-				return true
-			}
-			currentHasTag = false
-			currentMatchesIP = false
-			continue
-		}
+	copied := *record
+	w.blockedIPs[record.IP] = &copied
+}
 
-		if strings.HasPrefix(lower, "description") && strings.Contains(line, guardianRuleTag) {
-			currentHasTag = true
-		}
+// RestoreRule puts an active block back into a batch rule (written by CommitRules)
+func (w *WindowsProvider) RestoreRule(record *models.BlockRecord) (string, error) {
+	return w.batcher.Add(record.IP)
+}
 
-		if strings.HasPrefix(lower, "remoteip") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				for _, candidate := range strings.Split(parts[1], ",") {
-					if strings.TrimSpace(candidate) == ip {
-						currentMatchesIP = true
-						break
-					}
-				}
-			}
-		}
+// RemoveRuleEntry takes an address out of a batch rule or deletes another Guardian rule
+func (w *WindowsProvider) RemoveRuleEntry(entry firewall.RuleEntry) error {
+	if !w.batcher.IsBatchRule(entry.RuleName) {
+		return w.batcher.DeleteRule(entry.RuleName)
 	}
+	if owner, held := w.batcher.RuleOf(entry.Target); held && owner == entry.RuleName {
+		w.batcher.Remove(entry.Target)
+	}
+	// A duplicate in another batch rule is dropped when that rule is rewritten
+	return nil
+}
+
+// CommitRules writes the batch rule changes made during reconciliation
+func (w *WindowsProvider) CommitRules() error {
+	return w.batcher.Flush()
+}
+
+// beginBatch defers netsh writes so a scan that blocks many addresses rewrites each rule once
+func (w *WindowsProvider) beginBatch() {
+	w.mu.Lock()
+	w.batching = true
+	w.mu.Unlock()
+}
+
+// endBatch writes all rule changes collected since beginBatch
+func (w *WindowsProvider) endBatch() {
+	w.mu.Lock()
+	w.batching = false
+	w.mu.Unlock()
 
-	return checkRule()
+	if err := w.batcher.Flush(); err != nil {
+		// Rules stay marked as changed, so the next flush retries them
+		logger.Error("Failed to write batched firewall rules", "error", err)
+		w.notifier.Notify(notify.NewEvent(models.EventFirewallError, "", "", models.SeverityHigh,
+			fmt.Sprintf("Failed to write batched firewall rules (retried on the next flush): %v", err)))
+	}
 }
 
 // startCleanupScheduler runs periodic cleanup like your PowerShell script
@@ -607,6 +981,8 @@ func (w *WindowsProvider) startCleanupScheduler(ctx context.Context) {
 			return
 		case <-ticker.C:
 			w.cleanupExpiredRules()
+			// Lift whatever the sweep missed and release the manager's expired blocks
+			w.firewall.Cleanup()
 		}
 	}
 }
@@ -621,45 +997,71 @@ func (w *WindowsProvider) cleanupExpiredRules() {
 	removedCount := 0
 	var removedIPs []string
 
+	// Take every expired address out of the rule stored in its record;
+	// batch rules are rewritten once after all removals
+	var expired []string
+	w.batching = true
 	for ip, record := range w.blockedIPs {
-		if record.IsActive && record.ExpiresAt != nil {
-			if currentTime.After(*record.ExpiresAt) {
-				// Remove expired rule
-				ruleName := w.config.Blocking.GenerateRuleName(ip, "RDP")
-
-				cmd := exec.Command("netsh", "advfirewall", "firewall", "delete", "rule",
-					fmt.Sprintf("name=%s", ruleName))
-
-				if err := cmd.Run(); err == nil {
-					record.IsActive = false
-					record.UnblockedAt = &currentTime
-					removedCount++
-					removedIPs = append(removedIPs, ip)
-
-					elapsed := currentTime.Sub(record.BlockedAt)
-					logger.Info("Removed expired Windows Firewall rule",
-						"ip", ip,
-						"rule", ruleName,
-						"activeTime", elapsed.Truncate(time.Second))
-				} else {
-					logger.Error("Failed to remove expired firewall rule",
-						"ip", ip,
-						"rule", ruleName,
-						"error", err)
-				}
+		if !record.IsActive || record.ExpiresAt == nil || !currentTime.After(*record.ExpiresAt) {
+			continue
+		}
+		if err := w.removeRuleEntry(ip, record.RuleName); err != nil {
+			logger.Error("Failed to remove expired firewall rule",
+				"ip", ip,
+				"rule", record.RuleName,
+				"error", err)
+			w.notifier.Notify(notify.NewEvent(models.EventFirewallError, "", ip, models.SeverityHigh,
+				fmt.Sprintf("Failed to remove expired firewall rule %s for %s: %v", record.RuleName, ip, err)))
+			continue
+		}
+		expired = append(expired, ip)
+	}
+	w.batching = false
+	if len(expired) == 0 {
+		logger.Debug("Cleanup operation completed - no expired rules found")
+		return
+	}
+
+	if err := w.batcher.Flush(); err != nil {
+		// Put the addresses back so the next cleanup retries them
+		for _, ip := range expired {
+			if w.batcher.IsBatchRule(w.blockedIPs[ip].RuleName) {
+				w.batcher.Add(ip)
 			}
 		}
+		logger.Error("Failed to remove expired firewall rules",
+			"ips", expired,
+			"error", err)
+		w.notifier.Notify(notify.NewEvent(models.EventFirewallError, "", "", models.SeverityHigh,
+			fmt.Sprintf("Failed to remove expired firewall rules for %s: %v", strings.Join(expired, ", "), err)))
+		return
 	}
 
-	if removedCount > 0 {
-		logger.Info("Cleanup operation completed",
-			"removedRules", removedCount,
-			"totalBlocked", len(w.blockedIPs),
-			"removedIPs", removedIPs)
+	for _, ip := range expired {
+		record := w.blockedIPs[ip]
+		record.IsActive = false
+		record.UnblockedAt = &currentTime
+		removedCount++
+		removedIPs = append(removedIPs, ip)
 
-		// Use structured logging for cleanup events
-		logger.LogCleanupOperation(w.config, removedCount, len(w.blockedIPs))
-	} else {
-		logger.Debug("Cleanup operation completed - no expired rules found")
+		if err := w.store.UpdateBlock(record); err != nil {
+			logger.Warn("Failed to persist expired block", "ip", ip, "error", err)
+		}
+
+		elapsed := currentTime.Sub(record.BlockedAt)
+		logger.Info("Removed expired Windows Firewall rule entry",
+			"ip", ip,
+			"rule", record.RuleName,
+			"activeTime", elapsed.Truncate(time.Second))
+		w.notifier.Notify(notify.NewEvent(models.EventIPUnblocked, "", ip, models.SeverityLow,
+			fmt.Sprintf("Block on %s expired after %s", ip, elapsed.Truncate(time.Second))))
 	}
+
+	logger.Info("Cleanup operation completed",
+		"removedRules", removedCount,
+		"totalBlocked", len(w.blockedIPs),
+		"removedIPs", removedIPs)
+
+	// Use structured logging for cleanup events
+	logger.LogCleanupOperation(w.config, removedCount, len(w.blockedIPs))
 }
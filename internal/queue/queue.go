@@ -0,0 +1,401 @@
+// Package queue provides the bounded queues between Guardian's processing stages
+// Each queue has an overflow policy and counts what it had to drop
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// Policy decides what happens when a queue is full
+type Policy string
+
+const (
+	PolicyBlock      Policy = "block"       // the producer waits for room
+	PolicyDropOldest Policy = "drop-oldest" // the oldest queued item makes room
+	PolicySpill      Policy = "spill"       // items overflow to a file and are read back in order
+)
+
+// Defaults for queues created without explicit options
+const (
+	DefaultCapacity      = 1000
+	DefaultMaxSpillBytes = 256 << 20
+)
+
+// Loss warnings are logged at most this often per queue
+const warnInterval = 10 * time.Second
+
+// ParsePolicy validates a configured policy; an empty value selects fallback
+func ParsePolicy(value string, fallback Policy) (Policy, error) {
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return fallback, nil
+	case PolicyBlock, PolicyDropOldest, PolicySpill:
+		return policy, nil
+	default:
+		return fallback, core.NewErrorf(core.ErrConfigInvalid, nil,
+			"unknown overflow policy %q (block, drop-oldest or spill)", value)
+	}
+}
+
+// Options configures a queue
+type Options struct {
+	Name          string // shown in status and logs; also names the spill file
+	Capacity      int    // items held in memory
+	Policy        Policy
+	SpillDir      string // where spill files are kept (spill policy)
+	MaxSpillBytes int64  // spill file limit; items beyond it are dropped
+}
+
+// FromConfig builds the options of a stage queue from monitoring.overflow
+// policy is the stage's configured value; an invalid one is reported and replaced by fallback
+func FromConfig(name string, capacity int, policy string, fallback Policy, overflow models.OverflowConfig) Options {
+	parsed, err := ParsePolicy(policy, fallback)
+	if err != nil {
+		logger.Warn("Invalid overflow policy, using default", "queue", name, "policy", string(fallback), "error", err)
+	}
+	spillDir := overflow.SpillDir
+	if spillDir == "" {
+		spillDir = filepath.Join(utils.NewPlatformPaths().GetDefaultDataDir(), "spill")
+	}
+	return Options{
+		Name:          name,
+		Capacity:      capacity,
+		Policy:        parsed,
+		SpillDir:      spillDir,
+		MaxSpillBytes: int64(overflow.MaxSpillMB) << 20,
+	}
+}
+
+// Stats is a snapshot of a queue's counters
+type Stats struct {
+	Name         string    `json:"name"`
+	Policy       Policy    `json:"policy"`
+	Capacity     int       `json:"capacity"`
+	Queued       int       `json:"queued"`  // in memory and on disk
+	Spilled      int       `json:"spilled"` // currently on disk
+	Enqueued     uint64    `json:"enqueued"`
+	Dropped      uint64    `json:"dropped"`
+	SpilledTotal uint64    `json:"spilled_total"`
+	LastDrop     time.Time `json:"last_drop,omitempty"`
+}
+
+// Queue is a bounded FIFO between a producer stage and the consumer reading Out
+// Push must not be called after Close
+type Queue[T any] struct {
+	options Options
+	out     chan T
+
+	spill *spillFile[T] // nil unless the policy is spill
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	enqueued     atomic.Uint64
+	dropped      atomic.Uint64
+	spilledTotal atomic.Uint64
+
+	warnMu     sync.Mutex
+	lastWarn   time.Time
+	lastDrop   time.Time
+	unreported uint64
+}
+
+// New creates a queue; a spill queue whose file cannot be opened falls back to blocking
+// Items left in a spill file by a previous run are delivered first
+func New[T any](options Options) *Queue[T] {
+	if options.Capacity <= 0 {
+		options.Capacity = DefaultCapacity
+	}
+	if options.Policy == "" {
+		options.Policy = PolicyBlock
+	}
+	if options.MaxSpillBytes <= 0 {
+		options.MaxSpillBytes = DefaultMaxSpillBytes
+	}
+
+	q := &Queue[T]{
+		options: options,
+		out:     make(chan T, options.Capacity),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if options.Policy == PolicySpill {
+		spill, err := openSpillFile[T](spillPath(options.SpillDir, options.Name), options.MaxSpillBytes)
+		if err != nil {
+			logger.Warn("Cannot open queue spill file, blocking instead", "queue", options.Name, "error", err)
+			q.options.Policy = PolicyBlock
+		} else {
+			q.spill = spill
+			q.wake = make(chan struct{}, 1)
+			go q.drain()
+			if spill.pending > 0 {
+				logger.Info("Replaying queue spill file", "queue", options.Name, "items", spill.pending)
+				q.signal()
+			}
+		}
+	}
+	if q.spill == nil {
+		close(q.done)
+	}
+
+	register(q)
+	return q
+}
+
+// Out is read by the consumer; it is closed by Close once everything queued was delivered
+func (q *Queue[T]) Out() <-chan T {
+	return q.out
+}
+
+// Push queues an item according to the policy
+// It reports false if the item was dropped or ctx ended while waiting (block policy)
+func (q *Queue[T]) Push(ctx context.Context, item T) bool {
+	q.enqueued.Add(1)
+
+	switch q.options.Policy {
+	case PolicyDropOldest:
+		for {
+			select {
+			case q.out <- item:
+				return true
+			default:
+			}
+			select {
+			case <-q.out:
+				q.recordDrop()
+			default:
+			}
+		}
+
+	case PolicySpill:
+		// Once items are on disk everything goes there, so order is kept
+		if q.spill.empty() {
+			select {
+			case q.out <- item:
+				return true
+			default:
+			}
+		}
+		if err := q.spill.write(item); err != nil {
+			q.recordDrop()
+			return false
+		}
+		q.spilledTotal.Add(1)
+		q.signal()
+		return true
+
+	default:
+		select {
+		case q.out <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Close delivers what is queued (including spilled items) and closes Out
+func (q *Queue[T]) Close() {
+	q.once.Do(func() {
+		close(q.stop)
+		<-q.done
+		close(q.out)
+		if q.spill != nil {
+			q.spill.close()
+		}
+		unregister(q)
+	})
+}
+
+// Stats returns the queue's counters
+func (q *Queue[T]) Stats() Stats {
+	q.warnMu.Lock()
+	lastDrop := q.lastDrop
+	q.warnMu.Unlock()
+
+	stats := Stats{
+		Name:         q.options.Name,
+		Policy:       q.options.Policy,
+		Capacity:     q.options.Capacity,
+		Queued:       len(q.out),
+		Enqueued:     q.enqueued.Load(),
+		Dropped:      q.dropped.Load(),
+		SpilledTotal: q.spilledTotal.Load(),
+		LastDrop:     lastDrop,
+	}
+	if q.spill != nil {
+		stats.Spilled = q.spill.count()
+		stats.Queued += stats.Spilled
+	}
+	return stats
+}
+
+// drain moves spilled items back into memory as the consumer makes room
+func (q *Queue[T]) drain() {
+	defer close(q.done)
+	for {
+		for !q.spill.empty() {
+			item, err := q.spill.read()
+			if err != nil {
+				logger.Warn("Queue spill file unreadable, discarding it", "queue", q.options.Name, "error", err)
+				q.recordDrops(uint64(q.spill.reset()))
+				break
+			}
+			q.out <- item
+			q.spill.delivered()
+		}
+		select {
+		case <-q.wake:
+		case <-q.stop:
+			if q.spill.empty() {
+				return
+			}
+		}
+	}
+}
+
+func (q *Queue[T]) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue[T]) recordDrop() {
+	q.recordDrops(1)
+}
+
+// recordDrops counts lost items and warns, at most once per warnInterval
+func (q *Queue[T]) recordDrops(count uint64) {
+	if count == 0 {
+		return
+	}
+	total := q.dropped.Add(count)
+
+	q.warnMu.Lock()
+	defer q.warnMu.Unlock()
+	q.lastDrop = time.Now()
+	q.unreported += count
+	if time.Since(q.lastWarn) < warnInterval {
+		return
+	}
+	logger.Warn("Queue full, items lost",
+		"queue", q.options.Name,
+		"policy", string(q.options.Policy),
+		"dropped", q.unreported,
+		"total_dropped", total,
+		"capacity", q.options.Capacity)
+	q.lastWarn, q.unreported = q.lastDrop, 0
+}
+
+// spillPath names a queue's spill file
+func spillPath(dir, name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	return filepath.Join(dir, safe+".spill")
+}
+
+// Registry of open queues, for status reporting
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[any]func() Stats)
+)
+
+func register[T any](q *Queue[T]) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[q] = q.Stats
+}
+
+func unregister[T any](q *Queue[T]) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, q)
+}
+
+// Snapshot returns the counters of every open queue, sorted by name
+func Snapshot() []Stats {
+	registryMu.Lock()
+	collectors := make([]func() Stats, 0, len(registry))
+	for _, collect := range registry {
+		collectors = append(collectors, collect)
+	}
+	registryMu.Unlock()
+
+	stats := make([]Stats, 0, len(collectors))
+	for _, collect := range collectors {
+		stats = append(stats, collect())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// StatusPath is where the running daemon publishes its queue counters
+func StatusPath() string {
+	return filepath.Join(utils.NewPlatformPaths().GetDefaultDataDir(), "queues.json")
+}
+
+// Summary formats the dropped counts of every open queue for log lines, e.g. "alerts=0 events=12"
+func Summary() string {
+	snapshot := Snapshot()
+	parts := make([]string, 0, len(snapshot))
+	for _, stats := range snapshot {
+		parts = append(parts, fmt.Sprintf("%s=%d", stats.Name, stats.Dropped))
+	}
+	return strings.Join(parts, " ")
+}
+
+// statusFile is what SaveSnapshot writes
+type statusFile struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Queues    []Stats   `json:"queues"`
+}
+
+// SaveSnapshot writes the counters of every open queue to path, for `guardian status`
+func SaveSnapshot(path string) error {
+	data, err := json.MarshalIndent(statusFile{UpdatedAt: time.Now(), Queues: Snapshot()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// LoadSnapshot reads counters written by SaveSnapshot
+func LoadSnapshot(path string) ([]Stats, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var status statusFile
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid queue status file %s: %w", path, err)
+	}
+	return status.Queues, status.UpdatedAt, nil
+}
@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// collect reads Out until it is closed
+func collect[T any](q *Queue[T]) []T {
+	var items []T
+	for item := range q.Out() {
+		items = append(items, item)
+	}
+	return items
+}
+
+// closeAndCollect closes q while reading what it delivers
+func closeAndCollect[T any](q *Queue[T]) []T {
+	done := make(chan []T)
+	go func() { done <- collect(q) }()
+	q.Close()
+	return <-done
+}
+
+func TestDropOldestKeepsNewest(t *testing.T) {
+	q := New[int](Options{Name: "test-drop", Capacity: 3, Policy: PolicyDropOldest})
+	for i := 1; i <= 5; i++ {
+		if !q.Push(context.Background(), i) {
+			t.Fatalf("Push(%d) reported a drop; drop-oldest always queues the new item", i)
+		}
+	}
+
+	stats := q.Stats()
+	if stats.Enqueued != 5 || stats.Dropped != 2 || stats.Queued != 3 || stats.LastDrop.IsZero() {
+		t.Errorf("stats = %+v, want 5 enqueued, 2 dropped, 3 queued", stats)
+	}
+	if items := closeAndCollect(q); !slices.Equal(items, []int{3, 4, 5}) {
+		t.Errorf("delivered %v, want [3 4 5]", items)
+	}
+}
+
+func TestBlockWaitsForRoom(t *testing.T) {
+	q := New[int](Options{Name: "test-block", Capacity: 1, Policy: PolicyBlock})
+	if !q.Push(context.Background(), 1) {
+		t.Fatal("Push into an empty queue failed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if q.Push(ctx, 2) {
+		t.Error("Push into a full queue should give up when ctx ends")
+	}
+	if items := closeAndCollect(q); !slices.Equal(items, []int{1}) {
+		t.Errorf("delivered %v, want [1]", items)
+	}
+}
+
+func TestSpillKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	q := New[int](Options{Name: "test-spill", Capacity: 2, Policy: PolicySpill, SpillDir: dir})
+	for i := 1; i <= 10; i++ {
+		if !q.Push(context.Background(), i) {
+			t.Fatalf("Push(%d) failed", i)
+		}
+	}
+
+	stats := q.Stats()
+	if stats.Dropped != 0 || stats.SpilledTotal != 8 || stats.Queued != 10 {
+		t.Errorf("stats = %+v, want 8 spilled and all 10 queued", stats)
+	}
+
+	// Close delivers the spilled items too, in order
+	if items := closeAndCollect(q); !slices.Equal(items, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("delivered %v, want 1 to 10 in order", items)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "test-spill.spill")); err != nil || info.Size() != 0 {
+		t.Errorf("spill file should be emptied once delivered: %v %v", info, err)
+	}
+}
+
+func TestSpillDropsBeyondMaxSpillBytes(t *testing.T) {
+	// Each item is written as "N\n": two fit in four bytes
+	q := New[int](Options{Name: "test-spill-limit", Capacity: 1, Policy: PolicySpill, SpillDir: t.TempDir(), MaxSpillBytes: 4})
+	for i := 1; i <= 3; i++ {
+		if !q.Push(context.Background(), i) {
+			t.Fatalf("Push(%d) failed", i)
+		}
+	}
+	if q.Push(context.Background(), 4) {
+		t.Error("Push beyond max_spill_bytes should report the drop")
+	}
+
+	stats := q.Stats()
+	if stats.Dropped != 1 || stats.SpilledTotal != 2 {
+		t.Errorf("stats = %+v, want 2 spilled and 1 dropped", stats)
+	}
+	if items := closeAndCollect(q); !slices.Equal(items, []int{1, 2, 3}) {
+		t.Errorf("delivered %v, want [1 2 3]", items)
+	}
+}
+
+func TestSpillReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	// Left by a previous run that stopped while writing its last entry
+	if err := os.WriteFile(filepath.Join(dir, "test-replay.spill"), []byte("1\n2\n3\n{\"cut"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	q := New[int](Options{Name: "test-replay", Capacity: 2, Policy: PolicySpill, SpillDir: dir})
+	// The cut entry is dropped; new items queue behind the replayed ones
+	q.Push(context.Background(), 4)
+	if items := closeAndCollect(q); !slices.Equal(items, []int{1, 2, 3, 4}) {
+		t.Errorf("delivered %v, want [1 2 3 4]", items)
+	}
+}
+
+func TestSpillFallsBackToBlocking(t *testing.T) {
+	// A file where the spill directory should be
+	dir := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(dir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	q := New[int](Options{Name: "test-fallback", Capacity: 1, Policy: PolicySpill, SpillDir: dir})
+	defer q.Close()
+	if policy := q.Stats().Policy; policy != PolicyBlock {
+		t.Errorf("policy = %s, want block when the spill file cannot be opened", policy)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for value, want := range map[string]Policy{"": PolicySpill, " Drop-Oldest ": PolicyDropOldest, "block": PolicyBlock, "spill": PolicySpill} {
+		if got, err := ParsePolicy(value, PolicySpill); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %s, %v; want %s", value, got, err, want)
+		}
+	}
+	if got, err := ParsePolicy("discard", PolicyBlock); err == nil || got != PolicyBlock {
+		t.Errorf("ParsePolicy(discard) = %s, %v; want the fallback and an error", got, err)
+	}
+}
+
+func TestSnapshotAndSummary(t *testing.T) {
+	events := New[int](Options{Name: "test-events", Capacity: 1, Policy: PolicyDropOldest})
+	alerts := New[int](Options{Name: "test-alerts", Capacity: 1})
+	for i := range 3 {
+		events.Push(context.Background(), i)
+	}
+
+	var names []string
+	for _, stats := range Snapshot() {
+		names = append(names, stats.Name)
+	}
+	if !slices.Contains(names, "test-events") || !slices.Contains(names, "test-alerts") || !slices.IsSorted(names) {
+		t.Errorf("snapshot names = %v, want both queues sorted by name", names)
+	}
+	if summary := Summary(); !strings.Contains(summary, "test-alerts=0 test-events=2") {
+		t.Errorf("summary = %q", summary)
+	}
+
+	path := filepath.Join(t.TempDir(), "queues.json")
+	if err := SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	saved, updatedAt, err := LoadSnapshot(path)
+	if err != nil || updatedAt.IsZero() || len(saved) != len(names) {
+		t.Errorf("LoadSnapshot = %v, %v, %v", saved, updatedAt, err)
+	}
+
+	// Closed queues leave the registry
+	events.Close()
+	alerts.Close()
+	if summary := Summary(); strings.Contains(summary, "test-events") {
+		t.Errorf("closed queue still reported: %q", summary)
+	}
+}
@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sr-tamim/guardian/internal/core"
+)
+
+// spillFile holds overflowing items as JSON lines
+// Items are appended by producers and read back in order by the drain goroutine;
+// the file is truncated whenever it has been read completely
+type spillFile[T any] struct {
+	path     string
+	maxBytes int64
+
+	mu      sync.Mutex
+	writer  *os.File
+	reader  *os.File
+	buffer  *bufio.Reader
+	size    int64
+	pending int // written, not yet delivered
+}
+
+// openSpillFile opens a spill file, keeping items a previous run left in it
+func openSpillFile[T any](path string, maxBytes int64) (*spillFile[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, core.NewError(core.ErrStorageOperation, "failed to create spill directory", err)
+	}
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, core.NewError(core.ErrStorageOperation, "failed to open spill file", err)
+	}
+	reader, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, core.NewError(core.ErrStorageOperation, "failed to open spill file", err)
+	}
+
+	spill := &spillFile[T]{path: path, maxBytes: maxBytes, writer: writer, reader: reader, buffer: bufio.NewReader(reader)}
+	if info, err := writer.Stat(); err == nil && info.Size() > 0 {
+		// A crash may have cut the last entry short; drop it so new entries start on a fresh line
+		spill.pending, spill.size = countLines(path)
+		if spill.size < info.Size() {
+			writer.Truncate(spill.size)
+		}
+	}
+	return spill, nil
+}
+
+// write appends an item
+func (s *spillFile[T]) write(item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+int64(len(data)) > s.maxBytes {
+		return core.NewErrorf(core.ErrStorageOperation, nil, "spill file %s is full", s.path)
+	}
+	if _, err := s.writer.Write(data); err != nil {
+		return core.NewError(core.ErrStorageOperation, "failed to write spill file", err)
+	}
+	s.size += int64(len(data))
+	s.pending++
+	return nil
+}
+
+// read returns the next item; delivered must be called once it is handed over
+func (s *spillFile[T]) read() (T, error) {
+	var item T
+	line, err := s.buffer.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return item, err
+	}
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		return item, core.NewError(core.ErrStorageOperation, "truncated spill file entry", err)
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// delivered marks the item last read as handed over, truncating the file once it is empty
+func (s *spillFile[T]) delivered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	if s.pending == 0 {
+		s.truncateLocked()
+	}
+}
+
+// reset discards everything on disk and returns how many items were lost
+func (s *spillFile[T]) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lost := s.pending
+	s.pending = 0
+	s.truncateLocked()
+	return lost
+}
+
+func (s *spillFile[T]) truncateLocked() {
+	if err := s.writer.Truncate(0); err == nil {
+		s.size = 0
+		s.reader.Seek(0, io.SeekStart)
+		s.buffer.Reset(s.reader)
+	}
+}
+
+// empty reports whether every spilled item was delivered
+func (s *spillFile[T]) empty() bool {
+	return s.count() == 0
+}
+
+func (s *spillFile[T]) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+func (s *spillFile[T]) close() {
+	s.writer.Close()
+	s.reader.Close()
+}
+
+// countLines counts the complete entries in a spill file left by a previous run and their size
+func countLines(path string) (int, int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+
+	lines, size := 0, int64(0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return lines, size
+		}
+		lines++
+		size += int64(len(line))
+	}
+}
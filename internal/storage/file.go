@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// fileFlushInterval bounds how long attack attempts stay unwritten
+const fileFlushInterval = 30 * time.Second
+
+// fileWriteDelay is how long block, alert and checkpoint changes wait before the file is written
+// Changes arriving within it are coalesced into one write
+const fileWriteDelay = 250 * time.Millisecond
+
+// snapshot is the on-disk JSON document
+type snapshot struct {
+	NextID      int64                    `json:"next_id"`
+	Attacks     []*models.AttackAttempt  `json:"attacks"`
+	Blocks      []*models.BlockRecord    `json:"blocks"`
+	Alerts      []*models.Alert          `json:"alerts,omitempty"`
+	Checkpoints []*models.TailCheckpoint `json:"checkpoints,omitempty"`
+}
+
+// FileStorage is a MemoryStorage persisted to a JSON file
+// Writes go to a temporary file that is renamed into place, so other
+// processes (status, TUI) always read a complete document
+type FileStorage struct {
+	*MemoryStorage
+	path string
+
+	flushMu sync.Mutex
+	dirty   bool
+	pending chan struct{} // asks flushLoop to write after fileWriteDelay
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// NewFileStorage opens (or creates) a JSON store at path
+func NewFileStorage(path string) (*FileStorage, error) {
+	store := &FileStorage{
+		MemoryStorage: NewMemoryStorage(),
+		path:          path,
+		pending:       make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	go store.flushLoop()
+	return store, nil
+}
+
+// OpenReadOnly loads a snapshot of the store at path without starting the flusher
+// Used by CLI commands that inspect the daemon's state
+func OpenReadOnly(path string) (*MemoryStorage, error) {
+	store := &FileStorage{MemoryStorage: NewMemoryStorage(), path: path}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store.MemoryStorage, nil
+}
+
+// Path returns the backing file path
+func (s *FileStorage) Path() string {
+	return s.path
+}
+
+// SaveAttack stores an attempt; it is written on the next periodic flush
+func (s *FileStorage) SaveAttack(attempt *models.AttackAttempt) error {
+	if err := s.MemoryStorage.SaveAttack(attempt); err != nil {
+		return err
+	}
+	s.markDirty()
+	return nil
+}
+
+// SaveBlock stores a block record; it is written after fileWriteDelay
+func (s *FileStorage) SaveBlock(block *models.BlockRecord) error {
+	if err := s.MemoryStorage.SaveBlock(block); err != nil {
+		return err
+	}
+	s.requestFlush()
+	return nil
+}
+
+// UpdateBlock updates a block record; it is written after fileWriteDelay
+func (s *FileStorage) UpdateBlock(block *models.BlockRecord) error {
+	if err := s.MemoryStorage.UpdateBlock(block); err != nil {
+		return err
+	}
+	s.requestFlush()
+	return nil
+}
+
+// SaveAlert stores an alert; it is written after fileWriteDelay, so the TUI and status see it quickly
+func (s *FileStorage) SaveAlert(alert *models.Alert) error {
+	if err := s.MemoryStorage.SaveAlert(alert); err != nil {
+		return err
+	}
+	s.requestFlush()
+	return nil
+}
+
+// SaveCheckpoints stores log file positions; they are written after fileWriteDelay
+// On shutdown Close writes them
+func (s *FileStorage) SaveCheckpoints(checkpoints []*models.TailCheckpoint) error {
+	if err := s.MemoryStorage.SaveCheckpoints(checkpoints); err != nil {
+		return err
+	}
+	s.requestFlush()
+	return nil
+}
+
+// Flush writes pending changes to disk
+func (s *FileStorage) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	s.mu.RLock()
+	data, err := json.MarshalIndent(snapshot{
+		NextID:      s.nextID,
+		Attacks:     s.attacks,
+		Blocks:      s.blocks,
+		Alerts:      s.alerts,
+		Checkpoints: s.checkpoints,
+	}, "", "  ")
+	s.mu.RUnlock()
+	if err != nil {
+		return core.NewError(core.ErrStorageOperation, "failed to encode storage snapshot", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return core.NewError(core.ErrStorageOperation, "failed to create storage directory", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return core.NewError(core.ErrStorageOperation, "failed to write storage file", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return core.NewError(core.ErrStorageOperation, "failed to replace storage file", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// Close flushes pending changes and stops the background flusher
+func (s *FileStorage) Close() error {
+	s.stopped.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
+	return s.Flush()
+}
+
+func (s *FileStorage) markDirty() {
+	s.flushMu.Lock()
+	s.dirty = true
+	s.flushMu.Unlock()
+}
+
+// requestFlush marks the store dirty and has flushLoop write it shortly
+func (s *FileStorage) requestFlush() {
+	s.markDirty()
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+func (s *FileStorage) flushLoop() {
+	ticker := time.NewTicker(fileFlushInterval)
+	defer ticker.Stop()
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.pending:
+			if delay == nil {
+				delay = time.After(fileWriteDelay)
+			}
+			continue
+		case <-delay:
+			delay = nil
+		case <-ticker.C:
+		}
+		if err := s.Flush(); err != nil {
+			logger.Warn("Failed to flush storage", "path", s.path, "error", err)
+		}
+	}
+}
+
+func (s *FileStorage) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return core.NewError(core.ErrStorageConnection, "failed to read storage file", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return core.NewError(core.ErrStorageConnection, "failed to decode storage file", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID = snap.NextID
+	s.attacks = snap.Attacks
+	s.blocks = snap.Blocks
+	s.alerts = snap.Alerts
+	s.checkpoints = snap.Checkpoints
+	return nil
+}
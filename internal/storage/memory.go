@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+// DefaultMaxAttacks bounds how many attack attempts are retained
+const DefaultMaxAttacks = 10000
+
+// DefaultMaxAlerts bounds how many detection alerts are retained
+const DefaultMaxAlerts = 1000
+
+// MemoryStorage implements core.Storage in memory
+// It is the default for development and the base of FileStorage
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	attacks     []*models.AttackAttempt
+	blocks      []*models.BlockRecord
+	alerts      []*models.Alert
+	checkpoints []*models.TailCheckpoint
+	maxAttacks  int
+	maxAlerts   int
+	nextID      int64
+	startTime   time.Time
+}
+
+// NewMemoryStorage creates an empty in-memory store
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		maxAttacks: DefaultMaxAttacks,
+		maxAlerts:  DefaultMaxAlerts,
+		startTime:  time.Now(),
+	}
+}
+
+// SaveAttack stores an attack attempt, dropping the oldest beyond the retention limit
+func (s *MemoryStorage) SaveAttack(attempt *models.AttackAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	copied := *attempt
+	copied.ID = s.nextID
+	attempt.ID = copied.ID
+	s.attacks = append(s.attacks, &copied)
+	if len(s.attacks) > s.maxAttacks {
+		s.attacks = s.attacks[len(s.attacks)-s.maxAttacks:]
+	}
+	return nil
+}
+
+// GetAttacks returns attempts newest first
+func (s *MemoryStorage) GetAttacks(limit int, offset int) ([]*models.AttackAttempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.AttackAttempt
+	for i := len(s.attacks) - 1 - offset; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		copied := *s.attacks[i]
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// GetAttacksByIP returns attempts from ip at or after since, oldest first
+func (s *MemoryStorage) GetAttacksByIP(ip string, since time.Time) ([]*models.AttackAttempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.AttackAttempt
+	for _, attempt := range s.attacks {
+		if attempt.IP == ip && !attempt.Timestamp.Before(since) {
+			copied := *attempt
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+// SaveBlock stores a new block record and assigns its ID
+func (s *MemoryStorage) SaveBlock(block *models.BlockRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	block.ID = s.nextID
+	copied := *block
+	s.blocks = append(s.blocks, &copied)
+	return nil
+}
+
+// GetBlock returns the most recent block record for ip
+func (s *MemoryStorage) GetBlock(ip string) (*models.BlockRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if s.blocks[i].IP == ip {
+			copied := *s.blocks[i]
+			return &copied, nil
+		}
+	}
+	return nil, core.NewErrorf(core.ErrRecordNotFound, nil, "no block record for %s", ip)
+}
+
+// GetActiveBlocks returns all active block records, oldest first
+func (s *MemoryStorage) GetActiveBlocks() ([]*models.BlockRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.BlockRecord
+	for _, block := range s.blocks {
+		if block.IsActive {
+			copied := *block
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlockedAt.Before(result[j].BlockedAt)
+	})
+	return result, nil
+}
+
+// GetBlocks returns the block records placed or lifted since the given time, oldest first
+func (s *MemoryStorage) GetBlocks(since time.Time) ([]*models.BlockRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.BlockRecord
+	for _, block := range s.blocks {
+		if !block.BlockedAt.Before(since) || (block.UnblockedAt != nil && !block.UnblockedAt.Before(since)) {
+			copied := *block
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlockedAt.Before(result[j].BlockedAt)
+	})
+	return result, nil
+}
+
+// UpdateBlock replaces the stored record with the same ID
+func (s *MemoryStorage) UpdateBlock(block *models.BlockRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.blocks {
+		if existing.ID == block.ID {
+			copied := *block
+			s.blocks[i] = &copied
+			return nil
+		}
+	}
+	return core.NewErrorf(core.ErrRecordNotFound, nil, "no block record with id %d", block.ID)
+}
+
+// SaveAlert stores a detection alert and assigns its ID, dropping the oldest beyond the retention limit
+func (s *MemoryStorage) SaveAlert(alert *models.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	alert.ID = s.nextID
+	copied := *alert
+	s.alerts = append(s.alerts, &copied)
+	if len(s.alerts) > s.maxAlerts {
+		s.alerts = s.alerts[len(s.alerts)-s.maxAlerts:]
+	}
+	return nil
+}
+
+// GetAlerts returns alerts newest first
+func (s *MemoryStorage) GetAlerts(limit int) ([]*models.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.Alert
+	for i := len(s.alerts) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		copied := *s.alerts[i]
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// SaveCheckpoints replaces the stored log file positions
+func (s *MemoryStorage) SaveCheckpoints(checkpoints []*models.TailCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints = make([]*models.TailCheckpoint, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		copied := *checkpoint
+		s.checkpoints = append(s.checkpoints, &copied)
+	}
+	return nil
+}
+
+// GetCheckpoints returns the stored log file positions
+func (s *MemoryStorage) GetCheckpoints() ([]*models.TailCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.TailCheckpoint, 0, len(s.checkpoints))
+	for _, checkpoint := range s.checkpoints {
+		copied := *checkpoint
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// GetStatistics summarizes stored attacks and blocks
+func (s *MemoryStorage) GetStatistics() (*models.Statistics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &models.Statistics{
+		TotalAttacks:  int64(len(s.attacks)),
+		BlockedIPs:    int64(len(s.blocks)),
+		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+	}
+	for _, block := range s.blocks {
+		if block.IsActive {
+			stats.ActiveBlocks++
+		}
+		if block.BlockedAt.After(stats.LastActivity) {
+			stats.LastActivity = block.BlockedAt
+		}
+	}
+	if len(s.attacks) > 0 {
+		if last := s.attacks[len(s.attacks)-1].Timestamp; last.After(stats.LastActivity) {
+			stats.LastActivity = last
+		}
+	}
+	return stats, nil
+}
+
+// Close releases resources (nothing to release in memory)
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/logger"
+	"github.com/sr-tamim/guardian/pkg/models"
+	"github.com/sr-tamim/guardian/pkg/utils"
+)
+
+// New creates the storage backend selected by the configuration
+// Supported types: memory, json. sqlite is a deprecated alias for json
+func New(config models.StorageConfig) (core.Storage, error) {
+	switch strings.ToLower(config.Type) {
+	case "", "memory":
+		return NewMemoryStorage(), nil
+	case "json":
+		return NewFileStorage(ResolvePath(config))
+	case "sqlite":
+		path := ResolvePath(config)
+		logger.Warn("Storage type sqlite is deprecated and stores JSON; set storage.type to json",
+			"path", path)
+		return NewFileStorage(path)
+	default:
+		return nil, core.NewErrorf(core.ErrConfigInvalid, nil, "unknown storage type: %s", config.Type)
+	}
+}
+
+// ResolvePath returns the file used for a storage configuration
+// An empty file_path falls back to the platform data directory
+func ResolvePath(config models.StorageConfig) string {
+	path := config.FilePath
+	if path == "" {
+		path = utils.NewPlatformPaths().GetDefaultGuardianDatabasePath()
+	}
+	// Earlier versions accepted sqlite and kept a JSON file with the extension swapped
+	if strings.EqualFold(config.Type, "sqlite") {
+		path = strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	}
+	return path
+}
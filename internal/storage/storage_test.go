@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
+)
+
+func TestNewTreatsSQLiteAsLegacyJSON(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(models.StorageConfig{Type: "sqlite", FilePath: filepath.Join(dir, "guardian.db")})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer store.Close()
+
+	file, ok := store.(*FileStorage)
+	if want := filepath.Join(dir, "guardian.json"); !ok || file.Path() != want {
+		t.Errorf("store = %T, want FileStorage at the earlier JSON file %s", store, want)
+	}
+}
+
+func TestNewRejectsUnknownType(t *testing.T) {
+	_, err := New(models.StorageConfig{Type: "postgres"})
+	if !core.IsErrorCode(err, core.ErrConfigInvalid) {
+		t.Errorf("New(postgres) error = %v, want a configuration error", err)
+	}
+}
+
+func TestNewJSONUsesConfiguredPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	store, err := New(models.StorageConfig{Type: "json", FilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	file, ok := store.(*FileStorage)
+	if !ok || file.Path() != path {
+		t.Errorf("store = %T, want FileStorage at %s", store, path)
+	}
+}
+
+func TestFileStorageCoalescesBlockWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	store, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for _, ip := range []string{"203.0.113.5", "203.0.113.6", "203.0.113.7"} {
+		if err := store.SaveBlock(&models.BlockRecord{IP: ip, BlockedAt: time.Now(), IsActive: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before the write delay: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reader, err := OpenReadOnly(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blocks, _ := reader.GetActiveBlocks(); len(blocks) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("blocks were not written after the write delay")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFileStorageCloseWritesPendingChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+	store, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveCheckpoints([]*models.TailCheckpoint{{Path: "/var/log/auth.log", Offset: 42}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoints, _ := reader.GetCheckpoints()
+	if len(checkpoints) != 1 || checkpoints[0].Offset != 42 {
+		t.Errorf("checkpoints after Close = %+v", checkpoints)
+	}
+}
@@ -15,12 +15,16 @@ import (
 	"github.com/sr-tamim/guardian/internal/autostart"
 	"github.com/sr-tamim/guardian/internal/core"
 	"github.com/sr-tamim/guardian/internal/daemon"
+	"github.com/sr-tamim/guardian/internal/firewall"
+	"github.com/sr-tamim/guardian/internal/storage"
+	"github.com/sr-tamim/guardian/pkg/models"
 	"github.com/sr-tamim/guardian/pkg/version"
 )
 
 // Dashboard represents the main TUI interface
 type Dashboard struct {
 	provider   core.PlatformProvider
+	config     *models.Config
 	devMode    bool
 	pidManager *daemon.PIDManager
 	width      int
@@ -36,17 +40,30 @@ type Dashboard struct {
 	attackCount      int64
 	lastUpdate       time.Time
 	recentLogs       []string
+	alerts           []*models.Alert
+	alertsNote       string
+	alertsLoaded     time.Time
+	protectionMode   string
+	modeOverride     *firewall.ModeOverride
+	modeNote         string
 
 	// Navigation
 	selectedTab int
 	tabs        []string
 }
 
+// Alerts are reloaded from storage at most this often
+const (
+	alertRefreshInterval = 5 * time.Second
+	maxDisplayedAlerts   = 10
+)
+
 // Tab constants
 const (
 	TabDashboard = iota
 	TabBlocked
 	TabLogs
+	TabAlerts
 	TabService
 	TabSettings
 )
@@ -54,7 +71,7 @@ const (
 // NewDashboard creates a new dashboard instance
 func NewDashboard() *Dashboard {
 	return &Dashboard{
-		tabs:       []string{"Dashboard", "Blocked IPs", "Logs", "Service", "Settings"},
+		tabs:       []string{"Dashboard", "Blocked IPs", "Logs", "Alerts", "Service", "Settings"},
 		lastUpdate: time.Now(),
 		pidManager: daemon.NewPIDManager(),
 		recentLogs: make([]string, 0),
@@ -67,6 +84,11 @@ func (d *Dashboard) SetProvider(provider core.PlatformProvider, devMode bool) {
 	d.devMode = devMode
 }
 
+// SetConfig sets the configuration used to find the daemon's alert store
+func (d *Dashboard) SetConfig(config *models.Config) {
+	d.config = config
+}
+
 // Init initializes the dashboard
 func (d *Dashboard) Init() tea.Cmd {
 	return tea.Batch(
@@ -128,6 +150,14 @@ func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return d, nil
 			}
 
+		case "m":
+			d.cycleMode()
+			return d, nil
+
+		case "M":
+			d.clearMode()
+			return d, nil
+
 		case "r":
 			// Refresh data
 			if d.provider != nil {
@@ -135,6 +165,7 @@ func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// In real implementation: d.blockedIPs = d.provider.GetBlockedIPs()
 				d.attackCount++ // Simulate activity
 			}
+			d.updateAlerts()
 			d.lastUpdate = time.Now()
 			return d, nil
 		}
@@ -153,6 +184,12 @@ func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		d.updateAutostartStatus()
 		// Update recent logs
 		d.updateRecentLogs()
+		d.updateMode()
+		// Update detection alerts
+		if msg.Time.Sub(d.alertsLoaded) >= alertRefreshInterval {
+			d.updateAlerts()
+			d.alertsLoaded = msg.Time
+		}
 		return d, d.tickCmd()
 	}
 
@@ -184,6 +221,8 @@ func (d *Dashboard) View() string {
 		content = d.renderBlockedTab()
 	case TabLogs:
 		content = d.renderLogsTab()
+	case TabAlerts:
+		content = d.renderAlertsTab()
 	case TabService:
 		content = d.renderServiceTab()
 	case TabSettings:
@@ -278,6 +317,7 @@ func (d *Dashboard) renderDashboardTab() string {
    • Last Update: %s
 
 🛡️  Protection Status:
+   • Mode: %s
    • %s
    • Platform Monitoring: %s
    • Platform Firewall: %s
@@ -291,6 +331,7 @@ Press 'r' to refresh, 'tab' to navigate"
 		d.attackCount,
 		len(d.blockedIPs),
 		d.lastUpdate.Format("15:04:05"),
+		d.modeText(),
 		platformInfo,
 		d.getServiceIcon(d.daemonRunning),
 		d.getServiceIcon(d.daemonRunning),
@@ -353,6 +394,41 @@ func (d *Dashboard) renderLogsTab() string {
 	return contentStyle.Render(content)
 }
 
+// renderAlertsTab shows password-spraying and credential-stuffing alerts
+func (d *Dashboard) renderAlertsTab() string {
+	contentStyle := lipgloss.NewStyle().
+		Padding(2).
+		Height(d.height - 6)
+
+	content := "⚠️  Detection Alerts:\n\n"
+
+	switch {
+	case d.alertsNote != "":
+		content += fmt.Sprintf("   %s\n", d.alertsNote)
+	case len(d.alerts) == 0:
+		content += "   No password spraying or credential stuffing detected\n"
+	default:
+		for _, alert := range d.alerts {
+			icon := "🎯"
+			subject := alert.IP + " (blocked)"
+			if alert.Kind == models.AlertCredentialStuffing {
+				icon = "🔑"
+				subject = "account " + alert.Username
+			}
+			content += fmt.Sprintf("   %s %s  %-20s %s  %s: %d in %s\n",
+				icon, alert.Timestamp.Format("01-02 15:04"), strings.ReplaceAll(alert.Kind, "_", " "),
+				alert.Service, subject, alert.Count, alert.Window)
+			if len(alert.Samples) > 0 {
+				content += fmt.Sprintf("      %s\n", strings.Join(alert.Samples, ", "))
+			}
+		}
+	}
+
+	content += "\nPress 'r' to refresh, 'tab' to navigate"
+
+	return contentStyle.Render(content)
+}
+
 // renderServiceTab shows service management
 func (d *Dashboard) renderServiceTab() string {
 	contentStyle := lipgloss.NewStyle().
@@ -429,7 +505,7 @@ func (d *Dashboard) renderFooter() string {
 		Padding(0, 2).
 		Width(d.width)
 
-	controls := "Tab: Navigate • R: Refresh • Q: Quit • Ctrl+C: Quit • TUI is Daemon Status Viewer"
+	controls := "Tab: Navigate • R: Refresh • M: Switch Mode • Shift+M: Configured Mode • Q: Quit • Ctrl+C: Quit • TUI is Daemon Status Viewer"
 	return footerStyle.Render(controls)
 }
 
@@ -500,6 +576,33 @@ func (d *Dashboard) updateRecentLogs() {
 	}
 }
 
+// updateAlerts reads recent detection alerts from the daemon's storage file
+func (d *Dashboard) updateAlerts() {
+	if d.config == nil {
+		return
+	}
+	storageType := strings.ToLower(d.config.Storage.Type)
+	if storageType == "" || storageType == "memory" {
+		d.alertsNote = "Alerts are kept in daemon memory; set storage.type to json to view them here"
+		return
+	}
+
+	store, err := storage.OpenReadOnly(storage.ResolvePath(d.config.Storage))
+	if err != nil {
+		d.alertsNote = fmt.Sprintf("Cannot read alerts: %v", err)
+		return
+	}
+	defer store.Close()
+
+	alerts, err := store.GetAlerts(maxDisplayedAlerts)
+	if err != nil {
+		d.alertsNote = fmt.Sprintf("Cannot read alerts: %v", err)
+		return
+	}
+	d.alerts = alerts
+	d.alertsNote = ""
+}
+
 // updateAutostartStatus checks and updates the autostart status
 func (d *Dashboard) updateAutostartStatus() {
 	if execPath, err := autostart.GetExecutablePath(); err == nil {
@@ -509,3 +612,71 @@ func (d *Dashboard) updateAutostartStatus() {
 		d.autostartEnabled = false
 	}
 }
+
+// tuiModeOrder is the order 'm' steps through the protection modes
+var tuiModeOrder = []string{models.ModeEnforce, models.ModeObserve, models.ModePaused}
+
+// updateMode reads the mode the daemon applies: the runtime override, else the configured mode
+func (d *Dashboard) updateMode() {
+	override, err := firewall.LoadModeOverride(firewall.ModePath())
+	if err != nil {
+		d.modeNote = err.Error()
+		override = nil
+	}
+	if !override.Active(time.Now()) {
+		override = nil
+	}
+	d.modeOverride = override
+	switch {
+	case override != nil:
+		d.protectionMode = override.Mode
+	case d.config != nil:
+		d.protectionMode = d.config.ModeFor("")
+	default:
+		d.protectionMode = models.ModeEnforce
+	}
+}
+
+// cycleMode switches every service to the next protection mode
+func (d *Dashboard) cycleMode() {
+	d.updateMode()
+	next := tuiModeOrder[0]
+	for i, mode := range tuiModeOrder {
+		if mode == d.protectionMode {
+			next = tuiModeOrder[(i+1)%len(tuiModeOrder)]
+		}
+	}
+	d.saveMode(&firewall.ModeOverride{Mode: next, SetBy: "tui", SetAt: time.Now()})
+}
+
+// clearMode returns to the configured protection modes
+func (d *Dashboard) clearMode() {
+	d.saveMode(nil)
+}
+
+// saveMode writes the mode file the daemon watches
+func (d *Dashboard) saveMode(override *firewall.ModeOverride) {
+	if err := firewall.SaveModeOverride(firewall.ModePath(), override); err != nil {
+		d.modeNote = fmt.Sprintf("could not switch mode: %v", err)
+		return
+	}
+	d.modeNote = ""
+	d.updateMode()
+}
+
+// modeText describes the protection mode for the dashboard
+func (d *Dashboard) modeText() string {
+	if d.protectionMode == "" {
+		d.updateMode()
+	}
+	text := d.protectionMode
+	if d.modeOverride != nil {
+		text = d.modeOverride.String()
+	} else {
+		text += " (configured)"
+	}
+	if d.modeNote != "" {
+		text += " ⚠️  " + d.modeNote
+	}
+	return text
+}
@@ -6,13 +6,15 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/sr-tamim/guardian/internal/core"
+	"github.com/sr-tamim/guardian/pkg/models"
 )
 
 // StartDashboard launches the Guardian TUI dashboard without system tray support
-func StartDashboard(provider core.PlatformProvider, devMode bool) error {
+func StartDashboard(provider core.PlatformProvider, config *models.Config, devMode bool) error {
 	// Create dashboard
 	dashboard := NewDashboard()
 	dashboard.SetProvider(provider, devMode)
+	dashboard.SetConfig(config)
 
 	// Create and run the TUI program
 	program := tea.NewProgram(dashboard, tea.WithAltScreen())
@@ -279,6 +279,19 @@ func (l *Logger) LogIPUnblocked(ip string, ruleName string, activeTime time.Dura
 	)
 }
 
+// LogIPEvicted logs when a block is removed to respect the concurrent block cap
+func (l *Logger) LogIPEvicted(ip string, policy string, replacement string) {
+	ctx := LogContext{
+		Module: "firewall",
+		Action: "evict_block",
+	}
+	l.WithContext(ctx).Warn("IP block evicted",
+		slog.String("ip", ip),
+		slog.String("policy", policy),
+		slog.String("replacement", replacement),
+	)
+}
+
 // LogAttackAttempt logs detected attack attempts
 func (l *Logger) LogAttackAttempt(ip string, service string, username string, severity string) {
 	ctx := LogContext{
@@ -364,6 +377,13 @@ func LogIPUnblocked(config *models.Config, ip, ruleName string, activeTime time.
 	}
 }
 
+// LogIPEvicted logs block evictions with automatic config checking
+func LogIPEvicted(config *models.Config, ip, policy, replacement string) {
+	if globalLogger != nil && config != nil && config.Logging.LogFirewallActions {
+		globalLogger.LogIPEvicted(ip, policy, replacement)
+	}
+}
+
 // LogAttackAttempt logs attack attempts with automatic config checking
 func LogAttackAttempt(config *models.Config, ip, service, username, severity string) {
 	if globalLogger != nil && config != nil && config.Logging.LogAttackAttempts {
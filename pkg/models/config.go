@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"runtime"
 	"strings"
 	"time"
@@ -10,19 +11,165 @@ import (
 
 // Config represents the complete Guardian configuration
 type Config struct {
-	Monitoring MonitoringConfig `yaml:"monitoring" json:"monitoring"`
-	Blocking   BlockingConfig   `yaml:"blocking" json:"blocking"`
-	Logging    LoggingConfig    `yaml:"logging" json:"logging"`
-	Storage    StorageConfig    `yaml:"storage" json:"storage"`
-	Services   []ServiceConfig  `yaml:"services" json:"services"`
+	Mode          string              `yaml:"mode" json:"mode"` // enforce (default), observe or paused; services may override it
+	Monitoring    MonitoringConfig    `yaml:"monitoring" json:"monitoring"`
+	Blocking      BlockingConfig      `yaml:"blocking" json:"blocking"`
+	Logging       LoggingConfig       `yaml:"logging" json:"logging"`
+	Storage       StorageConfig       `yaml:"storage" json:"storage"`
+	Detection     DetectionConfig     `yaml:"detection" json:"detection"`
+	Syslog        SyslogConfig        `yaml:"syslog" json:"syslog"`
+	Notifications NotificationsConfig `yaml:"notifications" json:"notifications"`
+	Services      []ServiceConfig     `yaml:"services" json:"services"`
+}
+
+// DetectionConfig holds the cross-username rules that per-IP thresholds miss
+// Zero disables a rule; services inherit these values unless they set their own
+type DetectionConfig struct {
+	SprayUsernames  int           `yaml:"spray_usernames" json:"spray_usernames"`   // Distinct usernames from one address within the window that block it
+	StuffingSources int           `yaml:"stuffing_sources" json:"stuffing_sources"` // Distinct addresses failing on one username within the window that raise an account alert
+	Window          time.Duration `yaml:"window" json:"window"`                     // Detection window (default monitoring.lookback_duration)
+}
+
+// ThresholdFor returns a service's custom_threshold, falling back to blocking.failure_threshold
+func (c *Config) ThresholdFor(name string) int {
+	for _, service := range c.Services {
+		if strings.EqualFold(service.Name, name) && service.CustomThreshold > 0 {
+			return service.CustomThreshold
+		}
+	}
+	if c.Blocking.FailureThreshold > 0 {
+		return c.Blocking.FailureThreshold
+	}
+	return 1
+}
+
+// Protection modes, set globally and per service
+const (
+	ModeEnforce = "enforce" // Blocks are written to the firewall
+	ModeObserve = "observe" // Block decisions are stored as would_block alerts; the firewall is not touched
+	ModePaused  = "paused"  // No new blocks and nothing recorded; detection and alerts continue
+)
+
+// ModeFor returns a service's mode, falling back to the global mode and then enforce
+func (c *Config) ModeFor(name string) string {
+	for _, service := range c.Services {
+		if strings.EqualFold(service.Name, name) && service.Mode != "" {
+			return strings.ToLower(service.Mode)
+		}
+	}
+	if c.Mode != "" {
+		return strings.ToLower(c.Mode)
+	}
+	return ModeEnforce
+}
+
+// ValidMode reports whether mode names a protection mode
+func ValidMode(mode string) bool {
+	switch strings.ToLower(mode) {
+	case ModeEnforce, ModeObserve, ModePaused:
+		return true
+	}
+	return false
+}
+
+// DetectionFor merges a service's detection overrides onto the global settings
+func (c *Config) DetectionFor(name string) DetectionConfig {
+	detection := c.Detection
+	for _, service := range c.Services {
+		if !strings.EqualFold(service.Name, name) {
+			continue
+		}
+		if service.Detection.SprayUsernames != 0 {
+			detection.SprayUsernames = service.Detection.SprayUsernames
+		}
+		if service.Detection.StuffingSources != 0 {
+			detection.StuffingSources = service.Detection.StuffingSources
+		}
+		if service.Detection.Window > 0 {
+			detection.Window = service.Detection.Window
+		}
+		break
+	}
+	if detection.Window <= 0 {
+		detection.Window = c.Monitoring.LookbackDuration
+	}
+	if detection.Window <= 0 {
+		detection.Window = time.Hour
+	}
+	return detection
+}
+
+// NotificationsConfig lists where alerts and engine events are delivered besides the log
+type NotificationsConfig struct {
+	Webhooks []WebhookConfig `yaml:"webhooks" json:"webhooks"`
+	Email    EmailConfig     `yaml:"email" json:"email"`
+}
+
+// WebhookConfig posts alerts and events as JSON to one URL
+type WebhookConfig struct {
+	Name       string            `yaml:"name" json:"name"`               // Shown in logs (default: the URL's host)
+	URL        string            `yaml:"url" json:"url"`                 // http or https endpoint
+	Preset     string            `yaml:"preset" json:"preset"`           // Payload shape: generic (default), slack, teams or discord
+	Template   string            `yaml:"template" json:"template"`       // Go text/template producing the JSON body; overrides preset
+	Events     []string          `yaml:"events" json:"events"`           // Kinds delivered ("*" = all; default: blocks, unblocks, firewall errors and detection alerts)
+	Secret     string            `yaml:"secret" json:"secret"`           // Signs each body with HMAC-SHA256 (X-Guardian-Signature header)
+	Headers    map[string]string `yaml:"headers" json:"headers"`         // Extra request headers, e.g. Authorization
+	Timeout    time.Duration     `yaml:"timeout" json:"timeout"`         // Per request (default 10s)
+	MaxRetries int               `yaml:"max_retries" json:"max_retries"` // Retries after a failed delivery (default 3, negative disables)
+	RateLimit  int               `yaml:"rate_limit" json:"rate_limit"`   // Deliveries per minute; more are dropped (default 30)
+}
+
+// EmailConfig sends immediate alerts and a daily digest over SMTP
+type EmailConfig struct {
+	Host        string        `yaml:"host" json:"host"`                 // SMTP server; empty disables email
+	Port        int           `yaml:"port" json:"port"`                 // Default 587 (starttls), 465 (tls) or 25 (none)
+	Security    string        `yaml:"security" json:"security"`         // starttls (default), tls or none
+	Username    string        `yaml:"username" json:"username"`         // AUTH PLAIN credentials; empty sends without auth
+	Password    string        `yaml:"password" json:"password"`         // Never sent over an unencrypted connection except to localhost
+	From        string        `yaml:"from" json:"from"`                 // Sender address (default guardian@<hostname>)
+	To          []string      `yaml:"to" json:"to"`                     // Recipients
+	Events      []string      `yaml:"events" json:"events"`             // Kinds emailed immediately ("*" = all; default ip_blocked)
+	MinSeverity string        `yaml:"min_severity" json:"min_severity"` // Least severity emailed immediately (default critical)
+	RateLimit   int           `yaml:"rate_limit" json:"rate_limit"`     // Immediate emails per hour; more are counted in the digest (default 20)
+	DigestTime  string        `yaml:"digest_time" json:"digest_time"`   // Local time of the daily digest, "HH:MM" (default 08:00, "off" disables)
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`           // Per SMTP session (default 30s)
+}
+
+// SyslogConfig configures the built-in syslog receiver
+// Services with log_path "syslog" receive the messages their routing rules match
+type SyslogConfig struct {
+	UDPAddress     string   `yaml:"udp_address" json:"udp_address"`           // e.g. ":514"; empty disables UDP
+	TCPAddress     string   `yaml:"tcp_address" json:"tcp_address"`           // e.g. ":514"; empty disables TCP
+	TLSAddress     string   `yaml:"tls_address" json:"tls_address"`           // e.g. ":6514"; empty disables TLS
+	TLSCertFile    string   `yaml:"tls_cert_file" json:"tls_cert_file"`       // PEM certificate for tls_address
+	TLSKeyFile     string   `yaml:"tls_key_file" json:"tls_key_file"`         // PEM key for tls_address
+	AllowedSenders []string `yaml:"allowed_senders" json:"allowed_senders"`   // Sender addresses or CIDRs accepted (empty only for loopback listeners)
+	MaxMessageSize int      `yaml:"max_message_size" json:"max_message_size"` // Longer messages are truncated (default 65536)
+}
+
+// Enabled reports whether any listener is configured
+func (c SyslogConfig) Enabled() bool {
+	return c.UDPAddress != "" || c.TCPAddress != "" || c.TLSAddress != ""
 }
 
 // MonitoringConfig holds monitoring-related settings
 type MonitoringConfig struct {
-	LookbackDuration time.Duration `yaml:"lookback_duration" json:"lookback_duration"`
-	CheckInterval    time.Duration `yaml:"check_interval" json:"check_interval"`
-	EnableRealTime   bool          `yaml:"enable_real_time" json:"enable_real_time"`
-	LogBufferSize    int           `yaml:"log_buffer_size" json:"log_buffer_size"`
+	LookbackDuration time.Duration  `yaml:"lookback_duration" json:"lookback_duration"`
+	CheckInterval    time.Duration  `yaml:"check_interval" json:"check_interval"`
+	EnableRealTime   bool           `yaml:"enable_real_time" json:"enable_real_time"`
+	LogBufferSize    int            `yaml:"log_buffer_size" json:"log_buffer_size"`
+	Backfill         bool           `yaml:"backfill" json:"backfill"` // Read rotated logs within lookback_duration when a file is first monitored
+	Overflow         OverflowConfig `yaml:"overflow" json:"overflow"`
+	ParserWorkers    int            `yaml:"parser_workers" json:"parser_workers"` // Goroutines parsing log lines, sharded by source address (default: number of CPUs)
+}
+
+// OverflowConfig sets what each processing stage does when its queue is full
+// Policies: block (the producer waits), drop-oldest (the oldest queued item is lost) or spill (overflow is written to disk)
+type OverflowConfig struct {
+	Events     string `yaml:"events" json:"events"`             // Log events awaiting parsing (default block)
+	Alerts     string `yaml:"alerts" json:"alerts"`             // Alerts awaiting notification (default drop-oldest)
+	SpillDir   string `yaml:"spill_dir" json:"spill_dir"`       // Where spill files are kept (default <data dir>/spill)
+	MaxSpillMB int    `yaml:"max_spill_mb" json:"max_spill_mb"` // Spill file limit per queue; overflow beyond it is dropped (default 256)
 }
 
 // BlockingConfig holds IP blocking settings
@@ -33,7 +180,63 @@ type BlockingConfig struct {
 	WhitelistedIPs      []string      `yaml:"whitelisted_ips" json:"whitelisted_ips"`
 	AutoUnblock         bool          `yaml:"auto_unblock" json:"auto_unblock"`
 	CleanupInterval     time.Duration `yaml:"cleanup_interval" json:"cleanup_interval"`
+	ReconcileInterval   time.Duration `yaml:"reconcile_interval" json:"reconcile_interval"` // How often firewall rules are compared with block records (default 1h)
 	RuleNameTemplate    string        `yaml:"rule_name_template" json:"rule_name_template"`
+	IPv4Prefix          int           `yaml:"ipv4_prefix" json:"ipv4_prefix"`                   // Aggregation prefix for IPv4 counting/blocking (default 32)
+	IPv6Prefix          int           `yaml:"ipv6_prefix" json:"ipv6_prefix"`                   // Aggregation prefix for IPv6 counting/blocking (default 64)
+	EvictionPolicy      string        `yaml:"eviction_policy" json:"eviction_policy"`           // oldest | lowest_severity | aggregate
+	EvictionIPv4Prefix  int           `yaml:"eviction_ipv4_prefix" json:"eviction_ipv4_prefix"` // Network size the aggregate policy merges IPv4 blocks into (default 24)
+	EvictionIPv6Prefix  int           `yaml:"eviction_ipv6_prefix" json:"eviction_ipv6_prefix"` // Network size the aggregate policy merges IPv6 blocks into (default 48)
+	BatchRulePrefix     string        `yaml:"batch_rule_prefix" json:"batch_rule_prefix"`       // Name prefix for consolidated Windows rules (default "Guardian Batch")
+	BatchRuleSize       int           `yaml:"batch_rule_size" json:"batch_rule_size"`           // Addresses per consolidated Windows rule (default/max 1000)
+	MinIPv4Prefix       int           `yaml:"min_ipv4_prefix" json:"min_ipv4_prefix"`           // Widest IPv4 network Guardian may block (default 16)
+	MinIPv6Prefix       int           `yaml:"min_ipv6_prefix" json:"min_ipv6_prefix"`           // Widest IPv6 network Guardian may block (default 32)
+}
+
+// Default aggregation prefixes: single IPv4 hosts, IPv6 /64 (one customer subnet)
+const (
+	DefaultIPv4Prefix = 32
+	DefaultIPv6Prefix = 64
+
+	DefaultEvictionIPv4Prefix = 24
+	DefaultEvictionIPv6Prefix = 48
+
+	DefaultMinIPv4Prefix = 16
+	DefaultMinIPv6Prefix = 32
+)
+
+// CheckBlockTarget refuses a normalized target wider than min_ipv4_prefix / min_ipv6_prefix
+func (b *BlockingConfig) CheckBlockTarget(target string) error {
+	ones, bits := utils.TargetPrefix(target)
+	minimum := b.MinIPv6Prefix
+	if minimum <= 0 {
+		minimum = DefaultMinIPv6Prefix
+	}
+	if bits == 32 {
+		minimum = b.MinIPv4Prefix
+		if minimum <= 0 {
+			minimum = DefaultMinIPv4Prefix
+		}
+	}
+	if ones < minimum {
+		return fmt.Errorf("network %s is wider than the /%d minimum", target, minimum)
+	}
+	return nil
+}
+
+// AggregationKey maps an attacking IP onto the address or network that is counted and blocked
+// IPv4-mapped IPv6 addresses are treated as IPv4; an IPv6 attacker rotating through
+// its /64 therefore lands on a single key such as "2001:db8:1:2::/64"
+func (b *BlockingConfig) AggregationKey(ip string) (string, error) {
+	ipv4Prefix := b.IPv4Prefix
+	if ipv4Prefix <= 0 {
+		ipv4Prefix = DefaultIPv4Prefix
+	}
+	ipv6Prefix := b.IPv6Prefix
+	if ipv6Prefix <= 0 {
+		ipv6Prefix = DefaultIPv6Prefix
+	}
+	return utils.AggregateIP(ip, ipv4Prefix, ipv6Prefix)
 }
 
 // GenerateRuleName creates a firewall rule name from the template
@@ -92,7 +295,7 @@ func DefaultConfig() *Config {
 	} else {
 		// Unix-like development paths (use temp for safety)
 		logPath = "/tmp/guardian-dev.log"
-		dbPath = "/tmp/guardian-dev.db"
+		dbPath = "/tmp/guardian-dev.json"
 		serviceLogPath = "/tmp/guardian_test_auth.log"
 	}
 
@@ -113,9 +316,13 @@ func DefaultConfig() *Config {
 				"192.168.0.0/16",
 				"10.0.0.0/8",
 			},
-			AutoUnblock:      true,
-			CleanupInterval:  30 * time.Second,                // Fast cleanup for development
-			RuleNameTemplate: "Guardian - {ip} - {timestamp}", // Default template
+			AutoUnblock:       true,
+			CleanupInterval:   30 * time.Second, // Fast cleanup for development
+			ReconcileInterval: time.Hour,
+			RuleNameTemplate:  "Guardian - {ip} - {timestamp}", // Default template
+			IPv4Prefix:        DefaultIPv4Prefix,
+			IPv6Prefix:        DefaultIPv6Prefix,
+			EvictionPolicy:    "oldest",
 		},
 		Logging: LoggingConfig{
 			Level:               "debug",
@@ -134,6 +341,11 @@ func DefaultConfig() *Config {
 			Type:     "memory",
 			FilePath: dbPath, // Platform-aware path
 		},
+		Detection: DetectionConfig{
+			SprayUsernames:  5,
+			StuffingSources: 3,
+			Window:          10 * time.Minute,
+		},
 		Services: []ServiceConfig{
 			{
 				Name:            "SSH",
@@ -166,9 +378,13 @@ func ProductionConfig() *Config {
 				"::1",
 				"192.168.1.0/24",
 			},
-			AutoUnblock:      true,
-			CleanupInterval:  5 * time.Minute,                 // Production cleanup every 5 minutes
-			RuleNameTemplate: "Guardian - {ip} - {timestamp}", // Default template
+			AutoUnblock:       true,
+			CleanupInterval:   5 * time.Minute, // Production cleanup every 5 minutes
+			ReconcileInterval: time.Hour,
+			RuleNameTemplate:  "Guardian - {ip} - {timestamp}", // Default template
+			IPv4Prefix:        DefaultIPv4Prefix,
+			IPv6Prefix:        DefaultIPv6Prefix,
+			EvictionPolicy:    "oldest",
 		},
 		Logging: LoggingConfig{
 			Level:               "info",
@@ -184,9 +400,14 @@ func ProductionConfig() *Config {
 			LogCleanupEvents:    true,
 		},
 		Storage: StorageConfig{
-			Type:     "sqlite",
+			Type:     "json",
 			FilePath: paths.GetDefaultGuardianDatabasePath(), // Platform-aware path
 		},
+		Detection: DetectionConfig{
+			SprayUsernames:  10,
+			StuffingSources: 5,
+			Window:          time.Hour,
+		},
 		Services: createPlatformServices(paths),
 	}
 }
@@ -219,6 +440,34 @@ func createPlatformServices(paths *utils.PlatformPaths) []ServiceConfig {
 			Enabled:         true,
 		})
 
+		// Domain controller logons, RD Gateway and OpenSSH for Windows event logs
+		services = append(services,
+			ServiceConfig{
+				Name:            "Domain Logons",
+				LogPath:         "Security",
+				LogPattern:      "eventlog",
+				EventIDs:        []int{4771, 4776, 4740}, // Kerberos pre-auth, NTLM validation, lockout
+				CustomThreshold: 10,
+				Enabled:         false,
+			},
+			ServiceConfig{
+				Name:            "RD Gateway",
+				LogPath:         "Microsoft-Windows-TerminalServices-Gateway/Operational",
+				LogPattern:      "eventlog",
+				EventIDs:        []int{201, 302, 303},
+				CustomThreshold: 0,
+				Enabled:         false,
+			},
+			ServiceConfig{
+				Name:            "OpenSSH",
+				LogPath:         "OpenSSH/Operational",
+				LogPattern:      "eventlog",
+				EventIDs:        []int{4},
+				CustomThreshold: 0,
+				Enabled:         false,
+			},
+		)
+
 		// Windows IIS (if available)
 		iisPaths := paths.GetDefaultServiceLogPaths("IIS")
 		if len(iisPaths) > 0 {
@@ -231,6 +480,18 @@ func createPlatformServices(paths *utils.PlatformPaths) []ServiceConfig {
 			})
 		}
 
+		// FileZilla Server (if available)
+		fileZillaPaths := paths.GetDefaultServiceLogPaths("FileZilla")
+		if len(fileZillaPaths) > 0 {
+			services = append(services, ServiceConfig{
+				Name:            "FileZilla",
+				LogPath:         fileZillaPaths[0],
+				LogPattern:      "filezilla",
+				CustomThreshold: 5,
+				Enabled:         false,
+			})
+		}
+
 	case "linux", "darwin":
 		// Apache service
 		apachePaths := paths.GetDefaultServiceLogPaths("Apache")
@@ -255,6 +516,34 @@ func createPlatformServices(paths *utils.PlatformPaths) []ServiceConfig {
 				Enabled:         false,
 			})
 		}
+
+		// FTP servers
+		for _, ftpService := range []string{"vsftpd", "ProFTPD", "Pure-FTPd"} {
+			ftpPaths := paths.GetDefaultServiceLogPaths(ftpService)
+			if len(ftpPaths) > 0 {
+				services = append(services, ServiceConfig{
+					Name:            ftpService,
+					LogPath:         ftpPaths[0],
+					LogPattern:      strings.ToLower(ftpService),
+					CustomThreshold: 5,
+					Enabled:         false,
+				})
+			}
+		}
+
+		// Mail services (both log to the system mail log)
+		for _, mailService := range []string{"Postfix", "Dovecot"} {
+			mailPaths := paths.GetDefaultServiceLogPaths(mailService)
+			if len(mailPaths) > 0 {
+				services = append(services, ServiceConfig{
+					Name:            mailService,
+					LogPath:         mailPaths[0],
+					LogPattern:      strings.ToLower(mailService),
+					CustomThreshold: 5,
+					Enabled:         false,
+				})
+			}
+		}
 	}
 
 	return services
@@ -1,8 +1,9 @@
 package models
 
 import (
-	"net"
 	"time"
+
+	"github.com/sr-tamim/guardian/pkg/utils"
 )
 
 // AttackAttempt represents a detected intrusion attempt
@@ -16,16 +17,23 @@ type AttackAttempt struct {
 	Severity  Severity  `json:"severity" db:"severity"`
 	Source    string    `json:"source" db:"source"` // log file path
 	Blocked   bool      `json:"blocked" db:"blocked"`
+
+	// Failure detail from the log, when the source records it
+	FailureCode string `json:"failure_code,omitempty" db:"failure_code"` // NTSTATUS (0xC000006A) or Kerberos (0x18) code
+	LogonType   int    `json:"logon_type,omitempty" db:"logon_type"`     // Windows logon type (3 network, 10 remote interactive)
 }
 
 // BlockRecord represents an IP that has been blocked
 type BlockRecord struct {
 	ID          int64      `json:"id" db:"id"`
-	IP          string     `json:"ip" db:"ip"`
+	IP          string     `json:"ip" db:"ip"`               // canonical address or aggregated CIDR network
+	Family      string     `json:"family" db:"family"`       // "ipv4" or "ipv6"
+	RuleName    string     `json:"rule_name" db:"rule_name"` // exact firewall rule holding the block; used for removal
 	BlockedAt   time.Time  `json:"blocked_at" db:"blocked_at"`
 	ExpiresAt   *time.Time `json:"expires_at" db:"expires_at"`
 	Reason      string     `json:"reason" db:"reason"`
 	Service     string     `json:"service" db:"service"`
+	Severity    Severity   `json:"severity" db:"severity"`
 	AttackCount int        `json:"attack_count" db:"attack_count"`
 	IsActive    bool       `json:"is_active" db:"is_active"`
 	UnblockedAt *time.Time `json:"unblocked_at" db:"unblocked_at"`
@@ -33,11 +41,68 @@ type BlockRecord struct {
 
 // ServiceConfig represents configuration for a monitored service
 type ServiceConfig struct {
-	Name            string `yaml:"name" json:"name"`
-	LogPath         string `yaml:"log_path" json:"log_path"`
-	LogPattern      string `yaml:"log_pattern" json:"log_pattern"`
-	CustomThreshold int    `yaml:"custom_threshold" json:"custom_threshold"`
-	Enabled         bool   `yaml:"enabled" json:"enabled"`
+	Name               string   `yaml:"name" json:"name"`
+	LogPath            string   `yaml:"log_path" json:"log_path"`
+	LogPattern         string   `yaml:"log_pattern" json:"log_pattern"`
+	LogFormat          string   `yaml:"log_format" json:"log_format"`                   // Access log format for web services: preset name or LogFormat/log_format string
+	EventIDs           []int    `yaml:"event_ids" json:"event_ids"`                     // Windows event IDs to monitor in the log_path channel
+	JournalUnits       []string `yaml:"journal_units" json:"journal_units"`             // systemd units to read when log_path is "journal"
+	JournalIdentifiers []string `yaml:"journal_identifiers" json:"journal_identifiers"` // SYSLOG_IDENTIFIER values to read when log_path is "journal"
+	SyslogAppNames     []string `yaml:"syslog_app_names" json:"syslog_app_names"`       // APP-NAME/TAG patterns routed here when log_path is "syslog"
+	SyslogHosts        []string `yaml:"syslog_hosts" json:"syslog_hosts"`               // HOSTNAME patterns or sender CIDRs routed here when log_path is "syslog"
+	DockerContainers   []string `yaml:"docker_containers" json:"docker_containers"`     // Container name patterns followed when log_path is "docker"
+	DockerLabels       []string `yaml:"docker_labels" json:"docker_labels"`             // Container labels ("key" or "key=value") followed when log_path is "docker"
+	CustomThreshold    int      `yaml:"custom_threshold" json:"custom_threshold"`
+	Mode               string   `yaml:"mode" json:"mode"` // enforce, observe or paused; overrides the global mode
+	Enabled            bool     `yaml:"enabled" json:"enabled"`
+
+	Detection DetectionConfig `yaml:"detection" json:"detection"` // Overrides the global detection settings (zero fields inherit)
+}
+
+// Alert kinds raised by the cross-username detection rules
+const (
+	AlertPasswordSpraying   = "password_spraying"   // one address, many usernames: the address is blocked
+	AlertCredentialStuffing = "credential_stuffing" // one username, many addresses: the account needs protection
+)
+
+// AlertWouldBlock records a block that observe mode decided on but did not carry out
+const AlertWouldBlock = "would_block"
+
+// Engine events handed to notifiers as alerts of these kinds; unlike detections they are not stored
+const (
+	EventAttackDetected = "attack_detected" // a failed attempt was parsed and scored
+	EventThresholdNear  = "threshold_near"  // one more failure blocks the address
+	EventIPBlocked      = "ip_blocked"
+	EventIPUnblocked    = "ip_unblocked"
+	EventFirewallError  = "firewall_error" // a firewall rule could not be written or removed
+)
+
+// Alert is a detection that needs attention beyond a single blocked address
+type Alert struct {
+	ID        int64         `json:"id" db:"id"`
+	Timestamp time.Time     `json:"timestamp" db:"timestamp"`
+	Kind      string        `json:"kind" db:"kind"`
+	Service   string        `json:"service" db:"service"`
+	IP        string        `json:"ip,omitempty" db:"ip"`             // spraying source
+	Username  string        `json:"username,omitempty" db:"username"` // stuffing target
+	Count     int           `json:"count" db:"count"`                 // distinct usernames (spraying) or addresses (stuffing)
+	Window    time.Duration `json:"window" db:"window"`
+	Samples   []string      `json:"samples,omitempty" db:"-"` // a few of the usernames or addresses involved
+	Severity  Severity      `json:"severity" db:"severity"`
+	Message   string        `json:"message" db:"message"`
+}
+
+// TailCheckpoint is how far a log file has been read, so a restart resumes where it stopped
+// Device and Inode identify the file (volume serial and file index on Windows); the fingerprint
+// hashes its first FingerprintSize bytes to catch a recycled inode
+type TailCheckpoint struct {
+	Path            string    `json:"path" db:"path"`
+	Device          uint64    `json:"device" db:"device"`
+	Inode           uint64    `json:"inode" db:"inode"`
+	Offset          int64     `json:"offset" db:"offset"`
+	Fingerprint     string    `json:"fingerprint" db:"fingerprint"`
+	FingerprintSize int       `json:"fingerprint_size" db:"fingerprint_size"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // Statistics holds monitoring and blocking statistics
@@ -47,6 +112,7 @@ type Statistics struct {
 	ActiveBlocks      int64     `json:"active_blocks"`
 	ServicesMonitored int       `json:"services_monitored"`
 	UptimeSeconds     int64     `json:"uptime_seconds"`
+	Evictions         int64     `json:"evictions"` // blocks removed to respect max_concurrent_blocks
 	LastActivity      time.Time `json:"last_activity"`
 }
 
@@ -75,9 +141,9 @@ func (s Severity) String() string {
 	}
 }
 
-// IsValidIP checks if the given string is a valid IP address
+// IsValidIP checks if the given string is a valid IPv4 or IPv6 address
 func (a *AttackAttempt) IsValidIP() bool {
-	return net.ParseIP(a.IP) != nil
+	return utils.ParseIP(a.IP) != nil
 }
 
 // IsExpired checks if a block record has expired
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Address family names used in block records and firewall rules
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// ParseIP parses an IPv4 or IPv6 address as it appears in logs
+// Surrounding brackets ("[2001:db8::1]") and zone identifiers ("fe80::1%eth0")
+// are stripped, and IPv4-mapped IPv6 addresses are returned as 4-byte IPv4
+func ParseIP(raw string) net.IP {
+	candidate := strings.TrimSpace(raw)
+	candidate = strings.TrimPrefix(candidate, "[")
+	candidate = strings.TrimSuffix(candidate, "]")
+	if idx := strings.Index(candidate, "%"); idx >= 0 {
+		candidate = candidate[:idx]
+	}
+
+	ip := net.ParseIP(candidate)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+// NormalizeIP returns the canonical string form of an address
+// "::FFFF:203.0.113.5" becomes "203.0.113.5" and "2001:DB8:0::1" becomes "2001:db8::1"
+func NormalizeIP(raw string) (string, error) {
+	ip := ParseIP(raw)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %q", raw)
+	}
+	return ip.String(), nil
+}
+
+// IPFamily returns FamilyIPv4 or FamilyIPv6 for a parsed address
+func IPFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// NormalizeBlockTarget canonicalizes a single address or a CIDR network
+// Host-sized networks (/32 for IPv4, /128 for IPv6) collapse to the bare address,
+// which keeps "203.0.113.5" and "203.0.113.5/32" (as netsh reports it) equal.
+// A /0 network, or an IPv4-mapped network wider than ::ffff:0:0/96, is refused
+func NormalizeBlockTarget(raw string) (string, error) {
+	target := strings.TrimSpace(raw)
+	if !strings.Contains(target, "/") {
+		return NormalizeIP(target)
+	}
+
+	ip, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid network: %q", raw)
+	}
+	ones, bits := network.Mask.Size()
+	if v4 := ip.To4(); v4 != nil && bits == 128 {
+		// IPv4-mapped network such as ::ffff:203.0.113.0/120
+		if ones < 96 {
+			return "", fmt.Errorf("IPv4-mapped network %q must be /96 or narrower", raw)
+		}
+		ones -= 96
+		network = &net.IPNet{IP: v4.Mask(net.CIDRMask(ones, 32)), Mask: net.CIDRMask(ones, 32)}
+		bits = 32
+	}
+	if ones == 0 {
+		return "", fmt.Errorf("network %q covers every address", raw)
+	}
+	if ones == bits {
+		return ParseIP(network.IP.String()).String(), nil
+	}
+	return network.String(), nil
+}
+
+// TargetPrefix returns the prefix length of a target and its family size; a bare address is host-sized
+func TargetPrefix(target string) (ones, bits int) {
+	host, prefix, found := strings.Cut(target, "/")
+	bits = 128
+	if ip := ParseIP(host); ip != nil && ip.To4() != nil {
+		bits = 32
+	}
+	if !found {
+		return bits, bits
+	}
+	if _, err := fmt.Sscanf(prefix, "%d", &ones); err != nil {
+		return 0, bits
+	}
+	return ones, bits
+}
+
+// TargetFamily returns the address family of an address or CIDR network
+func TargetFamily(target string) string {
+	host := target
+	if idx := strings.Index(host, "/"); idx >= 0 {
+		host = host[:idx]
+	}
+	if ip := ParseIP(host); ip != nil {
+		return IPFamily(ip)
+	}
+	return ""
+}
+
+// TargetContains reports whether an address falls inside an address or CIDR target
+func TargetContains(target, raw string) bool {
+	ip := ParseIP(raw)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(target, "/") {
+		other := ParseIP(target)
+		return other != nil && other.Equal(ip)
+	}
+	_, network, err := net.ParseCIDR(target)
+	return err == nil && network.Contains(ip)
+}
+
+// AggregateIP maps an address onto the network used for counting and blocking
+// A prefix of zero or the full family size (32 or 128) keeps the single address,
+// anything shorter returns the enclosing network in CIDR notation
+func AggregateIP(raw string, ipv4Prefix, ipv6Prefix int) (string, error) {
+	ip := ParseIP(raw)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %q", raw)
+	}
+
+	prefix, bits := ipv6Prefix, 128
+	if IPFamily(ip) == FamilyIPv4 {
+		prefix, bits = ipv4Prefix, 32
+	}
+	if prefix <= 0 || prefix >= bits {
+		return ip.String(), nil
+	}
+
+	mask := net.CIDRMask(prefix, bits)
+	network := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return network.String(), nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import "testing"
+
+func TestNormalizeBlockTarget(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "203.0.113.5", want: "203.0.113.5"},
+		{raw: "203.0.113.5/32", want: "203.0.113.5"},
+		{raw: "203.0.113.77/24", want: "203.0.113.0/24"},
+		{raw: "2001:DB8:0::1", want: "2001:db8::1"},
+		{raw: "2001:db8::1/128", want: "2001:db8::1"},
+		{raw: "::ffff:203.0.113.5", want: "203.0.113.5"},
+		{raw: "::ffff:203.0.113.0/120", want: "203.0.113.0/24"},
+		{raw: "::ffff:1.2.3.4/64", wantErr: true},
+		{raw: "::ffff:0:0/95", wantErr: true},
+		{raw: "0.0.0.0/0", wantErr: true},
+		{raw: "::/0", wantErr: true},
+		{raw: "not-an-ip", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeBlockTarget(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NormalizeBlockTarget(%q) = %q, want an error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeBlockTarget(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestTargetPrefix(t *testing.T) {
+	tests := []struct {
+		target     string
+		ones, bits int
+	}{
+		{"203.0.113.5", 32, 32},
+		{"203.0.113.0/24", 24, 32},
+		{"2001:db8::/48", 48, 128},
+		{"2001:db8::1", 128, 128},
+	}
+	for _, tt := range tests {
+		if ones, bits := TargetPrefix(tt.target); ones != tt.ones || bits != tt.bits {
+			t.Errorf("TargetPrefix(%q) = /%d of %d, want /%d of %d", tt.target, ones, bits, tt.ones, tt.bits)
+		}
+	}
+}
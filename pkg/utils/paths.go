@@ -126,6 +126,20 @@ func (p *PlatformPaths) getWindowsServiceLogPaths(service string) []string {
 			`C:\Apache24\logs\access.log`,
 			`C:\xampp\apache\logs\access.log`,
 		}
+	case "MSSQL", "mssql":
+		return []string{
+			`C:\Program Files\Microsoft SQL Server\MSSQL16.MSSQLSERVER\MSSQL\Log\ERRORLOG`, // SQL Server 2022
+			`C:\Program Files\Microsoft SQL Server\MSSQL15.MSSQLSERVER\MSSQL\Log\ERRORLOG`, // SQL Server 2019
+		}
+	case "MySQL", "mysql":
+		return []string{`C:\ProgramData\MySQL\MySQL Server 8.0\Data\*.err`}
+	case "PostgreSQL", "postgresql":
+		return []string{`C:\Program Files\PostgreSQL\16\data\log\*.log`}
+	case "FileZilla", "filezilla":
+		return []string{
+			`C:\ProgramData\filezilla-server\Logs\filezilla-server.log`, // FileZilla Server 1.x
+			`C:\Program Files (x86)\FileZilla Server\Logs\*.log`,        // FileZilla Server 0.9.x
+		}
 	default:
 		return []string{filepath.Join(p.GetDefaultLogDir(), service+"_test.log")}
 	}
@@ -150,6 +164,33 @@ func (p *PlatformPaths) getLinuxServiceLogPaths(service string) []string {
 			"/var/log/nginx/access.log",
 			"/var/log/nginx/error.log",
 		}
+	case "Postfix", "postfix", "Dovecot", "dovecot":
+		return []string{
+			"/var/log/mail.log", // Debian/Ubuntu
+			"/var/log/maillog",  // RHEL/CentOS
+		}
+	case "PostgreSQL", "postgresql":
+		return []string{
+			"/var/log/postgresql/*.log",     // Debian/Ubuntu
+			"/var/lib/pgsql/data/log/*.log", // RHEL/CentOS
+		}
+	case "MySQL", "mysql":
+		return []string{
+			"/var/log/mysql/error.log",     // Debian/Ubuntu
+			"/var/log/mysqld.log",          // RHEL/CentOS
+			"/var/log/mariadb/mariadb.log", // MariaDB on RHEL
+		}
+	case "MSSQL", "mssql":
+		return []string{"/var/opt/mssql/log/errorlog"}
+	case "vsftpd":
+		return []string{"/var/log/vsftpd.log"}
+	case "ProFTPD", "proftpd":
+		return []string{"/var/log/proftpd/proftpd.log"}
+	case "Pure-FTPd", "pure-ftpd":
+		return []string{
+			"/var/log/syslog",   // Debian/Ubuntu (ftp facility)
+			"/var/log/messages", // RHEL/CentOS
+		}
 	default:
 		return []string{filepath.Join("/tmp", service+"_test.log")}
 	}
@@ -173,6 +214,8 @@ func (p *PlatformPaths) getDarwinServiceLogPaths(service string) []string {
 			"/var/log/nginx/access.log",
 			"/usr/local/var/log/nginx/access.log", // Homebrew
 		}
+	case "Postfix", "postfix", "Dovecot", "dovecot":
+		return []string{"/var/log/mail.log"}
 	default:
 		return []string{filepath.Join("/tmp", service+"_test.log")}
 	}
@@ -188,9 +231,9 @@ func (p *PlatformPaths) GetDefaultGuardianLogPath() string {
 	return filepath.Join(p.GetDefaultLogDir(), "guardian.log")
 }
 
-// GetDefaultGuardianDatabasePath returns the default Guardian database path
+// GetDefaultGuardianDatabasePath returns the default Guardian storage file
 func (p *PlatformPaths) GetDefaultGuardianDatabasePath() string {
-	return filepath.Join(p.GetDefaultDataDir(), "guardian.db")
+	return filepath.Join(p.GetDefaultDataDir(), "guardian.json")
 }
 
 // EnsureDir creates a directory if it doesn't exist